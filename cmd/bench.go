@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/bench"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const benchDesc = `
+Benchmark a Proxy-Wasm extension in an embedded Proxy-Wasm host (as in 'pwo run').
+
+A workload of requests is sent to the extension for some iterations (after
+some warmup requests), reporting the latency percentiles of the requests and
+of every callback, the growth of the memory of the extension and the
+allocations made by the host in it (for passing headers, bodies...).
+
+By default, the workload is a single generated POST request with some headers
+and a JSON body, that can be changed with --headers and --body-size:
+
+  $ pwo bench main.wasm -n 10000 --body-size 4096 --config '{"requiredKeys": ["id"]}'
+
+The workload can also be read from a YAML file with a list of requests (and
+responses), recorded or written by hand:
+
+  - request:
+      method: POST
+      headers:
+        content-type: application/json
+      body: '{"id": 1}'
+    response:
+      status: 200
+
+  $ pwo bench oci://myregistry.com/myrepo:1.0.0 --workload workload.yaml
+
+With '--output json', the report is written as JSON, so it can be saved and
+used as the baseline for the next versions. With --baseline (a JSON report, or
+an extension benchmarked with the same workload), the latencies, the memory
+and the allocations are compared, and the command fails when any of them is
+worse than in the baseline by more than --max-regression percent:
+
+  $ pwo bench main.wasm -o json --baseline oci://myregistry.com/myrepo:1.0.0 > report.json
+`
+
+func newBenchCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("bench")
+	o := runOptions{}
+	iterations := bench.DefaultIterations
+	warmup := bench.DefaultWarmup
+	workloadFile := ""
+	numHeaders := 10
+	bodySize := 1024
+	output := "text"
+	outputFile := ""
+	baseline := ""
+	maxRegression := bench.DefaultMaxRegression
+
+	cmd := &cobra.Command{
+		Use:   "bench [wasm|remote]",
+		Short: "benchmark the latency and memory of a Proxy-Wasm extension",
+		Long:  benchDesc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q", output)
+			}
+
+			workload := bench.GenerateWorkload(numHeaders, bodySize)
+			if workloadFile != "" {
+				var err error
+				if workload, err = bench.LoadWorkload(workloadFile); err != nil {
+					return err
+				}
+			}
+
+			hostOpts, err := o.hostOptions()
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			run := func(ref string) (*bench.Report, error) {
+				log.Sugar().Infof("Loading %s", ref)
+				data, err := loadWasm(ref, cfg, o.CommonPullOptions, o.cacheDir)
+				if err != nil {
+					return nil, err
+				}
+				log.Sugar().Infof("Benchmarking %s (%d iterations)", ref, iterations)
+				report, err := bench.Run(ctx, data, workload,
+					bench.WithIterations(iterations),
+					bench.WithWarmup(warmup),
+					bench.WithHostOptions(hostOpts...))
+				if err != nil {
+					return nil, fmt.Errorf("when benchmarking %s: %w", ref, err)
+				}
+				report.Module = ref
+				return report, nil
+			}
+
+			report, err := run(args[0])
+			if err != nil {
+				return err
+			}
+
+			var base *bench.Report
+			if baseline != "" {
+				if strings.HasSuffix(baseline, ".json") && utils.IsFileExists(baseline) {
+					base, err = bench.LoadReport(baseline)
+				} else {
+					base, err = run(baseline)
+				}
+				if err != nil {
+					return fmt.Errorf("when obtaining the baseline: %w", err)
+				}
+				report.Baseline = baseline
+				report.Regressions = bench.Compare(base, report, maxRegression)
+			}
+
+			w := out
+			if outputFile != "" {
+				f, err := os.Create(outputFile)
+				if err != nil {
+					return err
+				}
+				defer f.Close()
+				w = f
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(w)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(report); err != nil {
+					return err
+				}
+			} else {
+				printBenchReport(w, report, base)
+			}
+
+			if n := len(report.Regressions); n > 0 {
+				lines := make([]string, 0, n)
+				for _, r := range report.Regressions {
+					lines = append(lines, "  "+r.String())
+				}
+				return fmt.Errorf("%d %s worse than in %s by more than %.1f%%:\n%s",
+					n, pluralize("metric", n), baseline, maxRegression,
+					strings.Join(lines, "\n"))
+			}
+			return nil
+		},
+	}
+
+	addRunFlags(cmd, &o)
+	f := cmd.Flags()
+	f.IntVarP(&iterations, "iterations", "n", iterations, "number of requests measured")
+	f.IntVar(&warmup, "warmup", warmup, "number of requests sent before measuring")
+	f.StringVar(&workloadFile, "workload", "", "YAML file with the requests (and responses) sent to the extension")
+	f.IntVar(&numHeaders, "headers", numHeaders, "number of extra headers in the generated request")
+	f.IntVar(&bodySize, "body-size", bodySize, "size of the body of the generated request and response")
+	f.StringVarP(&output, "output", "o", output, "output format (text or json)")
+	f.StringVar(&outputFile, "output-file", "", "file where the report is written (instead of the standard output)")
+	f.StringVar(&baseline, "baseline", "", "JSON report or extension to compare with")
+	f.Float64Var(&maxRegression, "max-regression", maxRegression, "maximum increase (in percent) of any metric compared with the baseline")
+
+	return cmd
+}
+
+// printBenchReport prints a benchmark report as a table, with the baseline (if any)
+func printBenchReport(out io.Writer, r *bench.Report, base *bench.Report) {
+	fmt.Fprintf(out, "Module:      %s (%s)\n", r.Module, r.Digest)
+	fmt.Fprintf(out, "Iterations:  %d (%d local responses)\n", r.Iterations, r.LocalResponses)
+	if base != nil {
+		fmt.Fprintf(out, "Baseline:    %s (%s)\n", base.Module, base.Digest)
+	}
+	fmt.Fprintln(out)
+
+	fmt.Fprintf(out, "%-30s %8s %10s %10s %10s %10s %10s\n", "", "calls", "mean", "p50", "p90", "p99", "max")
+	printStats := func(name string, s bench.Stats) {
+		fmt.Fprintf(out, "%-30s %8d %10s %10s %10s %10s %10s\n", name, s.Count,
+			round(s.Mean), round(s.P50), round(s.P90), round(s.P99), round(s.Max))
+	}
+	printStats("request", r.Request)
+	if base != nil {
+		printStats("  (baseline)", base.Request)
+	}
+
+	names := make([]string, 0, len(r.Callbacks))
+	for name := range r.Callbacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		printStats(name, r.Callbacks[name])
+		if base != nil {
+			if s, ok := base.Callbacks[name]; ok {
+				printStats("  (baseline)", s)
+			}
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "Memory:      %d -> %d bytes (+%d)\n", r.Memory.Initial, r.Memory.Final, r.Memory.Growth)
+	fmt.Fprintf(out, "Allocations: %d (%d bytes, %.2f per request)\n", r.Allocations.Count, r.Allocations.Bytes, r.Allocations.PerRequest)
+	if base != nil {
+		fmt.Fprintf(out, "  (baseline) %d -> %d bytes (+%d), %.2f allocations per request\n",
+			base.Memory.Initial, base.Memory.Final, base.Memory.Growth, base.Allocations.PerRequest)
+	}
+}
+
+// round rounds a duration for showing it
+func round(d time.Duration) time.Duration {
+	if d > time.Millisecond {
+		return d.Round(time.Microsecond)
+	}
+	return d.Round(10 * time.Nanosecond)
+}
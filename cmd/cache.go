@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const cacheDesc = `
+Manage the local cache of Proxy-Wasm extensions used by "pwo download".
+
+Example:
+
+  $ pwo cache list
+  $ pwo cache purge --ref oci://myregistry.com/myrepo
+  $ pwo cache prefetch oci://myregistry.com/myrepo:1.0.0 oci://myregistry.com/other
+`
+
+func newCacheCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	cacheDir := ""
+
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "manage the local cache of Proxy-Wasm extensions",
+		Long:  cacheDesc,
+	}
+
+	f := cmd.PersistentFlags()
+	f.StringVar(&cacheDir, "cache-dir", cache.DefaultPath(), "directory where downloaded extensions are cached")
+
+	getCache := func() *cache.Cache { return cache.New(cacheDir) }
+
+	cmd.AddCommand(newCacheListCmd(getCache, out))
+	cmd.AddCommand(newCachePurgeCmd(getCache, out))
+	cmd.AddCommand(newCacheFetchCmd(cfg, l, getCache, out, false))
+	cmd.AddCommand(newCacheFetchCmd(cfg, l, getCache, out, true))
+
+	return cmd
+}
+
+func newCacheListCmd(getCache func() *cache.Cache, out io.Writer) *cobra.Command {
+	return &cobra.Command{
+		Use:     "list",
+		Short:   "list the cached extensions",
+		Aliases: []string{"ls"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := getCache().List()
+			if err != nil {
+				return err
+			}
+			printCacheEntries(out, entries)
+			return nil
+		},
+	}
+}
+
+func newCachePurgeCmd(getCache func() *cache.Cache, out io.Writer) *cobra.Command {
+	ref := ""
+	digest := ""
+	all := false
+
+	cmd := &cobra.Command{
+		Use:     "purge",
+		Short:   "remove extensions from the cache, by reference or digest",
+		Aliases: []string{"rm"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if ref == "" && digest == "" && !all {
+				return fmt.Errorf("one of --ref, --digest or --all must be provided")
+			}
+
+			removed, err := getCache().Purge(ref, digest)
+			if err != nil {
+				return err
+			}
+			printCacheEntries(out, removed)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVar(&ref, "ref", "", "remove the extensions downloaded from this reference (all tags if no tag is provided)")
+	f.StringVar(&digest, "digest", "", "remove the extensions with this digest")
+	f.BoolVar(&all, "all", false, "remove all the extensions")
+
+	return cmd
+}
+
+// newCacheFetchCmd creates the "prefetch" command, or the "refresh" command when
+// floating references must be resolved again.
+func newCacheFetchCmd(cfg *registry.Configuration, l *zap.Logger, getCache func() *cache.Cache, out io.Writer, refresh bool) *cobra.Command {
+	log := l.Named("cache")
+	r := downloader.CommonPullOptions{}
+
+	use, short := "prefetch [remote...]", "download a list of extensions into the cache"
+	if refresh {
+		use, short = "refresh [remote...]", "resolve again floating references, downloading the extensions into the cache"
+	}
+
+	cmd := &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registryClient, err := registry.NewClientWithParams(r.RegistryParams, settings.RegistryConfigFilename, settings.Debug)
+			if err != nil {
+				return fmt.Errorf("when creating registry client: %w", err)
+			}
+
+			var entries []cache.Entry
+			for _, ref := range args {
+				if !downloader.All(settings).SupportsRef(ref) {
+					return fmt.Errorf("invalid reference: %s", ref)
+				}
+
+				version, err := getVersionFromRef(ref)
+				if err != nil {
+					return err
+				}
+
+				puller := downloader.NewPull(settings, cfg,
+					registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
+					registry.WithInsecure(r.Insecure),
+					registry.WithPlainHTTP(r.PlainHTTP),
+					registry.WithMaxAttempts(r.MaxAttempts),
+					downloader.WithVersion(version),
+					downloader.WithCache(getCache()),
+				)
+				puller.SetRegistryClient(registryClient)
+
+				log.Sugar().Infof("Fetching %s", ref)
+				fetch := puller.Fetch
+				if refresh {
+					fetch = puller.Refresh
+				}
+				entry, err := fetch(ref)
+				if err != nil {
+					return err
+				}
+				entries = append(entries, *entry)
+			}
+
+			printCacheEntries(out, entries)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r.RegistryParams)
+
+	return cmd
+}
+
+func printCacheEntries(out io.Writer, entries []cache.Entry) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "REF\tDIGEST\tSIZE\tLAST ACCESS")
+	for _, e := range entries {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", e.Ref, e.Digest, e.Size, e.LastAccess.Format(time.RFC3339))
+	}
+	w.Flush()
+}
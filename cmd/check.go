@@ -0,0 +1,192 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+const checkDesc = `
+Check that a Proxy-Wasm extension is compatible with some proxy releases.
+
+Extensions built with newer SDKs may declare ABI versions or import host
+functions (like proxy_get_log_level) that older proxies do not have, and the
+extensions would fail to load (or the calls would fail) in them. The imports of
+the extension are compared with a table of the ABI versions, host functions and
+foreign functions supported by every known release of Envoy and Istio:
+
+  $ pwo check main.wasm --host envoy@1.26 --host istio@1.14
+  $ pwo check oci://myregistry.com/myrepo:1.0.0 --host envoy@1.20
+
+Releases that are not in the table (like envoy@1.27) have the support of the
+previous known release. The oldest compatible release of every known proxy is
+always shown. The foreign functions called with proxy_call_foreign_function
+(like "clear_route_cache") are guessed from the strings in the extension, so
+missing ones are reported as warnings.
+
+The table can be extended (or corrected) with --hosts-file, a YAML file with a
+list of releases:
+
+  - name: envoy
+    version: "1.31"
+    abiVersions: ["0.1.0", "0.2.0", "0.2.1"]
+    hostFunctions: [proxy_log, proxy_get_log_level, ...]
+    foreignFunctions: [clear_route_cache, ...]
+  - name: mycompany-gateway
+    version: "2.0"
+    basedOn: envoy@1.30
+
+The command fails when the extension is not compatible with any of the hosts.
+`
+
+// checkResult is the result of checking an extension against some hosts, for the JSON output
+type checkResult struct {
+	Module          string            `json:"module"`
+	ABIVersion      wasm.ABIVersion   `json:"abiVersion"`
+	MinHostVersions map[string]string `json:"minHostVersions"`
+	Hosts           []hostCheckResult `json:"hosts,omitempty"`
+}
+
+type hostCheckResult struct {
+	*wasm.Compatibility
+	Compatible bool `json:"compatible"`
+}
+
+func newCheckCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("check")
+	r := downloader.CommonPullOptions{}
+	cacheDir := ""
+	hostSpecs := []string{}
+	hostsFile := ""
+	output := "text"
+
+	cmd := &cobra.Command{
+		Use:   "check [wasm|remote]",
+		Short: "check the compatibility of a Proxy-Wasm extension with some proxy releases",
+		Long:  checkDesc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref := args[0]
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q", output)
+			}
+
+			hosts := wasm.KnownHosts
+			if hostsFile != "" {
+				var err error
+				if hosts, err = wasm.LoadHosts(hostsFile); err != nil {
+					return err
+				}
+			}
+			var checked []*wasm.Host
+			for _, spec := range hostSpecs {
+				h, err := hosts.Find(spec)
+				if err != nil {
+					return err
+				}
+				checked = append(checked, h)
+			}
+
+			log.Sugar().Infof("Loading %s", ref)
+			data, err := loadWasm(ref, cfg, r, cacheDir)
+			if err != nil {
+				return err
+			}
+			m, _, err := wasm.ValidateProxyWasm(data)
+			if err != nil {
+				return err
+			}
+			// the foreign functions must be guessed from the ones of the hosts checked
+			pw, err := hosts.ProxyWasm(m)
+			if err != nil {
+				return err
+			}
+
+			res := checkResult{
+				Module:          ref,
+				ABIVersion:      pw.ABIVersion,
+				MinHostVersions: hosts.Minimum(pw),
+			}
+			var incompatible []string
+			for _, h := range checked {
+				c := h.Check(pw)
+				res.Hosts = append(res.Hosts, hostCheckResult{Compatibility: c, Compatible: c.Compatible()})
+				if !c.Compatible() {
+					incompatible = append(incompatible, c.Host)
+				}
+			}
+
+			if output == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(res); err != nil {
+					return err
+				}
+			} else {
+				printCheckResult(out, &res)
+			}
+
+			if len(incompatible) > 0 {
+				return fmt.Errorf("%s not compatible with %s", ref, strings.Join(incompatible, ", "))
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	downloader.AddDownloadFlags(f, &r)
+	registry.AddRegistryParamsFlags(f, &r.RegistryParams)
+	f.StringVar(&cacheDir, "cache-dir", cache.DefaultPath(), "directory where downloaded extensions are cached (no cache if empty)")
+	f.StringArrayVar(&hostSpecs, "host", nil, "proxy release checked, like envoy@1.26 (can be repeated)")
+	f.StringVar(&hostsFile, "hosts-file", "", "YAML file with more proxy releases (or replacing the known ones)")
+	f.StringVarP(&output, "output", "o", output, "output format (text or json)")
+
+	return cmd
+}
+
+// printCheckResult prints the minimum versions of the hosts, and the problems found with every host checked
+func printCheckResult(out io.Writer, res *checkResult) {
+	fmt.Fprintf(out, "Module:   %s (Proxy-Wasm ABI %s)\n", res.Module, res.ABIVersion)
+
+	names := make([]string, 0, len(res.MinHostVersions))
+	for name := range res.MinHostVersions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	minimum := make([]string, 0, len(names))
+	for _, name := range names {
+		minimum = append(minimum, name+"@"+res.MinHostVersions[name])
+	}
+	if len(minimum) == 0 {
+		minimum = append(minimum, "no compatible known host")
+	}
+	fmt.Fprintf(out, "Minimum:  %s\n", strings.Join(minimum, ", "))
+
+	for _, h := range res.Hosts {
+		status := "compatible"
+		if !h.Compatible {
+			status = "INCOMPATIBLE"
+		}
+		fmt.Fprintf(out, "\n%-20s %s\n", h.Host, status)
+		if h.UnsupportedABIVersion != "" {
+			fmt.Fprintf(out, "  unsupported ABI version: %s\n", h.UnsupportedABIVersion)
+		}
+		if len(h.MissingHostFunctions) > 0 {
+			fmt.Fprintf(out, "  missing host functions: %s\n", strings.Join(h.MissingHostFunctions, ", "))
+		}
+		if len(h.MissingForeignFunctions) > 0 {
+			fmt.Fprintf(out, "  warning: foreign functions that may be called but are missing: %s\n",
+				strings.Join(h.MissingForeignFunctions, ", "))
+		}
+	}
+}
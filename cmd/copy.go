@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	reg "oras.land/oras-go/pkg/registry"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const copyDesc = `
+Copy Proxy-Wasm extensions from an OCI registry to another one (or to another
+repository in the same registry).
+
+The manifest, the config, the layers and the attached signatures are copied
+byte for byte, so the digest, the annotations and the creation time of the
+extension are preserved. Blobs are mounted from the source repository when
+both repositories are in the same registry.
+
+When the destination does not have a tag, the tag of the source is used.
+With --version, all the tags matching a semver constraint are copied.
+
+Example:
+
+  $ pwo copy oci://staging.com/myrepo:1.0.0 oci://prod.com/myrepo
+  $ pwo copy --version "~1.2" oci://staging.com/myrepo oci://prod.com/myrepo
+`
+
+func newCopyCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("copy")
+	r := registry.RegistryParams{}
+	version := ""
+	skipSignatures := false
+
+	cmd := &cobra.Command{
+		Use:     "copy [src] [dst]",
+		Short:   "copy Proxy-Wasm extensions between OCI registries",
+		Aliases: []string{"cp", "mirror"},
+		Long:    copyDesc,
+		Args:    cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, dst := args[0], args[1]
+			for _, ref := range []string{src, dst} {
+				if !registry.IsOCI(ref) {
+					return fmt.Errorf("invalid OCI reference: %s", ref)
+				}
+			}
+			src = strings.TrimPrefix(src, fmt.Sprintf("%s://", registry.OCIScheme))
+			dst = strings.TrimPrefix(dst, fmt.Sprintf("%s://", registry.OCIScheme))
+
+			registryClient, err := registry.NewClientWithParams(r, settings.RegistryConfigFilename, settings.Debug,
+				registry.ClientOptWriter(out))
+			if err != nil {
+				return fmt.Errorf("when creating registry client: %w", err)
+			}
+
+			copyOpts := []registry.CopyOption{registry.CopyOptSkipSignatures(skipSignatures)}
+
+			if version == "" {
+				log.Sugar().Infof("Copying %s to %s", src, dst)
+				_, err := registryClient.Copy(src, dst, copyOpts...)
+				return err
+			}
+
+			srcRef, err := reg.ParseReference(src)
+			if err != nil {
+				return err
+			}
+			dstRef, err := reg.ParseReference(dst)
+			if err != nil {
+				return err
+			}
+			if srcRef.Reference != "" || dstRef.Reference != "" {
+				return fmt.Errorf("references must not have a tag when using --version")
+			}
+
+			allTags, err := registryClient.Tags(src)
+			if err != nil {
+				return fmt.Errorf("when listing tags in %s: %w", src, err)
+			}
+			tags, err := registry.GetTagsMatchingConstraint(allTags, version)
+			if err != nil {
+				return fmt.Errorf("invalid version constraint %q: %w", version, err)
+			}
+			if len(tags) == 0 {
+				return fmt.Errorf("no tags in %s matching %q", src, version)
+			}
+
+			for _, tag := range tags {
+				log.Sugar().Infof("Copying %s:%s to %s:%s", src, tag, dst, tag)
+				if _, err := registryClient.Copy(src+":"+tag, dst+":"+tag, copyOpts...); err != nil {
+					return fmt.Errorf("when copying %s:%s: %w", src, tag, err)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r)
+	f.StringVar(&version, "version", "", "copy all the tags matching a semver constraint (e.g. \"~1.2\")")
+	f.BoolVar(&skipSignatures, "skip-signatures", false, "do not copy the signatures attached to the extensions")
+
+	return cmd
+}
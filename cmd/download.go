@@ -1,76 +1,171 @@
 package cmd
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
 	"strings"
+	"sync"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
-	reg "oras.land/oras-go/pkg/registry"
+	"golang.org/x/sync/errgroup"
 
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
 	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
+	"github.com/inercia/proxy-wasm-oci/pkg/progress"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 	"github.com/inercia/proxy-wasm-oci/pkg/server"
 )
 
 const downloadDesc = `
-Retrieve a Proxy-Wasm extension from an OCI registry and save it locally.
+Retrieve Proxy-Wasm extensions from OCI registries and save them locally.
 
 This is useful for fetching extensions to inspect, modify, or repackage.
 
 Example:
 
   $ pwo download --dest /tmp oci://myregistry.com/myrepo:1.0.0
+
+Extensions can also be read from an OCI image layout directory:
+
+  $ pwo download --dest /tmp oci-layout:///mnt/media/myrepo:1.0.0
+
+or from HTTP(S) and file URLs, verifying their checksum with --sha256. The
+metadata in a Wasm.yaml next to the Wasm module is also downloaded when present:
+
+  $ pwo download --dest /tmp --sha256 4a5e... https://github.com/me/myext/releases/download/v1.0.0/myext.wasm
+
+Many extensions can be downloaded at once, up to --concurrency in parallel,
+with references in the command line and/or in a file (one per line, or '-'
+for reading them from the standard input):
+
+  $ pwo download --dest /tmp --from-file extensions.txt oci://myregistry.com/other:1.0.0
+
+With --sidecars, the metadata (Wasm.yaml), the provenance (the source and resolved
+references, digests, download time and verification result) and the checksum
+are written next to every extension, so the destination directory is
+self-describing and the extensions can be published again unchanged. The names
+of the files can be set with --output-template, a Go template with the fields
+.Name, .Version, .Repository, .Tag and .Digest:
+
+  $ pwo download --dest /tmp --sidecars --output-template '{{.Name}}/{{.Version}}/{{.Name}}.wasm' \
+      oci://myregistry.com/myrepo:1.0.0
+
+With --verify, the extensions are checked to be Proxy-Wasm extensions (Wasm
+binaries that declare a Proxy-Wasm ABI version and export the callbacks needed
+by the proxies) before saving them.
+
+The progress is shown with progress bars when the output is a terminal, or
+as events (one JSON document per line) otherwise.
 `
 
 func newDownloadCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
 	log := l.Named("download")
 	r := downloader.CommonPullOptions{}
 	destDir := ""
+	cacheDir := ""
+	fromFile := ""
+	sidecars := false
+	outputTemplate := ""
+	concurrency := defaultDownloadConcurrency
 
 	cmd := &cobra.Command{
-		Use:     "download [remote]",
-		Short:   "download a Proxy-Wasm extensions from an OCI registry into a local directory",
+		Use:     "download [remote...]",
+		Short:   "download Proxy-Wasm extensions from OCI registries into a local directory",
 		Aliases: []string{"fetch", "pull"},
 		Long:    downloadDesc,
-		Args:    MinimumNArgs(1),
+		Args:    cobra.ArbitraryArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			log.Info("Cretaing new registry client")
-			registryClient, err := registry.NewClientWithParams(r.RegistryParams, settings.RegistryConfigFilename, settings.Debug)
+			refs := args
+			if fromFile != "" {
+				more, err := readRefs(fromFile)
+				if err != nil {
+					return fmt.Errorf("when reading references from %s: %w", fromFile, err)
+				}
+				refs = append(refs, more...)
+			}
+			if len(refs) == 0 {
+				return fmt.Errorf("no references to download")
+			}
+			if r.SHA256 != "" && len(refs) > 1 {
+				return fmt.Errorf("--sha256 can only be used when downloading one extension")
+			}
+
+			for _, ref := range refs {
+				if !downloader.All(settings).SupportsRef(ref) {
+					return fmt.Errorf("invalid reference: %s", ref)
+				}
+			}
+
+			// the progress reporter shows what has been pulled, so the client does not print it
+			clientOut := io.Discard
+			if settings.Debug {
+				clientOut = os.Stderr
+			}
+
+			log.Info("Creating new registry client")
+			registryClient, err := registry.NewClientWithParams(r.RegistryParams, settings.RegistryConfigFilename, settings.Debug,
+				registry.ClientOptWriter(clientOut))
 			if err != nil {
 				return fmt.Errorf("when creating registry client: %w", err)
 			}
 
-			ref := args[0]
-			if !registry.IsOCI(ref) {
-				return fmt.Errorf("invalid OCI reference: %s", ref)
+			var c *cache.Cache
+			if cacheDir != "" {
+				c = cache.New(cacheDir)
 			}
 
-			version, err := getVersionFromRef(ref)
-			if err != nil {
-				return err
+			reporter := progress.New(out)
+
+			var mu sync.Mutex
+			var errs []error
+
+			g := errgroup.Group{}
+			if concurrency > 0 {
+				g.SetLimit(concurrency)
 			}
 
-			puller := downloader.NewPull(settings, cfg,
-				registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
-				registry.WithInsecure(r.Insecure),
-				registry.WithPlainHTTP(r.PlainHTTP),
-				downloader.WithDestDir(destDir),
-				downloader.WithVersion(version),
-			)
-			puller.SetRegistryClient(registryClient)
-
-			log.Sugar().Infof("Downloading %s", ref)
-			output, err := puller.Run(ref)
-			if err != nil {
-				return err
+			for _, ref := range refs {
+				ref := ref
+				g.Go(func() error {
+					reporter.Start(ref)
+					output, err := download(ref, cfg, registryClient, c, reporter,
+						registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
+						registry.WithInsecure(r.Insecure),
+						registry.WithPlainHTTP(r.PlainHTTP),
+						registry.WithMaxAttempts(r.MaxAttempts),
+						downloader.WithDestDir(destDir),
+						downloader.WithSHA256(r.SHA256),
+						downloader.WithVerify(r.Verify),
+						downloader.WithSidecars(sidecars),
+						downloader.WithOutputTemplate(outputTemplate),
+					)
+					reporter.Done(ref, output, err)
+					if err != nil {
+						mu.Lock()
+						errs = append(errs, fmt.Errorf("when downloading %s: %w", ref, err))
+						mu.Unlock()
+					}
+					// errors are collected, so the other downloads are not interrupted
+					return nil
+				})
+			}
+			_ = g.Wait()
+			reporter.Close()
+
+			if len(errs) > 0 {
+				return errors.Join(errs...)
+			}
+
+			if len(refs) == 1 {
+				log.Sugar().Infof("You could GET this from the server at:")
+				log.Sugar().Infof("  %s?%s", server.PathWASMDownload, getRefAsQueryParameters(refs[0]))
 			}
-			log.Sugar().Infof("File downloaded to %s", output)
-			log.Sugar().Infof("You could GET this from the server at:")
-			log.Sugar().Infof("  %s?%s", server.PathWASMDownload, getRefAsQueryParameters(ref))
 
 			return nil
 		},
@@ -80,19 +175,81 @@ func newDownloadCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *
 	downloader.AddDownloadFlags(f, &r)
 	registry.AddRegistryParamsFlags(f, &r.RegistryParams)
 	f.StringVarP(&destDir, "destination", "d", ".", "location to write the extension.")
+	f.StringVar(&cacheDir, "cache-dir", cache.DefaultPath(), "directory where downloaded extensions are cached (no cache if empty)")
+	f.StringVar(&fromFile, "from-file", "", "file with references to download, one per line ('-' for the standard input)")
+	f.IntVar(&concurrency, "concurrency", concurrency, "number of extensions downloaded in parallel")
+	f.BoolVar(&sidecars, "sidecars", false, "also write the metadata (.yaml), provenance (.provenance.json) and checksum (.sha256) next to the extensions")
+	f.StringVar(&outputTemplate, "output-template", "", "template for the names of the extensions, relative to the destination (e.g. '{{.Name}}/{{.Version}}/{{.Name}}.wasm')")
 
 	return cmd
 }
 
+// defaultDownloadConcurrency is the default number of extensions downloaded in parallel
+const defaultDownloadConcurrency = 4
+
+// download downloads an extension with its own puller, reporting the progress
+func download(ref string, cfg *registry.Configuration, client *registry.Client, c *cache.Cache,
+	reporter progress.Reporter, opts ...any,
+) (string, error) {
+	version, err := getVersionFromRef(ref)
+	if err != nil {
+		return "", err
+	}
+
+	opts = append(opts,
+		downloader.WithVersion(version),
+		downloader.WithProgress(func(done, total int64) {
+			reporter.Update(ref, done, total)
+		}),
+	)
+
+	// every puller gets its own copy of the configuration, as they run concurrently
+	pullerCfg := *cfg
+	puller := downloader.NewPull(settings, &pullerCfg, opts...)
+	puller.Cache = c
+	puller.SetRegistryClient(client)
+
+	return puller.Run(ref)
+}
+
+// readRefs reads the references in a file (or in the standard input for "-"),
+// one per line, ignoring empty lines and comments
+func readRefs(filename string) ([]string, error) {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		f, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+
+	var refs []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		refs = append(refs, line)
+	}
+	return refs, scanner.Err()
+}
+
 func getVersionFromRef(ref string) (string, error) {
-	refNoScheme := strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme))
-	parsedReference, err := reg.ParseReference(refNoScheme)
+	if !downloader.IsVersionedRef(ref) {
+		// URLs are not versioned
+		return "", nil
+	}
+
+	tag, err := registry.RefTag(ref)
 	if err != nil {
 		return "", err
 	}
 
-	if _, err = semver.NewVersion(parsedReference.Reference); parsedReference.Reference != "" && err == nil {
-		return parsedReference.Reference, nil
+	if _, err = semver.NewVersion(tag); tag != "" && err == nil {
+		return tag, nil
 	}
 
 	return ">0.0.0-0", nil
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadRefs(t *testing.T) {
+	content := `
+# extensions for the gateway
+myregistry.com/auth:1.0.0
+
+  myregistry.com/ratelimit:2.0.0  
+	# indented comment
+https://example.com/ext.wasm
+`
+	expected := []string{"myregistry.com/auth:1.0.0", "myregistry.com/ratelimit:2.0.0", "https://example.com/ext.wasm"}
+
+	filename := filepath.Join(t.TempDir(), "refs.txt")
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+
+	t.Run("file", func(t *testing.T) {
+		refs, err := readRefs(filename)
+		require.NoError(t, err)
+		assert.Equal(t, expected, refs)
+	})
+
+	t.Run("standard input", func(t *testing.T) {
+		f, err := os.Open(filename)
+		require.NoError(t, err)
+		defer f.Close()
+
+		stdin := os.Stdin
+		os.Stdin = f
+		defer func() { os.Stdin = stdin }()
+
+		refs, err := readRefs("-")
+		require.NoError(t, err)
+		assert.Equal(t, expected, refs)
+	})
+
+	t.Run("only comments", func(t *testing.T) {
+		empty := filepath.Join(t.TempDir(), "refs.txt")
+		require.NoError(t, os.WriteFile(empty, []byte("# nothing\n\n"), 0o644))
+		refs, err := readRefs(empty)
+		require.NoError(t, err)
+		assert.Empty(t, refs)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := readRefs(filepath.Join(t.TempDir(), "missing.txt"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
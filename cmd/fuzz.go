@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/fuzz"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/suite"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const fuzzDesc = `
+Fuzz a Proxy-Wasm extension in an embedded Proxy-Wasm host (as in 'pwo run').
+
+Random and mutated requests (headers, bodies, trailers...), responses and plugin
+configurations are sent to the extension, every one in a new instance, looking
+for crashes: traps like panics, aborts or out of bounds memory accesses, and
+runaway executions (callbacks running for longer than --timeout).
+
+The cases mutated are the requests in some test suites (see 'pwo test') given
+with --corpus, or some default requests when there are none:
+
+  $ pwo fuzz main.wasm --config '{"requiredKeys": ["id"]}' -n 10000
+  $ pwo fuzz oci://myregistry.com/myrepo:1.0.0 --corpus pwo-test.yaml --duration 5m
+
+Every new crash is minimized (removing headers, shortening bodies and values...
+while it crashes in the same way) and saved in --output-dir as a test suite
+that reproduces it with 'pwo test', so it can be debugged and kept as a
+regression test once fixed:
+
+  $ pwo test fuzz-crashers/crash-1f2e3d4c5b6a.yaml
+
+The seed of the random numbers is printed, so a run can be repeated with --seed.
+The command fails when any crash is found.
+`
+
+func newFuzzCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("fuzz")
+	o := runOptions{timeout: fuzz.DefaultTimeout}
+	iterations := fuzz.DefaultIterations
+	duration := time.Duration(0)
+	seed := int64(0)
+	corpus := []string{}
+	mutateConfig := true
+	minimize := true
+	outputDir := "fuzz-crashers"
+
+	cmd := &cobra.Command{
+		Use:   "fuzz [wasm|remote]",
+		Short: "fuzz a Proxy-Wasm extension in an embedded Proxy-Wasm host",
+		Long:  fuzzDesc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ref := args[0]
+
+			config, err := o.pluginConfig()
+			if err != nil {
+				return err
+			}
+			hostOpts, err := o.hostOptions()
+			if err != nil {
+				return err
+			}
+
+			seeds := fuzz.DefaultSeeds(string(config))
+			if len(corpus) > 0 {
+				if seeds, err = loadFuzzSeeds(corpus); err != nil {
+					return err
+				}
+			}
+
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			log.Sugar().Infof("Loading %s", ref)
+			data, err := loadWasm(ref, cfg, o.CommonPullOptions, o.cacheDir)
+			if err != nil {
+				return err
+			}
+
+			opts := []fuzz.Option{
+				fuzz.WithIterations(iterations),
+				fuzz.WithDuration(duration),
+				fuzz.WithMutateConfig(mutateConfig),
+				fuzz.WithMinimize(minimize),
+				fuzz.WithHostOptions(hostOpts...),
+			}
+			if seed == 0 {
+				seed = time.Now().UnixNano()
+			}
+			opts = append(opts, fuzz.WithSeed(seed))
+
+			// crashes are saved as soon as they are found, so they are not lost if interrupted
+			var saveErr error
+			opts = append(opts, fuzz.WithCrashFunc(func(crash *fuzz.Crash) {
+				filename, err := saveCrash(crash, o.reproducerSuite(ref), seed, outputDir)
+				if err != nil {
+					saveErr = err
+					log.Sugar().Errorf("When saving crash: %s", err)
+				}
+				printCrash(out, crash, filename)
+			}))
+
+			log.Sugar().Infof("Fuzzing %s (seed %d)", ref, seed)
+			res, err := fuzz.Run(ctx, data, seeds, opts...)
+			if err != nil {
+				return fmt.Errorf("when fuzzing %s: %w", ref, err)
+			}
+
+			fmt.Fprintf(out, "Seed: %d, runs: %d (%d with the configuration rejected), crashes: %d (%s)\n",
+				res.Seed, res.Runs, res.Rejected, len(res.Crashes), res.Duration.Round(time.Millisecond))
+			for _, crash := range res.Crashes {
+				fmt.Fprintf(out, "  %s (%d %s)\n", crash.Signature, crash.Count, pluralize("case", crash.Count))
+			}
+
+			if saveErr != nil {
+				return saveErr
+			}
+			if n := len(res.Crashes); n > 0 {
+				return fmt.Errorf("crashes found: %d (saved in %s)", n, outputDir)
+			}
+			return nil
+		},
+	}
+
+	addRunFlags(cmd, &o)
+	f := cmd.Flags()
+	f.IntVarP(&iterations, "iterations", "n", iterations, "number of cases run")
+	f.DurationVar(&duration, "duration", 0, "maximum time for fuzzing (no limit if zero)")
+	f.Int64Var(&seed, "seed", 0, "seed of the random numbers, for repeating a run (random if zero)")
+	f.StringArrayVar(&corpus, "corpus", nil, "test suite with the requests mutated (can be repeated)")
+	f.BoolVar(&mutateConfig, "mutate-config", mutateConfig, "mutate the plugin configuration too")
+	f.BoolVar(&minimize, "minimize", minimize, "minimize the cases of the crashes found")
+	f.StringVar(&outputDir, "output-dir", outputDir, "directory where the reproducers of the crashes are saved")
+
+	return cmd
+}
+
+// loadFuzzSeeds loads the requests (and configurations) in some test suites, for mutating them
+func loadFuzzSeeds(filenames []string) ([]fuzz.Case, error) {
+	var seeds []fuzz.Case
+	for _, filename := range filenames {
+		s, err := suite.Load(filename)
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range s.Tests {
+			// the requests are not sent when the configuration is rejected
+			if t.Expect.ConfigRejected {
+				continue
+			}
+			c := fuzz.Case{Config: s.Config, Exchange: t.ExchangeSpec}
+			if t.Config != nil {
+				c.Config = *t.Config
+			}
+			seeds = append(seeds, c)
+		}
+	}
+	if len(seeds) == 0 {
+		return nil, fmt.Errorf("no requests in the corpus")
+	}
+	return seeds, nil
+}
+
+// reproducerSuite returns the suite the reproducers of the crashes are based on,
+// with the options of the host used for fuzzing
+func (o *runOptions) reproducerSuite(module string) suite.Suite {
+	return suite.Suite{
+		Module:     module,
+		VMConfig:   o.vmConfig,
+		Properties: o.properties,
+		RootID:     o.rootID,
+		LogLevel:   o.logLevel,
+		Timeout:    o.timeout.String(),
+	}
+}
+
+// saveCrash saves the reproducer of a crash in a directory, returning the file name
+func saveCrash(crash *fuzz.Crash, base suite.Suite, seed int64, dir string) (string, error) {
+	name, data, err := fuzz.Reproducer(crash, base, seed)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	filename := filepath.Join(dir, name)
+	if err := utils.AtomicWriteFile(filename, bytes.NewReader(data), 0o644); err != nil {
+		return "", err
+	}
+	return filename, nil
+}
+
+// printCrash prints a crash, with the logs of the extension and the file with its reproducer
+func printCrash(out io.Writer, crash *fuzz.Crash, filename string) {
+	fmt.Fprintf(out, "CRASH %s\n", crash.Signature)
+	fmt.Fprintf(out, "      %s\n", strings.ReplaceAll(crash.Err.Error(), "\n", "\n      "))
+	for _, l := range crash.Logs {
+		fmt.Fprintf(out, "      | %s\n", l)
+	}
+	if filename != "" {
+		fmt.Fprintf(out, "      reproducer: %s\n", filename)
+	}
+}
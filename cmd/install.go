@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/lockfile"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const installDesc = `
+Install the extensions in a lock file (pwo.lock, created with 'pwo lock') in a
+directory, as "<name>.wasm" files.
+
+Extensions are obtained by the digests in the lock file, so exactly the same
+extensions are always installed, and the installation fails when any digest
+does not match. It also fails when the manifest (pwo.yaml) has been modified
+after generating the lock file.
+
+Example:
+
+  $ pwo install --destination /etc/envoy/wasm
+`
+
+func newInstallCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("install")
+	r := registry.RegistryParams{}
+	manifestFilename := lockfile.DefaultManifestFilename
+	lockFilename := lockfile.DefaultLockFilename
+	destDir := "."
+	concurrency := lockfile.DefaultConcurrency
+
+	cmd := &cobra.Command{
+		Use:   "install",
+		Short: "install the extensions in a lock file (pwo.lock) into a local directory",
+		Long:  installDesc,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			lock, err := lockfile.LoadLock(lockFilename)
+			if err != nil {
+				return fmt.Errorf("when loading lock file (run 'pwo lock' for creating it): %w", err)
+			}
+
+			// the manifest is optional, but the lock must be up to date when it is present
+			if _, err := os.Stat(manifestFilename); err == nil {
+				m, err := lockfile.LoadManifest(manifestFilename)
+				if err != nil {
+					return err
+				}
+				digest, err := m.Digest()
+				if err != nil {
+					return err
+				}
+				if digest != lock.Digest {
+					return fmt.Errorf("%s is out of date with %s: run 'pwo lock' for updating it", lockFilename, manifestFilename)
+				}
+			}
+
+			registryClient, err := registry.NewClientWithParams(r, settings.RegistryConfigFilename, settings.Debug)
+			if err != nil {
+				return fmt.Errorf("when creating registry client: %w", err)
+			}
+
+			log.Sugar().Infof("Installing %d extensions in %s", len(lock.Extensions), destDir)
+			return lockfile.Install(lock, registryClient, destDir, concurrency, out)
+		},
+	}
+
+	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r)
+	f.StringVar(&manifestFilename, "manifest", manifestFilename, "manifest with the extensions")
+	f.StringVar(&lockFilename, "lockfile", lockFilename, "lock file with the extensions to install")
+	f.StringVarP(&destDir, "destination", "d", destDir, "directory where the extensions are installed")
+	f.IntVar(&concurrency, "concurrency", concurrency, "number of extensions installed in parallel")
+
+	return cmd
+}
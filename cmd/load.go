@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const loadDesc = `
+Load the Proxy-Wasm extensions in one or more tar archives created with 'pwo save'
+into an OCI registry.
+
+Extensions are pushed, with their signatures, to the same repositories they
+were saved from. With --registry, they are pushed to those repositories in
+another registry (like a local registry in an air-gapped site). Contents are
+copied byte for byte, so digests are preserved.
+
+Example:
+
+  $ pwo load --registry localhost:5000 extensions.tar
+`
+
+func newLoadCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("load")
+	r := registry.RegistryParams{}
+	toRegistry := ""
+
+	cmd := &cobra.Command{
+		Use:   "load [archive...]",
+		Short: "load Proxy-Wasm extensions from a tar archive into an OCI registry",
+		Long:  loadDesc,
+		Args:  MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			registryClient, err := registry.NewClientWithParams(r, settings.RegistryConfigFilename, settings.Debug,
+				registry.ClientOptWriter(out))
+			if err != nil {
+				return fmt.Errorf("when creating registry client: %w", err)
+			}
+
+			for _, archive := range args {
+				log.Sugar().Infof("Loading %s", archive)
+				if _, err := registryClient.Load(archive, toRegistry); err != nil {
+					return fmt.Errorf("when loading %s: %w", archive, err)
+				}
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r)
+	f.StringVar(&toRegistry, "registry", "", "registry to push the extensions to, instead of the one they were saved from (e.g. \"localhost:5000\")")
+
+	return cmd
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/lockfile"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const lockDesc = `
+Resolve the extensions in a manifest (pwo.yaml) to exact versions, writing them
+in a lock file (pwo.lock) with the digests of their manifests, configs and layers.
+
+The manifest lists the extensions with their references and versions (a tag or
+a semver constraint, using the highest version when empty):
+
+  extensions:
+    - name: auth
+      ref: oci://myregistry.com/filters/auth
+      version: "~1.2"
+    - name: ratelimit
+      ref: oci://myregistry.com/filters/ratelimit
+
+The lock file can then be used with 'pwo install' for installing exactly the
+same extensions anywhere.
+
+Example:
+
+  $ pwo lock
+  $ pwo lock --manifest filters.yaml --lockfile filters.lock
+`
+
+func newLockCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("lock")
+	r := registry.RegistryParams{}
+	manifestFilename := lockfile.DefaultManifestFilename
+	lockFilename := lockfile.DefaultLockFilename
+
+	cmd := &cobra.Command{
+		Use:   "lock",
+		Short: "resolve the extensions in a manifest (pwo.yaml) into a lock file (pwo.lock)",
+		Long:  lockDesc,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := lockfile.LoadManifest(manifestFilename)
+			if err != nil {
+				return err
+			}
+
+			registryClient, err := registry.NewClientWithParams(r, settings.RegistryConfigFilename, settings.Debug)
+			if err != nil {
+				return fmt.Errorf("when creating registry client: %w", err)
+			}
+
+			log.Sugar().Infof("Resolving %d extensions in %s", len(m.Extensions), manifestFilename)
+			lock, err := lockfile.Resolve(m, registryClient)
+			if err != nil {
+				return err
+			}
+
+			for _, ext := range lock.Extensions {
+				fmt.Fprintf(out, "Locked: %s %s (%s)\n", ext.Name, ext.Ref, ext.Digest)
+			}
+			return lock.Save(lockFilename)
+		},
+	}
+
+	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r)
+	f.StringVar(&manifestFilename, "manifest", manifestFilename, "manifest with the extensions")
+	f.StringVar(&lockFilename, "lockfile", lockFilename, "lock file to write")
+
+	return cmd
+}
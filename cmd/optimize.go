@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+const optimizeDesc = `
+Optimize a Proxy-Wasm extension, removing the custom sections that are not
+needed for running it: debugging information (DWARF ".debug_*" sections),
+names, the tools that produced the module, source maps...
+
+Modules built with TinyGo or Rust usually contain these sections, and they can
+make modules several times larger than needed (and proxies have to compile all
+of it). All the other sections are copied unchanged.
+
+Example:
+
+  $ pwo optimize main.wasm -o main.optimized.wasm
+
+Some custom sections can be kept with --keep-section, and other sections can
+be removed with --strip-section (names, or prefixes ending in "*"):
+
+  $ pwo optimize main.wasm -o main.optimized.wasm --keep-section producers
+
+Extensions can also be optimized when publishing them with 'pwo publish --strip'.
+`
+
+func newOptimizeCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("optimize")
+	output := ""
+	var keep, strip []string
+
+	cmd := &cobra.Command{
+		Use:   "optimize [wasm]",
+		Short: "remove the custom sections (debugging information, names...) from a Proxy-Wasm extension",
+		Long:  optimizeDesc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			input := args[0]
+			data, err := os.ReadFile(input)
+			if err != nil {
+				return err
+			}
+
+			result, err := wasm.Strip(data, wasm.SectionMatcher(append(wasm.DefaultStripSections, strip...), keep))
+			if err != nil {
+				return fmt.Errorf("when optimizing %s: %w", input, err)
+			}
+
+			log.Sugar().Infof("Writing %s", output)
+			if err := utils.AtomicWriteFile(output, bytes.NewReader(result.Data), 0o644); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(out, "%s: %s\n", input, result)
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	f.StringVarP(&output, "output", "o", "", "file where the optimized extension is written (can be the same as the input)")
+	f.StringSliceVar(&keep, "keep-section", nil, "custom section to keep (can be repeated)")
+	f.StringSliceVar(&strip, "strip-section", nil, "extra custom section to remove, or prefix ending in '*' (can be repeated)")
+	_ = cmd.MarkFlagRequired("output")
+
+	return cmd
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"io"
+	"os"
 	"path"
 	"strings"
 
@@ -12,6 +13,7 @@ import (
 	"github.com/inercia/proxy-wasm-oci/pkg/publisher"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
 )
 
 const publishDesc = `
@@ -23,12 +25,35 @@ package it as a OCI image and pushed to the registry.
 Example:
 
   $ pwo publish main.wasm oci://myregistry.com/myrepo
+
+The file must be a Proxy-Wasm extension: a Wasm binary that declares a
+Proxy-Wasm ABI version (with a proxy_abi_version_* export) and exports the
+memory and the callbacks needed by all the proxies (like proxy_on_memory_allocate
+and proxy_on_context_create). This check can be disabled with
+--skip-wasm-validation.
+
+With --strip, the custom sections that are not needed for running the extension
+(debugging information, names...) are removed before publishing it, as in
+'pwo optimize'.
+
+With --smoke-test, the extension is started in an embedded Proxy-Wasm host
+(as in 'pwo run') before publishing it: it is initialized and proxy_on_vm_start
+and proxy_on_configure are called with the example configuration in the
+metadata ("exampleConfig" in the Wasm.yaml) or in --smoke-test-config. The
+extension is not published when any of them fails or traps, and the error
+includes the messages logged by the extension.
+
+  $ pwo publish --smoke-test main.wasm oci://myregistry.com/myrepo
 `
 
 func newPublishCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
 	log := l.Named("publish")
 	r := registry.RegistryParams{}
 	metaFilename := ""
+	skipWasmValidation := false
+	strip := false
+	smokeTest := false
+	smokeTestConfig := ""
 
 	cmd := &cobra.Command{
 		Use:     "publish [wasm] [remote]",
@@ -57,13 +82,30 @@ func newPublishCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *c
 				}
 			}
 
+			var smokeTestConfigData []byte
+			if smokeTestConfig != "" {
+				if smokeTestConfigData, err = os.ReadFile(smokeTestConfig); err != nil {
+					return fmt.Errorf("when reading the smoke test configuration: %w", err)
+				}
+				smokeTest = true
+			}
+
+			var stripSections func(string) bool
+			if strip {
+				stripSections = wasm.SectionMatcher(wasm.DefaultStripSections, nil)
+			}
+
 			client := publisher.NewPush(settings, cfg,
 				publisher.WithPushRegistryClient(registryClient),
 				publisher.WithPushConfig(cfg),
 				registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
 				registry.WithInsecure(r.Insecure),
 				registry.WithPlainHTTP(r.PlainHTTP),
-				publisher.WithPushOptWriter(out))
+				registry.WithMaxAttempts(r.MaxAttempts),
+				publisher.WithPushOptWriter(out),
+				publisher.WithSkipWasmValidation(skipWasmValidation),
+				publisher.WithStrip(stripSections),
+				publisher.WithSmokeTest(smokeTest, smokeTestConfigData))
 
 			client.Settings = settings
 
@@ -80,6 +122,10 @@ func newPublishCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *c
 	f := cmd.Flags()
 	registry.AddRegistryParamsFlags(f, &r)
 	f.StringVar(&metaFilename, "metadata", "", "filename of the metadata file (Wasm.yaml) to use")
+	f.BoolVar(&strip, "strip", false, "remove the custom sections not needed for running the extension (see 'pwo optimize')")
+	f.BoolVar(&skipWasmValidation, "skip-wasm-validation", false, "do not check that the file is a Proxy-Wasm extension")
+	f.BoolVar(&smokeTest, "smoke-test", false, "start the extension in an embedded Proxy-Wasm host before publishing it, with the example configuration in the metadata")
+	f.StringVar(&smokeTestConfig, "smoke-test-config", "", "file with the plugin configuration for the smoke test (implies --smoke-test)")
 
 	return cmd
 }
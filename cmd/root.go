@@ -19,6 +19,12 @@ Common actions for PWO:
 - pwo download:      download a Proxy-Wasm to your local directory to view
 - pwo publish:       upload the Proxy-Wasm to the regisrty
 - pwo serve:         serve the Proxy-Wasm from the registry, acting as a bridge between the Envoy and the registry.
+- pwo cache:         manage the local cache of downloaded Proxy-Wasm extensions.
+- pwo copy:          copy Proxy-Wasm extensions between registries, preserving their digests.
+- pwo save:          save Proxy-Wasm extensions into a tar archive, for moving them to air-gapped sites.
+- pwo load:          load the Proxy-Wasm extensions in a tar archive into a registry.
+- pwo lock:          resolve the Proxy-Wasm extensions in a manifest (pwo.yaml) into a lock file (pwo.lock).
+- pwo install:       install the Proxy-Wasm extensions in a lock file into a local directory.
 
 By default, the default directories depend on the Operating System. The defaults are listed below:
 
@@ -40,6 +46,7 @@ var settings = config.New()
 
 func newRootCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer, args []string) (*cobra.Command, error) {
 	flags := rootCmd.PersistentFlags()
+	settings.AddFlags(flags)
 
 	// We can safely ignore any errors that flags.Parse encounters since
 	// those errors will be caught later during the call to cmd.Execution.
@@ -48,7 +55,7 @@ func newRootCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer, args
 	flags.ParseErrorsWhitelist.UnknownFlags = true
 	flags.Parse(args)
 
-	registryClient, err := registry.NewDefaultRegistryClient(false)
+	registryClient, err := registry.NewClientWithParams(registry.RegistryParams{}, settings.RegistryConfigFilename, settings.Debug)
 	if err != nil {
 		return nil, err
 	}
@@ -79,6 +86,18 @@ func Execute() {
 	rootCmd.AddCommand(newPublishCmd(cfg, log, out))
 	rootCmd.AddCommand(newDownloadCmd(cfg, log, out))
 	rootCmd.AddCommand(newServeCmd(cfg, log, out))
+	rootCmd.AddCommand(newCacheCmd(cfg, log, out))
+	rootCmd.AddCommand(newCopyCmd(cfg, log, out))
+	rootCmd.AddCommand(newSaveCmd(cfg, log, out))
+	rootCmd.AddCommand(newLoadCmd(cfg, log, out))
+	rootCmd.AddCommand(newLockCmd(cfg, log, out))
+	rootCmd.AddCommand(newInstallCmd(cfg, log, out))
+	rootCmd.AddCommand(newOptimizeCmd(cfg, log, out))
+	rootCmd.AddCommand(newRunCmd(cfg, log, out))
+	rootCmd.AddCommand(newTestCmd(cfg, log, out))
+	rootCmd.AddCommand(newBenchCmd(cfg, log, out))
+	rootCmd.AddCommand(newFuzzCmd(cfg, log, out))
+	rootCmd.AddCommand(newCheckCmd(cfg, log, out))
 
 	if err := rootCmd.Execute(); err != nil {
 		os.Exit(1)
@@ -0,0 +1,272 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const runDesc = `
+Run a Proxy-Wasm extension without a proxy, sending a single HTTP request (and
+the response from the upstream) through it.
+
+The extension runs in an embedded Wasm runtime, with a Proxy-Wasm host that
+provides the plugin and VM configuration, headers, bodies and trailers, local
+responses, logging, properties, shared data and queues, and metrics. Features
+that need a real proxy (like HTTP calls to other services) are not available.
+
+The extension can be a local file or any reference that can be downloaded:
+
+  $ pwo run main.wasm --path /api --body 'hello'
+  $ pwo run oci://myregistry.com/myrepo:1.0.0 -H x-user=me --config '{"max": 10}'
+
+The request and response can also be described in a YAML file:
+
+  request:
+    method: POST
+    path: /api
+    headers:
+      content-type: application/json
+    body: '{"id": 1}'
+  response:
+    status: 200
+    body: 'ok'
+
+  $ pwo run main.wasm --request-file request.yaml
+
+The resulting request and response (or the local response sent by the
+extension), the logs and the callbacks called are printed, as text or as JSON
+with '--output json'.
+`
+
+// runOptions are the options for running an extension
+type runOptions struct {
+	downloader.CommonPullOptions
+
+	cacheDir   string
+	config     string
+	configFile string
+	vmConfig   string
+	properties map[string]string
+	rootID     string
+	logLevel   string
+	timeout    time.Duration
+}
+
+// addRunFlags adds the flags for loading and running an extension
+func addRunFlags(cmd *cobra.Command, o *runOptions) {
+	timeout := host.DefaultTimeout
+	if o.timeout != 0 {
+		timeout = o.timeout
+	}
+
+	f := cmd.Flags()
+	downloader.AddDownloadFlags(f, &o.CommonPullOptions)
+	registry.AddRegistryParamsFlags(f, &o.RegistryParams)
+	f.StringVar(&o.cacheDir, "cache-dir", cache.DefaultPath(), "directory where downloaded extensions are cached (no cache if empty)")
+	f.StringVar(&o.config, "config", "", "plugin configuration")
+	f.StringVar(&o.configFile, "config-file", "", "file with the plugin configuration")
+	f.StringVar(&o.vmConfig, "vm-config", "", "VM configuration")
+	f.StringToStringVar(&o.properties, "property", nil, "property obtained by the extension (e.g. 'node.id=proxy-1', can be repeated)")
+	f.StringVar(&o.rootID, "root-id", host.DefaultRootID, "root ID of the plugin")
+	f.StringVar(&o.logLevel, "log-level", "info", "log level reported to the extension (trace, debug, info, warn, error or critical)")
+	f.DurationVar(&o.timeout, "timeout", timeout, "maximum time for every callback")
+}
+
+// pluginConfig returns the plugin configuration, from --config or --config-file
+func (o *runOptions) pluginConfig() ([]byte, error) {
+	if o.configFile == "" {
+		return []byte(o.config), nil
+	}
+	if o.config != "" {
+		return nil, fmt.Errorf("--config and --config-file cannot be used together")
+	}
+	config, err := os.ReadFile(o.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("when reading the plugin configuration: %w", err)
+	}
+	return config, nil
+}
+
+// hostOptions returns the options for the host
+func (o *runOptions) hostOptions() ([]host.Option, error) {
+	config, err := o.pluginConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	level, err := host.ParseLogLevel(o.logLevel)
+	if err != nil {
+		return nil, err
+	}
+
+	return []host.Option{
+		host.WithPluginConfiguration(config),
+		host.WithVMConfiguration([]byte(o.vmConfig)),
+		host.WithProperties(o.properties),
+		host.WithRootID(o.rootID),
+		host.WithLogLevel(level),
+		host.WithTimeout(o.timeout),
+	}, nil
+}
+
+// startHost loads an extension and starts it in a new host
+func (o *runOptions) startHost(ctx context.Context, ref string, cfg *registry.Configuration, opts ...host.Option) (*host.Host, error) {
+	data, err := loadWasm(ref, cfg, o.CommonPullOptions, o.cacheDir)
+	if err != nil {
+		return nil, err
+	}
+
+	hostOpts, err := o.hostOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	h, err := host.New(ctx, data, append(hostOpts, opts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("when loading %s: %w", ref, err)
+	}
+	if err := h.Start(ctx); err != nil {
+		_ = h.Close(ctx)
+		return nil, fmt.Errorf("when starting %s: %w", ref, err)
+	}
+	return h, nil
+}
+
+func newRunCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("run")
+	o := runOptions{}
+	requestFile := ""
+	output := "text"
+	exchange := host.ExchangeSpec{}
+	bodyFile := ""
+
+	cmd := &cobra.Command{
+		Use:   "run [wasm|remote]",
+		Short: "run an HTTP request through a Proxy-Wasm extension, without a proxy",
+		Long:  runDesc,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output != "text" && output != "json" {
+				return fmt.Errorf("unknown output format %q", output)
+			}
+
+			if requestFile != "" {
+				data, err := os.ReadFile(requestFile)
+				if err != nil {
+					return err
+				}
+				exchange = host.ExchangeSpec{}
+				if err := yaml.UnmarshalStrict(data, &exchange); err != nil {
+					return fmt.Errorf("when parsing %s: %w", requestFile, err)
+				}
+			}
+			if bodyFile != "" {
+				data, err := os.ReadFile(bodyFile)
+				if err != nil {
+					return err
+				}
+				exchange.Request.Body = string(data)
+			}
+
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			log.Sugar().Infof("Loading %s", args[0])
+			h, err := o.startHost(ctx, args[0], cfg)
+			if err != nil {
+				return err
+			}
+			defer h.Close(ctx)
+
+			result, err := h.HTTP(ctx, exchange.Request.HTTPRequest(), exchange.Response.HTTPResponse())
+			if output == "json" {
+				enc := json.NewEncoder(out)
+				enc.SetIndent("", "  ")
+				if err := enc.Encode(result); err != nil {
+					return err
+				}
+			} else {
+				printHTTPResult(out, result)
+			}
+			return err
+		},
+	}
+
+	addRunFlags(cmd, &o)
+	f := cmd.Flags()
+	f.StringVar(&requestFile, "request-file", "", "YAML file with the request (and the response)")
+	f.StringVarP(&output, "output", "o", output, "output format (text or json)")
+	f.StringVar(&exchange.Request.Method, "method", "", "method of the request (default GET)")
+	f.StringVar(&exchange.Request.Path, "path", "", "path of the request (default /)")
+	f.StringVar(&exchange.Request.Authority, "authority", "", "authority of the request (default localhost)")
+	f.StringToStringVarP(&exchange.Request.Headers, "header", "H", nil, "header of the request (e.g. 'x-user=me', can be repeated)")
+	f.StringVar(&exchange.Request.Body, "body", "", "body of the request")
+	f.StringVar(&bodyFile, "body-file", "", "file with the body of the request")
+	f.IntVar(&exchange.Response.Status, "response-status", 0, "status of the response from the upstream (default 200)")
+	f.StringToStringVar(&exchange.Response.Headers, "response-header", nil, "header of the response from the upstream (can be repeated)")
+	f.StringVar(&exchange.Response.Body, "response-body", "", "body of the response from the upstream")
+
+	return cmd
+}
+
+// printHTTPResult prints the result of running a request through an extension
+func printHTTPResult(out io.Writer, result *host.HTTPResult) {
+	if result == nil {
+		return
+	}
+
+	printMessage := func(title string, msg *host.HTTPRequest) {
+		fmt.Fprintf(out, "%s:\n", title)
+		for _, h := range msg.Headers {
+			fmt.Fprintf(out, "  %s: %s\n", h.Name, h.Value)
+		}
+		if len(msg.Body) > 0 {
+			fmt.Fprintf(out, "\n%s\n", indent(string(msg.Body)))
+		}
+		for _, h := range msg.Trailers {
+			fmt.Fprintf(out, "  (trailer) %s: %s\n", h.Name, h.Value)
+		}
+		fmt.Fprintln(out)
+	}
+
+	printMessage("Request", result.Request)
+	if lr := result.LocalResponse; lr != nil {
+		title := fmt.Sprintf("Local response (%d)", lr.StatusCode)
+		if lr.Details != "" {
+			title = fmt.Sprintf("Local response (%d, %s)", lr.StatusCode, lr.Details)
+		}
+		printMessage(title, &host.HTTPResponse{Headers: lr.Headers, Body: lr.Body})
+	} else if result.Response != nil {
+		printMessage("Response", result.Response)
+	}
+
+	fmt.Fprintln(out, "Logs:")
+	for _, e := range result.Logs {
+		fmt.Fprintf(out, "  %s\n", e)
+	}
+	fmt.Fprintln(out, "\nCallbacks:")
+	for _, c := range result.Callbacks {
+		fmt.Fprintf(out, "  %-30s %-10s %s\n", c.Name, c.Action, c.Duration)
+	}
+}
+
+// indent indents all the lines in a text
+func indent(s string) string {
+	return "  " + strings.ReplaceAll(strings.TrimRight(s, "\n"), "\n", "\n  ")
+}
@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const saveDesc = `
+Save Proxy-Wasm extensions from OCI registries into a single tar archive.
+
+The manifests, the configs, the layers and the attached signatures are stored
+in the archive (as OCI image layouts, with an index of the extensions), so it
+can be moved to sites without access to the registries. There, it can be loaded
+in a local registry with 'pwo load', or served directly with 'pwo serve --bundle'.
+
+With --version, all the tags matching a semver constraint are saved for the
+references without a tag.
+
+Example:
+
+  $ pwo save -o extensions.tar oci://myregistry.com/myrepo:1.0.0 oci://myregistry.com/other:2.1.0
+  $ pwo save -o extensions.tar --version "~1.2" oci://myregistry.com/myrepo
+`
+
+func newSaveCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("save")
+	r := registry.RegistryParams{}
+	output := ""
+	version := ""
+	skipSignatures := false
+
+	cmd := &cobra.Command{
+		Use:   "save [remote...]",
+		Short: "save Proxy-Wasm extensions from OCI registries into a tar archive",
+		Long:  saveDesc,
+		Args:  MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if output == "" {
+				return fmt.Errorf("no output file provided (use --output)")
+			}
+
+			registryClient, err := registry.NewClientWithParams(r, settings.RegistryConfigFilename, settings.Debug,
+				registry.ClientOptWriter(out))
+			if err != nil {
+				return fmt.Errorf("when creating registry client: %w", err)
+			}
+
+			var refs []string
+			for _, ref := range args {
+				if !registry.IsOCI(ref) {
+					return fmt.Errorf("invalid OCI reference: %s", ref)
+				}
+				ref = strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme))
+
+				tag, err := registry.RefTag(ref)
+				if err != nil {
+					return err
+				}
+				if tag != "" {
+					refs = append(refs, ref)
+					continue
+				}
+				if version == "" {
+					return fmt.Errorf("no tag in %s (use --version for saving the tags matching a constraint)", ref)
+				}
+
+				allTags, err := registryClient.Tags(ref)
+				if err != nil {
+					return fmt.Errorf("when listing tags in %s: %w", ref, err)
+				}
+				tags, err := registry.GetTagsMatchingConstraint(allTags, version)
+				if err != nil {
+					return fmt.Errorf("invalid version constraint %q: %w", version, err)
+				}
+				if len(tags) == 0 {
+					return fmt.Errorf("no tags in %s matching %q", ref, version)
+				}
+				for _, tag := range tags {
+					refs = append(refs, ref+":"+tag)
+				}
+			}
+
+			log.Sugar().Infof("Saving %d extensions to %s", len(refs), output)
+			_, err = registryClient.Save(refs, output, registry.CopyOptSkipSignatures(skipSignatures))
+			return err
+		},
+	}
+
+	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r)
+	f.StringVarP(&output, "output", "o", "", "tar archive to write")
+	f.StringVar(&version, "version", "", "save all the tags matching a semver constraint (e.g. \"~1.2\") for references without a tag")
+	f.BoolVar(&skipSignatures, "skip-signatures", false, "do not save the signatures attached to the extensions")
+
+	return cmd
+}
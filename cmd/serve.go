@@ -3,11 +3,15 @@ package cmd
 import (
 	"context"
 	"io"
+	"os"
+	"path/filepath"
 	"sync"
 
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
 
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 	"github.com/inercia/proxy-wasm-oci/pkg/server"
 )
@@ -17,6 +21,63 @@ const DefListenPort = 15111
 const serveDesc = `
 Serve Proxy-Wasm extensions from an OCI registry through HTTP.
 
+Downloaded extensions are kept in a local cache. The cache can be inspected and
+managed through the admin API (at /api/v1/admin) when an admin token is provided
+with --admin-token (or the PWO_ADMIN_TOKEN environment variable).
+
+When the registry is unavailable, the last extension a reference was resolved to is
+served from the cache, marked with a "X-Pwo-Stale: true" header (see --stale-if-error).
+
+Release channels (like "stable" or "canary") can be defined in a configuration file
+provided with --config, rolling out several versions of an extension with weights:
+
+  channels:
+    canary:
+      extensions:
+        myext:
+          ref: oci://myregistry.com/myrepo/myext
+          versions:
+            - version: 1.0.0
+              weight: 90
+            - version: ~1.1
+              weight: 10
+
+Clients obtain a version consistently, hashing a request header ("hashHeader", like
+a header with Envoy's node ID) or the client IP, from
+/api/v1/wasm/download?channel=canary&name=myext
+
+The information about an extension (the Proxy-Wasm ABI version, the host functions
+imported and the oldest compatible release of every known proxy, as in 'pwo check')
+is returned as JSON from /api/v1/wasm/info, with the same parameters.
+
+Pulls from registries are limited globally (with --burst-limit) and per registry
+(with --pulls-per-registry): extra pulls wait in a queue (up to --pull-queue-timeout).
+Clients can be rate limited with --client-rate-limit. The queue and limits are
+reported in the metrics at /debug/vars.
+
+Registries can send their notifications (push and delete events) to
+/api/v1/webhooks/registry, invalidating the cached tags for the repository and
+prefetching the references in the "watch" list of the configuration file.
+Notifications are authenticated with a "X-Pwo-Signature-256: sha256=<HMAC>" header
+with a --webhook-secret. Without a secret, the webhook is disabled, unless
+unauthenticated notifications are accepted explicitly with --webhook-insecure.
+
+Extensions can also be served from OCI image layout directories (for example,
+in air-gapped sites) with references like "oci-layout:///mnt/media/myrepo:1.0.0",
+as long as they are in a directory allowed with --oci-layout-dir.
+
+Wasm modules in HTTP(S) URLs (like release assets) are also proxied and cached,
+with references like "https://example.com/releases/v1.0.0/myext.wasm", as long as
+they start with a prefix allowed with --allow-url-prefix (like
+"https://example.com/releases/"). The same applies to the schemes provided by
+plugins. Local files ("file:///mnt/media/myext.wasm") must be in a directory
+allowed with --oci-layout-dir. All the modules are checked to be Proxy-Wasm
+extensions before they are cached and served.
+
+With --bundle, extensions are served from a tar archive created with 'pwo save',
+without accessing any registry: references to the repositories in the archive
+(like "oci://myregistry.com/myrepo:1.0.0") are obtained from it.
+
 Example:
 
   $ pwo serve --port 17000
@@ -25,17 +86,69 @@ Example:
 func newServeCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
 	log := l.Named("server")
 	listenPort := 0
+	r := registry.RegistryParams{}
+	cacheDir := ""
+	adminToken := ""
+	resolveTTL := server.DefResolveTTL
+	staleIfError := true
+	configFilename := ""
+	webhookSecret := ""
+	webhookInsecure := false
+	pullsPerRegistry := server.DefPullsPerRegistry
+	pullQueueTimeout := server.DefPullQueueTimeout
+	clientRateLimit := 0
+	clientRateWindow := server.DefClientRateWindow
+	layoutDirs := []string{}
+	allowedURLs := []string{}
+	bundle := ""
 
 	cmd := &cobra.Command{
 		Use:     "serve [remote]",
 		Short:   "serve Proxy-Wasm extensionss from OCI registries through HTTP",
 		Aliases: []string{"server"},
-		Long:    downloadDesc,
+		Long:    serveDesc,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var wg sync.WaitGroup
 			ctx := context.Background()
 
-			srv, err := server.NewServer(settings, log, cfg)
+			srvCfg := &server.Config{}
+			if configFilename != "" {
+				c, err := server.LoadConfig(configFilename)
+				if err != nil {
+					return err
+				}
+				srvCfg = c
+			}
+
+			var bundleIndex *registry.BundleIndex
+			bundleDir := filepath.Join(cacheDir, "bundle")
+			if bundle != "" {
+				log.Sugar().Infof("Extracting bundle %s", bundle)
+				if err := os.RemoveAll(bundleDir); err != nil {
+					return err
+				}
+				index, err := registry.ExtractBundle(bundle, bundleDir)
+				if err != nil {
+					return err
+				}
+				bundleIndex = index
+			}
+
+			srv, err := server.NewServer(settings, log, cfg,
+				server.WithConfig(srvCfg),
+				server.WithCache(cache.New(cacheDir, cache.WithLogger(log.Named("cache")))),
+				server.WithResolveTTL(resolveTTL),
+				server.WithAdminToken(adminToken),
+				server.WithRegistryParams(r),
+				server.WithStaleIfError(staleIfError),
+				server.WithWebhookSecret(webhookSecret),
+				server.WithInsecureWebhook(webhookInsecure),
+				server.WithPullLimits(settings.BurstLimit, pullsPerRegistry, pullQueueTimeout),
+				server.WithClientRateLimit(clientRateLimit, clientRateWindow),
+				server.WithLayoutDirs(layoutDirs),
+				server.WithAllowedURLPrefixes(allowedURLs),
+				server.WithBundle(bundleIndex, bundleDir),
+			)
 			if err != nil {
 				return err
 			}
@@ -56,7 +169,22 @@ func newServeCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cob
 	}
 
 	f := cmd.Flags()
+	registry.AddRegistryParamsFlags(f, &r)
 	f.IntVar(&listenPort, "port", DefListenPort, "port to listen at, as PORT")
+	f.StringVar(&configFilename, "config", "", "server configuration file (with release channels, etc.)")
+	f.StringVar(&cacheDir, "cache-dir", config.CachePath("server"), "directory where downloaded extensions are cached")
+	f.StringVar(&adminToken, "admin-token", os.Getenv("PWO_ADMIN_TOKEN"), "token required for using the admin API (disabled if empty)")
+	f.StringVar(&webhookSecret, "webhook-secret", os.Getenv("PWO_WEBHOOK_SECRET"), "secret for checking the HMAC signature of registry notifications")
+	f.BoolVar(&webhookInsecure, "webhook-insecure", false, "enable the registry webhook without a --webhook-secret, accepting unauthenticated notifications")
+	f.IntVar(&pullsPerRegistry, "pulls-per-registry", pullsPerRegistry, "maximum number of concurrent pulls from a registry (the global limit is set with --burst-limit)")
+	f.DurationVar(&pullQueueTimeout, "pull-queue-timeout", pullQueueTimeout, "maximum time a pull waits for a free slot before failing")
+	f.IntVar(&clientRateLimit, "client-rate-limit", clientRateLimit, "maximum number of download requests per client in the rate window (no limit if 0)")
+	f.DurationVar(&clientRateWindow, "client-rate-window", clientRateWindow, "time window for the per-client rate limit")
+	f.BoolVar(&staleIfError, "stale-if-error", staleIfError, "serve the last known good extension from the cache when the registry is unavailable")
+	f.StringSliceVar(&layoutDirs, "oci-layout-dir", layoutDirs, "directory where OCI layout directories (and files) can be served from (with oci-layout:// and file:// references)")
+	f.StringSliceVar(&allowedURLs, "allow-url-prefix", allowedURLs, "prefix of the URLs (like \"https://example.com/releases/\") extensions can be fetched from (with http(s):// and plugin references)")
+	f.StringVar(&bundle, "bundle", "", "tar archive (created with 'pwo save') to serve extensions from")
+	f.DurationVar(&resolveTTL, "resolve-ttl", resolveTTL, "time the resolution of a floating reference is reused before resolving it again")
 
 	return cmd
 }
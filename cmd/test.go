@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/cobra"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/suite"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const testDesc = `
+Run declarative test suites against a compiled Proxy-Wasm extension.
+
+Suites are YAML files with the plugin configuration, the requests sent to the
+extension (and the responses from the upstream) and the expected outcomes: the
+status and body received by the client (like a local response sent with
+SendHttpResponse), the headers received by the upstream and the client, and the
+messages logged. Tests run in the embedded Proxy-Wasm host used by 'pwo run',
+so they work for extensions written in any language.
+
+  module: main.wasm
+  config: '{"requiredKeys": ["id"]}'
+  tests:
+    - name: rejects invalid payloads
+      request:
+        method: POST
+        headers:
+          content-type: application/json
+        body: 'not json'
+      expect:
+        localResponse: true
+        status: 403
+        body: invalid payload
+        logs: ["body is not a valid json"]
+    - name: forwards valid payloads
+      request:
+        method: POST
+        headers:
+          content-type: application/json
+        body: '{"id": 1}'
+      expect:
+        localResponse: false
+        requestHeaders:
+          content-type: application/json
+
+Suites can also set the VM configuration (vmConfig), the properties, the root ID
+of the plugin (rootID), the log level reported to the extension (logLevel) and
+the maximum time for every callback (timeout, like "1s").
+
+The module in the suite (a file relative to the suite, or a reference like
+oci://myregistry.com/myrepo:1.0.0) can be replaced with --module:
+
+  $ pwo test pwo-test.yaml
+  $ pwo test --module oci://myregistry.com/myrepo:1.0.0 pwo-test.yaml
+
+The results can also be written as a JUnit XML report with --junit. The command
+fails when any test fails.
+`
+
+func newTestCmd(cfg *registry.Configuration, l *zap.Logger, out io.Writer) *cobra.Command {
+	log := l.Named("test")
+	r := downloader.CommonPullOptions{}
+	cacheDir := ""
+	module := ""
+	junit := ""
+	timeout := host.DefaultTimeout
+
+	cmd := &cobra.Command{
+		Use:   "test [suite...]",
+		Short: "run test suites against a Proxy-Wasm extension in an embedded Proxy-Wasm host",
+		Long:  testDesc,
+		Args:  MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			// modules are loaded once, even when used in many suites
+			modules := map[string][]byte{}
+
+			var results []*suite.Result
+			for _, filename := range args {
+				s, err := suite.Load(filename)
+				if err != nil {
+					return err
+				}
+				if module != "" {
+					s.Module = module
+				}
+				if s.Module == "" {
+					return fmt.Errorf("%s: no module to test (use --module)", filename)
+				}
+
+				data, ok := modules[s.Module]
+				if !ok {
+					log.Sugar().Infof("Loading %s", s.Module)
+					if data, err = loadWasm(s.Module, cfg, r, cacheDir); err != nil {
+						return err
+					}
+					modules[s.Module] = data
+				}
+
+				// the timeout of the suite is used unless --timeout is given
+				var opts []host.Option
+				if cmd.Flags().Changed("timeout") {
+					opts = append(opts, host.WithTimeout(timeout))
+				}
+				result := s.Run(ctx, data, opts...)
+				printSuiteResult(out, result)
+				results = append(results, result)
+			}
+
+			if junit != "" {
+				var buf bytes.Buffer
+				if err := suite.WriteJUnit(&buf, results); err != nil {
+					return err
+				}
+				if err := utils.AtomicWriteFile(junit, &buf, 0o644); err != nil {
+					return fmt.Errorf("when writing JUnit report: %w", err)
+				}
+			}
+
+			failed := 0
+			for _, result := range results {
+				failed += result.Failed()
+			}
+			if failed > 0 {
+				return fmt.Errorf("%d %s failed", failed, pluralize("test", failed))
+			}
+			return nil
+		},
+	}
+
+	f := cmd.Flags()
+	downloader.AddDownloadFlags(f, &r)
+	registry.AddRegistryParamsFlags(f, &r.RegistryParams)
+	f.StringVar(&cacheDir, "cache-dir", cache.DefaultPath(), "directory where downloaded extensions are cached (no cache if empty)")
+	f.StringVarP(&module, "module", "m", "", "extension tested (a Wasm file or a reference), instead of the module in the suites")
+	f.StringVar(&junit, "junit", "", "file where a JUnit XML report is written")
+	f.DurationVar(&timeout, "timeout", timeout, "maximum time for every callback (overrides the timeout of the suites)")
+
+	return cmd
+}
+
+// printSuiteResult prints the results of the tests in a suite, with the failures
+// and the logs of the tests that did not pass
+func printSuiteResult(out io.Writer, result *suite.Result) {
+	fmt.Fprintf(out, "=== %s (%s)\n", result.Name, result.Module)
+	for _, t := range result.Tests {
+		duration := t.Duration.Round(time.Microsecond)
+		switch {
+		case t.Err != nil:
+			fmt.Fprintf(out, "ERROR %s (%s)\n      %s\n", t.Name, duration, t.Err)
+		case len(t.Failures) > 0:
+			fmt.Fprintf(out, "FAIL  %s (%s)\n", t.Name, duration)
+			for _, failure := range t.Failures {
+				fmt.Fprintf(out, "      %s\n", failure)
+			}
+		default:
+			fmt.Fprintf(out, "PASS  %s (%s)\n", t.Name, duration)
+			continue
+		}
+		for _, l := range t.Logs {
+			fmt.Fprintf(out, "      | %s\n", l)
+		}
+	}
+
+	failed := result.Failed()
+	fmt.Fprintf(out, "--- %d passed, %d failed (%s)\n", len(result.Tests)-failed, failed, result.Duration.Round(time.Millisecond))
+}
@@ -2,10 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"github.com/spf13/cobra"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 )
 
 func warning(format string, v ...interface{}) {
@@ -42,3 +47,55 @@ func pluralize(word string, n int) string {
 	}
 	return word + "s"
 }
+
+// loadWasm reads a Wasm module from a local file, or downloads it from a reference
+// (like "oci://myregistry.com/myrepo:1.0.0") when it is not a file
+func loadWasm(ref string, cfg *registry.Configuration, r downloader.CommonPullOptions, cacheDir string) ([]byte, error) {
+	if _, err := os.Stat(ref); err == nil {
+		return os.ReadFile(ref)
+	}
+	if !downloader.All(settings).SupportsRef(ref) {
+		return nil, fmt.Errorf("%s is not a file or a valid reference", ref)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "pwo-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	registryClient, err := registry.NewClientWithParams(r.RegistryParams, settings.RegistryConfigFilename, settings.Debug,
+		registry.ClientOptWriter(io.Discard))
+	if err != nil {
+		return nil, fmt.Errorf("when creating registry client: %w", err)
+	}
+
+	version := r.Version
+	if version == "" {
+		if version, err = getVersionFromRef(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	pullerCfg := *cfg
+	puller := downloader.NewPull(settings, &pullerCfg,
+		registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
+		registry.WithInsecure(r.Insecure),
+		registry.WithPlainHTTP(r.PlainHTTP),
+		registry.WithMaxAttempts(r.MaxAttempts),
+		downloader.WithDestDir(tmpDir),
+		downloader.WithVersion(version),
+		downloader.WithSHA256(r.SHA256),
+		downloader.WithVerify(r.Verify),
+	)
+	if cacheDir != "" {
+		puller.Cache = cache.New(cacheDir)
+	}
+	puller.SetRegistryClient(registryClient)
+
+	saved, err := puller.Run(ref)
+	if err != nil {
+		return nil, fmt.Errorf("when downloading %s: %w", ref, err)
+	}
+	return os.ReadFile(saved)
+}
@@ -0,0 +1,151 @@
+// Package wasmtest provides helpers for building Wasm binaries in the tests.
+//
+// It does not depend on pkg/wasm, so it can be used from the tests of that package.
+package wasmtest
+
+import "bytes"
+
+// Section IDs
+const (
+	SectionCustom   byte = 0
+	SectionType     byte = 1
+	SectionImport   byte = 2
+	SectionFunction byte = 3
+	SectionMemory   byte = 5
+	SectionGlobal   byte = 6
+	SectionExport   byte = 7
+	SectionCode     byte = 10
+	SectionData     byte = 11
+)
+
+// Kinds of imports and exports
+const (
+	KindFunction byte = 0
+	KindTable    byte = 1
+	KindMemory   byte = 2
+	KindGlobal   byte = 3
+)
+
+// Some types and instructions
+const (
+	I32      = 0x7f
+	I32Const = 0x41
+	Call     = 0x10
+	Drop     = 0x1a
+)
+
+// LEB encodes an unsigned LEB128 number.
+func LEB(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+// Name encodes a name.
+func Name(s string) []byte {
+	return append(LEB(uint64(len(s))), s...)
+}
+
+// Vector encodes a vector of (already encoded) items.
+func Vector(items ...[]byte) []byte {
+	return append(LEB(uint64(len(items))), bytes.Join(items, nil)...)
+}
+
+// Section encodes a section.
+func Section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, LEB(uint64(len(content)))...), content...)
+}
+
+// Module returns a module with the magic, the version and some sections.
+func Module(sections ...[]byte) []byte {
+	return append([]byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}, bytes.Join(sections, nil)...)
+}
+
+// ImportFunc encodes the import of a function with some type index.
+func ImportFunc(module, name string, typeIndex byte) []byte {
+	return append(append(Name(module), Name(name)...), KindFunction, typeIndex)
+}
+
+// Imports returns an import section.
+func Imports(imports ...[]byte) []byte {
+	return Section(SectionImport, Vector(imports...))
+}
+
+// Export encodes an export.
+func Export(name string, kind byte, index byte) []byte {
+	return append(Name(name), kind, index)
+}
+
+// Exports returns an export section.
+func Exports(exports ...[]byte) []byte {
+	return Section(SectionExport, Vector(exports...))
+}
+
+// Custom returns a custom section.
+func Custom(name string, data []byte) []byte {
+	return Section(SectionCustom, append(Name(name), data...))
+}
+
+// Func encodes the body of a function without locals.
+func Func(body ...byte) []byte {
+	code := append(append([]byte{0x00}, body...), 0x0b)
+	return append(LEB(uint64(len(code))), code...)
+}
+
+// LogMessage is the message in the memory of the ProxyWasm extensions, at address 0
+const LogMessage = "hello from proxy-wasm"
+
+// Log returns the instructions for logging the LogMessage at some level
+// in the ProxyWasm extensions.
+func Log(level byte) []byte {
+	return []byte{I32Const, level, I32Const, 0, I32Const, byte(len(LogMessage)), Call, 0, Drop}
+}
+
+// ProxyWasm returns a minimal Proxy-Wasm extension (for the 0.2.1 ABI), with a bump allocator
+// and a proxy_on_configure with some body, returning an i32 (1 when there is no body,
+// accepting any configuration). The extension imports proxy_log as function 0.
+func ProxyWasm(onConfigure ...byte) []byte {
+	if len(onConfigure) == 0 {
+		onConfigure = []byte{I32Const, 1}
+	}
+	return Module(
+		Section(SectionType, Vector(
+			[]byte{0x60, 1, I32, 1, I32},           // 0: (i32) -> i32
+			[]byte{0x60, 2, I32, I32, 0},           // 1: (i32, i32) -> ()
+			[]byte{0x60, 0, 0},                     // 2: () -> ()
+			[]byte{0x60, 2, I32, I32, 1, I32},      // 3: (i32, i32) -> i32
+			[]byte{0x60, 3, I32, I32, I32, 1, I32}, // 4: (i32, i32, i32) -> i32
+		)),
+		Imports(ImportFunc("env", "proxy_log", 4)),
+		Section(SectionFunction, Vector([]byte{0}, []byte{1}, []byte{2}, []byte{3}, []byte{3})),
+		Section(SectionMemory, Vector([]byte{0x00, 0x01})),
+		// the allocation pointer, starting at 4096
+		Section(SectionGlobal, Vector([]byte{I32, 0x01, I32Const, 0x80, 0x20, 0x0b})),
+		Exports(
+			Export("memory", KindMemory, 0),
+			Export("proxy_on_memory_allocate", KindFunction, 1),
+			Export("proxy_on_context_create", KindFunction, 2),
+			Export("proxy_abi_version_0_2_1", KindFunction, 3),
+			Export("proxy_on_vm_start", KindFunction, 4),
+			Export("proxy_on_configure", KindFunction, 5),
+		),
+		Section(SectionCode, Vector(
+			// global.get 0; global.get 0; local.get 0; i32.add; global.set 0
+			Func(0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0),
+			Func(),
+			Func(),
+			Func(I32Const, 1),
+			Func(onConfigure...),
+		)),
+		Section(SectionData, Vector(append([]byte{0x00, I32Const, 0, 0x0b}, Name(LogMessage)...))),
+	)
+}
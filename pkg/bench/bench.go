@@ -0,0 +1,216 @@
+// Package bench benchmarks Proxy-Wasm extensions in the embedded host (see pkg/host),
+// measuring the latency of the callbacks, the growth of the memory of the extension
+// and the allocations made by the host, and comparing the results with a baseline.
+package bench
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// Default values for benchmarks
+const (
+	DefaultIterations = 1000
+	DefaultWarmup     = 10
+)
+
+// options for benchmarks
+type options struct {
+	iterations int
+	warmup     int
+	hostOpts   []host.Option
+}
+
+// Option is an option for benchmarks
+type Option func(*options)
+
+// WithIterations sets the number of requests measured
+func WithIterations(n int) Option {
+	return func(o *options) {
+		o.iterations = n
+	}
+}
+
+// WithWarmup sets the number of requests sent before measuring
+func WithWarmup(n int) Option {
+	return func(o *options) {
+		o.warmup = n
+	}
+}
+
+// WithHostOptions sets the options for the host (like the plugin configuration)
+func WithHostOptions(opts ...host.Option) Option {
+	return func(o *options) {
+		o.hostOpts = append(o.hostOpts, opts...)
+	}
+}
+
+// Stats are statistics about the durations of something (all in nanoseconds in JSON)
+type Stats struct {
+	Count int           `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	P50   time.Duration `json:"p50"`
+	P90   time.Duration `json:"p90"`
+	P99   time.Duration `json:"p99"`
+	Max   time.Duration `json:"max"`
+}
+
+// newStats computes the statistics for some durations
+func newStats(durations []time.Duration) Stats {
+	if len(durations) == 0 {
+		return Stats{}
+	}
+	sorted := append([]time.Duration{}, durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, d := range sorted {
+		total += d
+	}
+	percentile := func(p int) time.Duration {
+		// nearest-rank method
+		i := (p*len(sorted)+99)/100 - 1
+		if i < 0 {
+			i = 0
+		}
+		return sorted[i]
+	}
+
+	return Stats{
+		Count: len(sorted),
+		Mean:  total / time.Duration(len(sorted)),
+		P50:   percentile(50),
+		P90:   percentile(90),
+		P99:   percentile(99),
+		Max:   sorted[len(sorted)-1],
+	}
+}
+
+// Memory is the size of the memory of the extension, in bytes
+type Memory struct {
+	// Initial is the size after starting the extension
+	Initial uint32 `json:"initial"`
+	// Final is the size after all the requests
+	Final uint32 `json:"final"`
+	// Growth is the difference between the final and the initial sizes
+	Growth uint32 `json:"growth"`
+}
+
+// Allocations are the allocations made by the host in the memory of the extension
+type Allocations struct {
+	Count      int     `json:"count"`
+	Bytes      int64   `json:"bytes"`
+	PerRequest float64 `json:"perRequest"`
+}
+
+// Report is the result of a benchmark
+type Report struct {
+	Module     string    `json:"module,omitempty"`
+	Digest     string    `json:"digest"`
+	Date       time.Time `json:"date"`
+	Iterations int       `json:"iterations"`
+	// Request are the statistics for the whole requests (all the callbacks)
+	Request Stats `json:"request"`
+	// Callbacks are the statistics for every callback
+	Callbacks   map[string]Stats `json:"callbacks"`
+	Memory      Memory           `json:"memory"`
+	Allocations Allocations      `json:"allocations"`
+	// LocalResponses is the number of requests answered with a local response
+	LocalResponses int `json:"localResponses"`
+
+	// Baseline is the module (or report) compared with, if any
+	Baseline string `json:"baseline,omitempty"`
+	// Regressions are the metrics that are worse than in the baseline
+	Regressions []Regression `json:"regressions,omitempty"`
+}
+
+// Run benchmarks an extension, sending the requests in a workload (in a loop) to a single host
+func Run(ctx context.Context, data []byte, workload Workload, opts ...Option) (*Report, error) {
+	o := options{iterations: DefaultIterations, warmup: DefaultWarmup}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(workload) == 0 {
+		return nil, fmt.Errorf("no requests in the workload")
+	}
+	if o.iterations <= 0 {
+		return nil, fmt.Errorf("invalid number of iterations: %d", o.iterations)
+	}
+
+	h, err := host.New(ctx, data, o.hostOpts...)
+	if err != nil {
+		return nil, err
+	}
+	defer h.Close(ctx)
+
+	if err := h.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	requests := make([]*host.HTTPRequest, len(workload))
+	responses := make([]*host.HTTPResponse, len(workload))
+	for i := range workload {
+		requests[i] = workload[i].Request.HTTPRequest()
+		responses[i] = workload[i].Response.HTTPResponse()
+	}
+
+	for i := 0; i < o.warmup; i++ {
+		j := i % len(workload)
+		if _, err := h.HTTP(ctx, requests[j], responses[j]); err != nil {
+			return nil, fmt.Errorf("when warming up: %w", err)
+		}
+		h.ResetHistory()
+	}
+
+	report := &Report{
+		Digest:     fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+		Date:       time.Now().UTC(),
+		Iterations: o.iterations,
+		Callbacks:  map[string]Stats{},
+		Memory:     Memory{Initial: h.MemorySize()},
+	}
+	allocations, allocatedBytes := h.Allocations()
+
+	total := make([]time.Duration, 0, o.iterations)
+	callbacks := map[string][]time.Duration{}
+	for i := 0; i < o.iterations; i++ {
+		j := i % len(workload)
+		res, err := h.HTTP(ctx, requests[j], responses[j])
+		if err != nil {
+			return nil, fmt.Errorf("in iteration %d: %w", i, err)
+		}
+		if res.LocalResponse != nil {
+			report.LocalResponses++
+		}
+
+		var d time.Duration
+		for _, c := range res.Callbacks {
+			callbacks[c.Name] = append(callbacks[c.Name], c.Duration)
+			d += c.Duration
+		}
+		total = append(total, d)
+		h.ResetHistory()
+	}
+
+	report.Request = newStats(total)
+	for name, durations := range callbacks {
+		report.Callbacks[name] = newStats(durations)
+	}
+
+	report.Memory.Final = h.MemorySize()
+	report.Memory.Growth = report.Memory.Final - report.Memory.Initial
+
+	n, b := h.Allocations()
+	report.Allocations = Allocations{
+		Count:      n - allocations,
+		Bytes:      b - allocatedBytes,
+		PerRequest: float64(n-allocations) / float64(o.iterations),
+	}
+
+	return report, nil
+}
@@ -0,0 +1,201 @@
+package bench
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ms returns some durations in milliseconds
+func ms(values ...int) []time.Duration {
+	res := make([]time.Duration, 0, len(values))
+	for _, v := range values {
+		res = append(res, time.Duration(v)*time.Millisecond)
+	}
+	return res
+}
+
+// sequence returns the durations from 1 to n milliseconds, in reverse order
+func sequence(n int) []time.Duration {
+	res := make([]time.Duration, 0, n)
+	for i := n; i > 0; i-- {
+		res = append(res, time.Duration(i)*time.Millisecond)
+	}
+	return res
+}
+
+func TestNewStats(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		durations []time.Duration
+		expected  Stats
+	}{
+		"no durations": {durations: []time.Duration{}, expected: Stats{}},
+		"one duration": {
+			durations: ms(7),
+			expected:  Stats{Count: 1, Mean: 7 * time.Millisecond, P50: 7 * time.Millisecond, P90: 7 * time.Millisecond, P99: 7 * time.Millisecond, Max: 7 * time.Millisecond},
+		},
+		"three durations, unsorted": {
+			durations: ms(3, 1, 2),
+			expected:  Stats{Count: 3, Mean: 2 * time.Millisecond, P50: 2 * time.Millisecond, P90: 3 * time.Millisecond, P99: 3 * time.Millisecond, Max: 3 * time.Millisecond},
+		},
+		"ten durations": {
+			durations: sequence(10),
+			expected: Stats{Count: 10, Mean: 5500 * time.Microsecond,
+				P50: 5 * time.Millisecond, P90: 9 * time.Millisecond, P99: 10 * time.Millisecond, Max: 10 * time.Millisecond},
+		},
+		"hundred durations": {
+			durations: sequence(100),
+			expected: Stats{Count: 100, Mean: 50500 * time.Microsecond,
+				P50: 50 * time.Millisecond, P90: 90 * time.Millisecond, P99: 99 * time.Millisecond, Max: 100 * time.Millisecond},
+		},
+		"outlier": {
+			durations: append(ms(1, 1, 1, 1, 1, 1, 1, 1, 1), time.Second),
+			expected: Stats{Count: 10, Mean: 100900 * time.Microsecond,
+				P50: time.Millisecond, P90: time.Millisecond, P99: time.Second, Max: time.Second},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			original := append([]time.Duration{}, tCase.durations...)
+			assert.Equal(t, tCase.expected, newStats(tCase.durations))
+			// the durations are not sorted in place
+			assert.Equal(t, original, tCase.durations)
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	report := func(p50, p99 time.Duration, callbacks map[string]Stats, memory uint32, perRequest float64) *Report {
+		return &Report{
+			Request:     Stats{P50: p50, P99: p99},
+			Callbacks:   callbacks,
+			Memory:      Memory{Final: memory},
+			Allocations: Allocations{PerRequest: perRequest},
+		}
+	}
+	baseline := report(100*time.Microsecond, 200*time.Microsecond,
+		map[string]Stats{"proxy_on_request_headers": {P50: 50 * time.Microsecond, P99: 100 * time.Microsecond}},
+		65536, 2)
+
+	for name, tCase := range map[string]struct {
+		baseline      *Report
+		current       *Report
+		maxRegression float64
+		expected      []Regression
+	}{
+		"same": {
+			baseline:      baseline,
+			current:       baseline,
+			maxRegression: DefaultMaxRegression,
+		},
+		"better": {
+			baseline:      baseline,
+			current:       report(50*time.Microsecond, 100*time.Microsecond, nil, 1024, 1),
+			maxRegression: DefaultMaxRegression,
+		},
+		"within the limit": {
+			baseline: baseline,
+			current: report(110*time.Microsecond, 220*time.Microsecond,
+				map[string]Stats{"proxy_on_request_headers": {P50: 55 * time.Microsecond, P99: 110 * time.Microsecond}},
+				72089, 2.1),
+			maxRegression: DefaultMaxRegression,
+		},
+		"regressions": {
+			baseline: baseline,
+			current: report(150*time.Microsecond, 200*time.Microsecond,
+				map[string]Stats{"proxy_on_request_headers": {P50: 50 * time.Microsecond, P99: 200 * time.Microsecond}},
+				131072, 3),
+			maxRegression: DefaultMaxRegression,
+			expected: []Regression{
+				{Metric: "request.p50", Unit: "us", Baseline: 100, Current: 150, Change: 50},
+				{Metric: "proxy_on_request_headers.p99", Unit: "us", Baseline: 100, Current: 200, Change: 100},
+				{Metric: "memory.final", Unit: "bytes", Baseline: 65536, Current: 131072, Change: 100},
+				{Metric: "allocations.perRequest", Baseline: 2, Current: 3, Change: 50},
+			},
+		},
+		"zero limit": {
+			baseline:      baseline,
+			current:       report(101*time.Microsecond, 200*time.Microsecond, nil, 65536, 2),
+			maxRegression: 0,
+			expected: []Regression{
+				{Metric: "request.p50", Unit: "us", Baseline: 100, Current: 101, Change: 1},
+			},
+		},
+		"zero baseline": {
+			baseline:      report(0, 0, nil, 0, 0),
+			current:       report(0, 10*time.Microsecond, nil, 0, 1),
+			maxRegression: DefaultMaxRegression,
+			expected: []Regression{
+				{Metric: "request.p99", Unit: "us", Baseline: 0, Current: 10, Change: math.Inf(1)},
+				{Metric: "allocations.perRequest", Baseline: 0, Current: 1, Change: math.Inf(1)},
+			},
+		},
+		"callbacks missing from the baseline": {
+			baseline: report(100*time.Microsecond, 200*time.Microsecond, nil, 65536, 2),
+			current: report(100*time.Microsecond, 200*time.Microsecond,
+				map[string]Stats{"proxy_on_response_headers": {P50: time.Second, P99: time.Second}},
+				65536, 2),
+			maxRegression: DefaultMaxRegression,
+		},
+		"callbacks missing from the current report": {
+			baseline:      baseline,
+			current:       report(100*time.Microsecond, 200*time.Microsecond, nil, 65536, 2),
+			maxRegression: DefaultMaxRegression,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			res := Compare(tCase.baseline, tCase.current, tCase.maxRegression)
+			require.Len(t, res, len(tCase.expected))
+			for i, expected := range tCase.expected {
+				assert.Equal(t, expected.Metric, res[i].Metric)
+				assert.Equal(t, expected.Unit, res[i].Unit)
+				assert.Equal(t, expected.Baseline, res[i].Baseline)
+				assert.Equal(t, expected.Current, res[i].Current)
+				assert.InDelta(t, expected.Change, res[i].Change, 1e-9)
+			}
+		})
+	}
+}
+
+func TestRegressionString(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		regression Regression
+		expected   string
+	}{
+		"latency": {
+			regression: Regression{Metric: "request.p50", Unit: "us", Baseline: 100, Current: 150.5, Change: 50.5},
+			expected:   "request.p50: 100 us -> 150.5 us (+50.5%)",
+		},
+		"no unit": {
+			regression: Regression{Metric: "allocations.perRequest", Baseline: 2, Current: 3, Change: 50},
+			expected:   "allocations.perRequest: 2 -> 3 (+50.0%)",
+		},
+		"zero baseline": {
+			regression: Regression{Metric: "memory.final", Unit: "bytes", Baseline: 0, Current: 65536, Change: math.Inf(1)},
+			expected:   "memory.final: 0 bytes -> 65536 bytes",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.regression.String())
+		})
+	}
+}
+
+func TestRegressionMarshalJSON(t *testing.T) {
+	// JSON has no infinite numbers, so the largest number is used instead
+	data, err := json.Marshal([]Regression{
+		{Metric: "memory.final", Unit: "bytes", Baseline: 0, Current: 65536, Change: math.Inf(1)},
+		{Metric: "request.p50", Unit: "us", Baseline: 100, Current: 150, Change: 50},
+	})
+	require.NoError(t, err)
+
+	var res []Regression
+	require.NoError(t, json.Unmarshal(data, &res))
+	require.Len(t, res, 2)
+	assert.Equal(t, math.MaxFloat64, res[0].Change)
+	assert.Equal(t, 50.0, res[1].Change)
+	assert.Equal(t, "memory.final", res[0].Metric)
+}
@@ -0,0 +1,109 @@
+package bench
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DefaultMaxRegression is the default maximum increase (in percent) that is not a regression
+const DefaultMaxRegression = 10.0
+
+// Regression is a metric that is worse than in the baseline
+type Regression struct {
+	Metric string `json:"metric"`
+	// Unit is the unit of the values ("us" for latencies, "bytes" for the memory)
+	Unit     string  `json:"unit,omitempty"`
+	Baseline float64 `json:"baseline"`
+	Current  float64 `json:"current"`
+	// Change is the increase, in percent (+Inf when the baseline is zero)
+	Change float64 `json:"change"`
+}
+
+func (r Regression) String() string {
+	s := fmt.Sprintf("%s: %s -> %s", r.Metric, r.format(r.Baseline), r.format(r.Current))
+	if !math.IsInf(r.Change, 1) {
+		s += fmt.Sprintf(" (+%.1f%%)", r.Change)
+	}
+	return s
+}
+
+// format formats a value with its unit
+func (r Regression) format(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if r.Unit != "" {
+		s += " " + r.Unit
+	}
+	return s
+}
+
+// MarshalJSON implements json.Marshaler, as JSON has no infinite numbers
+func (r Regression) MarshalJSON() ([]byte, error) {
+	type regression Regression
+	if math.IsInf(r.Change, 1) {
+		r.Change = math.MaxFloat64
+	}
+	return json.Marshal(regression(r))
+}
+
+// LoadReport loads a report written as JSON (e.g. the report of a previous version)
+func LoadReport(filename string) (*Report, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &Report{}
+	if err := json.Unmarshal(data, r); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+	return r, nil
+}
+
+// Compare compares a report with a baseline, returning the metrics that increased more than
+// some percentage: the median and 99th percentile latencies of the requests and every callback,
+// the final size of the memory and the allocations per request.
+func Compare(baseline, current *Report, maxRegression float64) []Regression {
+	var res []Regression
+	check := func(metric, unit string, base, cur float64) {
+		switch {
+		case cur <= base:
+		case base == 0:
+			res = append(res, Regression{Metric: metric, Unit: unit, Baseline: base, Current: cur, Change: math.Inf(1)})
+		default:
+			if change := 100 * (cur - base) / base; change > maxRegression {
+				res = append(res, Regression{Metric: metric, Unit: unit, Baseline: base, Current: cur, Change: change})
+			}
+		}
+	}
+	checkStats := func(name string, base, cur Stats) {
+		check(name+".p50", "us", micros(base.P50), micros(cur.P50))
+		check(name+".p99", "us", micros(base.P99), micros(cur.P99))
+	}
+
+	checkStats("request", baseline.Request, current.Request)
+
+	names := make([]string, 0, len(current.Callbacks))
+	for name := range current.Callbacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if base, ok := baseline.Callbacks[name]; ok {
+			checkStats(name, base, current.Callbacks[name])
+		}
+	}
+
+	check("memory.final", "bytes", float64(baseline.Memory.Final), float64(current.Memory.Final))
+	check("allocations.perRequest", "", baseline.Allocations.PerRequest, current.Allocations.PerRequest)
+
+	return res
+}
+
+// micros converts a duration to microseconds, for comparing and showing latencies
+func micros(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
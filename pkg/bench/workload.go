@@ -0,0 +1,65 @@
+package bench
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// Workload is the list of requests (and responses) sent to the extension, in a loop
+type Workload []host.ExchangeSpec
+
+// LoadWorkload loads a workload from a YAML (or JSON) file with a list of requests
+// and responses, like the ones in the tests of 'pwo test'
+func LoadWorkload(filename string) (Workload, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var w Workload
+	if err := yaml.UnmarshalStrict(data, &w); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+	if len(w) == 0 {
+		return nil, fmt.Errorf("%s: no requests", filename)
+	}
+	return w, nil
+}
+
+// GenerateWorkload generates a workload with a single POST request with some headers
+// and a JSON body of some size, and a response with the same body
+func GenerateWorkload(numHeaders, bodySize int) Workload {
+	headers := map[string]string{"content-type": "application/json"}
+	for i := 0; i < numHeaders; i++ {
+		headers[fmt.Sprintf("x-bench-%d", i)] = fmt.Sprintf("value-%d", i)
+	}
+
+	body := ""
+	if bodySize > 0 {
+		// a valid JSON document with the requested size (at least the size of the smallest one)
+		const prefix, suffix = `{"id":"bench","data":"`, `"}`
+		padding := bodySize - len(prefix) - len(suffix)
+		if padding < 0 {
+			padding = 0
+		}
+		body = prefix + strings.Repeat("x", padding) + suffix
+	}
+
+	return Workload{{
+		Request: host.RequestSpec{
+			Method:  "POST",
+			Path:    "/bench",
+			Headers: headers,
+			Body:    body,
+		},
+		Response: host.ResponseSpec{
+			Headers: map[string]string{"content-type": "application/json"},
+			Body:    body,
+		},
+	}}
+}
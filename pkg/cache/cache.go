@@ -0,0 +1,455 @@
+package cache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const (
+	// indexFilename is the name of the file (in the cache directory) where
+	// the cache index is stored.
+	indexFilename = "index.json"
+
+	// blobsDirname is the directory (in the cache directory) where the
+	// Wasm modules are stored, by digest.
+	blobsDirname = "blobs"
+
+	// digestAlgorithm is the algorithm used for computing digests.
+	digestAlgorithm = "sha256"
+
+	// accessFlushInterval is the minimum time between two writes of the index
+	// only for saving the last access times of the entries.
+	accessFlushInterval = time.Minute
+)
+
+// DefaultPath returns the path of the local cache used by the command line tools.
+func DefaultPath() string {
+	return config.CachePath("wasm")
+}
+
+// Entry describes a Proxy-Wasm extension stored in the cache.
+type Entry struct {
+	// Ref is the resolved reference (with an explicit tag) the extension was downloaded from.
+	Ref string `json:"ref"`
+	// Digest is the digest of the Wasm module (e.g. "sha256:abcd...").
+	Digest string `json:"digest"`
+	// Size is the size of the Wasm module, in bytes.
+	Size int64 `json:"size"`
+	// CreatedAt is the time the extension was added to the cache.
+	CreatedAt time.Time `json:"createdAt"`
+	// LastAccess is the last time the extension was obtained from the cache.
+	LastAccess time.Time `json:"lastAccess"`
+	// Stale is set when the entry is returned because the registry was unavailable.
+	// It is never stored in the cache.
+	Stale bool `json:"stale,omitempty"`
+}
+
+// Resolution records the reference a floating reference (a reference without
+// a tag, or with a semver constraint) was resolved to.
+type Resolution struct {
+	// Ref is the floating reference, as requested.
+	Ref string `json:"ref"`
+	// Constraint is the version constraint used for resolving the reference.
+	Constraint string `json:"constraint,omitempty"`
+	// Resolved is the reference (with an explicit tag) it was resolved to.
+	Resolved string `json:"resolved"`
+	// ResolvedAt is the last time the reference was resolved.
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+// index is the on-disk representation of the cache contents.
+type index struct {
+	Entries     map[string]*Entry      `json:"entries"`
+	Resolutions map[string]*Resolution `json:"resolutions"`
+}
+
+// Cache is a local, file-based cache of Proxy-Wasm extensions.
+//
+// Wasm modules are stored by digest, and an index maps the references
+// they were downloaded from to those modules.
+type Cache struct {
+	dir string
+	log *zap.Logger
+	mu  sync.Mutex
+
+	// accessed are the last access times not saved in the index yet, by reference.
+	accessed map[string]time.Time
+	// flushed is the last time the index was saved.
+	flushed time.Time
+}
+
+// CacheOpt is an option for a cache.
+type CacheOpt func(*Cache)
+
+// WithLogger sets the logger used for reporting errors that cannot be returned,
+// like the errors saving the last access times.
+func WithLogger(log *zap.Logger) CacheOpt {
+	return func(c *Cache) {
+		c.log = log
+	}
+}
+
+// New creates a new cache in the given directory.
+// The directory is created when the first extension is stored.
+func New(dir string, opts ...CacheOpt) *Cache {
+	c := &Cache{
+		dir:      dir,
+		log:      zap.NewNop(),
+		accessed: map[string]time.Time{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Dir returns the directory used by the cache.
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Path returns the path of the Wasm module for a cache entry.
+func (c *Cache) Path(e *Entry) string {
+	algo, hexDigest, _ := strings.Cut(e.Digest, ":")
+	return filepath.Join(c.dir, blobsDirname, algo, hexDigest)
+}
+
+// Get returns the cache entry for a resolved reference, updating the
+// last access time.
+//
+// Access times are kept in memory, and saved in the index with the next change
+// in the cache, with Flush, or at most once every minute by Get.
+func (c *Cache) Get(ref string) (*Entry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, false
+	}
+
+	e, ok := idx.Entries[ref]
+	if !ok {
+		return nil, false
+	}
+	if !utils.IsFileExists(c.Path(e)) {
+		return nil, false
+	}
+
+	now := time.Now()
+	e.LastAccess = now
+	c.accessed[ref] = now
+	if now.Sub(c.flushed) >= accessFlushInterval {
+		if err := c.save(idx); err != nil {
+			c.log.Warn("could not save last access times in cache index", zap.Error(err))
+		}
+	}
+
+	res := *e
+	return &res, true
+}
+
+// Put stores the Wasm module downloaded from a resolved reference.
+func (c *Cache) Put(ref string, data []byte) (*Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	sum := sha256.Sum256(data)
+	now := time.Now()
+	e := &Entry{
+		Ref:        ref,
+		Digest:     fmt.Sprintf("%s:%s", digestAlgorithm, hex.EncodeToString(sum[:])),
+		Size:       int64(len(data)),
+		CreatedAt:  now,
+		LastAccess: now,
+	}
+
+	blob := c.Path(e)
+	if !utils.IsFileExists(blob) {
+		if err := os.MkdirAll(filepath.Dir(blob), 0o755); err != nil {
+			return nil, fmt.Errorf("when creating cache directory: %w", err)
+		}
+		if err := utils.AtomicWriteFile(blob, bytes.NewReader(data), 0o644); err != nil {
+			return nil, fmt.Errorf("when storing %s in cache: %w", ref, err)
+		}
+	}
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+	idx.Entries[ref] = e
+	if err := c.save(idx); err != nil {
+		return nil, err
+	}
+
+	res := *e
+	return &res, nil
+}
+
+// List returns all the entries in the cache, sorted by reference.
+func (c *Cache) List() ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Entry, 0, len(idx.Entries))
+	for _, e := range idx.Entries {
+		res = append(res, *e)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Ref < res[j].Ref })
+
+	return res, nil
+}
+
+// Purge removes from the cache all the entries matching the given reference
+// or digest, returning the removed entries.
+//
+// A reference without a tag (e.g. "oci://myregistry.com/myrepo") matches all
+// the entries for that repository. An empty reference and digest purges everything.
+// Resolutions of floating references leading to removed entries are forgotten too.
+func (c *Cache) Purge(ref, digest string) ([]Entry, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Entry
+	for k, e := range idx.Entries {
+		if !matches(e, ref, digest) {
+			continue
+		}
+		removed = append(removed, *e)
+		delete(idx.Entries, k)
+	}
+
+	for k, r := range idx.Resolutions {
+		if _, ok := idx.Entries[r.Resolved]; !ok || (ref != "" && r.Ref == ref) {
+			delete(idx.Resolutions, k)
+		}
+	}
+
+	if err := c.save(idx); err != nil {
+		return nil, err
+	}
+
+	// remove the blobs that are not referenced anymore
+	referenced := map[string]bool{}
+	for _, e := range idx.Entries {
+		referenced[e.Digest] = true
+	}
+	for i := range removed {
+		if referenced[removed[i].Digest] {
+			continue
+		}
+		if err := os.Remove(c.Path(&removed[i])); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+	}
+
+	sort.Slice(removed, func(i, j int) bool { return removed[i].Ref < removed[j].Ref })
+
+	return removed, nil
+}
+
+// GetResolution returns the last resolution of a floating reference.
+func (c *Cache) GetResolution(ref, constraint string) (*Resolution, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, false
+	}
+
+	r, ok := idx.Resolutions[resolutionKey(ref, constraint)]
+	if !ok {
+		return nil, false
+	}
+
+	res := *r
+	return &res, true
+}
+
+// PutResolution records the resolution of a floating reference.
+func (c *Cache) PutResolution(ref, constraint, resolved string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return err
+	}
+
+	idx.Resolutions[resolutionKey(ref, constraint)] = &Resolution{
+		Ref:        ref,
+		Constraint: constraint,
+		Resolved:   resolved,
+		ResolvedAt: time.Now(),
+	}
+
+	return c.save(idx)
+}
+
+// Resolutions returns all the resolutions of floating references recorded in the cache.
+func (c *Cache) Resolutions() ([]Resolution, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]Resolution, 0, len(idx.Resolutions))
+	for _, r := range idx.Resolutions {
+		res = append(res, *r)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Ref < res[j].Ref })
+
+	return res, nil
+}
+
+// ForgetResolutions removes all the resolutions recorded for a floating reference,
+// whatever the constraint used, returning the removed resolutions.
+func (c *Cache) ForgetResolutions(ref string) ([]Resolution, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	idx, err := c.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Resolution
+	for k, r := range idx.Resolutions {
+		if r.Ref == ref {
+			removed = append(removed, *r)
+			delete(idx.Resolutions, k)
+		}
+	}
+
+	return removed, c.save(idx)
+}
+
+// Flush saves in the index the last access times kept in memory.
+func (c *Cache) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.accessed) == 0 {
+		return nil
+	}
+	idx, err := c.load()
+	if err != nil {
+		return err
+	}
+	return c.save(idx)
+}
+
+// load reads the index from disk, with the access times kept in memory.
+// A missing index is an empty one.
+func (c *Cache) load() (*index, error) {
+	idx := &index{
+		Entries:     map[string]*Entry{},
+		Resolutions: map[string]*Resolution{},
+	}
+
+	data, err := os.ReadFile(filepath.Join(c.dir, indexFilename))
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("when reading cache index: %w", err)
+	}
+
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("when parsing cache index: %w", err)
+	}
+	if idx.Entries == nil {
+		idx.Entries = map[string]*Entry{}
+	}
+	if idx.Resolutions == nil {
+		idx.Resolutions = map[string]*Resolution{}
+	}
+	for ref, t := range c.accessed {
+		if e, ok := idx.Entries[ref]; ok && t.After(e.LastAccess) {
+			e.LastAccess = t
+		}
+	}
+
+	return idx, nil
+}
+
+// save writes the index to disk, forgetting the access times kept in memory
+// (as they are in the index).
+func (c *Cache) save(idx *index) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("when creating cache directory: %w", err)
+	}
+
+	if err := utils.AtomicWriteFile(filepath.Join(c.dir, indexFilename), bytes.NewReader(data), 0o644); err != nil {
+		return err
+	}
+	c.accessed = map[string]time.Time{}
+	c.flushed = time.Now()
+	return nil
+}
+
+// resolutionKey returns the key used in the index for a floating reference.
+func resolutionKey(ref, constraint string) string {
+	if constraint == "" {
+		return ref
+	}
+	return ref + "@" + constraint
+}
+
+// matches returns true if an entry matches a reference and/or a digest.
+func matches(e *Entry, ref, digest string) bool {
+	if digest != "" && e.Digest != digest {
+		return false
+	}
+	if ref == "" || e.Ref == ref {
+		return true
+	}
+
+	// a reference without a tag matches all the tags in the repository
+	repo, _ := SplitTag(e.Ref)
+	return repo == ref
+}
+
+// SplitTag splits a reference like "oci://myregistry.com/myrepo:1.0.0" in
+// the repository and the tag. The tag is empty when not present.
+func SplitTag(ref string) (string, string) {
+	idx := strings.LastIndexByte(ref, ':')
+	if idx < 0 || strings.Contains(ref[idx+1:], "/") {
+		return ref, ""
+	}
+	return ref[:idx], ref[idx+1:]
+}
@@ -0,0 +1,166 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitTag(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		ref          string
+		expectedRepo string
+		expectedTag  string
+	}{
+		"with tag":             {ref: "oci://myregistry.com/myrepo:1.0.0", expectedRepo: "oci://myregistry.com/myrepo", expectedTag: "1.0.0"},
+		"without tag":          {ref: "oci://myregistry.com/myrepo", expectedRepo: "oci://myregistry.com/myrepo"},
+		"port without tag":     {ref: "oci://myregistry.com:5000/myrepo", expectedRepo: "oci://myregistry.com:5000/myrepo"},
+		"port and tag":         {ref: "oci://myregistry.com:5000/myrepo:latest", expectedRepo: "oci://myregistry.com:5000/myrepo", expectedTag: "latest"},
+		"no scheme":            {ref: "myrepo:1.0.0", expectedRepo: "myrepo", expectedTag: "1.0.0"},
+		"oci layout directory": {ref: "oci-layout:///tmp/layout:1.0.0", expectedRepo: "oci-layout:///tmp/layout", expectedTag: "1.0.0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			repo, tag := SplitTag(tCase.ref)
+			assert.Equal(t, tCase.expectedRepo, repo)
+			assert.Equal(t, tCase.expectedTag, tag)
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	c := New(t.TempDir())
+
+	_, ok := c.Get("oci://myregistry.com/myrepo:1.0.0")
+	assert.False(t, ok)
+
+	put, err := c.Put("oci://myregistry.com/myrepo:1.0.0", []byte("module"))
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte("module"))
+	assert.Equal(t, "sha256:"+hex.EncodeToString(sum[:]), put.Digest)
+	assert.EqualValues(t, len("module"), put.Size)
+
+	e, ok := c.Get("oci://myregistry.com/myrepo:1.0.0")
+	require.True(t, ok)
+	assert.Equal(t, put.Digest, e.Digest)
+	assert.False(t, e.LastAccess.Before(put.LastAccess))
+
+	data, err := os.ReadFile(c.Path(e))
+	require.NoError(t, err)
+	assert.Equal(t, "module", string(data))
+
+	// entries whose module has been removed are not returned
+	require.NoError(t, os.Remove(c.Path(e)))
+	_, ok = c.Get("oci://myregistry.com/myrepo:1.0.0")
+	assert.False(t, ok)
+}
+
+func TestGetAccessTimes(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir)
+
+	_, err := c.Put("oci://myregistry.com/myrepo:1.0.0", []byte("module"))
+	require.NoError(t, err)
+
+	// the access times are kept in memory until the cache is flushed
+	e, ok := c.Get("oci://myregistry.com/myrepo:1.0.0")
+	require.True(t, ok)
+	entries, err := New(dir).List()
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.True(t, entries[0].LastAccess.Before(e.LastAccess))
+
+	entries, err = c.List()
+	require.NoError(t, err)
+	assert.True(t, entries[0].LastAccess.Equal(e.LastAccess))
+
+	require.NoError(t, c.Flush())
+	entries, err = New(dir).List()
+	require.NoError(t, err)
+	assert.True(t, entries[0].LastAccess.Equal(e.LastAccess))
+}
+
+func TestPurge(t *testing.T) {
+	refs := []string{
+		"oci://myregistry.com/myrepo:1.0.0",
+		"oci://myregistry.com/myrepo:1.1.0",
+		"oci://myregistry.com/other:1.0.0",
+	}
+
+	for name, tCase := range map[string]struct {
+		ref            string
+		digest         string
+		expectedPurged []string
+	}{
+		"everything":              {expectedPurged: refs},
+		"reference with tag":      {ref: refs[1], expectedPurged: refs[1:2]},
+		"repository":              {ref: "oci://myregistry.com/myrepo", expectedPurged: refs[:2]},
+		"digest":                  {digest: "digest-of-module-1", expectedPurged: []string{refs[0], refs[2]}},
+		"reference and digest":    {ref: "oci://myregistry.com/myrepo", digest: "digest-of-module-1", expectedPurged: refs[:1]},
+		"unknown reference":       {ref: "oci://myregistry.com/unknown"},
+		"repository prefix":       {ref: "oci://myregistry.com/my"},
+		"unknown digest":          {digest: "sha256:0000"},
+		"tag of other repository": {ref: "oci://myregistry.com/other:1.1.0"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := New(t.TempDir())
+			digests := map[string]string{}
+			for i, ref := range refs {
+				// the first and the last references have the same module
+				e, err := c.Put(ref, []byte{byte(i % 2)})
+				require.NoError(t, err)
+				digests[fmt.Sprintf("digest-of-module-%d", i%2+1)] = e.Digest
+			}
+			require.NoError(t, c.PutResolution("oci://myregistry.com/myrepo", "~1", refs[1]))
+
+			digest := tCase.digest
+			if d, ok := digests[digest]; ok {
+				digest = d
+			}
+			removed, err := c.Purge(tCase.ref, digest)
+			require.NoError(t, err)
+
+			var purged []string
+			for _, e := range removed {
+				purged = append(purged, e.Ref)
+			}
+			assert.Equal(t, tCase.expectedPurged, purged)
+
+			entries, err := c.List()
+			require.NoError(t, err)
+			assert.Len(t, entries, len(refs)-len(tCase.expectedPurged))
+
+			// the modules are removed only when no entry uses them
+			for _, e := range entries {
+				assert.FileExists(t, c.Path(&e))
+			}
+			for _, e := range removed {
+				used := false
+				for _, left := range entries {
+					used = used || left.Digest == e.Digest
+				}
+				if !used {
+					assert.NoFileExists(t, c.Path(&e))
+				}
+			}
+
+			// and the resolutions of the repository purged, or to removed entries, are forgotten
+			_, ok := c.GetResolution("oci://myregistry.com/myrepo", "~1")
+			forgotten := tCase.ref == "oci://myregistry.com/myrepo" || contains(tCase.expectedPurged, refs[1])
+			assert.Equal(t, !forgotten, ok)
+		})
+	}
+}
+
+func contains(list []string, s string) bool {
+	for _, e := range list {
+		if e == s {
+			return true
+		}
+	}
+	return false
+}
@@ -62,6 +62,8 @@ type Metadata struct {
 	Annotations map[string]string `json:"annotations,omitempty"`
 	// Specifies the WASM extension type.
 	Type string `json:"type,omitempty"`
+	// An example plugin configuration, used for checking that the extension starts before publishing it
+	ExampleConfig string `json:"exampleConfig,omitempty"`
 }
 
 func NewMetadataFromFile(filename string) (Metadata, error) {
@@ -5,8 +5,11 @@ import (
 	"os"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/spf13/pflag"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/plugin"
 )
 
 // defaultMaxHistory sets the maximum number of releases to 0: unlimited
@@ -23,25 +26,57 @@ type GlobalSettings struct {
 	// RegistryConfigFilename is the path to the registry config file.
 	RegistryConfigFilename string
 
-	// BurstLimit is the default client-side throttling limit.
+	// BurstLimit is the default client-side throttling limit: the maximum
+	// number of concurrent requests to registries.
 	BurstLimit int
+
+	// PluginsDirectory is the path to the plugins directory.
+	PluginsDirectory string
+
+	// the plugins loaded from PluginsDirectory
+	pluginsMu  sync.Mutex
+	pluginsDir string
+	plugins    []*plugin.Plugin
 }
 
 func New() *GlobalSettings {
 	env := &GlobalSettings{
 		RegistryConfigFilename: envOr("PWO_REGISTRY_CONFIG", ConfigPath("registry/config.json")),
 		BurstLimit:             envIntOr("PWO_BURST_LIMIT", defaultBurstLimit),
+		PluginsDirectory:       envOr("PWO_PLUGINS", DataPath("plugins")),
 	}
 	env.Debug, _ = strconv.ParseBool(os.Getenv("PWO_DEBUG"))
 
 	return env
 }
 
+// Plugins returns the plugins in the PluginsDirectory. The directory is only
+// scanned the first time (or when PluginsDirectory changes).
+func (s *GlobalSettings) Plugins() ([]*plugin.Plugin, error) {
+	s.pluginsMu.Lock()
+	defer s.pluginsMu.Unlock()
+
+	if s.plugins != nil && s.pluginsDir == s.PluginsDirectory {
+		return s.plugins, nil
+	}
+
+	plugins, err := plugin.LoadAll(s.PluginsDirectory)
+	if err != nil {
+		return nil, err
+	}
+	if plugins == nil {
+		plugins = []*plugin.Plugin{}
+	}
+	s.plugins, s.pluginsDir = plugins, s.PluginsDirectory
+	return plugins, nil
+}
+
 // AddFlags binds flags to the given flagset.
 func (s *GlobalSettings) AddFlags(fs *pflag.FlagSet) {
 	fs.BoolVar(&s.Debug, "debug", s.Debug, "enable verbose output")
 	fs.StringVar(&s.RegistryConfigFilename, "registry-config", s.RegistryConfigFilename, "path to the registry config file")
-	fs.IntVar(&s.BurstLimit, "burst-limit", s.BurstLimit, "client-side default throttling limit")
+	fs.IntVar(&s.BurstLimit, "burst-limit", s.BurstLimit, "client-side default throttling limit (maximum number of concurrent requests to registries)")
+	fs.StringVar(&s.PluginsDirectory, "plugins-dir", s.PluginsDirectory, "path to the plugins directory")
 }
 
 func envOr(name, def string) string {
@@ -92,6 +127,7 @@ func (s *GlobalSettings) EnvVars() map[string]string {
 		"PWO_DEBUG":           fmt.Sprint(s.Debug),
 		"PWO_REGISTRY_CONFIG": s.RegistryConfigFilename,
 		"PWO_BURST_LIMIT":     strconv.Itoa(s.BurstLimit),
+		"PWO_PLUGINS":         s.PluginsDirectory,
 	}
 	return envvars
 }
@@ -0,0 +1,51 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/plugin"
+)
+
+func writePlugin(t *testing.T, dir, name string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0o755))
+	md := "name: " + name + "\ncommand: ./" + name + "\ngetters: [" + name + "]\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name, plugin.MetadataFilename), []byte(md), 0o644))
+}
+
+func TestPlugins(t *testing.T) {
+	dir := t.TempDir()
+	writePlugin(t, dir, "first")
+	s := &GlobalSettings{PluginsDirectory: dir}
+
+	plugins, err := s.Plugins()
+	require.NoError(t, err)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "first", plugins[0].Metadata.Name)
+
+	// the directory is not scanned again
+	writePlugin(t, dir, "second")
+	plugins, err = s.Plugins()
+	require.NoError(t, err)
+	assert.Len(t, plugins, 1)
+
+	// unless it changes
+	other := t.TempDir()
+	writePlugin(t, other, "third")
+	s.PluginsDirectory = other
+	plugins, err = s.Plugins()
+	require.NoError(t, err)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "third", plugins[0].Metadata.Name)
+
+	// and a missing directory has no plugins
+	s.PluginsDirectory = filepath.Join(dir, "missing")
+	plugins, err = s.Plugins()
+	require.NoError(t, err)
+	assert.Empty(t, plugins)
+}
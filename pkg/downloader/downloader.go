@@ -1,17 +1,27 @@
 package downloader
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
+	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/pkg/errors"
+	"sigs.k8s.io/yaml"
 
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
 )
 
 // VerificationStrategy describes a strategy for determining whether to verify a chart.
@@ -37,6 +47,15 @@ type Verification string
 // ErrNoOwnerRepo indicates that a given chart URL can't be found in any repos.
 var ErrNoOwnerRepo = errors.New("could not find a repo containing the given URL")
 
+// ErrNotFound is returned by the getters when the requested file does not exist.
+var ErrNotFound = errors.New("not found")
+
+// ErrChecksumMismatch indicates that a Wasm module does not match the expected checksum.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// ErrTooLarge is returned by the getters when the requested file exceeds the maximum size.
+var ErrTooLarge = errors.New("file too large")
+
 // WASMDownloader handles downloading a chart.
 //
 // It is capable of performing verifications on charts as well.
@@ -50,6 +69,32 @@ type WASMDownloader struct {
 	// Options provide parameters to be passed along to the Getter being initialized.
 	Options        []Option
 	RegistryClient *registry.Client
+	// Cache is an optional cache where extensions are stored, and obtained from
+	// when they have already been downloaded.
+	Cache *cache.Cache
+	// ResolveTTL is the time a resolution of a floating reference is reused from the
+	// Cache before resolving it again. Floating references are always resolved when zero.
+	ResolveTTL time.Duration
+	// StaleIfError enables returning the extension a floating reference was last
+	// resolved to from the Cache when the registry is unavailable.
+	StaleIfError bool
+	// Limiter optionally limits the concurrent requests to registries.
+	Limiter Limiter
+	// SHA256 is an optional checksum (in hex) the Wasm module must match.
+	SHA256 string
+	// Sidecars enables writing the metadata, the provenance and the checksum
+	// of the extensions next to them.
+	Sidecars bool
+	// OutputTemplate is an optional template for the name of the downloaded
+	// extensions, relative to the destination directory.
+	OutputTemplate string
+}
+
+// Limiter limits the concurrent requests to registries.
+type Limiter interface {
+	// Acquire waits until a request to a registry host can proceed, returning
+	// a function that must be called when the request is done.
+	Acquire(host string) (func(), error)
 }
 
 // DownloadTo retrieves a WASM extension.
@@ -57,36 +102,319 @@ type WASMDownloader struct {
 // Returns a string path to the location where the file was downloaded and a verification
 // (if provenance was verified), or an error if something bad happened.
 func (c *WASMDownloader) DownloadTo(ref, version, dest string) (string, *Verification, error) {
-	u, err := c.ResolveWASMExtVersion(ref, version)
-	if err != nil {
-		return "", nil, err
+	var u *url.URL
+	var data []byte
+	var pulled *registry.PullResult
+
+	// the cache does not keep the manifests of extensions, so they are always
+	// pulled from the source when they are needed for the sidecars
+	useCache := c.Cache != nil && !(c.Sidecars && IsVersionedRef(ref))
+
+	if useCache {
+		entry, err := c.Fetch(ref, version)
+		if err != nil {
+			return "", nil, err
+		}
+
+		if data, err = os.ReadFile(c.Cache.Path(entry)); err != nil {
+			return "", nil, err
+		}
+		if u, err = url.Parse(entry.Ref); err != nil {
+			return "", nil, err
+		}
+	} else {
+		resolved, err := c.ResolveWASMExtVersion(ref, version)
+		if err != nil {
+			return "", nil, err
+		}
+
+		buf, err := c.get(resolved, WithPullResultFunc(func(r *registry.PullResult) { pulled = r }))
+		if err != nil {
+			return "", nil, err
+		}
+		if err := c.checkSHA256(sha256Digest(buf.Bytes())); err != nil {
+			return "", nil, err
+		}
+		u, data = resolved, buf.Bytes()
 	}
 
-	g, err := c.Getters.ByScheme(u.Scheme)
+	// extensions downloaded from URLs can have their metadata next to them
+	var md *common.Metadata
+	var metadata []byte
+	if IsVersionedRef(u.String()) {
+		// the metadata in the config of the extension is only written as a sidecar
+		if pulled != nil && pulled.WASMExt.Meta != nil {
+			md = pulled.WASMExt.Meta
+			if c.Sidecars {
+				raw, err := yaml.Marshal(md)
+				if err != nil {
+					return "", nil, err
+				}
+				metadata = raw
+			}
+		}
+	} else {
+		var err error
+		if md, metadata, err = c.metadata(u); err != nil {
+			return "", nil, err
+		}
+	}
+
+	verification, err := c.verify(data)
 	if err != nil {
 		return "", nil, err
 	}
 
-	data, err := g.Get(u.String(), c.Options...)
+	name, err := c.outputFilename(u, md, sha256Digest(data))
 	if err != nil {
 		return "", nil, err
 	}
 
-	name := filepath.Base(u.Path)
-	idx := strings.LastIndexByte(name, ':')
-	name = fmt.Sprintf("%s-%s.wasm", name[:idx], name[idx+1:])
-
 	destfile := filepath.Join(dest, name)
-	if err := utils.AtomicWriteFile(destfile, data, 0o644); err != nil {
+	if err := os.MkdirAll(filepath.Dir(destfile), 0o755); err != nil {
+		return destfile, nil, err
+	}
+	if err := utils.AtomicWriteFile(destfile, bytes.NewReader(data), 0o644); err != nil {
 		return destfile, nil, err
 	}
+	if metadata != nil {
+		if err := utils.AtomicWriteFile(metadataFilename(destfile), bytes.NewReader(metadata), 0o644); err != nil {
+			return destfile, nil, err
+		}
+	}
+
+	if c.Sidecars {
+		if err := c.writeSidecars(destfile, ref, u, data, pulled, verification); err != nil {
+			return destfile, nil, err
+		}
+	}
+
+	return destfile, verification, nil
+}
+
+// Fetch retrieves a WASM extension into the Cache, returning the cache entry.
+//
+// Floating references are resolved again when their last resolution is older than
+// the ResolveTTL, and the extension is only downloaded when it is not in the Cache.
+// When StaleIfError is enabled and the registry is unavailable, the extension the
+// reference was last resolved to is returned from the Cache, marked as stale.
+func (c *WASMDownloader) Fetch(ref, version string) (*cache.Entry, error) {
+	return c.fetchOrStale(ref, version, false)
+}
+
+// Refresh ignores any previous resolution of a floating reference, resolving
+// it again and fetching the extension into the Cache.
+func (c *WASMDownloader) Refresh(ref, version string) (*cache.Entry, error) {
+	return c.fetchOrStale(ref, version, true)
+}
+
+func (c *WASMDownloader) fetchOrStale(ref, version string, force bool) (*cache.Entry, error) {
+	if c.Cache == nil {
+		return nil, errors.New("no cache configured")
+	}
+
+	entry, err := c.fetch(ref, version, force)
+	if err != nil && c.StaleIfError && registry.IsUnavailable(err) {
+		if stale, ok := c.stale(ref, version); ok {
+			fmt.Fprintf(c.Out, "WARNING: registry unavailable (%s): using stale %s\n", err, stale.Ref)
+			entry, err = stale, nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// extensions already in the cache must also match the checksum
+	if err := c.checkSHA256(entry.Digest); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (c *WASMDownloader) fetch(ref, version string, force bool) (*cache.Entry, error) {
+	u, resolved, err := c.resolveCached(ref, version, force)
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := c.Cache.Get(u.String())
+	if !ok {
+		data, err := c.get(u)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.checkSHA256(sha256Digest(data.Bytes())); err != nil {
+			return nil, err
+		}
+		if _, err := c.verify(data.Bytes()); err != nil {
+			return nil, err
+		}
+
+		entry, err = c.Cache.Put(u.String(), data.Bytes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// only record resolutions once the extension is in the cache, so
+	// we can always go back to the last successful one
+	if resolved {
+		if err := c.Cache.PutResolution(ref, version, u.String()); err != nil {
+			return nil, err
+		}
+	}
 
-	verification := Verification("")
-	if c.Verify > VerifyNever {
-		// TODO
+	return entry, nil
+}
+
+// stale returns the cache entry a floating reference was last resolved to.
+func (c *WASMDownloader) stale(ref, version string) (*cache.Entry, bool) {
+	if _, errSemVer := semver.NewVersion(version); errSemVer == nil {
+		// explicit versions are always obtained from the cache when present
+		return nil, false
+	}
+
+	r, ok := c.Cache.GetResolution(ref, version)
+	if !ok {
+		return nil, false
+	}
+
+	entry, ok := c.Cache.Get(r.Resolved)
+	if !ok {
+		return nil, false
 	}
+	entry.Stale = true
 
-	return destfile, &verification, nil
+	return entry, true
+}
+
+// resolveCached resolves a reference, reusing the resolution recorded in the Cache
+// when it is not older than the ResolveTTL (unless forced).
+// It returns true when the reference has been resolved against the registry.
+func (c *WASMDownloader) resolveCached(ref, version string, force bool) (*url.URL, bool, error) {
+	if _, errSemVer := semver.NewVersion(version); errSemVer == nil || !IsVersionedRef(ref) {
+		// explicit versions (and URLs) do not need to be resolved
+		u, err := c.ResolveWASMExtVersion(ref, version)
+		return u, false, err
+	}
+
+	if c.ResolveTTL > 0 && !force {
+		if r, ok := c.Cache.GetResolution(ref, version); ok && time.Since(r.ResolvedAt) < c.ResolveTTL {
+			u, err := url.Parse(r.Resolved)
+			return u, false, err
+		}
+	}
+
+	release, err := c.acquire(ref)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+
+	u, err := c.ResolveWASMExtVersion(ref, version)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return u, true, nil
+}
+
+// get downloads the WASM extension for a resolved URL with the appropriate Getter,
+// with some extra options.
+func (c *WASMDownloader) get(u *url.URL, extra ...Option) (*bytes.Buffer, error) {
+	g, err := c.Getters.ByScheme(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+
+	release, err := c.acquire(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	opts := append(append([]Option{}, c.Options...), extra...)
+	return g.Get(u.String(), opts...)
+}
+
+// metadata obtains the (optional) Wasm.yaml next to a Wasm module in a URL,
+// returning nil when there is no metadata.
+func (c *WASMDownloader) metadata(u *url.URL) (*common.Metadata, []byte, error) {
+	mu := *u
+	mu.Path = path.Join(path.Dir(u.Path), "Wasm.yaml")
+	mu.RawPath, mu.RawQuery, mu.Fragment = "", "", ""
+
+	// the progress is only reported for the Wasm module
+	buf, err := c.get(&mu, WithProgressFunc(nil))
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("when obtaining metadata from %s: %w", mu.String(), err)
+	}
+
+	md := &common.Metadata{}
+	if err := yaml.Unmarshal(buf.Bytes(), md); err != nil {
+		return nil, nil, fmt.Errorf("when parsing metadata from %s: %w", mu.String(), err)
+	}
+	if err := md.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid metadata in %s: %w", mu.String(), err)
+	}
+
+	return md, buf.Bytes(), nil
+}
+
+// checkSHA256 checks that a digest (like "sha256:abcd...") matches the expected checksum, if any.
+func (c *WASMDownloader) checkSHA256(digest string) error {
+	if c.SHA256 == "" {
+		return nil
+	}
+
+	algo, hexDigest, _ := strings.Cut(digest, ":")
+	if algo != "sha256" || !strings.EqualFold(hexDigest, c.SHA256) {
+		return fmt.Errorf("%w: expected sha256:%s, got %s", ErrChecksumMismatch, strings.ToLower(c.SHA256), digest)
+	}
+	return nil
+}
+
+// verify checks that a Wasm module is a Proxy-Wasm extension, depending on the
+// verification strategy. It returns nil when the module has not been verified.
+func (c *WASMDownloader) verify(data []byte) (*Verification, error) {
+	if c.Verify == VerifyNever || c.Verify == VerifyLater {
+		return nil, nil
+	}
+
+	_, pw, err := wasm.ValidateProxyWasm(data)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := Verification(fmt.Sprintf("Proxy-Wasm ABI %s", pw.ABIVersion))
+	return &verification, nil
+}
+
+// sha256Digest returns the digest of some data, like "sha256:abcd..."
+func sha256Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// acquire waits for the Limiter (if any) to allow a request to the registry in a reference.
+func (c *WASMDownloader) acquire(ref string) (func(), error) {
+	if c.Limiter == nil {
+		return func() {}, nil
+	}
+
+	u, err := url.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" {
+		// local references (like OCI layout directories) are not limited
+		return func() {}, nil
+	}
+
+	return c.Limiter.Acquire(u.Host)
 }
 
 func (c *WASMDownloader) getOciURI(ref, version string, u *url.URL) (*url.URL, error) {
@@ -99,7 +427,7 @@ func (c *WASMDownloader) getOciURI(ref, version string, u *url.URL) (*url.URL, e
 		tag = version
 	} else {
 		// Retrieve list of repository tags
-		tags, err := c.RegistryClient.Tags(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)))
+		tags, err := c.tags(ref)
 		if err != nil {
 			return nil, err
 		}
@@ -144,13 +472,38 @@ func (c *WASMDownloader) ResolveWASMExtVersion(ref, version string) (*url.URL, e
 		return nil, errors.Errorf("invalid chart URL format: %s", ref)
 	}
 
-	if !registry.IsOCI(u.String()) {
-		return nil, errors.Errorf("invalid URL: not an OCI", ref)
+	if !c.Getters.SupportsRef(u.String()) {
+		return nil, errors.Errorf("invalid URL: scheme not supported: %s", ref)
+	}
+
+	if !IsVersionedRef(u.String()) {
+		// URLs are not versioned
+		return u, nil
 	}
 
 	return c.getOciURI(ref, version, u)
 }
 
+// tags returns the semver tags available for a reference, sorted from the highest version.
+func (c *WASMDownloader) tags(ref string) ([]string, error) {
+	if registry.IsOCILayout(ref) {
+		dir, _, err := registry.ParseLayoutReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		return registry.LayoutTags(dir)
+	}
+
+	return c.RegistryClient.Tags(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)))
+}
+
+// IsVersionedRef returns true for the references with versions (tags) that can be
+// resolved: OCI references and OCI layout directories. Any other reference
+// (like HTTP(S) URLs, or the schemes in plugins) points to a single Wasm module.
+func IsVersionedRef(ref string) bool {
+	return registry.IsOCI(ref) || registry.IsOCILayout(ref)
+}
+
 // isTar tests whether the given file is a tar file.
 //
 // Currently, this simply checks extension, since a subsequent function will
@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+// FileGetter is the backend handler for extensions in the local filesystem
+type FileGetter struct {
+	opts options
+}
+
+// Get reads a file from a file:// URL and returns its contents.
+func (g *FileGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&g.opts)
+	}
+
+	u, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host != "" && u.Host != "localhost" {
+		return nil, fmt.Errorf("remote hosts are not supported in file URLs: %s", href)
+	}
+
+	data, err := os.ReadFile(filepath.FromSlash(u.Path))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, href)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if g.opts.progress != nil {
+		g.opts.progress(int64(len(data)), int64(len(data)))
+	}
+
+	return bytes.NewBuffer(data), nil
+}
+
+// NewFileGetter constructs a Getter for the local filesystem
+func NewFileGetter(ops ...Option) (Getter, error) {
+	var client FileGetter
+
+	for _, opt := range ops {
+		opt(&client.opts)
+	}
+
+	return &client, nil
+}
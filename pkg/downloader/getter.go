@@ -3,6 +3,7 @@ package downloader
 import (
 	"bytes"
 	"net/http"
+	"net/url"
 	"time"
 
 	"github.com/pkg/errors"
@@ -17,8 +18,11 @@ import (
 type options struct {
 	registry.RegistryParams
 
+	maxSize            int64
 	passCredentialsAll bool
 	password           string
+	progress           registry.ProgressFunc
+	pullResult         func(*registry.PullResult)
 	registryClient     *registry.Client
 	timeout            time.Duration
 	transport          *http.Transport
@@ -84,6 +88,13 @@ func WithPlainHTTP(plainHTTP bool) Option {
 	}
 }
 
+// WithMaxAttempts sets the maximum number of attempts for a request to the registry.
+func WithMaxAttempts(attempts int) Option {
+	return func(opts *options) {
+		opts.MaxAttempts = attempts
+	}
+}
+
 // WithTimeout sets the timeout for requests
 func WithTimeout(timeout time.Duration) Option {
 	return func(opts *options) {
@@ -91,6 +102,13 @@ func WithTimeout(timeout time.Duration) Option {
 	}
 }
 
+// WithMaxSize sets the maximum size of the content obtained from a URL (no limit if 0).
+func WithMaxSize(size int64) Option {
+	return func(opts *options) {
+		opts.maxSize = size
+	}
+}
+
 func WithTagName(tagname string) Option {
 	return func(opts *options) {
 		opts.version = tagname
@@ -109,6 +127,21 @@ func WithUntar() Option {
 	}
 }
 
+// WithProgressFunc sets a callback for reporting the progress of the download.
+func WithProgressFunc(progress registry.ProgressFunc) Option {
+	return func(opts *options) {
+		opts.progress = progress
+	}
+}
+
+// WithPullResultFunc sets a callback that receives the result of pulls from
+// registries and OCI layouts, with the manifest and config of the extension.
+func WithPullResultFunc(fn func(*registry.PullResult)) Option {
+	return func(opts *options) {
+		opts.pullResult = fn
+	}
+}
+
 // WithTransport sets the http.Transport to allow overwriting the HTTPGetter default.
 func WithTransport(transport *http.Transport) Option {
 	return func(opts *options) {
@@ -148,6 +181,20 @@ func (p Provider) Provides(scheme string) bool {
 // Providers is a collection of Provider objects.
 type Providers []Provider
 
+// SupportsRef returns true when there is a Provider for the scheme of a reference.
+func (p Providers) SupportsRef(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	for _, pp := range p {
+		if pp.Provides(u.Scheme) {
+			return true
+		}
+	}
+	return false
+}
+
 // ByScheme returns a Provider that handles the given scheme.
 //
 // If no provider handles this scheme, this will return an error.
@@ -165,19 +212,38 @@ const (
 	// https://github.com/curl/curl/blob/master/lib/connect.h#L40C21-L40C21
 	// The helm commands are usually executed manually. Considering the acceptable waiting time, we reduced the entire request time to 120s.
 	DefaultHTTPTimeout = 120
+
+	// DefaultHTTPMaxSize is the maximum size of the content obtained from HTTP(S) URLs.
+	DefaultHTTPMaxSize = 256 * 1024 * 1024
 )
 
-var defaultOptions = []Option{WithTimeout(time.Second * DefaultHTTPTimeout)}
+var defaultOptions = []Option{WithTimeout(time.Second * DefaultHTTPTimeout), WithMaxSize(DefaultHTTPMaxSize)}
 
 var ociProvider = Provider{
 	Schemes: []string{registry.OCIScheme},
 	New:     NewOCIGetter,
 }
 
+var ociLayoutProvider = Provider{
+	Schemes: []string{registry.OCILayoutScheme},
+	New:     NewOCILayoutGetter,
+}
+
+var httpProvider = Provider{
+	Schemes: []string{"http", "https"},
+	New:     NewHTTPGetter,
+}
+
+var fileProvider = Provider{
+	Schemes: []string{"file"},
+	New:     NewFileGetter,
+}
+
 // All finds all of the registered getters as a list of Provider instances.
-// Currently, the built-in getters and the discovered plugins with downloader
-// notations are collected.
+// The built-in getters and the discovered plugins with getters are collected.
 func All(settings *config.GlobalSettings) Providers {
-	result := Providers{ociProvider}
+	result := Providers{ociProvider, ociLayoutProvider, httpProvider, fileProvider}
+	pluginDownloaders, _ := collectPlugins(settings)
+	result = append(result, pluginDownloaders...)
 	return result
 }
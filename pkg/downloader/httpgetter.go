@@ -0,0 +1,127 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/version"
+)
+
+// HTTPGetter is the backend handler for extensions served over HTTP(S),
+// like release assets or artifact servers
+type HTTPGetter struct {
+	opts options
+}
+
+// Get performs a Get from an HTTP(S) URL and returns the body.
+func (g *HTTPGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&g.opts)
+	}
+	return g.get(href)
+}
+
+func (g *HTTPGetter) get(href string) (*bytes.Buffer, error) {
+	req, err := http.NewRequest(http.MethodGet, href, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	userAgent := g.opts.userAgent
+	if userAgent == "" {
+		userAgent = version.GetUserAgent()
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	// only send credentials to the host they were provided for, unless told otherwise
+	if g.opts.username != "" && g.opts.password != "" {
+		sameHost := true
+		if g.opts.url != "" {
+			u1, err := url.Parse(g.opts.url)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse getter URL: %w", err)
+			}
+			u2, err := url.Parse(href)
+			if err != nil {
+				return nil, fmt.Errorf("unable to parse URL getting from: %w", err)
+			}
+			sameHost = u1.Scheme == u2.Scheme && u1.Host == u2.Host
+		}
+		if g.opts.passCredentialsAll || sameHost {
+			req.SetBasicAuth(g.opts.username, g.opts.password)
+		}
+	}
+
+	client, err := g.httpClient(req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, href)
+	case resp.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("failed to fetch %s : %s", href, resp.Status)
+	}
+
+	if g.opts.maxSize > 0 && resp.ContentLength > g.opts.maxSize {
+		return nil, fmt.Errorf("%w: %s is %d bytes (maximum %d)", ErrTooLarge, href, resp.ContentLength, g.opts.maxSize)
+	}
+
+	var body io.Reader = resp.Body
+	if g.opts.progress != nil {
+		g.opts.progress(0, resp.ContentLength)
+		body = &registry.ProgressReader{ReadCloser: resp.Body, Total: resp.ContentLength, Progress: g.opts.progress}
+	}
+
+	if g.opts.maxSize > 0 {
+		// read one more byte, so we know when the limit has been exceeded
+		body = io.LimitReader(body, g.opts.maxSize+1)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if _, err := io.Copy(buf, body); err != nil {
+		return nil, err
+	}
+	if g.opts.maxSize > 0 && int64(buf.Len()) > g.opts.maxSize {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, href, g.opts.maxSize)
+	}
+	return buf, nil
+}
+
+func (g *HTTPGetter) httpClient(serverName string) (*http.Client, error) {
+	transport := g.opts.transport
+	if transport == nil {
+		t, err := registry.NewTransport(g.opts.RegistryParams, serverName)
+		if err != nil {
+			return nil, err
+		}
+		transport = t
+	}
+
+	return &http.Client{
+		Transport: registry.NewRetryTransport(transport, g.opts.MaxAttempts),
+		Timeout:   g.opts.timeout,
+	}, nil
+}
+
+// NewHTTPGetter constructs a valid http/https client as a Getter
+func NewHTTPGetter(ops ...Option) (Getter, error) {
+	var client HTTPGetter
+
+	for _, opt := range append(defaultOptions, ops...) {
+		opt(&client.opts)
+	}
+
+	return &client, nil
+}
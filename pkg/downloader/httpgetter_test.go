@@ -0,0 +1,48 @@
+package downloader
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPGetterMaxSize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("chunked") {
+			// without a Content-Length
+			w.(http.Flusher).Flush()
+		}
+		w.Write([]byte(strings.Repeat("x", 100)))
+	}))
+	defer srv.Close()
+
+	for name, tCase := range map[string]struct {
+		maxSize       int64
+		query         string
+		expectedError bool
+	}{
+		"below the limit":          {maxSize: 101},
+		"at the limit":             {maxSize: 100},
+		"above the limit":          {maxSize: 99, expectedError: true},
+		"above the limit, chunked": {maxSize: 99, query: "?chunked=1", expectedError: true},
+		"at the limit, chunked":    {maxSize: 100, query: "?chunked=1"},
+		"no limit":                 {maxSize: 0},
+	} {
+		t.Run(name, func(t *testing.T) {
+			g, err := NewHTTPGetter(WithMaxSize(tCase.maxSize))
+			require.NoError(t, err)
+
+			buf, err := g.Get(srv.URL + "/myext.wasm" + tCase.query)
+			if tCase.expectedError {
+				assert.ErrorIs(t, err, ErrTooLarge)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 100, buf.Len())
+		})
+	}
+}
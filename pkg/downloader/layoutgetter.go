@@ -0,0 +1,54 @@
+package downloader
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+// OCILayoutGetter is the backend handler for OCI image layout directories
+type OCILayoutGetter struct {
+	opts options
+}
+
+// Get performs a Get from an OCI layout directory and returns the body.
+func (g *OCILayoutGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&g.opts)
+	}
+
+	dir, tag, err := registry.ParseLayoutReference(href)
+	if err != nil {
+		return nil, err
+	}
+	if tag == "" {
+		return nil, fmt.Errorf("no tag in OCI layout reference: %s", href)
+	}
+
+	var pullOpts []registry.PullOption
+	if g.opts.progress != nil {
+		pullOpts = append(pullOpts, registry.PullOptProgress(g.opts.progress))
+	}
+
+	result, err := registry.PullLayout(dir, tag, pullOpts...)
+	if err != nil {
+		return nil, err
+	}
+	if g.opts.pullResult != nil {
+		g.opts.pullResult(result)
+	}
+
+	return bytes.NewBuffer(result.WASMExt.Data), nil
+}
+
+// NewOCILayoutGetter constructs a Getter for OCI layout directories
+func NewOCILayoutGetter(ops ...Option) (Getter, error) {
+	var client OCILayoutGetter
+
+	for _, opt := range ops {
+		opt(&client.opts)
+	}
+
+	return &client, nil
+}
@@ -3,11 +3,8 @@ package downloader
 import (
 	"bytes"
 	"fmt"
-	"net"
 	"net/http"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 	"github.com/inercia/proxy-wasm-oci/pkg/utils"
@@ -15,9 +12,7 @@ import (
 
 // OCIGetter is the default HTTP(/S) backend handler
 type OCIGetter struct {
-	opts      options
-	transport *http.Transport
-	once      sync.Once
+	opts options
 }
 
 // Get performs a Get from repo.Getter and returns the body.
@@ -43,11 +38,17 @@ func (g *OCIGetter) get(href string) (*bytes.Buffer, error) {
 	ref := strings.TrimPrefix(href, fmt.Sprintf("%s://", registry.OCIScheme))
 
 	var pullOpts []registry.PullOption
+	if g.opts.progress != nil {
+		pullOpts = append(pullOpts, registry.PullOptProgress(g.opts.progress))
+	}
 
 	result, err := client.Pull(ref, pullOpts...)
 	if err != nil {
 		return nil, err
 	}
+	if g.opts.pullResult != nil {
+		g.opts.pullResult(result)
+	}
 
 	return bytes.NewBuffer(result.WASMExt.Data), nil
 }
@@ -64,66 +65,23 @@ func NewOCIGetter(ops ...Option) (Getter, error) {
 }
 
 func (g *OCIGetter) newRegistryClient() (*registry.Client, error) {
+	opts := []registry.ClientOption{registry.ClientOptTimeout(g.opts.timeout)}
 	if g.opts.transport != nil {
-		client, err := registry.NewClient(
-			registry.ClientOptHTTPClient(&http.Client{
-				Transport: g.opts.transport,
-				Timeout:   g.opts.timeout,
-			}),
-		)
-		if err != nil {
-			return nil, err
-		}
-		return client, nil
+		opts = append(opts, registry.ClientOptHTTPClient(&http.Client{
+			Transport: g.opts.transport,
+			Timeout:   g.opts.timeout,
+		}))
 	}
-
-	g.once.Do(func() {
-		g.transport = &http.Transport{
-			// From https://github.com/google/go-containerregistry/blob/31786c6cbb82d6ec4fb8eb79cd9387905130534e/pkg/v1/remote/options.go#L87
-			DisableCompression: true,
-			DialContext: (&net.Dialer{
-				// By default we wrap the transport in retries, so reduce the
-				// default dial timeout to 5s to avoid 5x 30s of connection
-				// timeouts when doing the "ping" on certain http registries.
-				Timeout:   5 * time.Second,
-				KeepAlive: 30 * time.Second,
-			}).DialContext,
-			ForceAttemptHTTP2:     true,
-			MaxIdleConns:          100,
-			IdleConnTimeout:       90 * time.Second,
-			TLSHandshakeTimeout:   10 * time.Second,
-			ExpectContinueTimeout: 1 * time.Second,
-		}
-	})
-
-	if (g.opts.CertFile != "" && g.opts.KeyFile != "") || g.opts.CAFile != "" || g.opts.Insecure {
-		tlsConf, err := registry.NewClientTLS(g.opts.RegistryParams)
-		if err != nil {
-			return nil, fmt.Errorf("can't create TLS config for client: %w", err)
-		}
-
+	if g.opts.userAgent != "" {
+		opts = append(opts, registry.ClientOptUserAgent(g.opts.userAgent))
+	}
+	if g.opts.url != "" {
 		sni, err := utils.ExtractHostname(g.opts.url)
 		if err != nil {
 			return nil, err
 		}
-		tlsConf.ServerName = sni
-
-		g.transport.TLSClientConfig = tlsConf
-	}
-
-	opts := []registry.ClientOption{registry.ClientOptHTTPClient(&http.Client{
-		Transport: g.transport,
-		Timeout:   g.opts.timeout,
-	})}
-	if g.opts.PlainHTTP {
-		opts = append(opts, registry.ClientOptPlainHTTP())
-	}
-
-	client, err := registry.NewClient(opts...)
-
-	if err != nil {
-		return nil, err
+		opts = append(opts, registry.ClientOptServerName(sni))
 	}
 
-	return client, nil
+	return registry.NewClientWithParams(g.opts.RegistryParams, "", false, opts...)
 }
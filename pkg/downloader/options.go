@@ -10,6 +10,7 @@ type CommonPullOptions struct {
 
 	Password           string // --password
 	PassCredentialsAll bool   // --pass-credentials
+	SHA256             string // --sha256
 	Username           string // --username
 	Verify             bool   // --verify
 	Version            string // --version
@@ -10,10 +10,11 @@ import (
 
 func AddDownloadFlags(f *pflag.FlagSet, c *CommonPullOptions) {
 	f.StringVar(&c.Version, "version", "", "specify a version constraint for the Proxy-WASM extension version to use. This constraint can be a specific tag (e.g. 1.1.1) or it may reference a valid range (e.g. ^2.0.0). If this is not specified, the latest version is used")
-	f.BoolVar(&c.Verify, "verify", false, "verify the package before using it")
+	f.BoolVar(&c.Verify, "verify", false, "check that the extension is a Proxy-Wasm extension before using it")
 	f.StringVar(&c.Username, "username", "", "repository username where to locate the requested Proxy-WASM Extension")
 	f.StringVar(&c.Password, "password", "", "repository password where to locate the requested Proxy-WASM Extension")
 	f.BoolVar(&c.PassCredentialsAll, "pass-credentials", false, "pass credentials to all domains")
+	f.StringVar(&c.SHA256, "sha256", "", "expected SHA256 checksum (in hex) of the Proxy-WASM extension")
 }
 
 // defaultKeyring returns the expanded path to the default keyring.
@@ -0,0 +1,96 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
+	"github.com/inercia/proxy-wasm-oci/pkg/plugin"
+)
+
+// collectPlugins scans for getter plugins.
+// This will load plugins according to the given settings.
+func collectPlugins(settings *config.GlobalSettings) (Providers, error) {
+	plugins, err := settings.Plugins()
+	if err != nil {
+		return nil, err
+	}
+
+	var result Providers
+	for _, p := range plugins {
+		if len(p.Metadata.Getters) == 0 {
+			continue
+		}
+		result = append(result, Provider{
+			Schemes: p.Metadata.Getters,
+			New:     NewPluginGetter(p, settings),
+		})
+	}
+	return result, nil
+}
+
+// pluginGetter is a generic type to invoke custom getter plugins
+type pluginGetter struct {
+	plugin   *plugin.Plugin
+	settings *config.GlobalSettings
+	opts     options
+}
+
+// Get runs the plugin command for obtaining the file in a URL.
+func (p *pluginGetter) Get(href string, options ...Option) (*bytes.Buffer, error) {
+	for _, opt := range options {
+		opt(&p.opts)
+	}
+
+	req := &plugin.Request{
+		Operation: plugin.OperationGet,
+		URL:       href,
+		Username:  p.opts.username,
+		Password:  p.opts.password,
+		CertFile:  p.opts.CertFile,
+		KeyFile:   p.opts.KeyFile,
+		CAFile:    p.opts.CAFile,
+		Insecure:  p.opts.Insecure,
+		PlainHTTP: p.opts.PlainHTTP,
+	}
+
+	ctx := context.Background()
+	if p.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.timeout)
+		defer cancel()
+	}
+
+	buf := bytes.NewBuffer(nil)
+	out := plugin.NewLimitedWriter(buf, p.opts.maxSize)
+	err := p.plugin.Run(ctx, req, out, p.settings.EnvVars())
+	if out.Exceeded() {
+		return nil, fmt.Errorf("%w: %s is larger than %d bytes", ErrTooLarge, href, p.opts.maxSize)
+	}
+	if err != nil {
+		if errors.Is(err, plugin.ErrNotFound) {
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, href)
+		}
+		return nil, err
+	}
+	if p.opts.progress != nil {
+		p.opts.progress(int64(buf.Len()), int64(buf.Len()))
+	}
+	return buf, nil
+}
+
+// NewPluginGetter constructs a valid plugin getter
+func NewPluginGetter(p *plugin.Plugin, settings *config.GlobalSettings) Constructor {
+	return func(options ...Option) (Getter, error) {
+		result := &pluginGetter{
+			plugin:   p,
+			settings: settings,
+		}
+		for _, opt := range append(defaultOptions, options...) {
+			opt(&result.opts)
+		}
+		return result, nil
+	}
+}
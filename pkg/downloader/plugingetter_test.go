@@ -0,0 +1,55 @@
+package downloader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
+	"github.com/inercia/proxy-wasm-oci/pkg/plugin"
+)
+
+func TestPluginGetter(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins with shell scripts are not supported on Windows")
+	}
+
+	for name, tCase := range map[string]struct {
+		script        string
+		opts          []Option
+		expectedLen   int
+		expectedError error
+	}{
+		"below the limit": {script: `head -c 100 /dev/zero`, opts: []Option{WithMaxSize(101)}, expectedLen: 100},
+		"at the limit":    {script: `head -c 100 /dev/zero`, opts: []Option{WithMaxSize(100)}, expectedLen: 100},
+		"above the limit": {script: `head -c 100 /dev/zero`, opts: []Option{WithMaxSize(99)}, expectedError: ErrTooLarge},
+		"endless output":  {script: `exec yes`, opts: []Option{WithMaxSize(1000)}, expectedError: ErrTooLarge},
+		"not found":       {script: `exit 2`, expectedError: ErrNotFound},
+		"timeout":         {script: `exec sleep 10`, opts: []Option{WithTimeout(100 * time.Millisecond)}, expectedError: context.DeadlineExceeded},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.sh"), []byte("#!/bin/sh\n"+tCase.script+"\n"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, plugin.MetadataFilename), []byte("name: test\ncommand: plugin.sh\ngetters: [test]\n"), 0o644))
+			p, err := plugin.LoadDir(dir)
+			require.NoError(t, err)
+
+			g, err := NewPluginGetter(p, &config.GlobalSettings{})(tCase.opts...)
+			require.NoError(t, err)
+
+			buf, err := g.Get("test://some/myext.wasm")
+			if tCase.expectedError != nil {
+				assert.ErrorIs(t, err, tCase.expectedError)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expectedLen, buf.Len())
+		})
+	}
+}
@@ -2,8 +2,11 @@ package downloader
 
 import (
 	"fmt"
+	"io"
 	"strings"
+	"time"
 
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
 	"github.com/inercia/proxy-wasm-oci/pkg/config"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 )
@@ -17,6 +20,27 @@ type Pull struct {
 	RegistryConfig *registry.Configuration
 
 	DestDir string
+
+	// Cache is an optional cache for the downloaded extensions.
+	Cache *cache.Cache
+
+	// ResolveTTL is the time the resolution of a floating reference is reused from the Cache.
+	ResolveTTL time.Duration
+
+	// StaleIfError enables using stale extensions from the Cache when the registry is unavailable.
+	StaleIfError bool
+
+	// Limiter optionally limits the concurrent requests to registries.
+	Limiter Limiter
+
+	// Progress is an optional callback for reporting the progress of downloads.
+	Progress registry.ProgressFunc
+
+	// Sidecars enables writing the metadata, provenance and checksum next to the downloaded extensions.
+	Sidecars bool
+
+	// OutputTemplate is an optional template for the names of the downloaded extensions.
+	OutputTemplate string
 }
 
 type PullOpt func(*Pull)
@@ -39,6 +63,69 @@ func WithVersion(v string) PullOpt {
 	}
 }
 
+// WithSHA256 sets the checksum (in hex) the downloaded extension must match.
+func WithSHA256(sum string) PullOpt {
+	return func(p *Pull) {
+		p.SHA256 = sum
+	}
+}
+
+// WithVerify enables checking that the downloaded extensions are Proxy-Wasm extensions.
+func WithVerify(verify bool) PullOpt {
+	return func(p *Pull) {
+		p.Verify = verify
+	}
+}
+
+// WithProgress sets a callback for reporting the progress of downloads.
+func WithProgress(progress registry.ProgressFunc) PullOpt {
+	return func(p *Pull) {
+		p.Progress = progress
+	}
+}
+
+// WithSidecars enables writing the metadata, provenance and checksum next to the downloaded extensions.
+func WithSidecars(sidecars bool) PullOpt {
+	return func(p *Pull) {
+		p.Sidecars = sidecars
+	}
+}
+
+// WithOutputTemplate sets the template for the names of the downloaded extensions.
+func WithOutputTemplate(tmpl string) PullOpt {
+	return func(p *Pull) {
+		p.OutputTemplate = tmpl
+	}
+}
+
+// WithCache sets the cache used for storing the downloaded extensions.
+func WithCache(c *cache.Cache) PullOpt {
+	return func(p *Pull) {
+		p.Cache = c
+	}
+}
+
+// WithResolveTTL sets the time the resolution of a floating reference is reused from the cache.
+func WithResolveTTL(ttl time.Duration) PullOpt {
+	return func(p *Pull) {
+		p.ResolveTTL = ttl
+	}
+}
+
+// WithStaleIfError enables using stale extensions from the cache when the registry is unavailable.
+func WithStaleIfError(stale bool) PullOpt {
+	return func(p *Pull) {
+		p.StaleIfError = stale
+	}
+}
+
+// WithLimiter sets the limiter for the concurrent requests to registries.
+func WithLimiter(l Limiter) PullOpt {
+	return func(p *Pull) {
+		p.Limiter = l
+	}
+}
+
 // NewPull creates a new pull, with configuration options.
 func NewPull(settings *config.GlobalSettings, cfg *registry.Configuration, opts ...any) *Pull {
 	p := &Pull{
@@ -68,12 +155,43 @@ func (p *Pull) SetRegistryClient(client *registry.Client) {
 func (p *Pull) Run(remote string) (string, error) {
 	var out strings.Builder
 
-	if !registry.IsOCI(remote) {
-		return out.String(), fmt.Errorf("%q is not a valid OCI reference", remote)
+	if !All(p.Settings).SupportsRef(remote) {
+		return out.String(), fmt.Errorf("%q is not a valid reference", remote)
+	}
+
+	downloader := p.newDownloader(&out)
+
+	saved, _, err := downloader.DownloadTo(remote, p.Version, p.DestDir)
+	if err != nil {
+		return out.String(), err
+	}
+
+	return saved, nil
+}
+
+// Fetch performs a 'pull' of the given WASM extension into the cache.
+func (p *Pull) Fetch(remote string) (*cache.Entry, error) {
+	if !All(p.Settings).SupportsRef(remote) {
+		return nil, fmt.Errorf("%q is not a valid reference", remote)
 	}
 
-	downloader := WASMDownloader{
-		Out:     &out,
+	downloader := p.newDownloader(io.Discard)
+	return downloader.Fetch(remote, p.Version)
+}
+
+// Refresh resolves again the given WASM extension, fetching it into the cache.
+func (p *Pull) Refresh(remote string) (*cache.Entry, error) {
+	if !All(p.Settings).SupportsRef(remote) {
+		return nil, fmt.Errorf("%q is not a valid reference", remote)
+	}
+
+	downloader := p.newDownloader(io.Discard)
+	return downloader.Refresh(remote, p.Version)
+}
+
+func (p *Pull) newDownloader(out io.Writer) *WASMDownloader {
+	downloader := &WASMDownloader{
+		Out:     out,
 		Verify:  VerifyNever,
 		Getters: All(p.Settings),
 		Options: []Option{
@@ -82,23 +200,23 @@ func (p *Pull) Run(remote string) (string, error) {
 			WithTLSClientConfig(p.CertFile, p.KeyFile, p.CAFile),
 			WithInsecureSkipVerifyTLS(p.Insecure),
 			WithPlainHTTP(p.PlainHTTP),
+			WithMaxAttempts(p.MaxAttempts),
 			WithRegistryClient(p.RegistryConfig.RegistryClient),
+			WithProgressFunc(p.Progress),
 		},
 		RegistryClient: p.RegistryConfig.RegistryClient,
+		Cache:          p.Cache,
+		ResolveTTL:     p.ResolveTTL,
+		StaleIfError:   p.StaleIfError,
+		Limiter:        p.Limiter,
+		SHA256:         p.SHA256,
+		Sidecars:       p.Sidecars,
+		OutputTemplate: p.OutputTemplate,
 	}
 
 	if p.Verify {
 		downloader.Verify = VerifyAlways
 	}
 
-	saved, _, err := downloader.DownloadTo(remote, p.Version, p.DestDir)
-	if err != nil {
-		return out.String(), err
-	}
-
-	if p.Verify {
-		// TODO
-	}
-
-	return saved, nil
+	return downloader
 }
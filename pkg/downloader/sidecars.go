@@ -0,0 +1,161 @@
+package downloader
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const (
+	// ChecksumSuffix is the suffix of the file with the checksum of a downloaded
+	// extension, in the format used by "sha256sum"
+	ChecksumSuffix = ".sha256"
+
+	// ProvenanceSuffix is the suffix (replacing the extension) of the file with
+	// the provenance of a downloaded extension
+	ProvenanceSuffix = ".provenance.json"
+)
+
+// Verifications in the provenance of downloaded extensions
+const (
+	// VerificationChecksum means that the extension matched the expected checksum
+	VerificationChecksum = "checksum"
+	// VerificationProxyWasm means that the extension has been checked to be a Proxy-Wasm extension
+	VerificationProxyWasm = "proxy-wasm"
+)
+
+// Provenance describes where a downloaded extension has been obtained from
+type Provenance struct {
+	// Source is the reference, as requested.
+	Source string `json:"source"`
+	// Resolved is the reference the Source was resolved to (with an explicit tag for OCI references).
+	Resolved string `json:"resolved"`
+	// Tag is the tag the Source was resolved to, for OCI references.
+	Tag string `json:"tag,omitempty"`
+	// ManifestDigest is the digest of the OCI manifest.
+	ManifestDigest string `json:"manifestDigest,omitempty"`
+	// ConfigDigest is the digest of the OCI config, with the metadata.
+	ConfigDigest string `json:"configDigest,omitempty"`
+	// LayerDigest is the digest of the Wasm module.
+	LayerDigest string `json:"layerDigest"`
+	// DownloadedAt is the time the extension was downloaded.
+	DownloadedAt time.Time `json:"downloadedAt"`
+	// Verification are the verifications passed by the extension (none when empty).
+	Verification []string `json:"verification"`
+}
+
+// OutputData is the data available in the templates for the names of downloaded extensions
+type OutputData struct {
+	// Name is the name in the metadata, or the name of the repository (or file) otherwise
+	Name string
+	// Version is the version in the metadata, or the tag otherwise
+	Version string
+	// Repository is the last component of the repository (or the file name, without extension, for URLs)
+	Repository string
+	// Tag is the tag of OCI references
+	Tag string
+	// Digest is the SHA256 of the Wasm module, in hex
+	Digest string
+}
+
+// outputFilename returns the name for a downloaded extension, relative to the destination directory
+func (c *WASMDownloader) outputFilename(u *url.URL, md *common.Metadata, digest string) (string, error) {
+	base := path.Base(u.Path)
+	data := OutputData{
+		Repository: strings.TrimSuffix(base, path.Ext(base)),
+		Digest:     strings.TrimPrefix(digest, "sha256:"),
+	}
+	if idx := strings.LastIndexByte(base, ':'); idx >= 0 && IsVersionedRef(u.String()) {
+		data.Repository, data.Tag = base[:idx], base[idx+1:]
+	}
+	data.Name, data.Version = data.Repository, data.Tag
+	if md != nil && md.Name != "" && md.Version != "" {
+		data.Name, data.Version = md.Name, md.Version
+	}
+
+	if c.OutputTemplate == "" {
+		switch {
+		case data.Tag != "":
+			return fmt.Sprintf("%s-%s.wasm", data.Repository, data.Tag), nil
+		case md != nil && md.Name != "" && md.Version != "":
+			return filepath.Base(fmt.Sprintf("%s-%s.wasm", md.Name, md.Version)), nil
+		default:
+			return base, nil
+		}
+	}
+
+	tmpl, err := template.New("output").Option("missingkey=error").Parse(c.OutputTemplate)
+	if err != nil {
+		return "", fmt.Errorf("when parsing output template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("when executing output template: %w", err)
+	}
+
+	name := filepath.FromSlash(buf.String())
+	if !filepath.IsLocal(name) {
+		return "", fmt.Errorf("output template must produce a relative path inside the destination: %q", buf.String())
+	}
+	return name, nil
+}
+
+// metadataFilename returns the name of the metadata file for a downloaded extension
+func metadataFilename(filename string) string {
+	return strings.TrimSuffix(filename, filepath.Ext(filename)) + ".yaml"
+}
+
+// writeSidecars writes the provenance and the checksum of a downloaded extension next to it
+func (c *WASMDownloader) writeSidecars(filename, ref string, u *url.URL, data []byte, pulled *registry.PullResult,
+	verification *Verification,
+) error {
+	digest := sha256Digest(data)
+
+	provenance := Provenance{
+		Source:       ref,
+		Resolved:     u.String(),
+		LayerDigest:  digest,
+		DownloadedAt: time.Now().UTC(),
+		Verification: []string{},
+	}
+	if IsVersionedRef(u.String()) {
+		tag, err := registry.RefTag(u.String())
+		if err != nil {
+			return err
+		}
+		provenance.Tag = tag
+	}
+	if pulled != nil {
+		provenance.ManifestDigest = pulled.Manifest.Digest
+		provenance.ConfigDigest = pulled.Config.Digest
+	}
+	// the download would have failed when any verification failed
+	if c.SHA256 != "" {
+		provenance.Verification = append(provenance.Verification, VerificationChecksum)
+	}
+	if verification != nil {
+		provenance.Verification = append(provenance.Verification, VerificationProxyWasm)
+	}
+
+	raw, err := json.MarshalIndent(provenance, "", "  ")
+	if err != nil {
+		return err
+	}
+	provenanceFile := strings.TrimSuffix(filename, filepath.Ext(filename)) + ProvenanceSuffix
+	if err := utils.AtomicWriteFile(provenanceFile, bytes.NewReader(append(raw, '\n')), 0o644); err != nil {
+		return err
+	}
+
+	checksum := fmt.Sprintf("%s  %s\n", strings.TrimPrefix(digest, "sha256:"), filepath.Base(filename))
+	return utils.AtomicWriteFile(filename+ChecksumSuffix, strings.NewReader(checksum), 0o644)
+}
@@ -0,0 +1,186 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+func TestOutputFilename(t *testing.T) {
+	const digest = "sha256:0123abcd"
+
+	for name, tCase := range map[string]struct {
+		ref         string
+		md          *common.Metadata
+		template    string
+		expected    string
+		expectedMsg string
+	}{
+		"OCI reference": {
+			ref:      "oci://myregistry.com/team/auth:1.0.0",
+			expected: "auth-1.0.0.wasm",
+		},
+		"OCI reference with metadata": {
+			// the tag is preferred to the version in the metadata
+			ref:      "oci://myregistry.com/auth:latest",
+			md:       &common.Metadata{Name: "authn", Version: "1.2.0"},
+			expected: "auth-latest.wasm",
+		},
+		"URL": {
+			ref:      "https://example.com/files/auth.wasm",
+			expected: "auth.wasm",
+		},
+		"URL with metadata": {
+			ref:      "https://example.com/files/download",
+			md:       &common.Metadata{Name: "auth", Version: "1.2.0"},
+			expected: "auth-1.2.0.wasm",
+		},
+		"URL with metadata without a version": {
+			ref:      "https://example.com/files/auth.wasm",
+			md:       &common.Metadata{Name: "authn"},
+			expected: "auth.wasm",
+		},
+		"URL with metadata with a path in the name": {
+			ref:      "https://example.com/files/download",
+			md:       &common.Metadata{Name: "../auth", Version: "1.2.0"},
+			expected: "auth-1.2.0.wasm",
+		},
+		"template": {
+			ref:      "oci://myregistry.com/auth:1.0.0",
+			template: "{{.Repository}}/{{.Tag}}/{{.Digest}}.wasm",
+			expected: filepath.Join("auth", "1.0.0", "0123abcd.wasm"),
+		},
+		"template with metadata": {
+			ref:      "oci://myregistry.com/auth:1.0.0",
+			md:       &common.Metadata{Name: "authn", Version: "1.2.0"},
+			template: "{{.Name}}-{{.Version}}.wasm",
+			expected: "authn-1.2.0.wasm",
+		},
+		"template with the fallback of the name and version": {
+			ref:      "oci://myregistry.com/auth:1.0.0",
+			md:       &common.Metadata{Name: "authn"},
+			template: "{{.Name}}-{{.Version}}.wasm",
+			expected: "auth-1.0.0.wasm",
+		},
+		"template escaping the destination": {
+			ref:         "https://example.com/files/download",
+			md:          &common.Metadata{Name: "../x", Version: "1.0.0"},
+			template:    "{{.Name}}.wasm",
+			expectedMsg: `output template must produce a relative path inside the destination: "../x.wasm"`,
+		},
+		"template with an absolute path": {
+			ref:         "oci://myregistry.com/auth:1.0.0",
+			template:    "/tmp/{{.Name}}.wasm",
+			expectedMsg: "output template must produce a relative path inside the destination",
+		},
+		"template with an empty result": {
+			ref:         "oci://myregistry.com/auth:1.0.0",
+			template:    "{{if false}}x{{end}}",
+			expectedMsg: "output template must produce a relative path inside the destination",
+		},
+		"template with a missing key": {
+			ref:         "oci://myregistry.com/auth:1.0.0",
+			template:    "{{.Missing}}.wasm",
+			expectedMsg: "when executing output template",
+		},
+		"invalid template": {
+			ref:         "oci://myregistry.com/auth:1.0.0",
+			template:    "{{.Name",
+			expectedMsg: "when parsing output template",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tCase.ref)
+			require.NoError(t, err)
+
+			c := &WASMDownloader{OutputTemplate: tCase.template}
+			res, err := c.outputFilename(u, tCase.md, digest)
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, res)
+		})
+	}
+}
+
+func TestWriteSidecars(t *testing.T) {
+	data := []byte("some wasm module")
+	sum := sha256.Sum256(data)
+	hexSum := hex.EncodeToString(sum[:])
+	proxyWasm := Verification("proxy-wasm")
+
+	for name, tCase := range map[string]struct {
+		ref                  string
+		sha256               string
+		pulled               *registry.PullResult
+		verification         *Verification
+		expectedTag          string
+		expectedManifest     string
+		expectedVerification []string
+	}{
+		"URL": {
+			ref:                  "https://example.com/auth.wasm",
+			expectedVerification: []string{},
+		},
+		"OCI reference": {
+			ref: "oci://myregistry.com/auth:1.0.0",
+			pulled: &registry.PullResult{
+				Manifest: &registry.DescriptorPullSummary{Digest: "sha256:m"},
+				Config:   &registry.DescriptorPullSummary{Digest: "sha256:c"},
+			},
+			expectedTag:          "1.0.0",
+			expectedManifest:     "sha256:m",
+			expectedVerification: []string{},
+		},
+		"checksum": {
+			ref:                  "https://example.com/auth.wasm",
+			sha256:               hexSum,
+			expectedVerification: []string{VerificationChecksum},
+		},
+		"checksum and Proxy-Wasm": {
+			ref:                  "https://example.com/auth.wasm",
+			sha256:               hexSum,
+			verification:         &proxyWasm,
+			expectedVerification: []string{VerificationChecksum, VerificationProxyWasm},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			u, err := url.Parse(tCase.ref)
+			require.NoError(t, err)
+			filename := filepath.Join(t.TempDir(), "auth.wasm")
+
+			c := &WASMDownloader{SHA256: tCase.sha256}
+			require.NoError(t, c.writeSidecars(filename, tCase.ref, u, data, tCase.pulled, tCase.verification))
+
+			checksum, err := os.ReadFile(filename + ChecksumSuffix)
+			require.NoError(t, err)
+			// the format of sha256sum
+			assert.Equal(t, hexSum+"  auth.wasm\n", string(checksum))
+
+			raw, err := os.ReadFile(filepath.Join(filepath.Dir(filename), "auth"+ProvenanceSuffix))
+			require.NoError(t, err)
+			var provenance Provenance
+			require.NoError(t, json.Unmarshal(raw, &provenance))
+
+			assert.Equal(t, tCase.ref, provenance.Source)
+			assert.Equal(t, u.String(), provenance.Resolved)
+			assert.Equal(t, "sha256:"+hexSum, provenance.LayerDigest)
+			assert.Equal(t, tCase.expectedTag, provenance.Tag)
+			assert.Equal(t, tCase.expectedManifest, provenance.ManifestDigest)
+			assert.Equal(t, tCase.expectedVerification, provenance.Verification)
+			assert.False(t, provenance.DownloadedAt.IsZero())
+		})
+	}
+}
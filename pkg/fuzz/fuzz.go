@@ -0,0 +1,283 @@
+// Package fuzz fuzzes Proxy-Wasm extensions in the embedded host (see pkg/host), sending
+// random and mutated requests, responses and plugin configurations, detecting traps
+// (like panics or out of bounds memory accesses) and runaway executions, and saving
+// minimized reproducers as test suites for 'pwo test' (see pkg/suite).
+package fuzz
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// Default values for fuzzing
+const (
+	DefaultIterations = 1000
+	DefaultTimeout    = time.Second
+
+	// maxMinimizeRuns and maxMinimizeTime limit the runs for minimizing a crash
+	maxMinimizeRuns = 500
+	maxMinimizeTime = time.Minute
+)
+
+// Case is a plugin configuration and a request (and response) sent to the extension
+type Case struct {
+	Config   string
+	Exchange host.ExchangeSpec
+}
+
+// clone returns a deep copy of a case
+func (c Case) clone() Case {
+	res := c
+	cloneMap := func(m map[string]string) map[string]string {
+		if m == nil {
+			return nil
+		}
+		res := make(map[string]string, len(m))
+		for k, v := range m {
+			res[k] = v
+		}
+		return res
+	}
+	res.Exchange.Request.Headers = cloneMap(c.Exchange.Request.Headers)
+	res.Exchange.Request.Trailers = cloneMap(c.Exchange.Request.Trailers)
+	res.Exchange.Response.Headers = cloneMap(c.Exchange.Response.Headers)
+	res.Exchange.Response.Trailers = cloneMap(c.Exchange.Response.Trailers)
+	return res
+}
+
+// Crash is a case that makes the extension trap or run for too long
+type Crash struct {
+	// Signature identifies the crash: the callback and the kind of crash
+	Signature string
+	// Err is the error returned by the host
+	Err error
+	// Case is the (minimized) case
+	Case Case
+	// Logs are the messages logged by the extension
+	Logs []host.LogEntry
+	// Count is the number of cases found with the same signature
+	Count int
+}
+
+// options for fuzzing
+type options struct {
+	iterations   int
+	duration     time.Duration
+	seed         int64
+	mutateConfig bool
+	minimize     bool
+	hostOpts     []host.Option
+	onCrash      func(*Crash)
+}
+
+// Option is an option for fuzzing
+type Option func(*options)
+
+// WithIterations sets the number of cases run
+func WithIterations(n int) Option {
+	return func(o *options) {
+		o.iterations = n
+	}
+}
+
+// WithDuration sets the maximum time for fuzzing (no limit when zero)
+func WithDuration(d time.Duration) Option {
+	return func(o *options) {
+		o.duration = d
+	}
+}
+
+// WithSeed sets the seed of the random numbers, for repeating a run
+func WithSeed(seed int64) Option {
+	return func(o *options) {
+		o.seed = seed
+	}
+}
+
+// WithMutateConfig enables mutating the plugin configuration too
+func WithMutateConfig(enabled bool) Option {
+	return func(o *options) {
+		o.mutateConfig = enabled
+	}
+}
+
+// WithMinimize enables minimizing the cases of the crashes found
+func WithMinimize(enabled bool) Option {
+	return func(o *options) {
+		o.minimize = enabled
+	}
+}
+
+// WithHostOptions sets the options for the host (like the timeout for callbacks)
+func WithHostOptions(opts ...host.Option) Option {
+	return func(o *options) {
+		o.hostOpts = append(o.hostOpts, opts...)
+	}
+}
+
+// WithCrashFunc sets a function called for every new crash found (after minimizing it)
+func WithCrashFunc(fn func(*Crash)) Option {
+	return func(o *options) {
+		o.onCrash = fn
+	}
+}
+
+// Result is the result of fuzzing an extension
+type Result struct {
+	Seed     int64
+	Runs     int
+	Duration time.Duration
+	// Rejected is the number of cases where the plugin configuration was rejected
+	Rejected int
+	// Crashes are the unique crashes found, in order
+	Crashes []*Crash
+}
+
+// fuzzer runs cases in new hosts
+type fuzzer struct {
+	data     []byte
+	hostOpts []host.Option
+}
+
+// Run fuzzes an extension, mutating some seed cases (or some default requests when there are none)
+func Run(ctx context.Context, data []byte, seeds []Case, opts ...Option) (*Result, error) {
+	o := options{
+		iterations:   DefaultIterations,
+		seed:         time.Now().UnixNano(),
+		mutateConfig: true,
+		minimize:     true,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if len(seeds) == 0 {
+		seeds = DefaultSeeds("")
+	}
+
+	// the module is compiled only once for all the cases
+	cache := wazero.NewCompilationCache()
+	defer cache.Close(ctx)
+
+	f := &fuzzer{
+		data:     data,
+		hostOpts: append([]host.Option{host.WithTimeout(DefaultTimeout), host.WithCompilationCache(cache)}, o.hostOpts...),
+	}
+
+	// the seeds must work, or all the cases would crash in the same way
+	for i, seed := range seeds {
+		if crash, _, err := f.run(ctx, seed); err != nil {
+			return nil, err
+		} else if crash != nil {
+			return nil, fmt.Errorf("seed #%d crashes: %w", i+1, crash.Err)
+		}
+	}
+
+	start := time.Now()
+	res := &Result{Seed: o.seed}
+	m := &mutator{rnd: rand.New(rand.NewSource(o.seed))}
+	crashes := map[string]*Crash{}
+
+	for res.Runs < o.iterations && (o.duration == 0 || time.Since(start) < o.duration) {
+		if err := ctx.Err(); err != nil {
+			break
+		}
+
+		c := m.mutate(seeds[m.rnd.Intn(len(seeds))], o.mutateConfig)
+		crash, rejected, err := f.run(ctx, c)
+		res.Runs++
+		if err != nil {
+			return nil, err
+		}
+		if rejected {
+			res.Rejected++
+		}
+		if crash == nil {
+			continue
+		}
+
+		if known, ok := crashes[crash.Signature]; ok {
+			known.Count++
+			continue
+		}
+		if o.minimize {
+			crash = f.minimize(ctx, crash)
+		}
+		crash.Count = 1
+		crashes[crash.Signature] = crash
+		res.Crashes = append(res.Crashes, crash)
+		if o.onCrash != nil {
+			o.onCrash(crash)
+		}
+	}
+
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// run runs a case in a new host, returning the crash (if any) and whether the plugin
+// configuration was rejected. Errors are returned only when the host cannot be created.
+func (f *fuzzer) run(ctx context.Context, c Case) (*Crash, bool, error) {
+	opts := append(append([]host.Option{}, f.hostOpts...), host.WithPluginConfiguration([]byte(c.Config)))
+	h, err := host.New(ctx, f.data, opts...)
+	if err != nil {
+		var trap *host.TrapError
+		if errors.As(err, &trap) {
+			return newCrash(c, err, nil), false, nil
+		}
+		return nil, false, err
+	}
+	defer h.Close(ctx)
+
+	if err := h.Start(ctx); errors.Is(err, host.ErrFailed) {
+		return nil, true, nil
+	} else if err != nil {
+		return newCrash(c, err, h.Logs()), false, nil
+	}
+
+	if _, err := h.HTTP(ctx, c.Exchange.Request.HTTPRequest(), c.Exchange.Response.HTTPResponse()); err != nil {
+		return newCrash(c, err, h.Logs()), false, nil
+	}
+	return nil, false, nil
+}
+
+// newCrash creates a crash for an error
+func newCrash(c Case, err error, logs []host.LogEntry) *Crash {
+	return &Crash{Signature: signature(err), Err: err, Case: c, Logs: logs}
+}
+
+// signature identifies a crash, with the callback and the kind of crash
+func signature(err error) string {
+	callback := "unknown"
+	var trap *host.TrapError
+	if errors.As(err, &trap) {
+		callback = trap.Callback
+	}
+
+	var exitErr *sys.ExitError
+	msg := err.Error()
+	kind := ""
+	switch {
+	case errors.Is(err, host.ErrTimeout):
+		kind = "timeout"
+	case errors.As(err, &exitErr):
+		kind = fmt.Sprintf("exit code %d", exitErr.ExitCode())
+	case strings.Contains(msg, "out of bounds memory access"):
+		kind = "out of bounds memory access"
+	case strings.Contains(msg, "unreachable"):
+		kind = "unreachable (panic)"
+	default:
+		// the first line, without the stack trace
+		kind = strings.SplitN(msg, "\n", 2)[0]
+		kind = strings.TrimPrefix(kind, callback+" trapped: ")
+	}
+	return callback + ": " + kind
+}
@@ -0,0 +1,150 @@
+package fuzz
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/suite"
+)
+
+// minimize simplifies the case of a crash while it crashes in the same way: removing
+// headers and trailers, and shortening the bodies, the values and the configuration
+func (f *fuzzer) minimize(ctx context.Context, crash *Crash) *Crash {
+	best := crash
+	runs := 0
+	start := time.Now()
+
+	// try replaces the best case when a candidate crashes with the same signature
+	try := func(candidate Case) bool {
+		if runs >= maxMinimizeRuns || time.Since(start) > maxMinimizeTime {
+			return false
+		}
+		runs++
+		c, _, err := f.run(ctx, candidate)
+		if err != nil || c == nil || c.Signature != crash.Signature {
+			return false
+		}
+		best = c
+		return true
+	}
+
+	// shrink shortens a string in a case, removing chunks of decreasing sizes
+	shrink := func(get func(*Case) string, set func(*Case, string)) {
+		with := func(s string) Case {
+			c := best.Case.clone()
+			set(&c, s)
+			return c
+		}
+		if get(&best.Case) == "" || try(with("")) {
+			return
+		}
+		// strings are shortened by runes, so they are still valid UTF-8
+		for chunk := len([]rune(get(&best.Case))) / 2; chunk > 0; chunk /= 2 {
+			for i := 0; i < len([]rune(get(&best.Case))); {
+				r := []rune(get(&best.Case))
+				end := i + chunk
+				if end > len(r) {
+					end = len(r)
+				}
+				if !try(with(string(r[:i]) + string(r[end:]))) {
+					i += chunk
+				}
+			}
+		}
+	}
+
+	// shrinkField shortens a string field of a case
+	shrinkField := func(field func(*Case) *string) {
+		shrink(func(c *Case) string { return *field(c) }, func(c *Case, s string) { *field(c) = s })
+	}
+
+	// shrinkHeaders removes headers, and shortens the values of the headers left
+	shrinkHeaders := func(headers func(*Case) map[string]string) {
+		for _, name := range sortedKeys(headers(&best.Case)) {
+			c := best.Case.clone()
+			delete(headers(&c), name)
+			if try(c) {
+				continue
+			}
+			name := name
+			shrink(func(c *Case) string { return headers(c)[name] }, func(c *Case, s string) { headers(c)[name] = s })
+		}
+	}
+
+	for progress := true; progress && runs < maxMinimizeRuns && time.Since(start) < maxMinimizeTime; {
+		before := best
+
+		shrinkField(func(c *Case) *string { return &c.Config })
+		shrinkHeaders(func(c *Case) map[string]string { return c.Exchange.Request.Headers })
+		shrinkHeaders(func(c *Case) map[string]string { return c.Exchange.Request.Trailers })
+		shrinkField(func(c *Case) *string { return &c.Exchange.Request.Body })
+		shrinkHeaders(func(c *Case) map[string]string { return c.Exchange.Response.Headers })
+		shrinkHeaders(func(c *Case) map[string]string { return c.Exchange.Response.Trailers })
+		shrinkField(func(c *Case) *string { return &c.Exchange.Response.Body })
+		shrinkField(func(c *Case) *string { return &c.Exchange.Request.Method })
+		shrinkField(func(c *Case) *string { return &c.Exchange.Request.Path })
+		shrinkField(func(c *Case) *string { return &c.Exchange.Request.Authority })
+		if best.Case.Exchange.Response.Status != 0 {
+			c := best.Case.clone()
+			c.Exchange.Response.Status = 0
+			try(c)
+		}
+
+		progress = best != before
+	}
+
+	return best
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Reproducer returns a test suite for 'pwo test' that reproduces a crash, and
+// a file name for it (derived from the signature of the crash). The base suite has
+// the module and the options of the host used for fuzzing (like the timeout), so
+// the crash is reproduced in the same conditions.
+func Reproducer(crash *Crash, base suite.Suite, seed int64) (string, []byte, error) {
+	s := base
+	if s.Module != "" && !strings.Contains(s.Module, "://") {
+		if abs, err := filepath.Abs(s.Module); err == nil {
+			s.Module = abs
+		}
+	}
+	if s.Name == "" {
+		s.Name = "fuzz"
+	}
+	s.Config = crash.Case.Config
+	s.Tests = []suite.Test{{
+		Name:         crash.Signature,
+		ExchangeSpec: crash.Case.Exchange,
+	}}
+
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "# Found by 'pwo fuzz' (seed %d):\n", seed)
+	for _, line := range strings.Split(strings.TrimSpace(crash.Err.Error()), "\n") {
+		fmt.Fprintf(&header, "#   %s\n", line)
+	}
+	header.WriteString("#\n# Run it with 'pwo test' (it fails until the crash is fixed)\n")
+
+	sum := sha256.Sum256([]byte(crash.Signature))
+	filename := fmt.Sprintf("crash-%x.yaml", sum[:6])
+	return filename, append([]byte(header.String()), data...), nil
+}
@@ -0,0 +1,162 @@
+package fuzz
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+	"github.com/inercia/proxy-wasm-oci/pkg/suite"
+)
+
+func TestReproducer(t *testing.T) {
+	var err error = &host.TrapError{
+		Callback: "proxy_on_request_headers",
+		Err:      fmt.Errorf("%w: running for more than %s", host.ErrTimeout, DefaultTimeout),
+	}
+	crash := newCrash(Case{
+		Config: `{"loop": true}`,
+		Exchange: host.ExchangeSpec{
+			Request:  host.RequestSpec{Method: "POST", Path: "/a", Headers: map[string]string{"x-a": "1"}, Body: "hello"},
+			Response: host.ResponseSpec{Status: 503},
+		},
+	}, err, nil)
+	require.Equal(t, "proxy_on_request_headers: timeout", crash.Signature)
+
+	base := suite.Suite{
+		Module:     "oci://myregistry.com/myrepo:1.0.0",
+		VMConfig:   "vm",
+		Properties: map[string]string{"node.id": "proxy-1"},
+		RootID:     "my-root",
+		LogLevel:   "debug",
+		Timeout:    DefaultTimeout.String(),
+	}
+	name, data, err := Reproducer(crash, base, 42)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(name, "crash-") && strings.HasSuffix(name, ".yaml"), name)
+	assert.Contains(t, string(data), "# Found by 'pwo fuzz' (seed 42):\n#   proxy_on_request_headers trapped: timeout")
+
+	filename := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(filename, data, 0o644))
+	s, err := suite.Load(filename)
+	require.NoError(t, err)
+
+	assert.Equal(t, "fuzz", s.Name)
+	assert.Equal(t, base.Module, s.Module)
+	assert.Equal(t, `{"loop": true}`, s.Config)
+	assert.Equal(t, "vm", s.VMConfig)
+	assert.Equal(t, map[string]string{"node.id": "proxy-1"}, s.Properties)
+	assert.Equal(t, "my-root", s.RootID)
+	assert.Equal(t, "debug", s.LogLevel)
+	timeout, err := time.ParseDuration(s.Timeout)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultTimeout, timeout)
+
+	require.Len(t, s.Tests, 1)
+	assert.Equal(t, crash.Signature, s.Tests[0].Name)
+	assert.Equal(t, crash.Case.Exchange, s.Tests[0].ExchangeSpec)
+
+	// the base suite is not modified
+	assert.Empty(t, base.Tests)
+	assert.Empty(t, base.Config)
+}
+
+func TestReproducerLocalModule(t *testing.T) {
+	crash := newCrash(Case{}, &host.TrapError{Callback: "proxy_on_configure", Err: fmt.Errorf("unreachable")}, nil)
+	_, data, err := Reproducer(crash, suite.Suite{Module: "main.wasm"}, 1)
+	require.NoError(t, err)
+
+	abs, err := filepath.Abs("main.wasm")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "module: "+abs)
+}
+
+// crashModule is a Proxy-Wasm extension that traps in proxy_on_request_headers
+// when the request has a "x-crash" header
+var crashModule = func() []byte {
+	const (
+		i32      = wasmtest.I32
+		i32Const = wasmtest.I32Const
+	)
+	return wasmtest.Module(
+		wasmtest.Section(wasmtest.SectionType, wasmtest.Vector(
+			[]byte{0x60, 1, i32, 1, i32},                     // 0: (i32) -> i32
+			[]byte{0x60, 2, i32, i32, 0},                     // 1: (i32, i32) -> ()
+			[]byte{0x60, 0, 0},                               // 2: () -> ()
+			[]byte{0x60, 2, i32, i32, 1, i32},                // 3: (i32, i32) -> i32
+			[]byte{0x60, 3, i32, i32, i32, 1, i32},           // 4: (i32, i32, i32) -> i32
+			[]byte{0x60, 5, i32, i32, i32, i32, i32, 1, i32}, // 5: (i32 x 5) -> i32
+		)),
+		wasmtest.Imports(wasmtest.ImportFunc("env", "proxy_get_header_map_value", 5)),
+		wasmtest.Section(wasmtest.SectionFunction, wasmtest.Vector([]byte{0}, []byte{1}, []byte{2}, []byte{3}, []byte{3}, []byte{4})),
+		wasmtest.Section(wasmtest.SectionMemory, wasmtest.Vector([]byte{0x00, 0x01})),
+		// the allocation pointer, starting at 4096
+		wasmtest.Section(wasmtest.SectionGlobal, wasmtest.Vector([]byte{i32, 0x01, i32Const, 0x80, 0x20, 0x0b})),
+		wasmtest.Exports(
+			wasmtest.Export("memory", wasmtest.KindMemory, 0),
+			wasmtest.Export("proxy_on_memory_allocate", wasmtest.KindFunction, 1),
+			wasmtest.Export("proxy_on_context_create", wasmtest.KindFunction, 2),
+			wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 3),
+			wasmtest.Export("proxy_on_vm_start", wasmtest.KindFunction, 4),
+			wasmtest.Export("proxy_on_configure", wasmtest.KindFunction, 5),
+			wasmtest.Export("proxy_on_request_headers", wasmtest.KindFunction, 6),
+		),
+		wasmtest.Section(wasmtest.SectionCode, wasmtest.Vector(
+			// global.get 0; global.get 0; local.get 0; i32.add; global.set 0
+			wasmtest.Func(0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0),
+			wasmtest.Func(),
+			wasmtest.Func(),
+			wasmtest.Func(i32Const, 1),
+			wasmtest.Func(i32Const, 1),
+			// if (proxy_get_header_map_value(0, "x-crash", ...) == OK) { unreachable }
+			wasmtest.Func(i32Const, 0, i32Const, 0, i32Const, 7, i32Const, 16, i32Const, 20, wasmtest.Call, 0,
+				0x45, 0x04, 0x40, 0x00, 0x0b, i32Const, 0),
+		)),
+		wasmtest.Section(wasmtest.SectionData, wasmtest.Vector(append([]byte{0x00, i32Const, 0, 0x0b}, wasmtest.Name("x-crash")...))),
+	)
+}()
+
+func TestMinimize(t *testing.T) {
+	ctx := context.Background()
+	f := &fuzzer{data: crashModule, hostOpts: []host.Option{host.WithTimeout(DefaultTimeout)}}
+
+	// the seeds do not crash
+	for _, seed := range DefaultSeeds(`{"key": "value"}`) {
+		crash, rejected, err := f.run(ctx, seed)
+		require.NoError(t, err)
+		assert.False(t, rejected)
+		assert.Nil(t, crash)
+	}
+
+	c := DefaultSeeds(`{"key": "value"}`)[1]
+	c.Exchange.Request.Headers["x-crash"] = "some long value"
+	c.Exchange.Request.Headers["x-other"] = "other value"
+	c.Exchange.Request.Trailers = map[string]string{"x-trailer": "value"}
+	crash, _, err := f.run(ctx, c)
+	require.NoError(t, err)
+	require.NotNil(t, crash)
+	require.Equal(t, "proxy_on_request_headers: unreachable (panic)", crash.Signature)
+
+	minimized := f.minimize(ctx, crash)
+	assert.Equal(t, crash.Signature, minimized.Signature)
+	assert.Equal(t, map[string]string{"x-crash": ""}, minimized.Case.Exchange.Request.Headers)
+	assert.Empty(t, minimized.Case.Exchange.Request.Trailers)
+	assert.Empty(t, minimized.Case.Exchange.Request.Body)
+	assert.Empty(t, minimized.Case.Exchange.Request.Method)
+	assert.Empty(t, minimized.Case.Exchange.Response.Headers)
+	assert.Empty(t, minimized.Case.Exchange.Response.Body)
+	assert.Zero(t, minimized.Case.Exchange.Response.Status)
+	assert.Empty(t, minimized.Case.Config)
+
+	// the original crash is not modified
+	assert.Equal(t, "some long value", crash.Case.Exchange.Request.Headers["x-crash"])
+	assert.Equal(t, c, crash.Case)
+}
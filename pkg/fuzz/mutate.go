@@ -0,0 +1,182 @@
+package fuzz
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// interesting are some strings that often break parsers
+var interesting = []string{
+	"", " ", "\x00", "\n", "\r\n", "{", "}", "[", "]", "\"", "\\", "%", "%00", "%zz",
+	"null", "true", "-1", "0", "4294967295", "18446744073709551616", "1e999", "NaN",
+	"{}", "[]", `{"":""}`, `{"a":`, "[[[[[[[[[[", "é", "😀", "�", "../", "//",
+}
+
+var methods = []string{"GET", "POST", "PUT", "DELETE", "HEAD", "OPTIONS", "PATCH", "CONNECT", "TRACE", "", "get", "X-CUSTOM"}
+
+// mutator mutates the requests, responses and configurations of cases. All the
+// strings generated are valid UTF-8, so cases can be saved as YAML unchanged.
+type mutator struct {
+	rnd *rand.Rand
+}
+
+// mutate returns a mutated copy of a case, with some (1 to 4) mutations
+func (m *mutator) mutate(c Case, mutateConfig bool) Case {
+	res := c.clone()
+
+	mutations := []func(*Case){
+		func(c *Case) { c.Exchange.Request.Headers = m.headers(c.Exchange.Request.Headers) },
+		func(c *Case) { c.Exchange.Request.Headers = m.headers(c.Exchange.Request.Headers) },
+		func(c *Case) { c.Exchange.Request.Body = m.string(c.Exchange.Request.Body) },
+		func(c *Case) { c.Exchange.Request.Body = m.string(c.Exchange.Request.Body) },
+		func(c *Case) { c.Exchange.Request.Trailers = m.headers(c.Exchange.Request.Trailers) },
+		func(c *Case) { c.Exchange.Request.Method = methods[m.rnd.Intn(len(methods))] },
+		func(c *Case) {
+			c.Exchange.Request.Path = "/" + m.string(strings.TrimPrefix(c.Exchange.Request.Path, "/"))
+		},
+		func(c *Case) { c.Exchange.Request.Authority = m.string(c.Exchange.Request.Authority) },
+		func(c *Case) { c.Exchange.Response.Headers = m.headers(c.Exchange.Response.Headers) },
+		func(c *Case) { c.Exchange.Response.Body = m.string(c.Exchange.Response.Body) },
+		func(c *Case) { c.Exchange.Response.Trailers = m.headers(c.Exchange.Response.Trailers) },
+		func(c *Case) { c.Exchange.Response.Status = m.rnd.Intn(1000) },
+	}
+	if mutateConfig {
+		mutations = append(mutations, func(c *Case) { c.Config = m.string(c.Config) })
+	}
+
+	for i := m.rnd.Intn(4); i >= 0; i-- {
+		mutations[m.rnd.Intn(len(mutations))](&res)
+	}
+	return res
+}
+
+// headers mutates some headers: adding, removing, renaming or changing the value of one of them
+func (m *mutator) headers(h map[string]string) map[string]string {
+	res := map[string]string{}
+	names := make([]string, 0, len(h))
+	for k, v := range h {
+		res[k] = v
+		names = append(names, k)
+	}
+	// sorted, so the same seed generates the same cases
+	sort.Strings(names)
+
+	switch op := m.rnd.Intn(4); {
+	case op == 0 || len(names) == 0:
+		res[m.headerName()] = m.string("")
+	case op == 1:
+		delete(res, names[m.rnd.Intn(len(names))])
+	case op == 2:
+		name := names[m.rnd.Intn(len(names))]
+		v := res[name]
+		delete(res, name)
+		res[m.headerName()] = v
+	default:
+		name := names[m.rnd.Intn(len(names))]
+		res[name] = m.string(res[name])
+	}
+	return res
+}
+
+// headerName returns a random header name (in lowercase, as in HTTP/2)
+func (m *mutator) headerName() string {
+	common := []string{"content-type", "content-length", "authorization", "cookie", "x-forwarded-for", "host", "user-agent", "accept"}
+	if m.rnd.Intn(2) == 0 {
+		return common[m.rnd.Intn(len(common))]
+	}
+	return strings.ToLower(m.random(1 + m.rnd.Intn(16)))
+}
+
+// string mutates a string: replacing, inserting or removing some characters,
+// truncating or repeating it, or replacing it with an interesting or random string
+func (m *mutator) string(s string) string {
+	r := []rune(s)
+	switch op := m.rnd.Intn(8); {
+	case op == 0 || len(r) == 0:
+		return interesting[m.rnd.Intn(len(interesting))]
+	case op == 1:
+		return m.random(m.rnd.Intn(64))
+	case op == 2:
+		// insert an interesting string
+		i := m.rnd.Intn(len(r) + 1)
+		return string(r[:i]) + interesting[m.rnd.Intn(len(interesting))] + string(r[i:])
+	case op == 3:
+		// replace a character
+		i := m.rnd.Intn(len(r))
+		r[i] = m.rune()
+		return string(r)
+	case op == 4:
+		// remove a range
+		i := m.rnd.Intn(len(r))
+		j := i + m.rnd.Intn(len(r)-i+1)
+		return string(r[:i]) + string(r[j:])
+	case op == 5:
+		return string(r[:m.rnd.Intn(len(r))])
+	case op == 6:
+		// repeat it (up to 64KiB)
+		n := 2 + m.rnd.Intn(64)
+		if n*len(s) > 64*1024 {
+			n = 64*1024/len(s) + 1
+		}
+		return strings.Repeat(s, n)
+	default:
+		return m.random(1024 + m.rnd.Intn(64*1024))
+	}
+}
+
+// random returns a random string of some length
+func (m *mutator) random(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		b.WriteRune(m.rune())
+	}
+	return b.String()
+}
+
+// rune returns a random character: mostly printable ASCII, but also control and non-ASCII characters
+func (m *mutator) rune() rune {
+	switch m.rnd.Intn(10) {
+	case 0:
+		return rune(m.rnd.Intn(0x20))
+	case 1:
+		return []rune("éñü€😀� ")[m.rnd.Intn(7)]
+	default:
+		return rune(0x20 + m.rnd.Intn(0x5f))
+	}
+}
+
+// DefaultSeeds returns the cases mutated when no corpus is provided: a GET request and
+// a POST request with a JSON body, with some plugin configuration
+func DefaultSeeds(config string) []Case {
+	return []Case{
+		{
+			Config: config,
+			Exchange: host.ExchangeSpec{
+				Request: host.RequestSpec{
+					Method:  "GET",
+					Path:    "/",
+					Headers: map[string]string{"accept": "*/*", "user-agent": "pwo-fuzz"},
+				},
+			},
+		},
+		{
+			Config: config,
+			Exchange: host.ExchangeSpec{
+				Request: host.RequestSpec{
+					Method:  "POST",
+					Path:    "/api",
+					Headers: map[string]string{"content-type": "application/json"},
+					Body:    `{"id": "1", "token": "abc", "items": [1, 2, 3]}`,
+				},
+				Response: host.ResponseSpec{
+					Status:  200,
+					Headers: map[string]string{"content-type": "application/json"},
+					Body:    `{"ok": true}`,
+				},
+			},
+		},
+	}
+}
@@ -0,0 +1,61 @@
+package fuzz
+
+import (
+	"math/rand"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMutatorUTF8(t *testing.T) {
+	m := &mutator{rnd: rand.New(rand.NewSource(1))}
+	seeds := DefaultSeeds(`{"key": "value"}`)
+
+	checkHeaders := func(i int, kind string, headers map[string]string) {
+		for k, v := range headers {
+			assert.True(t, utf8.ValidString(k), "invalid UTF-8 in the name of a %s in iteration %d: %q", kind, i, k)
+			assert.True(t, utf8.ValidString(v), "invalid UTF-8 in the value of a %s in iteration %d: %q", kind, i, v)
+		}
+	}
+
+	var c Case
+	for i := 0; i < 2000; i++ {
+		// mutations accumulate, starting again from a seed from time to time
+		if i%50 == 0 {
+			c = seeds[m.rnd.Intn(len(seeds))]
+		}
+		c = m.mutate(c, true)
+
+		for field, s := range map[string]string{
+			"config":        c.Config,
+			"method":        c.Exchange.Request.Method,
+			"path":          c.Exchange.Request.Path,
+			"authority":     c.Exchange.Request.Authority,
+			"request body":  c.Exchange.Request.Body,
+			"response body": c.Exchange.Response.Body,
+		} {
+			assert.True(t, utf8.ValidString(s), "invalid UTF-8 in the %s in iteration %d: %q", field, i, s)
+		}
+		checkHeaders(i, "request header", c.Exchange.Request.Headers)
+		checkHeaders(i, "request trailer", c.Exchange.Request.Trailers)
+		checkHeaders(i, "response header", c.Exchange.Response.Headers)
+		checkHeaders(i, "response trailer", c.Exchange.Response.Trailers)
+		if t.Failed() {
+			return
+		}
+	}
+}
+
+func TestMutatorSeed(t *testing.T) {
+	// the same seed generates the same cases
+	seed := DefaultSeeds("")[1]
+	m1 := &mutator{rnd: rand.New(rand.NewSource(42))}
+	m2 := &mutator{rnd: rand.New(rand.NewSource(42))}
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, m1.mutate(seed, true), m2.mutate(seed, true))
+	}
+
+	// and the seed is not modified
+	assert.Equal(t, DefaultSeeds("")[1], seed)
+}
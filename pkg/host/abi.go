@@ -0,0 +1,725 @@
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+// buffer types, as in the Proxy-Wasm ABI
+const (
+	bufferHTTPRequestBody     = 0
+	bufferHTTPResponseBody    = 1
+	bufferVMConfiguration     = 6
+	bufferPluginConfiguration = 7
+)
+
+// map types, as in the Proxy-Wasm ABI
+const (
+	mapHTTPRequestHeaders   = 0
+	mapHTTPRequestTrailers  = 1
+	mapHTTPResponseHeaders  = 2
+	mapHTTPResponseTrailers = 3
+)
+
+// errMemory is returned when the extension passes pointers out of its memory
+var errMemory = errors.New("invalid memory access")
+
+// hostFunc is the implementation of a host function, returning a status
+type hostFunc func(h *Host, ctx context.Context, mod api.Module, params []uint64) Status
+
+// hostFunctions are the host functions implemented, by name
+var hostFunctions = map[string]hostFunc{
+	"proxy_log":                          proxyLog,
+	"proxy_get_log_level":                proxyGetLogLevel,
+	"proxy_get_current_time_nanoseconds": proxyGetCurrentTimeNanoseconds,
+	"proxy_set_tick_period_milliseconds": proxyOK,
+	"proxy_set_effective_context":        proxyOK,
+	"proxy_done":                         proxyOK,
+	"proxy_continue_stream":              proxyOK,
+	"proxy_close_stream":                 proxyOK,
+	"proxy_continue_request":             proxyOK,
+	"proxy_continue_response":            proxyOK,
+	"proxy_clear_route_cache":            proxyOK,
+	"proxy_get_buffer_bytes":             proxyGetBufferBytes,
+	"proxy_get_buffer_status":            proxyGetBufferStatus,
+	"proxy_set_buffer_bytes":             proxySetBufferBytes,
+	"proxy_get_configuration":            proxyGetConfiguration,
+	"proxy_get_header_map_pairs":         proxyGetHeaderMapPairs,
+	"proxy_set_header_map_pairs":         proxySetHeaderMapPairs,
+	"proxy_get_header_map_size":          proxyGetHeaderMapSize,
+	"proxy_get_header_map_value":         proxyGetHeaderMapValue,
+	"proxy_add_header_map_value":         proxyAddHeaderMapValue,
+	"proxy_replace_header_map_value":     proxyReplaceHeaderMapValue,
+	"proxy_remove_header_map_value":      proxyRemoveHeaderMapValue,
+	"proxy_send_local_response":          proxySendLocalResponse,
+	"proxy_get_property":                 proxyGetProperty,
+	"proxy_set_property":                 proxySetProperty,
+	"proxy_get_shared_data":              proxyGetSharedData,
+	"proxy_set_shared_data":              proxySetSharedData,
+	"proxy_register_shared_queue":        proxyRegisterSharedQueue,
+	"proxy_resolve_shared_queue":         proxyResolveSharedQueue,
+	"proxy_enqueue_shared_queue":         proxyEnqueueSharedQueue,
+	"proxy_dequeue_shared_queue":         proxyDequeueSharedQueue,
+	"proxy_define_metric":                proxyDefineMetric,
+	"proxy_increment_metric":             proxyIncrementMetric,
+	"proxy_record_metric":                proxyRecordMetric,
+	"proxy_get_metric":                   proxyGetMetric,
+	"proxy_call_foreign_function":        proxyCallForeignFunction,
+}
+
+// instantiateHostModule instantiates the functions imported by the extension from the
+// host module, with the signatures in the module. Functions that are not implemented
+// return an "unimplemented" status.
+func (h *Host) instantiateHostModule(ctx context.Context, compiled wazero.CompiledModule) error {
+	builder := h.runtime.NewHostModuleBuilder(wasm.HostModule)
+
+	for _, def := range compiled.ImportedFunctions() {
+		module, name, _ := def.Import()
+		if module != wasm.HostModule {
+			continue
+		}
+
+		fn, ok := hostFunctions[name]
+		if !ok {
+			fn = func(h *Host, _ context.Context, _ api.Module, _ []uint64) Status {
+				h.warnOnce(name, "host function %s is not implemented in the embedded host", name)
+				return StatusUnimplemented
+			}
+		}
+
+		numParams, numResults := len(def.ParamTypes()), len(def.ResultTypes())
+		builder.NewFunctionBuilder().
+			WithGoModuleFunction(api.GoModuleFunc(func(ctx context.Context, mod api.Module, stack []uint64) {
+				params := append([]uint64(nil), stack[:numParams]...)
+				status := fn(h, ctx, mod, params)
+				if numResults > 0 {
+					stack[0] = uint64(status)
+				}
+			}), def.ParamTypes(), def.ResultTypes()).
+			Export(name)
+	}
+
+	_, err := builder.Instantiate(ctx)
+	return err
+}
+
+// warnOnce logs a warning from the host, only once for a key
+func (h *Host) warnOnce(key, format string, args ...any) {
+	if h.warned[key] {
+		return
+	}
+	h.warned[key] = true
+	h.log(LogLevelWarn, SourceHost, fmt.Sprintf(format, args...))
+}
+
+///////////////////////////////////////////////////////////////////////
+// memory
+///////////////////////////////////////////////////////////////////////
+
+// read reads some bytes from the memory of the extension
+func read(mod api.Module, ptr, size uint64) ([]byte, error) {
+	if size == 0 {
+		return nil, nil
+	}
+	data, ok := mod.Memory().Read(uint32(ptr), uint32(size))
+	if !ok {
+		return nil, errMemory
+	}
+	return append([]byte(nil), data...), nil
+}
+
+// readString reads a string from the memory of the extension
+func readString(mod api.Module, ptr, size uint64) (string, error) {
+	data, err := read(mod, ptr, size)
+	return string(data), err
+}
+
+// writeUint32 writes a 32 bits integer in the memory of the extension
+func writeUint32(mod api.Module, ptr uint64, v uint32) error {
+	if !mod.Memory().WriteUint32Le(uint32(ptr), v) {
+		return errMemory
+	}
+	return nil
+}
+
+// writeUint64 writes a 64 bits integer in the memory of the extension
+func writeUint64(mod api.Module, ptr uint64, v uint64) error {
+	if !mod.Memory().WriteUint64Le(uint32(ptr), v) {
+		return errMemory
+	}
+	return nil
+}
+
+// allocate allocates memory in the extension, with proxy_on_memory_allocate (or malloc in old ABIs)
+func (h *Host) allocate(ctx context.Context, mod api.Module, size int) (uint32, error) {
+	fn := mod.ExportedFunction("proxy_on_memory_allocate")
+	if fn == nil {
+		fn = mod.ExportedFunction("malloc")
+	}
+	if fn == nil {
+		return 0, errors.New("no memory allocation function exported")
+	}
+
+	res, err := fn.Call(ctx, uint64(size))
+	if err != nil {
+		return 0, err
+	}
+	h.allocations++
+	h.allocatedBytes += int64(size)
+	if len(res) == 0 || (res[0] == 0 && size > 0) {
+		return 0, errors.New("memory allocation failed")
+	}
+	return uint32(res[0]), nil
+}
+
+// copyOut copies some data to memory allocated in the extension, returning the pointer
+// and the size in the locations given by the extension
+func (h *Host) copyOut(ctx context.Context, mod api.Module, data []byte, retPtr, retSize uint64) Status {
+	var ptr uint32
+	if len(data) > 0 {
+		var err error
+		if ptr, err = h.allocate(ctx, mod, len(data)); err != nil {
+			h.log(LogLevelError, SourceHost, fmt.Sprintf("when allocating memory in the extension: %s", err))
+			return StatusInternalFailure
+		}
+		if !mod.Memory().Write(ptr, data) {
+			return StatusInvalidMemoryAccess
+		}
+	}
+
+	if writeUint32(mod, retPtr, ptr) != nil || writeUint32(mod, retSize, uint32(len(data))) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+///////////////////////////////////////////////////////////////////////
+// logging and time
+///////////////////////////////////////////////////////////////////////
+
+func proxyOK(_ *Host, _ context.Context, _ api.Module, _ []uint64) Status {
+	return StatusOK
+}
+
+func proxyLog(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	msg, err := readString(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	h.log(LogLevel(p[0]), SourceExtension, msg)
+	return StatusOK
+}
+
+func proxyGetLogLevel(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	if writeUint32(mod, p[0], uint32(h.opts.logLevel)) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxyGetCurrentTimeNanoseconds(_ *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	if writeUint64(mod, p[0], uint64(time.Now().UnixNano())) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+///////////////////////////////////////////////////////////////////////
+// buffers
+///////////////////////////////////////////////////////////////////////
+
+// buffer returns a pointer to a buffer, or nil if it is not available
+func (h *Host) buffer(bufferType uint64) *[]byte {
+	switch bufferType {
+	case bufferVMConfiguration:
+		return &h.opts.vmConfiguration
+	case bufferPluginConfiguration:
+		return &h.opts.pluginConfiguration
+	}
+	if h.stream == nil {
+		return nil
+	}
+	switch bufferType {
+	case bufferHTTPRequestBody:
+		return (*[]byte)(&h.stream.request.Body)
+	case bufferHTTPResponseBody:
+		return (*[]byte)(&h.stream.response.Body)
+	}
+	return nil
+}
+
+func proxyGetBufferBytes(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	buf := h.buffer(p[0])
+	if buf == nil {
+		return StatusNotFound
+	}
+
+	start, size := p[1], p[2]
+	if start > uint64(len(*buf)) {
+		return StatusBadArgument
+	}
+	end := start + size
+	if end > uint64(len(*buf)) {
+		end = uint64(len(*buf))
+	}
+	return h.copyOut(ctx, mod, (*buf)[start:end], p[3], p[4])
+}
+
+func proxyGetBufferStatus(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	buf := h.buffer(p[0])
+	if buf == nil {
+		return StatusNotFound
+	}
+	if writeUint32(mod, p[1], uint32(len(*buf))) != nil || writeUint32(mod, p[2], 0) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxySetBufferBytes(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	buf := h.buffer(p[0])
+	if buf == nil {
+		return StatusNotFound
+	}
+	data, err := read(mod, p[3], p[4])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+
+	// replace the bytes in [start, start+size) with the data
+	start, end := p[1], p[1]+p[2]
+	if start > uint64(len(*buf)) {
+		start = uint64(len(*buf))
+	}
+	if end > uint64(len(*buf)) {
+		end = uint64(len(*buf))
+	}
+	res := append([]byte{}, (*buf)[:start]...)
+	res = append(res, data...)
+	*buf = append(res, (*buf)[end:]...)
+	return StatusOK
+}
+
+// proxyGetConfiguration is the way the configuration was obtained in the 0.1.0 ABI
+func proxyGetConfiguration(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	return h.copyOut(ctx, mod, h.opts.pluginConfiguration, p[0], p[1])
+}
+
+///////////////////////////////////////////////////////////////////////
+// headers and trailers
+///////////////////////////////////////////////////////////////////////
+
+// headerMap returns a pointer to a header map, or nil if it is not available
+func (h *Host) headerMap(mapType uint64) *Headers {
+	if h.stream == nil {
+		return nil
+	}
+	switch mapType {
+	case mapHTTPRequestHeaders:
+		return &h.stream.request.Headers
+	case mapHTTPRequestTrailers:
+		return &h.stream.request.Trailers
+	case mapHTTPResponseHeaders:
+		return &h.stream.response.Headers
+	case mapHTTPResponseTrailers:
+		return &h.stream.response.Trailers
+	}
+	return nil
+}
+
+func proxyGetHeaderMapPairs(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	return h.copyOut(ctx, mod, m.serialize(), p[1], p[2])
+}
+
+func proxySetHeaderMapPairs(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	data, err := read(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	headers, err := deserializeHeaders(data)
+	if err != nil {
+		return StatusBadArgument
+	}
+	*m = headers
+	return StatusOK
+}
+
+func proxyGetHeaderMapSize(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	if writeUint32(mod, p[1], uint32(len(m.serialize()))) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxyGetHeaderMapValue(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	key, err := readString(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	value, ok := m.Get(key)
+	if !ok {
+		return StatusNotFound
+	}
+	return h.copyOut(ctx, mod, []byte(value), p[3], p[4])
+}
+
+// readKeyValue reads the key and value passed to a host function
+func readKeyValue(mod api.Module, p []uint64) (string, string, error) {
+	key, err := readString(mod, p[0], p[1])
+	if err != nil {
+		return "", "", err
+	}
+	value, err := readString(mod, p[2], p[3])
+	return key, value, err
+}
+
+func proxyAddHeaderMapValue(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	key, value, err := readKeyValue(mod, p[1:])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	*m = append(*m, Header{Name: key, Value: value})
+	return StatusOK
+}
+
+func proxyReplaceHeaderMapValue(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	key, value, err := readKeyValue(mod, p[1:])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	*m = m.Set(key, value)
+	return StatusOK
+}
+
+func proxyRemoveHeaderMapValue(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	m := h.headerMap(p[0])
+	if m == nil {
+		return StatusNotFound
+	}
+	key, err := readString(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	*m = m.Remove(key, 0)
+	return StatusOK
+}
+
+func proxySendLocalResponse(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	if h.stream == nil {
+		return StatusBadArgument
+	}
+
+	details, err := readString(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	body, err := read(mod, p[3], p[4])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	data, err := read(mod, p[5], p[6])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	headers, err := deserializeHeaders(data)
+	if err != nil {
+		return StatusBadArgument
+	}
+
+	h.stream.localResponse = &LocalResponse{
+		StatusCode: uint32(p[0]),
+		Details:    details,
+		Body:       body,
+		Headers:    headers,
+		GRPCStatus: int32(p[7]),
+	}
+	return StatusOK
+}
+
+///////////////////////////////////////////////////////////////////////
+// properties
+///////////////////////////////////////////////////////////////////////
+
+// propertyPath converts a path of a property (segments separated by null characters) to a dotted path
+func propertyPath(mod api.Module, ptr, size uint64) (string, error) {
+	path, err := readString(mod, ptr, size)
+	if err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(strings.TrimRight(path, "\x00"), "\x00", "."), nil
+}
+
+// streamProperty returns the properties of the current HTTP stream
+func (h *Host) streamProperty(path string) ([]byte, bool) {
+	if h.stream == nil {
+		return nil, false
+	}
+	var header string
+	switch path {
+	case "request.path":
+		header = ":path"
+	case "request.method":
+		header = ":method"
+	case "request.host":
+		header = ":authority"
+	case "request.scheme":
+		header = ":scheme"
+	case "response.code":
+		header = ":status"
+	default:
+		if name, ok := strings.CutPrefix(path, "request.headers."); ok {
+			header = name
+		} else {
+			return nil, false
+		}
+	}
+	if v, ok := h.stream.request.Headers.Get(header); ok && !strings.HasPrefix(path, "response.") {
+		return []byte(v), true
+	}
+	if v, ok := h.stream.response.Headers.Get(header); ok && strings.HasPrefix(path, "response.") {
+		return []byte(v), true
+	}
+	return nil, false
+}
+
+func proxyGetProperty(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	path, err := propertyPath(mod, p[0], p[1])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	value, ok := h.properties[path]
+	if !ok {
+		if value, ok = h.streamProperty(path); !ok {
+			return StatusNotFound
+		}
+	}
+	return h.copyOut(ctx, mod, value, p[2], p[3])
+}
+
+func proxySetProperty(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	path, err := propertyPath(mod, p[0], p[1])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	value, err := read(mod, p[2], p[3])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	h.properties[path] = value
+	return StatusOK
+}
+
+///////////////////////////////////////////////////////////////////////
+// shared data and queues
+///////////////////////////////////////////////////////////////////////
+
+// sharedValue is a value in the shared data, with its CAS
+type sharedValue struct {
+	data []byte
+	cas  uint32
+}
+
+func proxyGetSharedData(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	key, err := readString(mod, p[0], p[1])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	v, ok := h.sharedData[key]
+	if !ok {
+		return StatusNotFound
+	}
+	if status := h.copyOut(ctx, mod, v.data, p[2], p[3]); status != StatusOK {
+		return status
+	}
+	if writeUint32(mod, p[4], v.cas) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxySetSharedData(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	key, err := readString(mod, p[0], p[1])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	value, err := read(mod, p[2], p[3])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+
+	v, ok := h.sharedData[key]
+	if !ok {
+		v = &sharedValue{}
+		h.sharedData[key] = v
+	}
+	if cas := uint32(p[4]); cas != 0 && cas != v.cas {
+		return StatusCasMismatch
+	}
+	v.data = value
+	v.cas++
+	return StatusOK
+}
+
+func proxyRegisterSharedQueue(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	name, err := readString(mod, p[0], p[1])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	id, ok := h.queues[name]
+	if !ok {
+		id = uint32(len(h.queues) + 1)
+		h.queues[name] = id
+	}
+	if writeUint32(mod, p[2], id) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxyResolveSharedQueue(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	// there is only one VM, so the VM ID is ignored
+	name, err := readString(mod, p[2], p[3])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	id, ok := h.queues[name]
+	if !ok {
+		return StatusNotFound
+	}
+	if writeUint32(mod, p[4], id) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxyEnqueueSharedQueue(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	id := uint32(p[0])
+	if id == 0 || id > uint32(len(h.queues)) {
+		return StatusNotFound
+	}
+	data, err := read(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	h.queueData[id] = append(h.queueData[id], data)
+	return StatusOK
+}
+
+func proxyDequeueSharedQueue(h *Host, ctx context.Context, mod api.Module, p []uint64) Status {
+	id := uint32(p[0])
+	if id == 0 || id > uint32(len(h.queues)) {
+		return StatusNotFound
+	}
+	if len(h.queueData[id]) == 0 {
+		return StatusEmpty
+	}
+	data := h.queueData[id][0]
+	h.queueData[id] = h.queueData[id][1:]
+	return h.copyOut(ctx, mod, data, p[1], p[2])
+}
+
+///////////////////////////////////////////////////////////////////////
+// metrics
+///////////////////////////////////////////////////////////////////////
+
+// metric is a metric defined by the extension
+type metric struct {
+	name  string
+	value uint64
+}
+
+func proxyDefineMetric(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	name, err := readString(mod, p[1], p[2])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	id := uint32(len(h.metrics) + 1)
+	h.metrics[id] = &metric{name: name}
+	if writeUint32(mod, p[3], id) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+func proxyIncrementMetric(h *Host, _ context.Context, _ api.Module, p []uint64) Status {
+	m, ok := h.metrics[uint32(p[0])]
+	if !ok {
+		return StatusNotFound
+	}
+	m.value = uint64(int64(m.value) + int64(p[1]))
+	return StatusOK
+}
+
+func proxyRecordMetric(h *Host, _ context.Context, _ api.Module, p []uint64) Status {
+	m, ok := h.metrics[uint32(p[0])]
+	if !ok {
+		return StatusNotFound
+	}
+	m.value = p[1]
+	return StatusOK
+}
+
+func proxyGetMetric(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	m, ok := h.metrics[uint32(p[0])]
+	if !ok {
+		return StatusNotFound
+	}
+	if writeUint64(mod, p[1], m.value) != nil {
+		return StatusInvalidMemoryAccess
+	}
+	return StatusOK
+}
+
+// Metrics returns the current values of the metrics defined by the extension, by name
+func (h *Host) Metrics() map[string]uint64 {
+	res := map[string]uint64{}
+	for _, m := range h.metrics {
+		res[m.name] = m.value
+	}
+	return res
+}
+
+///////////////////////////////////////////////////////////////////////
+// foreign functions
+///////////////////////////////////////////////////////////////////////
+
+func proxyCallForeignFunction(h *Host, _ context.Context, mod api.Module, p []uint64) Status {
+	name, err := readString(mod, p[0], p[1])
+	if err != nil {
+		return StatusInvalidMemoryAccess
+	}
+	h.warnOnce("foreign:"+name, "foreign function %s is not available in the embedded host", name)
+	return StatusNotFound
+}
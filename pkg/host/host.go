@@ -0,0 +1,414 @@
+// Package host is an embedded Proxy-Wasm host, for running Proxy-Wasm extensions
+// without a proxy. Extensions run in a pure-Go Wasm runtime (wazero), with an
+// emulated Proxy-Wasm ABI: plugin and VM configuration, HTTP headers, bodies and
+// trailers, local responses, logging, properties, shared data and queues, and metrics.
+//
+// Features that need a real proxy (like HTTP or gRPC calls to other services)
+// are not implemented, and the host functions return an "unimplemented" status.
+package host
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+const (
+	// DefaultTimeout is the default maximum time for a callback
+	DefaultTimeout = 10 * time.Second
+
+	// DefaultMemoryLimitPages is the default limit for the memory of extensions, in pages of 64KiB (256MiB)
+	DefaultMemoryLimitPages = 4096
+
+	// DefaultRootID is the default root ID of the plugin
+	DefaultRootID = ""
+
+	// rootContextID is the ID of the root context
+	rootContextID = 1
+)
+
+var (
+	// ErrTimeout is returned when a callback runs for longer than the timeout
+	ErrTimeout = errors.New("timeout")
+
+	// ErrFailed is returned when the extension reports a failure (like a configuration that has been rejected)
+	ErrFailed = errors.New("failed")
+)
+
+// TrapError is returned when the extension traps (panics, accesses memory out of bounds...)
+type TrapError struct {
+	// Callback is the callback that trapped
+	Callback string
+	Err      error
+}
+
+func (e *TrapError) Error() string {
+	return fmt.Sprintf("%s trapped: %s", e.Callback, e.Err)
+}
+
+func (e *TrapError) Unwrap() error {
+	return e.Err
+}
+
+// options for the host
+type options struct {
+	pluginConfiguration []byte
+	vmConfiguration     []byte
+	properties          map[string]string
+	rootID              string
+	logLevel            LogLevel
+	logWriter           io.Writer
+	timeout             time.Duration
+	memoryLimitPages    uint32
+	compilationCache    wazero.CompilationCache
+}
+
+// Option is an option for the host
+type Option func(*options)
+
+// WithPluginConfiguration sets the configuration of the plugin
+func WithPluginConfiguration(config []byte) Option {
+	return func(o *options) {
+		o.pluginConfiguration = config
+	}
+}
+
+// WithVMConfiguration sets the configuration of the VM
+func WithVMConfiguration(config []byte) Option {
+	return func(o *options) {
+		o.vmConfiguration = config
+	}
+}
+
+// WithProperties sets some properties (like "node.id") obtained by the extension with proxy_get_property.
+// Paths are joined with dots.
+func WithProperties(properties map[string]string) Option {
+	return func(o *options) {
+		o.properties = properties
+	}
+}
+
+// WithRootID sets the root ID of the plugin
+func WithRootID(id string) Option {
+	return func(o *options) {
+		o.rootID = id
+	}
+}
+
+// WithLogLevel sets the log level reported to the extension
+func WithLogLevel(level LogLevel) Option {
+	return func(o *options) {
+		o.logLevel = level
+	}
+}
+
+// WithLogWriter sets a writer where logs are also written as they are produced
+func WithLogWriter(w io.Writer) Option {
+	return func(o *options) {
+		o.logWriter = w
+	}
+}
+
+// WithTimeout sets the maximum time for a callback, so runaway extensions are interrupted
+func WithTimeout(timeout time.Duration) Option {
+	return func(o *options) {
+		o.timeout = timeout
+	}
+}
+
+// WithMemoryLimitPages sets the limit for the memory of the extension, in pages of 64KiB
+func WithMemoryLimitPages(pages uint32) Option {
+	return func(o *options) {
+		o.memoryLimitPages = pages
+	}
+}
+
+// WithCompilationCache sets a cache for the compiled modules, so extensions are compiled
+// only once when creating many hosts for them
+func WithCompilationCache(cache wazero.CompilationCache) Option {
+	return func(o *options) {
+		o.compilationCache = cache
+	}
+}
+
+// Host runs a Proxy-Wasm extension in an embedded runtime.
+// Hosts are not safe for concurrent use.
+type Host struct {
+	opts options
+
+	runtime wazero.Runtime
+	module  api.Module
+
+	// Module is the Wasm module of the extension
+	Module *wasm.Module
+	// ProxyWasm is the Proxy-Wasm information of the extension
+	ProxyWasm *wasm.ProxyWasm
+
+	logs      []LogEntry
+	callbacks []Callback
+
+	nextContextID uint32
+	stream        *stream
+
+	properties map[string][]byte
+	sharedData map[string]*sharedValue
+	queues     map[string]uint32
+	queueData  map[uint32][][]byte
+	metrics    map[uint32]*metric
+	warned     map[string]bool
+
+	allocations    int
+	allocatedBytes int64
+}
+
+// New compiles and instantiates a Proxy-Wasm extension, running its initialization
+// (the "_initialize" or "_start" functions). The VM and the plugin are started with Start.
+func New(ctx context.Context, data []byte, opts ...Option) (*Host, error) {
+	h := &Host{
+		opts: options{
+			rootID:           DefaultRootID,
+			logLevel:         LogLevelInfo,
+			timeout:          DefaultTimeout,
+			memoryLimitPages: DefaultMemoryLimitPages,
+		},
+		nextContextID: rootContextID + 1,
+		properties:    map[string][]byte{},
+		sharedData:    map[string]*sharedValue{},
+		queues:        map[string]uint32{},
+		queueData:     map[uint32][][]byte{},
+		metrics:       map[uint32]*metric{},
+		warned:        map[string]bool{},
+	}
+	for _, opt := range opts {
+		opt(&h.opts)
+	}
+	for k, v := range h.opts.properties {
+		h.properties[k] = []byte(v)
+	}
+	h.properties["plugin_root_id"] = []byte(h.opts.rootID)
+
+	m, pw, err := wasm.ValidateProxyWasm(data)
+	if err != nil {
+		return nil, err
+	}
+	h.Module, h.ProxyWasm = m, pw
+
+	runtimeConfig := wazero.NewRuntimeConfig().
+		WithCloseOnContextDone(true).
+		WithMemoryLimitPages(h.opts.memoryLimitPages)
+	if h.opts.compilationCache != nil {
+		runtimeConfig = runtimeConfig.WithCompilationCache(h.opts.compilationCache)
+	}
+	h.runtime = wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
+
+	compiled, err := h.runtime.CompileModule(ctx, data)
+	if err != nil {
+		h.Close(ctx)
+		return nil, fmt.Errorf("when compiling module: %w", err)
+	}
+
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, h.runtime); err != nil {
+		h.Close(ctx)
+		return nil, fmt.Errorf("when instantiating WASI: %w", err)
+	}
+	if err := h.instantiateHostModule(ctx, compiled); err != nil {
+		h.Close(ctx)
+		return nil, fmt.Errorf("when instantiating the Proxy-Wasm host: %w", err)
+	}
+
+	config := wazero.NewModuleConfig().
+		WithStartFunctions().
+		WithStdout(&logWriter{host: h, source: SourceStdout, level: LogLevelInfo}).
+		WithStderr(&logWriter{host: h, source: SourceStderr, level: LogLevelError}).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep()
+
+	h.module, err = h.runtime.InstantiateModule(ctx, compiled, config)
+	if err != nil {
+		h.Close(ctx)
+		return nil, fmt.Errorf("when instantiating module: %w", err)
+	}
+
+	// reactors are initialized with "_initialize", and commands (like TinyGo modules) with "_start"
+	for _, name := range []string{"_initialize", "_start"} {
+		if h.module.ExportedFunction(name) == nil {
+			continue
+		}
+		if _, err := h.call(ctx, name); err != nil {
+			h.Close(ctx)
+			return nil, err
+		}
+		break
+	}
+
+	return h, nil
+}
+
+// Start starts the VM and the plugin, calling proxy_on_vm_start and proxy_on_configure
+// with the VM and plugin configurations. It returns an error wrapping ErrFailed when
+// the extension rejects any of them.
+func (h *Host) Start(ctx context.Context) error {
+	if _, err := h.callback(ctx, "proxy_on_context_create", rootContextID, 0); err != nil {
+		return err
+	}
+
+	ok, err := h.callback(ctx, "proxy_on_vm_start", rootContextID, uint64(len(h.opts.vmConfiguration)))
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return fmt.Errorf("proxy_on_vm_start %w", ErrFailed)
+	}
+
+	ok, err = h.callback(ctx, "proxy_on_configure", rootContextID, uint64(len(h.opts.pluginConfiguration)))
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return fmt.Errorf("proxy_on_configure %w: the plugin configuration has been rejected", ErrFailed)
+	}
+
+	return nil
+}
+
+// Logs returns all the messages logged so far
+func (h *Host) Logs() []LogEntry {
+	return h.logs
+}
+
+// Callbacks returns all the callbacks called so far
+func (h *Host) Callbacks() []Callback {
+	return h.callbacks
+}
+
+// ResetHistory forgets the logs and callbacks recorded so far, so long running hosts do not grow
+func (h *Host) ResetHistory() {
+	h.logs, h.callbacks = nil, nil
+}
+
+// Allocations returns the number of allocations made by the host in the memory of the
+// extension (for passing headers, bodies...), and the total number of bytes allocated
+func (h *Host) Allocations() (int, int64) {
+	return h.allocations, h.allocatedBytes
+}
+
+// MemorySize returns the current size of the memory of the extension, in bytes
+func (h *Host) MemorySize() uint32 {
+	if h.module == nil || h.module.Memory() == nil {
+		return 0
+	}
+	return h.module.Memory().Size()
+}
+
+// Close releases all the resources of the host
+func (h *Host) Close(ctx context.Context) error {
+	if h.runtime == nil {
+		return nil
+	}
+	return h.runtime.Close(ctx)
+}
+
+// callback calls a callback exported by the extension, if it exists, returning 0 otherwise.
+// Extra arguments are ignored, as the callbacks in old ABIs have less parameters.
+func (h *Host) callback(ctx context.Context, name string, args ...uint64) (uint64, error) {
+	fn := h.module.ExportedFunction(name)
+	if fn == nil {
+		return 0, nil
+	}
+	if n := len(fn.Definition().ParamTypes()); n < len(args) {
+		args = args[:n]
+	}
+
+	start := time.Now()
+	res, err := h.call(ctx, name, args...)
+	cb := Callback{Name: name, Duration: time.Since(start), Action: ActionContinue}
+	if len(res) > 0 && isStreamCallback(name) {
+		cb.Action = Action(res[0])
+	}
+	h.callbacks = append(h.callbacks, cb)
+
+	if err != nil || len(res) == 0 {
+		return 0, err
+	}
+	return res[0], nil
+}
+
+// isStreamCallback returns true for the callbacks of HTTP streams, which return an Action
+func isStreamCallback(name string) bool {
+	for _, suffix := range []string{"_headers", "_body", "_trailers"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// call calls a function exported by the extension, with a timeout
+func (h *Host) call(ctx context.Context, name string, args ...uint64) ([]uint64, error) {
+	fn := h.module.ExportedFunction(name)
+	if fn == nil {
+		return nil, fmt.Errorf("function %s is not exported", name)
+	}
+
+	if h.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.opts.timeout)
+		defer cancel()
+	}
+
+	res, err := fn.Call(ctx, args...)
+	if err != nil {
+		var exitErr *sys.ExitError
+		if errors.As(err, &exitErr) {
+			switch exitErr.ExitCode() {
+			case sys.ExitCodeDeadlineExceeded:
+				return nil, &TrapError{Callback: name, Err: fmt.Errorf("%w: running for more than %s", ErrTimeout, h.opts.timeout)}
+			case 0:
+				return nil, fmt.Errorf("%s: the extension exited", name)
+			}
+		}
+		return nil, &TrapError{Callback: name, Err: err}
+	}
+	return res, nil
+}
+
+// log records a log message
+func (h *Host) log(level LogLevel, source, msg string) {
+	entry := LogEntry{Level: level, Message: msg, Source: source}
+	h.logs = append(h.logs, entry)
+	if h.opts.logWriter != nil {
+		fmt.Fprintln(h.opts.logWriter, entry)
+	}
+}
+
+// logWriter writes the standard output (or error) of the extension as log messages, one per line
+type logWriter struct {
+	host   *Host
+	source string
+	level  LogLevel
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := strings.IndexByte(string(w.buf), '\n')
+		if i < 0 {
+			break
+		}
+		w.host.log(w.level, w.source, string(w.buf[:i]))
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
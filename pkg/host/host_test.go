@@ -0,0 +1,219 @@
+package host
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+const (
+	i32      = wasmtest.I32
+	i32Const = wasmtest.I32Const
+	call     = wasmtest.Call
+	drop     = wasmtest.Drop
+)
+
+// testModule is a small Proxy-Wasm extension, with:
+//   - a bump allocator for proxy_on_memory_allocate
+//   - proxy_on_configure logging "hello" at the "info" level
+//   - proxy_on_request_headers setting the "x-test: yes" request header
+//   - proxy_on_response_headers looping forever
+var testModule = wasmtest.Module(
+	wasmtest.Section(wasmtest.SectionType, wasmtest.Vector(
+		[]byte{0x60, 3, i32, i32, i32, 1, i32},           // 0: (i32, i32, i32) -> i32
+		[]byte{0x60, 5, i32, i32, i32, i32, i32, 1, i32}, // 1: (i32 x 5) -> i32
+		[]byte{0x60, 1, i32, 1, i32},                     // 2: (i32) -> i32
+		[]byte{0x60, 2, i32, i32, 0},                     // 3: (i32, i32) -> ()
+		[]byte{0x60, 0, 0},                               // 4: () -> ()
+		[]byte{0x60, 2, i32, i32, 1, i32},                // 5: (i32, i32) -> i32
+	)),
+	wasmtest.Imports(
+		wasmtest.ImportFunc("env", "proxy_log", 0),
+		wasmtest.ImportFunc("env", "proxy_replace_header_map_value", 1),
+	),
+	wasmtest.Section(wasmtest.SectionFunction, wasmtest.Vector([]byte{2}, []byte{3}, []byte{4}, []byte{5}, []byte{5}, []byte{0}, []byte{0})),
+	wasmtest.Section(wasmtest.SectionMemory, wasmtest.Vector([]byte{0x00, 0x01})),
+	// the allocation pointer, starting at 4096
+	wasmtest.Section(wasmtest.SectionGlobal, wasmtest.Vector([]byte{i32, 0x01, i32Const, 0x80, 0x20, 0x0b})),
+	wasmtest.Exports(
+		wasmtest.Export("memory", wasmtest.KindMemory, 0),
+		wasmtest.Export("proxy_on_memory_allocate", wasmtest.KindFunction, 2),
+		wasmtest.Export("proxy_on_context_create", wasmtest.KindFunction, 3),
+		wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 4),
+		wasmtest.Export("proxy_on_vm_start", wasmtest.KindFunction, 5),
+		wasmtest.Export("proxy_on_configure", wasmtest.KindFunction, 6),
+		wasmtest.Export("proxy_on_request_headers", wasmtest.KindFunction, 7),
+		wasmtest.Export("proxy_on_response_headers", wasmtest.KindFunction, 8),
+	),
+	wasmtest.Section(wasmtest.SectionCode, wasmtest.Vector(
+		// proxy_on_memory_allocate: global.get 0; global.get 0; local.get 0; i32.add; global.set 0
+		wasmtest.Func(0x23, 0, 0x23, 0, 0x20, 0, 0x6a, 0x24, 0),
+		wasmtest.Func(),
+		wasmtest.Func(),
+		wasmtest.Func(i32Const, 1),
+		wasmtest.Func(i32Const, 2, i32Const, 0, i32Const, 5, call, 0, drop, i32Const, 1),
+		wasmtest.Func(i32Const, 0, i32Const, 16, i32Const, 6, i32Const, 32, i32Const, 3, call, 1, drop, i32Const, 0),
+		// loop { br 0 }
+		wasmtest.Func(0x03, 0x40, 0x0c, 0, 0x0b, i32Const, 0),
+	)),
+	wasmtest.Section(wasmtest.SectionData, wasmtest.Vector(
+		append([]byte{0x00, i32Const, 0, 0x0b}, wasmtest.Name("hello")...),
+		append([]byte{0x00, i32Const, 16, 0x0b}, wasmtest.Name("x-test")...),
+		append([]byte{0x00, i32Const, 32, 0x0b}, wasmtest.Name("yes")...),
+	)),
+)
+
+func newTestHost(t *testing.T, opts ...Option) *Host {
+	t.Helper()
+	ctx := context.Background()
+	h, err := New(ctx, testModule, opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { h.Close(ctx) })
+	return h
+}
+
+func TestHostStart(t *testing.T) {
+	h := newTestHost(t)
+	require.NoError(t, h.Start(context.Background()))
+
+	assert.Equal(t, wasm.ABIVersion021, h.ProxyWasm.ABIVersion)
+	assert.Equal(t, []LogEntry{{Level: LogLevelInfo, Message: "hello", Source: SourceExtension}}, h.Logs())
+
+	var names []string
+	for _, cb := range h.Callbacks() {
+		names = append(names, cb.Name)
+	}
+	assert.Equal(t, []string{"proxy_on_context_create", "proxy_on_vm_start", "proxy_on_configure"}, names)
+}
+
+func TestHostHTTP(t *testing.T) {
+	h := newTestHost(t, WithTimeout(100*time.Millisecond))
+	ctx := context.Background()
+	require.NoError(t, h.Start(ctx))
+
+	req := (&RequestSpec{Path: "/a"}).HTTPRequest()
+	res, err := h.HTTP(ctx, req, nil)
+
+	// the response headers callback loops forever, and it is interrupted
+	var trapErr *TrapError
+	require.ErrorAs(t, err, &trapErr)
+	assert.Equal(t, "proxy_on_response_headers", trapErr.Callback)
+	assert.ErrorIs(t, err, ErrTimeout)
+
+	// the request has been modified by the extension, but not the original one
+	value, ok := res.Request.Headers.Get("x-test")
+	assert.True(t, ok)
+	assert.Equal(t, "yes", value)
+	_, ok = req.Headers.Get("x-test")
+	assert.False(t, ok)
+
+	require.Len(t, res.Callbacks, 3)
+	assert.Equal(t, "proxy_on_request_headers", res.Callbacks[1].Name)
+	assert.Equal(t, ActionContinue, res.Callbacks[1].Action)
+}
+
+// readOut reads the data returned by a host function in the memory of the extension
+func readOut(t *testing.T, h *Host, retPtr, retSize uint32) []byte {
+	t.Helper()
+	ptr, ok := h.module.Memory().ReadUint32Le(retPtr)
+	require.True(t, ok)
+	size, ok := h.module.Memory().ReadUint32Le(retSize)
+	require.True(t, ok)
+	data, err := read(h.module, uint64(ptr), uint64(size))
+	require.NoError(t, err)
+	return data
+}
+
+func TestHostBuffers(t *testing.T) {
+	h := newTestHost(t, WithPluginConfiguration([]byte("0123456789")))
+	ctx := context.Background()
+	const retPtr, retSize = 100, 104
+
+	for name, tCase := range map[string]struct {
+		start, size    uint64
+		expected       []byte
+		expectedStatus Status
+	}{
+		"all":             {start: 0, size: 10, expected: []byte("0123456789")},
+		"middle":          {start: 2, size: 3, expected: []byte("234")},
+		"past the end":    {start: 8, size: 100, expected: []byte("89")},
+		"at the end":      {start: 10, size: 1, expected: nil},
+		"start too large": {start: 11, size: 1, expectedStatus: StatusBadArgument},
+	} {
+		t.Run(name, func(t *testing.T) {
+			status := proxyGetBufferBytes(h, ctx, h.module, []uint64{bufferPluginConfiguration, tCase.start, tCase.size, retPtr, retSize})
+			require.Equal(t, tCase.expectedStatus, status)
+			if status == StatusOK {
+				assert.Equal(t, tCase.expected, readOut(t, h, retPtr, retSize))
+			}
+		})
+	}
+
+	// the HTTP bodies are not available out of HTTP streams
+	assert.Equal(t, StatusNotFound, proxyGetBufferBytes(h, ctx, h.module, []uint64{bufferHTTPRequestBody, 0, 1, retPtr, retSize}))
+
+	// replace "234" with "x", using "hello" in the memory of the extension
+	require.Equal(t, StatusOK, proxySetBufferBytes(h, ctx, h.module, []uint64{bufferPluginConfiguration, 2, 3, 0, 1}))
+	assert.Equal(t, []byte("01h56789"), h.opts.pluginConfiguration)
+
+	// appending past the end
+	require.Equal(t, StatusOK, proxySetBufferBytes(h, ctx, h.module, []uint64{bufferPluginConfiguration, 100, 0, 1, 2}))
+	assert.Equal(t, []byte("01h56789el"), h.opts.pluginConfiguration)
+
+	// reading out of the memory of the extension
+	assert.Equal(t, StatusInvalidMemoryAccess, proxySetBufferBytes(h, ctx, h.module, []uint64{bufferPluginConfiguration, 0, 0, 1 << 20, 1}))
+}
+
+func TestHostSharedData(t *testing.T) {
+	h := newTestHost(t)
+	ctx := context.Background()
+	const retPtr, retSize, retCas = 100, 104, 108
+
+	// the key is "hello" and the values are "x-test" and "yes", in the memory of the extension
+	set := func(value, size uint64, cas uint64) Status {
+		return proxySetSharedData(h, ctx, h.module, []uint64{0, 5, value, size, cas})
+	}
+	get := func() ([]byte, uint32, Status) {
+		status := proxyGetSharedData(h, ctx, h.module, []uint64{0, 5, retPtr, retSize, retCas})
+		if status != StatusOK {
+			return nil, 0, status
+		}
+		cas, ok := h.module.Memory().ReadUint32Le(retCas)
+		require.True(t, ok)
+		return readOut(t, h, retPtr, retSize), cas, status
+	}
+
+	_, _, status := get()
+	assert.Equal(t, StatusNotFound, status)
+
+	require.Equal(t, StatusOK, set(16, 6, 0))
+	value, cas, status := get()
+	require.Equal(t, StatusOK, status)
+	assert.Equal(t, []byte("x-test"), value)
+	assert.Equal(t, uint32(1), cas)
+
+	// a stale CAS is rejected, and the value is not changed
+	require.Equal(t, StatusOK, set(32, 3, uint64(cas)))
+	assert.Equal(t, StatusCasMismatch, set(16, 6, uint64(cas)))
+	value, cas, _ = get()
+	assert.Equal(t, []byte("yes"), value)
+	assert.Equal(t, uint32(2), cas)
+
+	// a zero CAS always overwrites the value
+	require.Equal(t, StatusOK, set(16, 6, 0))
+	value, cas, _ = get()
+	assert.Equal(t, []byte("x-test"), value)
+	assert.Equal(t, uint32(3), cas)
+}
+
+func TestNewInvalidModule(t *testing.T) {
+	_, err := New(context.Background(), []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00})
+	assert.ErrorIs(t, err, wasm.ErrInvalidProxyWasm)
+}
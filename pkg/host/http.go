@@ -0,0 +1,141 @@
+package host
+
+import (
+	"context"
+)
+
+// HTTPRequest is an HTTP request (or response) going through the extension
+type HTTPRequest struct {
+	Headers  Headers `json:"headers"`
+	Body     Body    `json:"body,omitempty"`
+	Trailers Headers `json:"trailers,omitempty"`
+}
+
+// HTTPResponse is an HTTP response going through the extension
+type HTTPResponse = HTTPRequest
+
+// LocalResponse is a response sent by the extension with proxy_send_local_response,
+// instead of forwarding the request to the upstream
+type LocalResponse struct {
+	StatusCode uint32  `json:"statusCode"`
+	Details    string  `json:"details,omitempty"`
+	Body       Body    `json:"body,omitempty"`
+	Headers    Headers `json:"headers,omitempty"`
+	GRPCStatus int32   `json:"grpcStatus,omitempty"`
+}
+
+// HTTPResult is the result of running an HTTP request and response through the extension
+type HTTPResult struct {
+	// Request is the request, after the changes made by the extension
+	Request *HTTPRequest `json:"request"`
+	// Response is the response, after the changes made by the extension.
+	// It is nil when the extension sent a local response.
+	Response *HTTPResponse `json:"response,omitempty"`
+	// LocalResponse is the local response sent by the extension, if any
+	LocalResponse *LocalResponse `json:"localResponse,omitempty"`
+	// Callbacks are the callbacks called for the request
+	Callbacks []Callback `json:"callbacks"`
+	// Logs are the messages logged while processing the request
+	Logs []LogEntry `json:"logs"`
+}
+
+// stream is the HTTP stream being processed
+type stream struct {
+	contextID     uint32
+	request       HTTPRequest
+	response      HTTPResponse
+	localResponse *LocalResponse
+}
+
+// HTTP runs an HTTP request and its response through the extension, in a new HTTP context.
+// Headers, bodies and trailers are sent in a single callback each. Processing stops
+// (and the response is not sent) when the extension sends a local response.
+func (h *Host) HTTP(ctx context.Context, req *HTTPRequest, resp *HTTPResponse) (*HTTPResult, error) {
+	s := &stream{contextID: h.nextContextID}
+	h.nextContextID++
+	if req != nil {
+		s.request = HTTPRequest{Headers: req.Headers.Clone(), Body: append(Body{}, req.Body...), Trailers: req.Trailers.Clone()}
+	}
+	if resp != nil {
+		s.response = HTTPResponse{Headers: resp.Headers.Clone(), Body: append(Body{}, resp.Body...), Trailers: resp.Trailers.Clone()}
+	}
+
+	h.stream = s
+	defer func() { h.stream = nil }()
+
+	firstLog, firstCallback := len(h.logs), len(h.callbacks)
+	result := func() *HTTPResult {
+		res := &HTTPResult{
+			Request:       &s.request,
+			LocalResponse: s.localResponse,
+			Callbacks:     append([]Callback{}, h.callbacks[firstCallback:]...),
+			Logs:          append([]LogEntry{}, h.logs[firstLog:]...),
+		}
+		if s.localResponse == nil {
+			res.Response = &s.response
+		}
+		return res
+	}
+
+	id := uint64(s.contextID)
+	if _, err := h.callback(ctx, "proxy_on_context_create", id, rootContextID); err != nil {
+		return result(), err
+	}
+
+	steps := []func() error{
+		func() error { return h.sendMessage(ctx, id, "request", &s.request) },
+		func() error { return h.sendMessage(ctx, id, "response", &s.response) },
+	}
+	for _, step := range steps {
+		if err := step(); err != nil {
+			return result(), err
+		}
+		if s.localResponse != nil {
+			break
+		}
+	}
+
+	for _, name := range []string{"proxy_on_log", "proxy_on_done", "proxy_on_delete"} {
+		if _, err := h.callback(ctx, name, id); err != nil {
+			return result(), err
+		}
+	}
+
+	return result(), nil
+}
+
+// sendMessage sends the headers, body and trailers of a request (or a response) to the extension
+func (h *Host) sendMessage(ctx context.Context, id uint64, kind string, msg *HTTPRequest) error {
+	hasBody, hasTrailers := len(msg.Body) > 0, len(msg.Trailers) > 0
+
+	if _, err := h.callback(ctx, "proxy_on_"+kind+"_headers", id, uint64(len(msg.Headers)), eos(!hasBody && !hasTrailers)); err != nil {
+		return err
+	}
+	if h.stream.localResponse != nil {
+		return nil
+	}
+
+	if hasBody {
+		if _, err := h.callback(ctx, "proxy_on_"+kind+"_body", id, uint64(len(msg.Body)), eos(!hasTrailers)); err != nil {
+			return err
+		}
+		if h.stream.localResponse != nil {
+			return nil
+		}
+	}
+
+	if hasTrailers {
+		if _, err := h.callback(ctx, "proxy_on_"+kind+"_trailers", id, uint64(len(msg.Trailers))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eos converts an "end of stream" flag to an argument for callbacks
+func eos(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
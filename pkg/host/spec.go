@@ -0,0 +1,104 @@
+package host
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default values for requests
+const (
+	DefaultMethod    = "GET"
+	DefaultPath      = "/"
+	DefaultAuthority = "localhost"
+	DefaultScheme    = "http"
+	DefaultStatus    = 200
+)
+
+// RequestSpec describes an HTTP request, as found in files or in the command line
+type RequestSpec struct {
+	Method    string            `json:"method,omitempty"`
+	Path      string            `json:"path,omitempty"`
+	Authority string            `json:"authority,omitempty"`
+	Headers   map[string]string `json:"headers,omitempty"`
+	Body      string            `json:"body,omitempty"`
+	Trailers  map[string]string `json:"trailers,omitempty"`
+}
+
+// HTTPRequest returns the request, with the pseudo-headers (":method", ":path"...) first
+func (r *RequestSpec) HTTPRequest() *HTTPRequest {
+	headers := Headers{
+		{Name: ":method", Value: orDefault(r.Method, DefaultMethod)},
+		{Name: ":path", Value: orDefault(r.Path, DefaultPath)},
+		{Name: ":authority", Value: orDefault(r.Authority, DefaultAuthority)},
+		{Name: ":scheme", Value: DefaultScheme},
+	}
+	if r.Body != "" {
+		headers = append(headers, Header{Name: "content-length", Value: strconv.Itoa(len(r.Body))})
+	}
+	for _, h := range mapHeaders(r.Headers) {
+		headers = headers.Set(h.Name, h.Value)
+	}
+
+	return &HTTPRequest{
+		Headers:  headers,
+		Body:     Body(r.Body),
+		Trailers: mapHeaders(r.Trailers),
+	}
+}
+
+// ResponseSpec describes an HTTP response from the upstream, as found in files or in the command line
+type ResponseSpec struct {
+	Status   int               `json:"status,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"`
+	Body     string            `json:"body,omitempty"`
+	Trailers map[string]string `json:"trailers,omitempty"`
+}
+
+// HTTPResponse returns the response, with the ":status" pseudo-header first
+func (r *ResponseSpec) HTTPResponse() *HTTPResponse {
+	status := r.Status
+	if status == 0 {
+		status = DefaultStatus
+	}
+	headers := Headers{{Name: ":status", Value: strconv.Itoa(status)}}
+	if r.Body != "" {
+		headers = append(headers, Header{Name: "content-length", Value: strconv.Itoa(len(r.Body))})
+	}
+	for _, h := range mapHeaders(r.Headers) {
+		headers = headers.Set(h.Name, h.Value)
+	}
+
+	return &HTTPResponse{
+		Headers:  headers,
+		Body:     Body(r.Body),
+		Trailers: mapHeaders(r.Trailers),
+	}
+}
+
+// ExchangeSpec describes an HTTP request and the response from the upstream
+type ExchangeSpec struct {
+	Request  RequestSpec  `json:"request,omitempty"`
+	Response ResponseSpec `json:"response,omitempty"`
+}
+
+// mapHeaders converts some headers in a map to Headers, sorted by name
+// (and with lowercase names, as in HTTP/2)
+func mapHeaders(m map[string]string) Headers {
+	if len(m) == 0 {
+		return nil
+	}
+	res := make(Headers, 0, len(m))
+	for k, v := range m {
+		res = append(res, Header{Name: strings.ToLower(k), Value: v})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res
+}
+
+func orDefault(s, def string) string {
+	if s == "" {
+		return def
+	}
+	return s
+}
@@ -0,0 +1,99 @@
+package host
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestSpecHTTPRequest(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		spec     RequestSpec
+		expected *HTTPRequest
+	}{
+		"defaults": {
+			spec: RequestSpec{},
+			expected: &HTTPRequest{
+				Headers: Headers{
+					{Name: ":method", Value: "GET"},
+					{Name: ":path", Value: "/"},
+					{Name: ":authority", Value: "localhost"},
+					{Name: ":scheme", Value: "http"},
+				},
+				Body: Body(""),
+			},
+		},
+		"headers sorted after the pseudo-headers": {
+			spec: RequestSpec{
+				Method:    "POST",
+				Path:      "/api",
+				Authority: "example.com",
+				Headers:   map[string]string{"X-B": "2", "x-a": "1"},
+				Body:      "hello",
+				Trailers:  map[string]string{"Grpc-Status": "0"},
+			},
+			expected: &HTTPRequest{
+				Headers: Headers{
+					{Name: ":method", Value: "POST"},
+					{Name: ":path", Value: "/api"},
+					{Name: ":authority", Value: "example.com"},
+					{Name: ":scheme", Value: "http"},
+					{Name: "content-length", Value: "5"},
+					{Name: "x-a", Value: "1"},
+					{Name: "x-b", Value: "2"},
+				},
+				Body:     Body("hello"),
+				Trailers: Headers{{Name: "grpc-status", Value: "0"}},
+			},
+		},
+		"pseudo-headers and content length overridden in place": {
+			spec: RequestSpec{
+				Path:    "/a",
+				Headers: map[string]string{":path": "/b", "Content-Length": "1", "accept": "*/*"},
+				Body:    "hello",
+			},
+			expected: &HTTPRequest{
+				Headers: Headers{
+					{Name: ":method", Value: "GET"},
+					{Name: ":path", Value: "/b"},
+					{Name: ":authority", Value: "localhost"},
+					{Name: ":scheme", Value: "http"},
+					{Name: "content-length", Value: "1"},
+					{Name: "accept", Value: "*/*"},
+				},
+				Body: Body("hello"),
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.spec.HTTPRequest())
+		})
+	}
+}
+
+func TestResponseSpecHTTPResponse(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		spec     ResponseSpec
+		expected *HTTPResponse
+	}{
+		"defaults": {
+			spec:     ResponseSpec{},
+			expected: &HTTPResponse{Headers: Headers{{Name: ":status", Value: "200"}}, Body: Body("")},
+		},
+		"status first": {
+			spec: ResponseSpec{Status: 404, Headers: map[string]string{"Content-Type": "text/plain"}, Body: "no"},
+			expected: &HTTPResponse{
+				Headers: Headers{
+					{Name: ":status", Value: "404"},
+					{Name: "content-length", Value: "2"},
+					{Name: "content-type", Value: "text/plain"},
+				},
+				Body: Body("no"),
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.spec.HTTPResponse())
+		})
+	}
+}
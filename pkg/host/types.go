@@ -0,0 +1,236 @@
+package host
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Status is the result of a host function, as in the Proxy-Wasm ABI
+type Status uint32
+
+const (
+	StatusOK                  Status = 0
+	StatusNotFound            Status = 1
+	StatusBadArgument         Status = 2
+	StatusSerializationFailed Status = 3
+	StatusParseFailure        Status = 4
+	StatusInvalidMemoryAccess Status = 6
+	StatusEmpty               Status = 7
+	StatusCasMismatch         Status = 8
+	StatusInternalFailure     Status = 10
+	StatusUnimplemented       Status = 12
+)
+
+// Action is the result of the callbacks of HTTP streams, as in the Proxy-Wasm ABI
+type Action uint32
+
+const (
+	ActionContinue Action = 0
+	ActionPause    Action = 1
+)
+
+func (a Action) String() string {
+	switch a {
+	case ActionContinue:
+		return "continue"
+	case ActionPause:
+		return "pause"
+	}
+	return fmt.Sprintf("action(%d)", uint32(a))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (a Action) MarshalText() ([]byte, error) {
+	return []byte(a.String()), nil
+}
+
+// LogLevel is the level of the logs of an extension
+type LogLevel uint32
+
+const (
+	LogLevelTrace    LogLevel = 0
+	LogLevelDebug    LogLevel = 1
+	LogLevelInfo     LogLevel = 2
+	LogLevelWarn     LogLevel = 3
+	LogLevelError    LogLevel = 4
+	LogLevelCritical LogLevel = 5
+)
+
+var logLevelNames = []string{"trace", "debug", "info", "warn", "error", "critical"}
+
+func (l LogLevel) String() string {
+	if int(l) < len(logLevelNames) {
+		return logLevelNames[l]
+	}
+	return fmt.Sprintf("level(%d)", uint32(l))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (l LogLevel) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLogLevel parses the name of a log level
+func ParseLogLevel(s string) (LogLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return LogLevel(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown log level %q", s)
+}
+
+// LogEntry is a message logged by an extension (or by the host about the extension)
+type LogEntry struct {
+	Level   LogLevel `json:"level"`
+	Message string   `json:"message"`
+	// Source is "extension" for messages logged with proxy_log, "stdout" or "stderr"
+	// for the standard output, or "host" for messages from the host
+	Source string `json:"source"`
+}
+
+func (e LogEntry) String() string {
+	return fmt.Sprintf("[%s] %s", e.Level, e.Message)
+}
+
+// Log sources
+const (
+	SourceExtension = "extension"
+	SourceStdout    = "stdout"
+	SourceStderr    = "stderr"
+	SourceHost      = "host"
+)
+
+// Callback is a call to a callback exported by the extension
+type Callback struct {
+	Name     string        `json:"name"`
+	Action   Action        `json:"action"`
+	Duration time.Duration `json:"duration"`
+}
+
+// Header is an HTTP header (or pseudo-header, like ":path")
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Headers are HTTP headers, in order
+type Headers []Header
+
+// Get returns the value of a header (names are case insensitive)
+func (h Headers) Get(name string) (string, bool) {
+	for _, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			return header.Value, true
+		}
+	}
+	return "", false
+}
+
+// Set replaces the value of a header, adding it when it does not exist
+func (h Headers) Set(name, value string) Headers {
+	for i, header := range h {
+		if strings.EqualFold(header.Name, name) {
+			h[i].Value = value
+			return h.Remove(name, i+1)
+		}
+	}
+	return append(h, Header{Name: name, Value: value})
+}
+
+// Remove removes all the values of a header, starting at some position
+func (h Headers) Remove(name string, from int) Headers {
+	res := h[:from]
+	for _, header := range h[from:] {
+		if !strings.EqualFold(header.Name, name) {
+			res = append(res, header)
+		}
+	}
+	return res
+}
+
+// Clone returns a copy of the headers
+func (h Headers) Clone() Headers {
+	if h == nil {
+		return nil
+	}
+	return append(Headers{}, h...)
+}
+
+// serialize serializes the headers as in the Proxy-Wasm ABI: the number of pairs, the
+// sizes of all the keys and values and then all the keys and values, null terminated
+func (h Headers) serialize() []byte {
+	size := 4
+	for _, header := range h {
+		size += 8 + len(header.Name) + len(header.Value) + 2
+	}
+
+	buf := make([]byte, size)
+	binary.LittleEndian.PutUint32(buf, uint32(len(h)))
+	pos := 4
+	for _, header := range h {
+		binary.LittleEndian.PutUint32(buf[pos:], uint32(len(header.Name)))
+		binary.LittleEndian.PutUint32(buf[pos+4:], uint32(len(header.Value)))
+		pos += 8
+	}
+	for _, header := range h {
+		pos += copy(buf[pos:], header.Name) + 1
+		pos += copy(buf[pos:], header.Value) + 1
+	}
+	return buf
+}
+
+var errBadHeaders = errors.New("bad serialized headers")
+
+// deserializeHeaders parses headers serialized as in the Proxy-Wasm ABI
+func deserializeHeaders(buf []byte) (Headers, error) {
+	if len(buf) == 0 {
+		return Headers{}, nil
+	}
+	if len(buf) < 4 {
+		return nil, errBadHeaders
+	}
+
+	n := int(binary.LittleEndian.Uint32(buf))
+	if 4+8*n > len(buf) {
+		return nil, errBadHeaders
+	}
+
+	headers := make(Headers, 0, n)
+	data := 4 + 8*n
+	for i := 0; i < n; i++ {
+		kl := int(binary.LittleEndian.Uint32(buf[4+8*i:]))
+		vl := int(binary.LittleEndian.Uint32(buf[8+8*i:]))
+		if data+kl+vl+2 > len(buf) {
+			return nil, errBadHeaders
+		}
+		name := string(buf[data : data+kl])
+		data += kl + 1
+		value := string(buf[data : data+vl])
+		data += vl + 1
+		headers = append(headers, Header{Name: name, Value: value})
+	}
+	return headers, nil
+}
+
+// Body is the body of a request or response, marshalled as a string in JSON
+type Body []byte
+
+// MarshalJSON implements json.Marshaler
+func (b Body) MarshalJSON() ([]byte, error) {
+	return json.Marshal(string(b))
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*b = Body(s)
+	return nil
+}
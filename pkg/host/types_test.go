@@ -0,0 +1,199 @@
+package host
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeadersSerialize(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		headers  Headers
+		expected []byte
+	}{
+		"empty": {
+			headers:  Headers{},
+			expected: []byte{0, 0, 0, 0},
+		},
+		"one header": {
+			headers: Headers{{Name: "a", Value: "bc"}},
+			expected: []byte{
+				1, 0, 0, 0,
+				1, 0, 0, 0, 2, 0, 0, 0,
+				'a', 0, 'b', 'c', 0,
+			},
+		},
+		"empty value": {
+			headers: Headers{{Name: ":path", Value: ""}, {Name: "x", Value: "y"}},
+			expected: []byte{
+				2, 0, 0, 0,
+				5, 0, 0, 0, 0, 0, 0, 0,
+				1, 0, 0, 0, 1, 0, 0, 0,
+				':', 'p', 'a', 't', 'h', 0, 0,
+				'x', 0, 'y', 0,
+			},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			buf := tCase.headers.serialize()
+			assert.Equal(t, tCase.expected, buf)
+
+			headers, err := deserializeHeaders(buf)
+			require.NoError(t, err)
+			assert.Equal(t, tCase.headers, headers)
+		})
+	}
+}
+
+func TestDeserializeHeaders(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		buf         []byte
+		expected    Headers
+		expectedErr bool
+	}{
+		"no data":          {buf: nil, expected: Headers{}},
+		"short count":      {buf: []byte{1, 0}, expectedErr: true},
+		"missing sizes":    {buf: []byte{1, 0, 0, 0, 1, 0, 0, 0}, expectedErr: true},
+		"missing data":     {buf: []byte{1, 0, 0, 0, 1, 0, 0, 0, 1, 0, 0, 0, 'a', 0}, expectedErr: true},
+		"huge count":       {buf: []byte{0xff, 0xff, 0xff, 0x0f}, expectedErr: true},
+		"huge header size": {buf: []byte{1, 0, 0, 0, 0xff, 0xff, 0xff, 0x7f, 0, 0, 0, 0, 0, 0}, expectedErr: true},
+		"zero headers":     {buf: []byte{0, 0, 0, 0}, expected: Headers{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			headers, err := deserializeHeaders(tCase.buf)
+			if tCase.expectedErr {
+				assert.ErrorIs(t, err, errBadHeaders)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, headers)
+		})
+	}
+}
+
+func TestHeadersSet(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		headers  Headers
+		name     string
+		value    string
+		expected Headers
+	}{
+		"new header": {
+			headers:  Headers{{Name: "a", Value: "1"}},
+			name:     "b",
+			value:    "2",
+			expected: Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+		},
+		"replaced in place": {
+			headers:  Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+			name:     "A",
+			value:    "3",
+			expected: Headers{{Name: "a", Value: "3"}, {Name: "b", Value: "2"}},
+		},
+		"duplicates removed": {
+			headers:  Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "a", Value: "3"}, {Name: "c", Value: "4"}},
+			name:     "a",
+			value:    "5",
+			expected: Headers{{Name: "a", Value: "5"}, {Name: "b", Value: "2"}, {Name: "c", Value: "4"}},
+		},
+		"nil headers": {
+			name:     "a",
+			value:    "1",
+			expected: Headers{{Name: "a", Value: "1"}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.headers.Set(tCase.name, tCase.value))
+		})
+	}
+}
+
+func TestHeadersRemove(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		headers  Headers
+		name     string
+		from     int
+		expected Headers
+	}{
+		"all the values": {
+			headers:  Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "A", Value: "3"}, {Name: "c", Value: "4"}},
+			name:     "a",
+			expected: Headers{{Name: "b", Value: "2"}, {Name: "c", Value: "4"}},
+		},
+		"from a position": {
+			headers:  Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "a", Value: "3"}},
+			name:     "a",
+			from:     1,
+			expected: Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}},
+		},
+		"missing header": {
+			headers:  Headers{{Name: "a", Value: "1"}},
+			name:     "b",
+			expected: Headers{{Name: "a", Value: "1"}},
+		},
+		"everything": {
+			headers:  Headers{{Name: "a", Value: "1"}, {Name: "a", Value: "2"}},
+			name:     "a",
+			expected: Headers{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.headers.Remove(tCase.name, tCase.from))
+		})
+	}
+}
+
+func TestHeadersCloneIsolation(t *testing.T) {
+	// Set and Remove reuse the backing array of the headers, so the host always
+	// works on clones of the headers it is given
+	original := Headers{{Name: "a", Value: "1"}, {Name: "b", Value: "2"}, {Name: "a", Value: "3"}}
+	expected := original.Clone()
+
+	clone := original.Clone()
+	clone = clone.Remove("a", 0)
+	clone = clone.Set("b", "4")
+	assert.Equal(t, Headers{{Name: "b", Value: "4"}}, clone)
+	assert.Equal(t, expected, original)
+
+	assert.Nil(t, Headers(nil).Clone())
+}
+
+func TestParseLogLevel(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		s           string
+		expected    LogLevel
+		expectedErr bool
+	}{
+		"trace":       {s: "trace", expected: LogLevelTrace},
+		"upper case":  {s: "WARN", expected: LogLevelWarn},
+		"critical":    {s: "critical", expected: LogLevelCritical},
+		"unknown":     {s: "fatal", expectedErr: true},
+		"empty level": {s: "", expectedErr: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			level, err := ParseLogLevel(tCase.s)
+			if tCase.expectedErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, level)
+		})
+	}
+
+	assert.Equal(t, "level(9)", LogLevel(9).String())
+	assert.Equal(t, "action(7)", Action(7).String())
+}
+
+func TestBodyJSON(t *testing.T) {
+	data, err := json.Marshal(Body("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, `"hello"`, string(data))
+
+	var b Body
+	require.NoError(t, json.Unmarshal([]byte(`"world"`), &b))
+	assert.Equal(t, Body("world"), b)
+	assert.Error(t, json.Unmarshal([]byte(`[1]`), &b))
+}
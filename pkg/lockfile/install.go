@@ -0,0 +1,157 @@
+package lockfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+// DefaultConcurrency is the default number of extensions installed in parallel
+const DefaultConcurrency = 4
+
+// Resolve resolves the versions in a manifest to exact tags, recording
+// the digests of their manifests, configs and layers in a lock.
+func Resolve(m *Manifest, client *registry.Client) (*Lock, error) {
+	digest, err := m.Digest()
+	if err != nil {
+		return nil, err
+	}
+
+	lock := &Lock{
+		Generated: time.Now().UTC(),
+		Digest:    digest,
+	}
+
+	for _, ext := range m.Extensions {
+		allTags, err := tags(client, ext.Ref)
+		if err != nil {
+			return nil, fmt.Errorf("when listing tags for %q: %w", ext.Name, err)
+		}
+		tag, err := registry.GetTagMatchingVersionOrConstraint(allTags, ext.Version)
+		if err != nil {
+			return nil, fmt.Errorf("when resolving version %q for %q: %w", ext.Version, ext.Name, err)
+		}
+
+		ref := ext.Ref + ":" + tag
+		result, err := pull(client, ref)
+		if err != nil {
+			return nil, fmt.Errorf("when pulling %s: %w", ref, err)
+		}
+
+		lock.Extensions = append(lock.Extensions, &LockedExtension{
+			Name:    ext.Name,
+			Ref:     ref,
+			Version: ext.Version,
+			Digest:  result.Manifest.Digest,
+			Config:  result.Config.Digest,
+			Layers:  []string{result.WASMExt.Digest},
+		})
+	}
+
+	return lock, nil
+}
+
+// Install fetches the extensions in a lock (up to concurrency in parallel), writing them as
+// "<name>.wasm" files in a directory. It fails when the digests do not match the lock.
+func Install(lock *Lock, client *registry.Client, dest string, concurrency int, out io.Writer) error {
+	// the names are used for the files written in the destination
+	if err := lock.Validate(); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dest, 0o755); err != nil {
+		return err
+	}
+
+	g := errgroup.Group{}
+	if concurrency > 0 {
+		g.SetLimit(concurrency)
+	}
+
+	for _, ext := range lock.Extensions {
+		ext := ext
+		g.Go(func() error {
+			ref := ext.Ref
+			if registry.IsOCI(ref) {
+				// obtain exactly the locked manifest, even when the tag has been moved
+				ref = withDigest(ref, ext.Digest)
+			}
+
+			result, err := pull(client, ref)
+			if err != nil {
+				return fmt.Errorf("when pulling %s: %w", ref, err)
+			}
+			if err := ext.check(result); err != nil {
+				return err
+			}
+
+			filename := filepath.Join(dest, ext.Name+".wasm")
+			if err := utils.AtomicWriteFile(filename, bytes.NewReader(result.WASMExt.Data), 0o644); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "Installed: %s -> %s\n", ext.Ref, filename)
+			return nil
+		})
+	}
+
+	return g.Wait()
+}
+
+// check checks that the result of a pull matches the digests in the lock
+func (ext *LockedExtension) check(result *registry.PullResult) error {
+	mismatch := func(what, expected, got string) error {
+		return fmt.Errorf("%s digest of %q does not match the lock (expected %s, got %s): run 'pwo lock' for updating it",
+			what, ext.Name, expected, got)
+	}
+
+	if result.Manifest.Digest != ext.Digest {
+		return mismatch("manifest", ext.Digest, result.Manifest.Digest)
+	}
+	if result.Config.Digest != ext.Config {
+		return mismatch("config", ext.Config, result.Config.Digest)
+	}
+	if len(ext.Layers) != 1 || result.WASMExt.Digest != ext.Layers[0] {
+		return mismatch("layer", strings.Join(ext.Layers, ","), result.WASMExt.Digest)
+	}
+	return nil
+}
+
+// withDigest replaces the tag in a reference by a digest
+func withDigest(ref, digest string) string {
+	if idx := strings.LastIndexByte(ref, ':'); idx > strings.LastIndexByte(ref, '/') {
+		ref = ref[:idx]
+	}
+	return ref + "@" + digest
+}
+
+// tags returns the semver tags available for a reference, sorted from the highest version.
+func tags(client *registry.Client, ref string) ([]string, error) {
+	if registry.IsOCILayout(ref) {
+		dir, _, err := registry.ParseLayoutReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		return registry.LayoutTags(dir)
+	}
+	return client.Tags(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)))
+}
+
+// pull obtains an extension from a registry, or from an OCI layout directory.
+func pull(client *registry.Client, ref string) (*registry.PullResult, error) {
+	if registry.IsOCILayout(ref) {
+		dir, tag, err := registry.ParseLayoutReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		return registry.PullLayout(dir, tag)
+	}
+	return client.Pull(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)))
+}
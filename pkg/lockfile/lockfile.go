@@ -0,0 +1,175 @@
+// Package lockfile implements the manifests (pwo.yaml) listing the Proxy-Wasm extensions
+// to install, and the lock files (pwo.lock) with the exact versions and digests they
+// were resolved to, so the same extensions can be installed again anywhere.
+package lockfile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const (
+	// DefaultManifestFilename is the default name of the manifest
+	DefaultManifestFilename = "pwo.yaml"
+
+	// DefaultLockFilename is the default name of the lock file
+	DefaultLockFilename = "pwo.lock"
+)
+
+// Manifest lists the extensions to install. This models the structure of a pwo.yaml file.
+type Manifest struct {
+	Extensions []*Extension `json:"extensions"`
+}
+
+// Extension is an extension in a manifest
+type Extension struct {
+	// Name is the name of the extension, used for the file where it is installed. Required.
+	Name string `json:"name"`
+	// Ref is the OCI (or OCI layout) reference, without a tag. Required.
+	Ref string `json:"ref"`
+	// Version is a tag (e.g. "1.2.0") or a semver constraint (e.g. "~1.2").
+	// The highest version is used when empty.
+	Version string `json:"version,omitempty"`
+}
+
+// Lock records the exact versions and digests the extensions in a manifest were resolved to.
+// This models the structure of a pwo.lock file.
+type Lock struct {
+	// Generated is the time the lock was generated
+	Generated time.Time `json:"generated"`
+	// Digest is the digest of the extensions in the manifest, for detecting changes in the manifest
+	Digest     string             `json:"digest"`
+	Extensions []*LockedExtension `json:"extensions"`
+}
+
+// LockedExtension is an extension in a lock file
+type LockedExtension struct {
+	Name string `json:"name"`
+	// Ref is the reference, with the exact tag the version was resolved to
+	Ref string `json:"ref"`
+	// Version is the version (or constraint) in the manifest
+	Version string `json:"version,omitempty"`
+	// Digest is the digest of the manifest
+	Digest string `json:"digest"`
+	// Config is the digest of the config
+	Config string `json:"config"`
+	// Layers are the digests of the layers
+	Layers []string `json:"layers"`
+}
+
+// LoadManifest loads and validates a manifest
+func LoadManifest(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := yaml.UnmarshalStrict(data, m); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid manifest %s: %w", filename, err)
+	}
+	return m, nil
+}
+
+// Validate checks the manifest for known issues
+func (m *Manifest) Validate() error {
+	names := map[string]bool{}
+	for i, ext := range m.Extensions {
+		if err := validateName(ext.Name, i); err != nil {
+			return err
+		}
+		if names[ext.Name] {
+			return fmt.Errorf("duplicated extension %q", ext.Name)
+		}
+		names[ext.Name] = true
+
+		if !registry.IsOCI(ext.Ref) && !registry.IsOCILayout(ext.Ref) {
+			return fmt.Errorf("invalid reference in extension %q: %s", ext.Name, ext.Ref)
+		}
+		tag, err := registry.RefTag(ext.Ref)
+		if err != nil {
+			return fmt.Errorf("invalid reference in extension %q: %w", ext.Name, err)
+		}
+		if tag != "" {
+			return fmt.Errorf("reference in extension %q must not have a tag (use the version)", ext.Name)
+		}
+	}
+	return nil
+}
+
+// validateName checks the name of the extension #i, used as the name of the file where it is installed
+func validateName(name string, i int) error {
+	if name == "" {
+		return fmt.Errorf("no name in extension #%d", i)
+	}
+	if !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid name in extension #%d: %q (it must be a file name)", i, name)
+	}
+	return nil
+}
+
+// Digest returns the digest of the extensions in the manifest
+func (m *Manifest) Digest() (string, error) {
+	data, err := json.Marshal(m.Extensions)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// LoadLock loads and validates a lock file
+func LoadLock(filename string) (*Lock, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &Lock{}
+	if err := yaml.Unmarshal(data, l); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+	if err := l.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid lock file %s: %w", filename, err)
+	}
+	return l, nil
+}
+
+// Validate checks the lock for known issues
+func (l *Lock) Validate() error {
+	names := map[string]bool{}
+	for i, ext := range l.Extensions {
+		if err := validateName(ext.Name, i); err != nil {
+			return err
+		}
+		if names[ext.Name] {
+			return fmt.Errorf("duplicated extension %q", ext.Name)
+		}
+		names[ext.Name] = true
+	}
+	return nil
+}
+
+// Save writes the lock file
+func (l *Lock) Save(filename string) error {
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return err
+	}
+	return utils.AtomicWriteFile(filename, bytes.NewReader(data), 0o644)
+}
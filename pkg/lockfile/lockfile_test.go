@@ -0,0 +1,158 @@
+package lockfile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+func TestManifestValidate(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		extensions  []*Extension
+		expectedMsg string
+	}{
+		"valid": {
+			extensions: []*Extension{
+				{Name: "auth", Ref: "oci://myregistry.com/auth", Version: "~1.2"},
+				{Name: "ratelimit.v2", Ref: "oci-layout:///tmp/layout"},
+			},
+		},
+		"no extensions": {},
+		"no name":       {extensions: []*Extension{{Ref: "oci://myregistry.com/auth"}}, expectedMsg: "no name in extension #0"},
+		"duplicated name": {
+			extensions: []*Extension{
+				{Name: "auth", Ref: "oci://myregistry.com/auth"},
+				{Name: "auth", Ref: "oci://myregistry.com/other"},
+			},
+			expectedMsg: `duplicated extension "auth"`,
+		},
+		"path traversal":       {extensions: []*Extension{{Name: "../../etc/x", Ref: "oci://myregistry.com/auth"}}, expectedMsg: "invalid name in extension #0"},
+		"absolute path":        {extensions: []*Extension{{Name: "/etc/x", Ref: "oci://myregistry.com/auth"}}, expectedMsg: "invalid name in extension #0"},
+		"subdirectory":         {extensions: []*Extension{{Name: "a/b", Ref: "oci://myregistry.com/auth"}}, expectedMsg: "invalid name in extension #0"},
+		"backslash":            {extensions: []*Extension{{Name: `a\b`, Ref: "oci://myregistry.com/auth"}}, expectedMsg: "invalid name in extension #0"},
+		"dot dot":              {extensions: []*Extension{{Name: "..", Ref: "oci://myregistry.com/auth"}}, expectedMsg: "invalid name in extension #0"},
+		"not OCI":              {extensions: []*Extension{{Name: "auth", Ref: "https://example.com/auth.wasm"}}, expectedMsg: `invalid reference in extension "auth"`},
+		"reference with a tag": {extensions: []*Extension{{Name: "auth", Ref: "oci://myregistry.com/auth:1.0.0"}}, expectedMsg: "must not have a tag"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := (&Manifest{Extensions: tCase.extensions}).Validate()
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestLoadLock(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		content     string
+		expectedMsg string
+	}{
+		"valid": {
+			content: "extensions:\n- name: auth\n  ref: oci://myregistry.com/auth:1.0.0\n  digest: sha256:aaa\n",
+		},
+		"path traversal": {
+			content:     "extensions:\n- name: ../../etc/x\n  ref: oci://myregistry.com/auth:1.0.0\n",
+			expectedMsg: "invalid name in extension #0",
+		},
+		"no name": {
+			content:     "extensions:\n- ref: oci://myregistry.com/auth:1.0.0\n",
+			expectedMsg: "no name in extension #0",
+		},
+		"duplicated name": {
+			content:     "extensions:\n- name: auth\n  ref: oci://myregistry.com/auth:1.0.0\n- name: auth\n  ref: oci://myregistry.com/auth:2.0.0\n",
+			expectedMsg: `duplicated extension "auth"`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), DefaultLockFilename)
+			require.NoError(t, os.WriteFile(filename, []byte(tCase.content), 0o644))
+
+			lock, err := LoadLock(filename)
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, lock.Extensions, 1)
+			assert.Equal(t, "auth", lock.Extensions[0].Name)
+		})
+	}
+}
+
+func TestInstallInvalidLock(t *testing.T) {
+	dest := filepath.Join(t.TempDir(), "dest")
+	lock := &Lock{Extensions: []*LockedExtension{{Name: "../x", Ref: "oci://myregistry.com/auth:1.0.0"}}}
+
+	err := Install(lock, nil, dest, 1, nil)
+	assert.ErrorContains(t, err, "invalid name")
+	assert.NoDirExists(t, dest)
+}
+
+func TestLockedExtensionCheck(t *testing.T) {
+	ext := &LockedExtension{Name: "auth", Digest: "sha256:m", Config: "sha256:c", Layers: []string{"sha256:l"}}
+
+	result := func(manifest, config, layer string) *registry.PullResult {
+		return &registry.PullResult{
+			Manifest: &registry.DescriptorPullSummary{Digest: manifest},
+			Config:   &registry.DescriptorPullSummary{Digest: config},
+			WASMExt:  &registry.DescriptorPullSummaryWithMeta{DescriptorPullSummary: registry.DescriptorPullSummary{Digest: layer}},
+		}
+	}
+
+	for name, tCase := range map[string]struct {
+		ext         *LockedExtension
+		result      *registry.PullResult
+		expectedMsg string
+	}{
+		"matching":          {ext: ext, result: result("sha256:m", "sha256:c", "sha256:l")},
+		"manifest mismatch": {ext: ext, result: result("sha256:x", "sha256:c", "sha256:l"), expectedMsg: `manifest digest of "auth" does not match the lock (expected sha256:m, got sha256:x)`},
+		"config mismatch":   {ext: ext, result: result("sha256:m", "sha256:x", "sha256:l"), expectedMsg: `config digest of "auth" does not match the lock (expected sha256:c, got sha256:x)`},
+		"layer mismatch":    {ext: ext, result: result("sha256:m", "sha256:c", "sha256:x"), expectedMsg: `layer digest of "auth" does not match the lock (expected sha256:l, got sha256:x)`},
+		"several layers": {
+			ext:         &LockedExtension{Name: "auth", Digest: "sha256:m", Config: "sha256:c", Layers: []string{"sha256:l", "sha256:l"}},
+			result:      result("sha256:m", "sha256:c", "sha256:l"),
+			expectedMsg: "expected sha256:l,sha256:l, got sha256:l",
+		},
+		"no layers": {
+			ext:         &LockedExtension{Name: "auth", Digest: "sha256:m", Config: "sha256:c"},
+			result:      result("sha256:m", "sha256:c", "sha256:l"),
+			expectedMsg: "layer digest",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := tCase.ext.check(tCase.result)
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWithDigest(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		ref      string
+		expected string
+	}{
+		"with a tag":             {ref: "oci://myregistry.com/auth:1.0.0", expected: "oci://myregistry.com/auth@sha256:aaa"},
+		"without a tag":          {ref: "oci://myregistry.com/auth", expected: "oci://myregistry.com/auth@sha256:aaa"},
+		"port and tag":           {ref: "oci://localhost:5000/auth:1.0.0", expected: "oci://localhost:5000/auth@sha256:aaa"},
+		"port without a tag":     {ref: "oci://localhost:5000/auth", expected: "oci://localhost:5000/auth@sha256:aaa"},
+		"nested repository":      {ref: "oci://myregistry.com/team/auth:1.0.0", expected: "oci://myregistry.com/team/auth@sha256:aaa"},
+		"without a scheme":       {ref: "myregistry.com/auth:latest", expected: "myregistry.com/auth@sha256:aaa"},
+		"port and nested no tag": {ref: "oci://localhost:5000/team/auth", expected: "oci://localhost:5000/team/auth@sha256:aaa"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, withDigest(tCase.ref, "sha256:aaa"))
+		})
+	}
+}
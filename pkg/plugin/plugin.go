@@ -0,0 +1,250 @@
+// Package plugin implements the external plugins that extend pwo with getters
+// and pushers for custom schemes (like "s3://" or "artifactory://").
+//
+// Plugins are directories in the plugins directory with a plugin.yaml file
+// describing the command to run and the schemes it handles:
+//
+//	name: artifactory
+//	version: 0.1.0
+//	description: get and push extensions from/to Artifactory
+//	command: bin/pwo-artifactory
+//	getters:
+//	  - artifactory
+//	pushers:
+//	  - artifactory
+//
+// The command (relative to the plugin directory, or looked up in the PATH)
+// is started with the operation as argument ("get" or "push") and a Request
+// (in JSON) in its standard input:
+//
+//   - for "get", the command must write the Wasm module (or the file in the URL,
+//     like the Wasm.yaml next to a module) to its standard output, and exit with
+//     status 2 when the URL does not exist.
+//   - for "push", the command must upload the files in the request to the URL.
+//
+// Any other non-zero exit status is a failure, and the standard error is used as error message.
+// Commands are killed when they run for longer than the timeout of the operation.
+// Commands get the PWO_* environment variables (like PWO_DEBUG or PWO_CACHE_HOME),
+// as well as PWO_PLUGIN_NAME and PWO_PLUGIN_DIR.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+const (
+	// MetadataFilename is the name of the file describing a plugin
+	MetadataFilename = "plugin.yaml"
+
+	// APIVersion is the version of the protocol used with plugins
+	APIVersion = "pwo.plugin/v1"
+
+	// ExitNotFound is the exit status of a plugin for URLs that do not exist
+	ExitNotFound = 2
+
+	// waitDelay is the time the output of a killed command is still read (e.g. from its children)
+	waitDelay = 5 * time.Second
+)
+
+// Operations performed by plugins
+const (
+	OperationGet  = "get"
+	OperationPush = "push"
+)
+
+// ErrNotFound is returned when a plugin reports that a URL does not exist
+var ErrNotFound = errors.New("not found")
+
+// ErrOutputTooLarge is returned by a LimitedWriter when the output exceeds the maximum size
+var ErrOutputTooLarge = errors.New("output too large")
+
+// Metadata describes a plugin. This models the structure of a plugin.yaml file.
+type Metadata struct {
+	// Name is the name of the plugin
+	Name string `json:"name"`
+	// Version is the version of the plugin
+	Version string `json:"version,omitempty"`
+	// Description is a short description of the plugin
+	Description string `json:"description,omitempty"`
+	// Command is the executable run for all the operations
+	Command string `json:"command"`
+	// Getters are the schemes the plugin can get extensions from
+	Getters []string `json:"getters,omitempty"`
+	// Pushers are the schemes the plugin can push extensions to
+	Pushers []string `json:"pushers,omitempty"`
+}
+
+// Plugin is a plugin installed in a directory
+type Plugin struct {
+	Metadata *Metadata
+	// Dir is the directory where the plugin is installed
+	Dir string
+}
+
+// Request is sent to the plugin commands in their standard input
+type Request struct {
+	APIVersion string `json:"apiVersion"`
+	Operation  string `json:"operation"`
+	// URL is the URL to get from, or to push to
+	URL string `json:"url"`
+
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+	CertFile  string `json:"certFile,omitempty"`
+	KeyFile   string `json:"keyFile,omitempty"`
+	CAFile    string `json:"caFile,omitempty"`
+	Insecure  bool   `json:"insecure,omitempty"`
+	PlainHTTP bool   `json:"plainHTTP,omitempty"`
+
+	// WasmFile is the Wasm module to push
+	WasmFile string `json:"wasmFile,omitempty"`
+	// MetadataFile is the metadata (Wasm.yaml) of the Wasm module to push
+	MetadataFile string `json:"metadataFile,omitempty"`
+}
+
+// LoadDir loads the plugin in a directory
+func LoadDir(dir string) (*Plugin, error) {
+	data, err := os.ReadFile(filepath.Join(dir, MetadataFilename))
+	if err != nil {
+		return nil, fmt.Errorf("when reading plugin metadata: %w", err)
+	}
+
+	md := &Metadata{}
+	if err := yaml.Unmarshal(data, md); err != nil {
+		return nil, fmt.Errorf("when parsing plugin metadata in %s: %w", dir, err)
+	}
+	if md.Name == "" {
+		return nil, fmt.Errorf("no name in plugin metadata in %s", dir)
+	}
+	if md.Command == "" {
+		return nil, fmt.Errorf("no command in plugin metadata in %s", dir)
+	}
+
+	return &Plugin{Metadata: md, Dir: dir}, nil
+}
+
+// LoadAll loads all the plugins in a directory, skipping the ones that cannot be loaded.
+// No plugins are returned when the directory does not exist.
+func LoadAll(basedir string) ([]*Plugin, error) {
+	entries, err := os.ReadDir(basedir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var plugins []*Plugin
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		p, err := LoadDir(filepath.Join(basedir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		plugins = append(plugins, p)
+	}
+	return plugins, nil
+}
+
+// Run runs the plugin command for a request, writing its standard output to out.
+// The env are additional environment variables for the command. The command is
+// killed when the context is done.
+func (p *Plugin) Run(ctx context.Context, req *Request, out io.Writer, env map[string]string) error {
+	req.APIVersion = APIVersion
+	input, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, p.command(), req.Operation)
+	cmd.WaitDelay = waitDelay
+	cmd.Dir = p.Dir
+	cmd.Env = os.Environ()
+	for k, v := range env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	cmd.Env = append(cmd.Env, "PWO_PLUGIN_NAME="+p.Metadata.Name, "PWO_PLUGIN_DIR="+p.Dir)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = out
+
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return fmt.Errorf("plugin %q failed: %w", p.Metadata.Name, ctxErr)
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == ExitNotFound {
+			return fmt.Errorf("%w: %s", ErrNotFound, req.URL)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("plugin %q failed: %s", p.Metadata.Name, msg)
+		}
+		return fmt.Errorf("plugin %q failed: %w", p.Metadata.Name, err)
+	}
+	if debug, _ := strconv.ParseBool(env["PWO_DEBUG"]); debug && stderr.Len() > 0 {
+		fmt.Fprintf(os.Stderr, "plugin %q: %s", p.Metadata.Name, stderr.String())
+	}
+
+	return nil
+}
+
+// command returns the path of the plugin command
+func (p *Plugin) command() string {
+	command := filepath.FromSlash(p.Metadata.Command)
+	if filepath.IsAbs(command) {
+		return command
+	}
+	if local := filepath.Join(p.Dir, command); utils.IsFileExists(local) {
+		return local
+	}
+	// a command in the PATH
+	return command
+}
+
+// LimitedWriter is a writer for the output of plugins that writes up to
+// a maximum number of bytes (no limit if 0), failing after that.
+type LimitedWriter struct {
+	w        io.Writer
+	max      int64
+	written  int64
+	exceeded bool
+}
+
+// NewLimitedWriter creates a writer that writes up to max bytes to w.
+func NewLimitedWriter(w io.Writer, max int64) *LimitedWriter {
+	return &LimitedWriter{w: w, max: max}
+}
+
+func (l *LimitedWriter) Write(p []byte) (int, error) {
+	if l.max > 0 && l.written+int64(len(p)) > l.max {
+		l.exceeded = true
+		return 0, ErrOutputTooLarge
+	}
+	n, err := l.w.Write(p)
+	l.written += int64(n)
+	return n, err
+}
+
+// Exceeded returns true when the output was larger than the maximum.
+func (l *LimitedWriter) Exceeded() bool {
+	return l.exceeded
+}
@@ -0,0 +1,137 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newScriptPlugin creates a plugin with a shell script as command
+func newScriptPlugin(t *testing.T, script string) *Plugin {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins with shell scripts are not supported on Windows")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.sh"), []byte("#!/bin/sh\n"+script+"\n"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, MetadataFilename), []byte("name: test\ncommand: plugin.sh\ngetters: [test]\n"), 0o644))
+
+	p, err := LoadDir(dir)
+	require.NoError(t, err)
+	return p
+}
+
+func TestPluginRun(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		script        string
+		env           map[string]string
+		expectedOut   string
+		expectedErr   error
+		expectedMsg   string
+		checkDeadline bool
+	}{
+		"output": {
+			script:      `echo "$1 $PWO_PLUGIN_NAME $FOO"`,
+			env:         map[string]string{"FOO": "bar"},
+			expectedOut: "get test bar\n",
+		},
+		"plugin directory": {
+			script:      `[ "$PWO_PLUGIN_DIR" = "$(pwd)" ] && echo ok`,
+			expectedOut: "ok\n",
+		},
+		"not found": {
+			script:      `echo "no such thing" >&2; exit 2`,
+			expectedErr: ErrNotFound,
+			expectedMsg: "not found: test://some/url",
+		},
+		"failure with a message": {
+			script:      `echo "  access denied  " >&2; exit 1`,
+			expectedMsg: `plugin "test" failed: access denied`,
+		},
+		"failure without a message": {
+			script:      `exit 3`,
+			expectedMsg: `plugin "test" failed: exit status 3`,
+		},
+		"timeout": {
+			script:        `exec sleep 10`,
+			expectedErr:   context.DeadlineExceeded,
+			checkDeadline: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newScriptPlugin(t, tCase.script)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			if !tCase.checkDeadline {
+				ctx = context.Background()
+			}
+
+			out := &bytes.Buffer{}
+			start := time.Now()
+			err := p.Run(ctx, &Request{Operation: OperationGet, URL: "test://some/url"}, out, tCase.env)
+			if tCase.checkDeadline {
+				assert.Less(t, time.Since(start), 5*time.Second)
+			}
+
+			switch {
+			case tCase.expectedErr != nil:
+				assert.ErrorIs(t, err, tCase.expectedErr)
+				if tCase.expectedMsg != "" {
+					assert.EqualError(t, err, tCase.expectedMsg)
+				}
+			case tCase.expectedMsg != "":
+				assert.EqualError(t, err, tCase.expectedMsg)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tCase.expectedOut, out.String())
+			}
+		})
+	}
+}
+
+func TestPluginRunRequest(t *testing.T) {
+	// the request is sent in the standard input
+	p := newScriptPlugin(t, `cat`)
+
+	out := &bytes.Buffer{}
+	req := &Request{Operation: OperationPush, URL: "test://some/url", WasmFile: "/tmp/main.wasm", Insecure: true}
+	require.NoError(t, p.Run(context.Background(), req, out, nil))
+
+	received := &Request{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), received))
+	assert.Equal(t, APIVersion, received.APIVersion)
+	assert.Equal(t, req, received)
+}
+
+func TestLoadAll(t *testing.T) {
+	basedir := t.TempDir()
+	for _, name := range []string{"good", "no-command"} {
+		require.NoError(t, os.Mkdir(filepath.Join(basedir, name), 0o755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(basedir, "good", MetadataFilename), []byte("name: good\ncommand: run\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(basedir, "no-command", MetadataFilename), []byte("name: bad\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(basedir, "file"), nil, 0o644))
+
+	plugins, err := LoadAll(basedir)
+	require.NoError(t, err)
+	require.Len(t, plugins, 1)
+	assert.Equal(t, "good", plugins[0].Metadata.Name)
+	assert.Equal(t, filepath.Join(basedir, "good"), plugins[0].Dir)
+
+	plugins, err = LoadAll(filepath.Join(basedir, "missing"))
+	assert.NoError(t, err)
+	assert.Nil(t, plugins)
+
+	_, err = LoadDir(filepath.Join(basedir, "no-command"))
+	assert.ErrorContains(t, err, "no command in plugin metadata")
+}
@@ -0,0 +1,250 @@
+// Package progress reports the progress of several concurrent downloads, with
+// progress bars on terminals and structured events (JSON lines) otherwise.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/moby/term"
+)
+
+const (
+	// barWidth is the width of the progress bars
+	barWidth = 30
+
+	// redrawInterval is the minimum time between redraws of the progress bars
+	redrawInterval = 100 * time.Millisecond
+
+	// eventInterval is the minimum time between progress events for a download
+	eventInterval = time.Second
+)
+
+// Reporter reports the progress of several concurrent downloads,
+// identified by their names.
+type Reporter interface {
+	// Start reports a download that has been started
+	Start(name string)
+	// Update reports the bytes downloaded so far, and the total (or -1 when unknown)
+	Update(name string, done, total int64)
+	// Done reports a finished download, with the file it has been saved to or an error
+	Done(name, path string, err error)
+	// Close flushes any pending output
+	Close()
+}
+
+// New returns a Reporter that shows progress bars when the writer is a terminal,
+// or writes progress events otherwise.
+func New(out io.Writer) Reporter {
+	if _, isTerminal := term.GetFdInfo(out); isTerminal {
+		return NewBars(out)
+	}
+	return NewEvents(out)
+}
+
+// item is the state of a download
+type item struct {
+	name        string
+	done, total int64
+	finished    bool
+	path        string
+	err         error
+	lastEvent   time.Time
+}
+
+// Bars shows a progress bar per download
+type Bars struct {
+	out io.Writer
+
+	mu       sync.Mutex
+	items    []*item
+	byName   map[string]*item
+	lines    int
+	lastDraw time.Time
+}
+
+// NewBars creates a Reporter that shows progress bars
+func NewBars(out io.Writer) *Bars {
+	return &Bars{out: out, byName: map[string]*item{}}
+}
+
+func (b *Bars) Start(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.get(name)
+	b.draw(true)
+}
+
+func (b *Bars) Update(name string, done, total int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	it := b.get(name)
+	it.done, it.total = done, total
+	b.draw(false)
+}
+
+func (b *Bars) Done(name, path string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	it := b.get(name)
+	it.finished, it.path, it.err = true, path, err
+	b.draw(true)
+}
+
+func (b *Bars) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.draw(true)
+}
+
+func (b *Bars) get(name string) *item {
+	it, ok := b.byName[name]
+	if !ok {
+		it = &item{name: name, total: -1}
+		b.items = append(b.items, it)
+		b.byName[name] = it
+	}
+	return it
+}
+
+// draw redraws all the progress bars (unless they have been drawn very recently)
+func (b *Bars) draw(force bool) {
+	if !force && time.Since(b.lastDraw) < redrawInterval {
+		return
+	}
+	b.lastDraw = time.Now()
+
+	var sb strings.Builder
+	if b.lines > 0 {
+		// go back to the first bar
+		fmt.Fprintf(&sb, "\x1b[%dA", b.lines)
+	}
+
+	nameWidth := 0
+	for _, it := range b.items {
+		if len(it.name) > nameWidth {
+			nameWidth = len(it.name)
+		}
+	}
+
+	for _, it := range b.items {
+		fmt.Fprintf(&sb, "\r\x1b[K%-*s  %s\n", nameWidth, it.name, it.status())
+	}
+	b.lines = len(b.items)
+
+	fmt.Fprint(b.out, sb.String())
+}
+
+func (it *item) status() string {
+	switch {
+	case it.err != nil:
+		return fmt.Sprintf("error: %s", it.err)
+	case it.finished:
+		return fmt.Sprintf("done -> %s", it.path)
+	case it.total <= 0:
+		return fmt.Sprintf("[%s] %s", strings.Repeat("?", barWidth), formatBytes(it.done))
+	}
+
+	filled := int(float64(barWidth) * float64(it.done) / float64(it.total))
+	if filled > barWidth {
+		filled = barWidth
+	}
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", barWidth-filled)
+	return fmt.Sprintf("[%s] %s / %s", bar, formatBytes(it.done), formatBytes(it.total))
+}
+
+// Event is a progress event
+type Event struct {
+	Time  time.Time `json:"time"`
+	Event string    `json:"event"`
+	Name  string    `json:"name"`
+	Bytes int64     `json:"bytes,omitempty"`
+	Total int64     `json:"total,omitempty"`
+	Path  string    `json:"path,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Event types
+const (
+	EventStart    = "start"
+	EventProgress = "progress"
+	EventDone     = "done"
+	EventError    = "error"
+)
+
+// Events writes the progress as events, one JSON document per line
+type Events struct {
+	out io.Writer
+
+	mu     sync.Mutex
+	byName map[string]*item
+}
+
+// NewEvents creates a Reporter that writes events
+func NewEvents(out io.Writer) *Events {
+	return &Events{out: out, byName: map[string]*item{}}
+}
+
+func (e *Events) Start(name string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.byName[name] = &item{name: name}
+	e.write(Event{Event: EventStart, Name: name})
+}
+
+func (e *Events) Update(name string, done, total int64) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	it, ok := e.byName[name]
+	if !ok {
+		it = &item{name: name}
+		e.byName[name] = it
+	}
+
+	// progress events are throttled, but the last one is always reported
+	if done != total && time.Since(it.lastEvent) < eventInterval {
+		return
+	}
+	it.lastEvent = time.Now()
+	e.write(Event{Event: EventProgress, Name: name, Bytes: done, Total: total})
+}
+
+func (e *Events) Done(name, path string, err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err != nil {
+		e.write(Event{Event: EventError, Name: name, Error: err.Error()})
+		return
+	}
+	e.write(Event{Event: EventDone, Name: name, Path: path})
+}
+
+func (e *Events) Close() {}
+
+func (e *Events) write(ev Event) {
+	ev.Time = time.Now().UTC()
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(e.out, "%s\n", data)
+}
+
+// formatBytes formats a size in bytes for humans
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
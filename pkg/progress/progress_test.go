@@ -0,0 +1,148 @@
+package progress
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeEvents decodes the events written by an Events reporter
+func decodeEvents(t *testing.T, r io.Reader) []Event {
+	t.Helper()
+	var res []Event
+	dec := json.NewDecoder(r)
+	for {
+		var ev Event
+		err := dec.Decode(&ev)
+		if errors.Is(err, io.EOF) {
+			return res
+		}
+		require.NoError(t, err)
+		assert.False(t, ev.Time.IsZero())
+		ev.Time = time.Time{}
+		res = append(res, ev)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEvents(&buf)
+
+	e.Start("a")
+	e.Update("a", 10, 100)
+	// throttled
+	e.Update("a", 20, 100)
+	e.Update("a", 50, 100)
+	// the last one is always reported
+	e.Update("a", 100, 100)
+	e.Done("a", "/tmp/a.wasm", nil)
+	// without a start
+	e.Update("b", 1, -1)
+	e.Done("b", "", errors.New("not found"))
+	e.Close()
+
+	assert.Equal(t, []Event{
+		{Event: EventStart, Name: "a"},
+		{Event: EventProgress, Name: "a", Bytes: 10, Total: 100},
+		{Event: EventProgress, Name: "a", Bytes: 100, Total: 100},
+		{Event: EventDone, Name: "a", Path: "/tmp/a.wasm"},
+		{Event: EventProgress, Name: "b", Bytes: 1, Total: -1},
+		{Event: EventError, Name: "b", Error: "not found"},
+	}, decodeEvents(t, &buf))
+}
+
+func TestEventsThrottling(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEvents(&buf)
+
+	e.Start("a")
+	e.Update("a", 10, 100)
+	e.Update("a", 20, 100)
+	// some time later
+	e.byName["a"].lastEvent = time.Now().Add(-eventInterval)
+	e.Update("a", 30, 100)
+
+	events := decodeEvents(t, &buf)
+	require.Len(t, events, 3)
+	assert.Equal(t, int64(10), events[1].Bytes)
+	assert.Equal(t, int64(30), events[2].Bytes)
+}
+
+func TestBars(t *testing.T) {
+	var buf bytes.Buffer
+	b := NewBars(&buf)
+
+	b.Start("a")
+	assert.Equal(t, "\r\x1b[Ka  [??????????????????????????????] 0 B\n", buf.String())
+
+	// the second bar is drawn below the first one
+	buf.Reset()
+	b.Start("bb")
+	assert.Equal(t, "\x1b[1A\r\x1b[Ka   [??????????????????????????????] 0 B\n\r\x1b[Kbb  [??????????????????????????????] 0 B\n", buf.String())
+
+	// redraws are throttled
+	buf.Reset()
+	b.Update("a", 512, 1024)
+	assert.Empty(t, buf.String())
+
+	// some time later
+	b.lastDraw = time.Now().Add(-redrawInterval)
+	b.Update("a", 1024, 2048)
+	assert.Equal(t, "\x1b[2A\r\x1b[Ka   [===============               ] 1.0 KiB / 2.0 KiB\n\r\x1b[Kbb  [??????????????????????????????] 0 B\n", buf.String())
+
+	// finished downloads are always drawn
+	buf.Reset()
+	b.Done("bb", "", errors.New("not found"))
+	assert.True(t, strings.HasSuffix(buf.String(), "\r\x1b[Kbb  error: not found\n"))
+
+	buf.Reset()
+	b.Close()
+	assert.NotEmpty(t, buf.String())
+}
+
+func TestItemStatus(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		item     item
+		expected string
+	}{
+		"unknown total":   {item: item{done: 100, total: -1}, expected: "[??????????????????????????????] 100 B"},
+		"zero total":      {item: item{done: 100, total: 0}, expected: "[??????????????????????????????] 100 B"},
+		"started":         {item: item{done: 0, total: 100}, expected: "[                              ] 0 B / 100 B"},
+		"half":            {item: item{done: 50, total: 100}, expected: "[===============               ] 50 B / 100 B"},
+		"complete":        {item: item{done: 100, total: 100}, expected: "[==============================] 100 B / 100 B"},
+		"more than total": {item: item{done: 200, total: 100}, expected: "[==============================] 200 B / 100 B"},
+		"finished":        {item: item{finished: true, path: "/tmp/a.wasm"}, expected: "done -> /tmp/a.wasm"},
+		"error":           {item: item{finished: true, err: errors.New("not found")}, expected: "error: not found"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, tCase.item.status())
+		})
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		n        int64
+		expected string
+	}{
+		"zero":            {n: 0, expected: "0 B"},
+		"below a KiB":     {n: 1023, expected: "1023 B"},
+		"one KiB":         {n: 1024, expected: "1.0 KiB"},
+		"KiB with a half": {n: 1536, expected: "1.5 KiB"},
+		"below a MiB":     {n: 1024*1024 - 1, expected: "1024.0 KiB"},
+		"one MiB":         {n: 1024 * 1024, expected: "1.0 MiB"},
+		"one GiB":         {n: 1024 * 1024 * 1024, expected: "1.0 GiB"},
+		"one TiB":         {n: 1 << 40, expected: "1.0 TiB"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, formatBytes(tCase.n))
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package publisher
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+// sourceAnnotations returns the annotations that describe the source of a Wasm file:
+// the git commit (when the file is in a git checkout) and the toolchain
+func sourceAnnotations(wasmExe string, data []byte) map[string]string {
+	annotations := map[string]string{}
+	if commit := gitCommit(filepath.Dir(wasmExe)); commit != "" {
+		annotations[ocispec.AnnotationRevision] = commit
+	}
+	if m, err := wasm.Parse(data); err == nil {
+		if toolchain := m.Toolchain(); toolchain != "" {
+			annotations[registry.AnnotationToolchain] = toolchain
+		}
+	}
+	return annotations
+}
+
+// gitCommit returns the commit checked out in the git repository a directory
+// belongs to, or an empty string when it is not in a git checkout (or git is not available)
+func gitCommit(dir string) string {
+	out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
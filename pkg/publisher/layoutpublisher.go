@@ -0,0 +1,66 @@
+package publisher
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+// OCILayoutPusher is the backend handler for OCI image layout directories
+type OCILayoutPusher struct {
+	opts options
+}
+
+// Push stores a Proxy-WASM extension in an OCI layout directory, tagged with the
+// tag in the reference or, when there is no tag, with the version in the metadata.
+func (pusher *OCILayoutPusher) Push(wasmExe, metadataFile, href string, options ...Option) error {
+	for _, opt := range options {
+		opt(&pusher.opts)
+	}
+
+	dir, tag, err := registry.ParseLayoutReference(href)
+	if err != nil {
+		return err
+	}
+
+	stat, err := os.Stat(wasmExe)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: no such file", wasmExe)
+		}
+		return err
+	}
+	if stat.IsDir() {
+		return fmt.Errorf("cannot push directory, must provide Proxy-WASM file")
+	}
+
+	meta, err := common.NewMetadataFromFile(metadataFile)
+	if err != nil {
+		return err
+	}
+	if tag == "" {
+		tag = meta.Version
+	}
+
+	wasmExeBytes, err := os.ReadFile(wasmExe)
+	if err != nil {
+		return err
+	}
+
+	_, err = registry.PushLayout(wasmExeBytes, meta, dir, tag,
+		registry.PushOptAnnotations(pusher.opts.annotations))
+	return err
+}
+
+// NewOCILayoutPusher constructs a Pusher for OCI layout directories
+func NewOCILayoutPusher(ops ...Option) (Pusher, error) {
+	var client OCILayoutPusher
+
+	for _, opt := range ops {
+		opt(&client.opts)
+	}
+
+	return &client, nil
+}
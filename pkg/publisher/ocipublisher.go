@@ -2,12 +2,9 @@ package publisher
 
 import (
 	"fmt"
-	"net"
-	"net/http"
 	"os"
 	"path"
 	"strings"
-	"time"
 
 	"github.com/inercia/proxy-wasm-oci/pkg/common"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
@@ -57,7 +54,9 @@ func (pusher *OCIPusher) push(wasmExe, metadataFile, href string) error {
 		return err
 	}
 
-	var pushOpts []registry.PushOption
+	pushOpts := []registry.PushOption{
+		registry.PushOptAnnotations(pusher.opts.annotations),
+	}
 
 	ref := fmt.Sprintf("%s:%s",
 		path.Join(strings.TrimPrefix(href, fmt.Sprintf("%s://", registry.OCIScheme)), meta.Name),
@@ -79,48 +78,5 @@ func NewOCIPusher(ops ...Option) (Pusher, error) {
 }
 
 func (pusher *OCIPusher) newRegistryClient() (*registry.Client, error) {
-	if (pusher.opts.CertFile != "" && pusher.opts.KeyFile != "") || pusher.opts.CAFile != "" || pusher.opts.Insecure {
-		tlsConf, err := registry.NewClientTLS(pusher.opts.RegistryParams)
-		if err != nil {
-			return nil, fmt.Errorf("can't create TLS config for client: %w", err)
-		}
-
-		registryClient, err := registry.NewClient(
-			registry.ClientOptHTTPClient(&http.Client{
-				// From https://github.com/google/go-containerregistry/blob/31786c6cbb82d6ec4fb8eb79cd9387905130534e/pkg/v1/remote/options.go#L87
-				Transport: &http.Transport{
-					Proxy: http.ProxyFromEnvironment,
-					DialContext: (&net.Dialer{
-						// By default we wrap the transport in retries, so reduce the
-						// default dial timeout to 5s to avoid 5x 30s of connection
-						// timeouts when doing the "ping" on certain http registries.
-						Timeout:   5 * time.Second,
-						KeepAlive: 30 * time.Second,
-					}).DialContext,
-					ForceAttemptHTTP2:     true,
-					MaxIdleConns:          100,
-					IdleConnTimeout:       90 * time.Second,
-					TLSHandshakeTimeout:   10 * time.Second,
-					ExpectContinueTimeout: 1 * time.Second,
-					TLSClientConfig:       tlsConf,
-				},
-			}),
-			registry.ClientOptEnableCache(true),
-		)
-		if err != nil {
-			return nil, err
-		}
-		return registryClient, nil
-	}
-
-	opts := []registry.ClientOption{registry.ClientOptEnableCache(true)}
-	if pusher.opts.PlainHTTP {
-		opts = append(opts, registry.ClientOptPlainHTTP())
-	}
-
-	registryClient, err := registry.NewClient(opts...)
-	if err != nil {
-		return nil, err
-	}
-	return registryClient, nil
+	return registry.NewClientWithParams(pusher.opts.RegistryParams, "", false)
 }
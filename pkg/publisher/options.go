@@ -17,6 +17,10 @@ limitations under the License.
 package publisher
 
 import (
+	"io"
+	"net/url"
+	"time"
+
 	"github.com/pkg/errors"
 
 	"github.com/inercia/proxy-wasm-oci/pkg/config"
@@ -27,6 +31,9 @@ import (
 //
 // Pushers may or may not ignore these parameters as they are passed in.
 type options struct {
+	annotations    map[string]string
+	out            io.Writer
+	timeout        time.Duration
 	registryClient *registry.Client
 	registry.RegistryParams
 }
@@ -42,6 +49,27 @@ func WithRegistryClient(client *registry.Client) Option {
 	}
 }
 
+// WithAnnotations sets some extra annotations for the extension (like the source revision).
+func WithAnnotations(annotations map[string]string) Option {
+	return func(opts *options) {
+		opts.annotations = annotations
+	}
+}
+
+// WithWriter sets the writer for the messages of the pusher.
+func WithWriter(out io.Writer) Option {
+	return func(opts *options) {
+		opts.out = out
+	}
+}
+
+// WithTimeout sets the timeout for pushes with plugins.
+func WithTimeout(timeout time.Duration) Option {
+	return func(opts *options) {
+		opts.timeout = timeout
+	}
+}
+
 func WithTLSClientConfig(certFile, keyFile, caFile string) Option {
 	return func(p *options) {
 		p.CertFile = certFile
@@ -62,6 +90,13 @@ func WithPlainHTTP(plain bool) Option {
 	}
 }
 
+// WithMaxAttempts sets the maximum number of attempts for a request to the registry.
+func WithMaxAttempts(attempts int) Option {
+	return func(p *options) {
+		p.MaxAttempts = attempts
+	}
+}
+
 // Pusher is an interface to support upload to the specified URL.
 type Pusher interface {
 	// Push file content by url string
@@ -91,6 +126,20 @@ func (p Provider) Provides(scheme string) bool {
 // Providers is a collection of Provider objects.
 type Providers []Provider
 
+// SupportsRef returns true when there is a Provider for the scheme of a reference.
+func (p Providers) SupportsRef(ref string) bool {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return false
+	}
+	for _, pp := range p {
+		if pp.Provides(u.Scheme) {
+			return true
+		}
+	}
+	return false
+}
+
 // ByScheme returns a Provider that handles the given scheme.
 //
 // If no provider handles this scheme, this will return an error.
@@ -103,14 +152,31 @@ func (p Providers) ByScheme(scheme string) (Pusher, error) {
 	return nil, errors.Errorf("scheme %q not supported", scheme)
 }
 
+const (
+	// DefaultPluginTimeout is the maximum time (in seconds) for a pusher plugin to upload an extension.
+	DefaultPluginTimeout = 120
+
+	// DefaultPluginMaxOutput is the maximum size of the output of a pusher plugin.
+	DefaultPluginMaxOutput = 1024 * 1024
+)
+
+var defaultOptions = []Option{WithTimeout(time.Second * DefaultPluginTimeout)}
+
 var ociProvider = Provider{
 	Schemes: []string{registry.OCIScheme},
 	New:     NewOCIPusher,
 }
 
+var ociLayoutProvider = Provider{
+	Schemes: []string{registry.OCILayoutScheme},
+	New:     NewOCILayoutPusher,
+}
+
 // All finds all of the registered pushers as a list of Provider instances.
-// Currently, just the built-in pushers are collected.
+// The built-in pushers and the discovered plugins with pushers are collected.
 func All(settings *config.GlobalSettings) Providers {
-	result := Providers{ociProvider}
+	result := Providers{ociProvider, ociLayoutProvider}
+	pluginPushers, _ := collectPlugins(settings)
+	result = append(result, pluginPushers...)
 	return result
 }
@@ -0,0 +1,101 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
+	"github.com/inercia/proxy-wasm-oci/pkg/plugin"
+)
+
+// collectPlugins scans for pusher plugins.
+// This will load plugins according to the given settings.
+func collectPlugins(settings *config.GlobalSettings) (Providers, error) {
+	plugins, err := settings.Plugins()
+	if err != nil {
+		return nil, err
+	}
+
+	var result Providers
+	for _, p := range plugins {
+		if len(p.Metadata.Pushers) == 0 {
+			continue
+		}
+		result = append(result, Provider{
+			Schemes: p.Metadata.Pushers,
+			New:     NewPluginPusher(p, settings),
+		})
+	}
+	return result, nil
+}
+
+// pluginPusher is a generic type to invoke custom pusher plugins
+type pluginPusher struct {
+	plugin   *plugin.Plugin
+	settings *config.GlobalSettings
+	opts     options
+}
+
+// Push runs the plugin command for uploading a Proxy-WASM extension to a URL.
+func (p *pluginPusher) Push(wasmExe, metadataFile, href string, options ...Option) error {
+	for _, opt := range options {
+		opt(&p.opts)
+	}
+
+	// plugins run in their own directory
+	wasmExe, err := filepath.Abs(wasmExe)
+	if err != nil {
+		return err
+	}
+	metadataFile, err = filepath.Abs(metadataFile)
+	if err != nil {
+		return err
+	}
+
+	req := &plugin.Request{
+		Operation:    plugin.OperationPush,
+		URL:          href,
+		CertFile:     p.opts.CertFile,
+		KeyFile:      p.opts.KeyFile,
+		CAFile:       p.opts.CAFile,
+		Insecure:     p.opts.Insecure,
+		PlainHTTP:    p.opts.PlainHTTP,
+		WasmFile:     wasmExe,
+		MetadataFile: metadataFile,
+	}
+
+	ctx := context.Background()
+	if p.opts.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.opts.timeout)
+		defer cancel()
+	}
+
+	var w io.Writer = os.Stdout
+	if p.opts.out != nil {
+		w = p.opts.out
+	}
+	out := plugin.NewLimitedWriter(w, DefaultPluginMaxOutput)
+	err = p.plugin.Run(ctx, req, out, p.settings.EnvVars())
+	if out.Exceeded() {
+		return fmt.Errorf("plugin %q wrote more than %d bytes", p.plugin.Metadata.Name, DefaultPluginMaxOutput)
+	}
+	return err
+}
+
+// NewPluginPusher constructs a valid plugin pusher
+func NewPluginPusher(p *plugin.Plugin, settings *config.GlobalSettings) Constructor {
+	return func(options ...Option) (Pusher, error) {
+		result := &pluginPusher{
+			plugin:   p,
+			settings: settings,
+		}
+		for _, opt := range append(defaultOptions, options...) {
+			opt(&result.opts)
+		}
+		return result, nil
+	}
+}
@@ -0,0 +1,60 @@
+package publisher
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
+	"github.com/inercia/proxy-wasm-oci/pkg/plugin"
+)
+
+func TestPluginPusher(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugins with shell scripts are not supported on Windows")
+	}
+
+	for name, tCase := range map[string]struct {
+		script        string
+		opts          []Option
+		expectedOut   string
+		expectedError error
+		expectedMsg   string
+	}{
+		"pushed":         {script: `echo "$1 done"`, expectedOut: "push done\n"},
+		"failure":        {script: `echo "denied" >&2; exit 1`, expectedMsg: `plugin "test" failed: denied`},
+		"endless output": {script: `exec yes`, expectedMsg: `plugin "test" wrote more than`},
+		"timeout":        {script: `exec sleep 10`, opts: []Option{WithTimeout(100 * time.Millisecond)}, expectedError: context.DeadlineExceeded},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "plugin.sh"), []byte("#!/bin/sh\n"+tCase.script+"\n"), 0o755))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, plugin.MetadataFilename), []byte("name: test\ncommand: plugin.sh\npushers: [test]\n"), 0o644))
+			p, err := plugin.LoadDir(dir)
+			require.NoError(t, err)
+
+			pusher, err := NewPluginPusher(p, &config.GlobalSettings{})(tCase.opts...)
+			require.NoError(t, err)
+
+			var out bytes.Buffer
+			err = pusher.Push("myext.wasm", "Wasm.yaml", "test://some/myext", WithWriter(&out))
+			switch {
+			case tCase.expectedError != nil:
+				assert.ErrorIs(t, err, tCase.expectedError)
+			case tCase.expectedMsg != "":
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				assert.LessOrEqual(t, out.Len(), DefaultPluginMaxOutput)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tCase.expectedOut, out.String())
+			}
+		})
+	}
+}
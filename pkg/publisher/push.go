@@ -17,6 +17,19 @@ type Push struct {
 	cfg      *registry.Configuration
 	registry.RegistryParams
 	out io.Writer
+
+	// SkipWasmValidation disables checking that the file is a Proxy-Wasm extension.
+	SkipWasmValidation bool
+
+	// Strip optionally matches the custom sections to remove before publishing.
+	Strip func(name string) bool
+
+	// SmokeTest enables starting the extension in an embedded host before publishing it.
+	SmokeTest bool
+
+	// SmokeTestConfig is the plugin configuration used in the smoke test.
+	// The example configuration in the metadata is used when it is nil.
+	SmokeTestConfig []byte
 }
 
 // PushOpt is a type of function that sets options for a push action.
@@ -36,6 +49,29 @@ func WithPushOptWriter(out io.Writer) PushOpt {
 	}
 }
 
+// WithSkipWasmValidation disables checking that the file is a Proxy-Wasm extension before publishing it.
+func WithSkipWasmValidation(skip bool) PushOpt {
+	return func(p *Push) {
+		p.SkipWasmValidation = skip
+	}
+}
+
+// WithStrip sets the function that matches the custom sections to remove before publishing.
+func WithStrip(strip func(name string) bool) PushOpt {
+	return func(p *Push) {
+		p.Strip = strip
+	}
+}
+
+// WithSmokeTest enables starting the extension in an embedded host before publishing it, with
+// some plugin configuration (or the example configuration in the metadata when it is nil).
+func WithSmokeTest(enabled bool, config []byte) PushOpt {
+	return func(p *Push) {
+		p.SmokeTest = enabled
+		p.SmokeTestConfig = config
+	}
+}
+
 // WithPushRegistryClient sets the registry client on the push configuration object.
 func WithPushRegistryClient(client *registry.Client) PushOpt {
 	return func(p *Push) {
@@ -66,20 +102,32 @@ func NewPush(settings *config.GlobalSettings, cfg *registry.Configuration, opts
 func (p *Push) Run(wasmExe string, metadataFile string, remote string) (string, error) {
 	var out strings.Builder
 
-	if !registry.IsOCI(remote) {
-		return "", fmt.Errorf("only OCI registries are supported")
+	pushers := All(p.Settings)
+	if !pushers.SupportsRef(remote) {
+		return "", fmt.Errorf("only OCI registries, OCI layout directories and the schemes in plugins are supported")
+	}
+
+	if p.SmokeTest {
+		if err := p.smokeTest(wasmExe, metadataFile, &out); err != nil {
+			return out.String(), err
+		}
 	}
 
 	c := WASMUploader{
 		Out:     &out,
-		Pushers: All(p.Settings),
+		Pushers: pushers,
 		Options: []Option{
 			WithTLSClientConfig(p.CertFile, p.KeyFile, p.CAFile),
 			WithInsecureSkipTLSVerify(p.Insecure),
 			WithPlainHTTP(p.PlainHTTP),
+			WithMaxAttempts(p.MaxAttempts),
 			WithRegistryClient(p.cfg.RegistryClient),
+			WithWriter(&out),
 		},
+		SkipWasmValidation: p.SkipWasmValidation,
+		Strip:              p.Strip,
 	}
 
-	return out.String(), c.UploadTo(wasmExe, metadataFile, remote)
+	err := c.UploadTo(wasmExe, metadataFile, remote)
+	return out.String(), err
 }
@@ -0,0 +1,69 @@
+package publisher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+	"github.com/inercia/proxy-wasm-oci/pkg/config"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const testMetadata = "apiVersion: v1\nname: myext\nversion: 1.0.0\n"
+
+// writeExtension writes a Wasm module and its metadata to a temporary directory
+func writeExtension(t *testing.T, data []byte, metadata string) (string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	wasmExe := filepath.Join(dir, "myext.wasm")
+	metadataFile := filepath.Join(dir, "Wasm.yaml")
+	require.NoError(t, os.WriteFile(wasmExe, data, 0o644))
+	require.NoError(t, os.WriteFile(metadataFile, []byte(metadata), 0o644))
+	return wasmExe, metadataFile
+}
+
+// newTestPush creates a push action without plugins
+func newTestPush(t *testing.T, opts ...any) *Push {
+	t.Helper()
+	settings := &config.GlobalSettings{PluginsDirectory: t.TempDir()}
+	return NewPush(settings, &registry.Configuration{}, opts...)
+}
+
+func TestPushWasmValidation(t *testing.T) {
+	// a gzip'd module
+	gzipped := []byte{0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0xff}
+
+	for name, tCase := range map[string]struct {
+		data        []byte
+		skip        bool
+		expectedMsg string
+	}{
+		"valid":                   {data: wasmtest.ProxyWasm()},
+		"invalid":                 {data: gzipped, expectedMsg: "invalid Proxy-Wasm extension"},
+		"invalid, skipped":        {data: gzipped, skip: true},
+		"not Proxy-Wasm":          {data: wasmtest.Module(), expectedMsg: "use --skip-wasm-validation for publishing it anyway"},
+		"not Proxy-Wasm, skipped": {data: wasmtest.Module(), skip: true},
+	} {
+		t.Run(name, func(t *testing.T) {
+			wasmExe, metadataFile := writeExtension(t, tCase.data, testMetadata)
+			layout := filepath.Join(t.TempDir(), "layout")
+
+			_, err := newTestPush(t, WithSkipWasmValidation(tCase.skip)).Run(wasmExe, metadataFile, "oci-layout://"+layout+":1.0.0")
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				// nothing has been published
+				assert.NoDirExists(t, layout)
+				return
+			}
+			require.NoError(t, err)
+
+			tags, err := registry.LayoutTags(layout)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"1.0.0"}, tags)
+		})
+	}
+}
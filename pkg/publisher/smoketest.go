@@ -0,0 +1,68 @@
+package publisher
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// SmokeTestError is returned when an extension fails the smoke test
+type SmokeTestError struct {
+	Err error
+	// Logs are the messages logged by the extension while starting
+	Logs []host.LogEntry
+}
+
+func (e *SmokeTestError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "smoke test failed: %s", e.Err)
+	if len(e.Logs) > 0 {
+		b.WriteString("\nlogs:")
+		for _, l := range e.Logs {
+			fmt.Fprintf(&b, "\n  %s", l)
+		}
+	}
+	return b.String()
+}
+
+func (e *SmokeTestError) Unwrap() error {
+	return e.Err
+}
+
+// smokeTest starts the extension in an embedded Proxy-Wasm host, running its initialization,
+// proxy_on_vm_start and proxy_on_configure with the example configuration, so extensions that
+// trap on startup or reject their configuration are not published.
+func (p *Push) smokeTest(wasmExe, metadataFile string, out io.Writer) error {
+	data, err := os.ReadFile(wasmExe)
+	if err != nil {
+		return err
+	}
+
+	config := p.SmokeTestConfig
+	if config == nil && metadataFile != "" {
+		meta, err := common.NewMetadataFromFile(metadataFile)
+		if err != nil {
+			return fmt.Errorf("when reading metadata from %s: %w", metadataFile, err)
+		}
+		config = []byte(meta.ExampleConfig)
+	}
+
+	ctx := context.Background()
+	h, err := host.New(ctx, data, host.WithPluginConfiguration(config))
+	if err != nil {
+		return &SmokeTestError{Err: err}
+	}
+	defer h.Close(ctx)
+
+	if err := h.Start(ctx); err != nil {
+		return &SmokeTestError{Err: err, Logs: h.Logs()}
+	}
+
+	fmt.Fprintf(out, "%s: smoke test passed\n", wasmExe)
+	return nil
+}
@@ -0,0 +1,75 @@
+package publisher
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+func TestPushSmokeTest(t *testing.T) {
+	const (
+		logError    = 4
+		unreachable = 0x00
+	)
+	// rejects any non-empty configuration: local.get 1; i32.eqz
+	rejectConfig := append(wasmtest.Log(logError), 0x20, 1, 0x45)
+
+	for name, tCase := range map[string]struct {
+		data        []byte
+		metadata    string
+		config      []byte
+		expectedMsg string
+	}{
+		"passed": {
+			data:     wasmtest.ProxyWasm(),
+			metadata: testMetadata,
+		},
+		"trap in proxy_on_configure": {
+			data:        wasmtest.ProxyWasm(append(wasmtest.Log(logError), unreachable)...),
+			metadata:    testMetadata,
+			expectedMsg: "smoke test failed: proxy_on_configure trapped",
+		},
+		"example configuration rejected": {
+			data:        wasmtest.ProxyWasm(rejectConfig...),
+			metadata:    testMetadata + "exampleConfig: '{\"key\": \"value\"}'\n",
+			expectedMsg: "the plugin configuration has been rejected",
+		},
+		"configuration rejected": {
+			data:        wasmtest.ProxyWasm(rejectConfig...),
+			metadata:    testMetadata,
+			config:      []byte(`{"key": "value"}`),
+			expectedMsg: "the plugin configuration has been rejected",
+		},
+		"configuration accepted instead of the example": {
+			data:     wasmtest.ProxyWasm(rejectConfig...),
+			metadata: testMetadata + "exampleConfig: '{\"key\": \"value\"}'\n",
+			config:   []byte{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			wasmExe, metadataFile := writeExtension(t, tCase.data, tCase.metadata)
+			layout := filepath.Join(t.TempDir(), "layout")
+
+			out, err := newTestPush(t, WithSmokeTest(true, tCase.config)).Run(wasmExe, metadataFile, "oci-layout://"+layout+":1.0.0")
+			if tCase.expectedMsg != "" {
+				var smokeErr *SmokeTestError
+				require.True(t, errors.As(err, &smokeErr), "unexpected error: %v", err)
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				assert.Contains(t, smokeErr.Logs, host.LogEntry{Level: host.LogLevelError, Message: wasmtest.LogMessage, Source: host.SourceExtension})
+				assert.Contains(t, err.Error(), "logs:")
+				// nothing has been published
+				assert.NoDirExists(t, layout)
+				return
+			}
+			require.NoError(t, err)
+			assert.Contains(t, out, "smoke test passed")
+			assert.DirExists(t, layout)
+		})
+	}
+}
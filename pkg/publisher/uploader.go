@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"io"
 	"net/url"
+	"os"
+	"path/filepath"
 
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
 )
 
 // WASMUploader handles uploading a Proxy-WASM extension.
@@ -18,6 +21,10 @@ type WASMUploader struct {
 	Options []Option
 	// RegistryClient is a client for interacting with registries.
 	RegistryClient *registry.Client
+	// SkipWasmValidation disables checking that the file is a Proxy-Wasm extension.
+	SkipWasmValidation bool
+	// Strip optionally matches the custom sections to remove before publishing.
+	Strip func(name string) bool
 }
 
 func NewWASMUploader(out io.Writer, pushers Providers, opts ...Option) WASMUploader {
@@ -45,5 +52,43 @@ func (c *WASMUploader) UploadTo(wasmExe, metadataFile, remote string) error {
 		return err
 	}
 
-	return p.Push(wasmExe, metadataFile, remoteURL.String(), c.Options...)
+	data, err := os.ReadFile(wasmExe)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: no such file", wasmExe)
+		}
+		return err
+	}
+
+	if !c.SkipWasmValidation {
+		if _, _, err := wasm.ValidateProxyWasm(data); err != nil {
+			return fmt.Errorf("%s: %w (use --skip-wasm-validation for publishing it anyway)", wasmExe, err)
+		}
+	}
+
+	// annotations are obtained from the original file, as stripping
+	// removes the toolchain and the file is published from a temporary directory
+	annotations := sourceAnnotations(wasmExe, data)
+
+	if c.Strip != nil {
+		stripped, err := wasm.Strip(data, c.Strip)
+		if err != nil {
+			return fmt.Errorf("when stripping %s: %w", wasmExe, err)
+		}
+		fmt.Fprintf(c.Out, "%s: %s\n", wasmExe, stripped)
+
+		dir, err := os.MkdirTemp("", "pwo-publish-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+
+		wasmExe = filepath.Join(dir, filepath.Base(wasmExe))
+		if err := os.WriteFile(wasmExe, stripped.Data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	options := append(append([]Option{}, c.Options...), WithAnnotations(annotations))
+	return p.Push(wasmExe, metadataFile, remoteURL.String(), options...)
 }
@@ -0,0 +1,296 @@
+package registry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/pkg/content"
+	"oras.land/oras-go/pkg/registry"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+)
+
+// BundleIndexFilename is the name of the index in a bundle
+const BundleIndexFilename = "bundle.json"
+
+type (
+	// BundleIndex describes the extensions in a bundle
+	BundleIndex struct {
+		Extensions []BundleEntry `json:"extensions"`
+	}
+
+	// BundleEntry is an extension in a bundle, stored in an OCI layout
+	// (shared by all the extensions from the same repository)
+	BundleEntry struct {
+		// Ref is the reference the extension was saved from
+		Ref    string `json:"ref"`
+		Digest string `json:"digest"`
+		// Path is the OCI layout directory in the bundle, where the extension is tagged with the tag in the Ref
+		Path string `json:"path"`
+		// Signatures are the tags of the signatures (and other artifacts) attached to the manifest
+		Signatures []string `json:"signatures,omitempty"`
+	}
+)
+
+// Save writes some extensions, with their config, layers and the attached signatures,
+// into a tar archive that can be loaded in a registry or served without any registry.
+// All the references must have a tag.
+func (c *Client) Save(refs []string, filename string, options ...CopyOption) (*BundleIndex, error) {
+	operation := &copyOperation{}
+	for _, option := range options {
+		option(operation)
+	}
+
+	dir, err := os.MkdirTemp("", "pwo-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx := ctx(c.out, c.debug)
+	index := &BundleIndex{}
+
+	for _, ref := range refs {
+		parsedRef, err := parseReference(ref)
+		if err != nil {
+			return nil, err
+		}
+		if parsedRef.Reference == "" || strings.Contains(parsedRef.Reference, ":") {
+			return nil, fmt.Errorf("no tag in reference %q", ref)
+		}
+
+		entry, err := c.saveReference(ctx, dir, parsedRef, operation)
+		if err != nil {
+			return nil, fmt.Errorf("when saving %s: %w", parsedRef.String(), err)
+		}
+		index.Extensions = append(index.Extensions, *entry)
+
+		fmt.Fprintf(c.out, "Saved: %s\n", entry.Ref)
+		fmt.Fprintf(c.out, "Digest: %s\n", entry.Digest)
+	}
+
+	for _, entry := range index.Extensions {
+		// the store leaves an (empty) directory for staging uploads
+		_ = os.Remove(filepath.Join(dir, filepath.FromSlash(entry.Path), "ingest"))
+	}
+
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, BundleIndexFilename), data, 0o644); err != nil {
+		return nil, err
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(utils.TarDir(pw, dir))
+	}()
+	if err := utils.AtomicWriteFile(filename, pr, 0o644); err != nil {
+		pr.CloseWithError(err)
+		return nil, fmt.Errorf("when writing %s: %w", filename, err)
+	}
+
+	return index, nil
+}
+
+// saveReference copies a manifest (and its signatures) to the OCI layout for its repository in a bundle directory.
+func (c *Client) saveReference(ctx context.Context, dir string, ref registry.Reference, operation *copyOperation) (*BundleEntry, error) {
+	resolver, err := c.resolver(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	path := bundlePath(ref)
+	layoutDir := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(layoutDir, 0o755); err != nil {
+		return nil, err
+	}
+	store, err := content.NewOCI(layoutDir)
+	if err != nil {
+		return nil, fmt.Errorf("when creating OCI layout: %w", err)
+	}
+
+	copyTo := func(src registry.Reference) (ocispec.Descriptor, error) {
+		name, desc, err := resolver.Resolve(ctx, src.String())
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		fetcher, err := resolver.Fetcher(ctx, name)
+		if err != nil {
+			return ocispec.Descriptor{}, err
+		}
+		cp := &copier{fetcher: fetcher, pusher: layoutPusher(store, src.Reference)}
+		return desc, cp.copy(ctx, desc, true)
+	}
+
+	desc, err := copyTo(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &BundleEntry{
+		Ref:    ref.String(),
+		Digest: desc.Digest.String(),
+		Path:   path,
+	}
+
+	if !operation.skipSignatures {
+		for _, suffix := range signatureSuffixes {
+			tag := signatureTag(desc.Digest, suffix)
+			sigRef := registry.Reference{Registry: ref.Registry, Repository: ref.Repository, Reference: tag}
+			if _, err := copyTo(sigRef); err != nil {
+				if errdefs.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("when saving %s: %w", sigRef.String(), err)
+			}
+			entry.Signatures = append(entry.Signatures, tag)
+		}
+	}
+
+	return entry, nil
+}
+
+// Load pushes all the extensions in a bundle (with their signatures) to the registries
+// they were saved from or, when a registry is provided, to the same repositories in that registry.
+func (c *Client) Load(filename, toRegistry string) ([]*CopyResult, error) {
+	dir, err := os.MkdirTemp("", "pwo-bundle-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	index, err := ExtractBundle(filename, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := ctx(c.out, c.debug)
+	var results []*CopyResult
+
+	for _, entry := range index.Extensions {
+		dstRef, err := parseReference(entry.Ref)
+		if err != nil {
+			return nil, err
+		}
+		if toRegistry != "" {
+			dstRef.Registry = toRegistry
+		}
+
+		resolver, err := c.resolver(dstRef)
+		if err != nil {
+			return nil, err
+		}
+
+		store, err := openLayout(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return nil, err
+		}
+
+		result := &CopyResult{
+			Src:    fmt.Sprintf("%s://%s:%s", OCILayoutScheme, entry.Path, dstRef.Reference),
+			Dst:    dstRef.String(),
+			Digest: entry.Digest,
+		}
+
+		for _, tag := range append([]string{dstRef.Reference}, entry.Signatures...) {
+			_, desc, err := store.Resolve(ctx, tag)
+			if err != nil {
+				return nil, fmt.Errorf("when reading %s from bundle: %w", tag, err)
+			}
+
+			tagRef := registry.Reference{Registry: dstRef.Registry, Repository: dstRef.Repository, Reference: tag}
+			cp := &copier{fetcher: store, pusher: registryPusher(resolver, tagRef)}
+			if err := cp.copy(ctx, desc, true); err != nil {
+				return nil, fmt.Errorf("when pushing %s: %w", tagRef.String(), err)
+			}
+			if tag != dstRef.Reference {
+				result.Signatures = append(result.Signatures, tagRef.String())
+			}
+		}
+
+		fmt.Fprintf(c.out, "Loaded: %s\n", result.Dst)
+		fmt.Fprintf(c.out, "Digest: %s\n", result.Digest)
+		for _, sig := range result.Signatures {
+			fmt.Fprintf(c.out, "Loaded: %s\n", sig)
+		}
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// ExtractBundle extracts a bundle into a directory, returning its index.
+func ExtractBundle(filename, dir string) (*BundleIndex, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := utils.Untar(f, dir); err != nil {
+		return nil, fmt.Errorf("when extracting bundle %s: %w", filename, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, BundleIndexFilename))
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a bundle: %w", filename, err)
+	}
+
+	index := &BundleIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("when parsing bundle index: %w", err)
+	}
+	// the paths are used for opening the OCI layouts, so they must be in the bundle
+	for _, entry := range index.Extensions {
+		if !filepath.IsLocal(filepath.FromSlash(entry.Path)) {
+			return nil, fmt.Errorf("invalid path in bundle index for %s: %q", entry.Ref, entry.Path)
+		}
+	}
+	return index, nil
+}
+
+// LayoutRef returns the reference to the OCI layout in a bundle (extracted in a directory) for an
+// OCI reference, like "oci-layout:///bundle/myregistry.com/myrepo:1.0.0" for "oci://myregistry.com/myrepo:1.0.0".
+// It returns false when the repository is not in the bundle.
+func (index *BundleIndex) LayoutRef(dir, ref string) (string, bool) {
+	parsedRef, err := parseReference(strings.TrimPrefix(ref, fmt.Sprintf("%s://", OCIScheme)))
+	if err != nil {
+		return "", false
+	}
+
+	path := bundlePath(parsedRef)
+	for _, entry := range index.Extensions {
+		if entry.Path != path {
+			continue
+		}
+
+		layoutRef := fmt.Sprintf("%s://%s", OCILayoutScheme, filepath.Join(dir, filepath.FromSlash(path)))
+		if parsedRef.Reference != "" {
+			layoutRef += ":" + parsedRef.Reference
+		}
+		return layoutRef, true
+	}
+
+	return "", false
+}
+
+// bundlePath returns the path of the OCI layout for the repository of a reference in a bundle
+func bundlePath(ref registry.Reference) string {
+	// ports are not a good idea in paths
+	return strings.ReplaceAll(ref.Registry, ":", "_") + "/" + ref.Repository
+}
+
+// signatureTag returns the tag used for attaching a signature (or some other artifact) to a manifest
+func signatureTag(dgst digest.Digest, suffix string) string {
+	return fmt.Sprintf("%s-%s%s", dgst.Algorithm(), dgst.Encoded(), suffix)
+}
@@ -0,0 +1,57 @@
+package registry
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractBundle(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		index       string
+		expectedMsg string
+	}{
+		"valid":            {index: `{"extensions": [{"ref": "myregistry.com/myrepo:1.0.0", "path": "myregistry.com/myrepo"}]}`},
+		"no extensions":    {index: `{"extensions": []}`},
+		"parent directory": {index: `{"extensions": [{"ref": "myregistry.com/myrepo:1.0.0", "path": "../../etc"}]}`, expectedMsg: `invalid path in bundle index for myregistry.com/myrepo:1.0.0: "../../etc"`},
+		"nested parent":    {index: `{"extensions": [{"ref": "r/x:1", "path": "myregistry.com/../../x"}]}`, expectedMsg: "invalid path in bundle index"},
+		"absolute path":    {index: `{"extensions": [{"ref": "r/x:1", "path": "/etc"}]}`, expectedMsg: "invalid path in bundle index"},
+		"empty path":       {index: `{"extensions": [{"ref": "r/x:1", "path": ""}]}`, expectedMsg: "invalid path in bundle index"},
+		"invalid index":    {index: `not json`, expectedMsg: "when parsing bundle index"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			require.NoError(t, tw.WriteHeader(&tar.Header{Name: BundleIndexFilename, Mode: 0o644, Size: int64(len(tCase.index))}))
+			_, err := tw.Write([]byte(tCase.index))
+			require.NoError(t, err)
+			require.NoError(t, tw.Close())
+
+			filename := filepath.Join(t.TempDir(), "bundle.tar")
+			require.NoError(t, os.WriteFile(filename, buf.Bytes(), 0o644))
+
+			index, err := ExtractBundle(filename, t.TempDir())
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, index)
+		})
+	}
+}
+
+func TestExtractBundleNoIndex(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, tar.NewWriter(&buf).Close())
+	filename := filepath.Join(t.TempDir(), "bundle.tar")
+	require.NoError(t, os.WriteFile(filename, buf.Bytes(), 0o644))
+
+	_, err := ExtractBundle(filename, t.TempDir())
+	assert.ErrorContains(t, err, "is not a bundle")
+}
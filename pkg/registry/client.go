@@ -9,6 +9,7 @@ import (
 	"os"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/Masterminds/semver/v3"
 	"github.com/containerd/containerd/remotes"
@@ -21,6 +22,7 @@ import (
 	"oras.land/oras-go/pkg/registry"
 	registryremote "oras.land/oras-go/pkg/registry/remote"
 	registryauth "oras.land/oras-go/pkg/registry/remote/auth"
+	"oras.land/oras-go/pkg/target"
 
 	"github.com/inercia/proxy-wasm-oci/pkg/common"
 	"github.com/inercia/proxy-wasm-oci/pkg/config"
@@ -40,6 +42,11 @@ type (
 		resolver           func(ref registry.Reference) (remotes.Resolver, error)
 		httpClient         *http.Client
 		plainHTTP          bool
+		maxAttempts        int
+		params             RegistryParams
+		serverName         string
+		timeout            time.Duration
+		userAgent          string
 	}
 
 	// ClientOption allows specifying various settings configurable by the user for overriding the defaults
@@ -66,6 +73,36 @@ func NewClient(options ...ClientOption) (*Client, error) {
 		client.authorizer = authClient
 	}
 
+	if client.userAgent == "" {
+		client.userAgent = version.GetUserAgent()
+	}
+
+	if client.httpClient == nil {
+		transport, err := NewTransport(client.params, client.serverName)
+		if err != nil {
+			return nil, err
+		}
+		client.httpClient = &http.Client{
+			Transport: transport,
+			Timeout:   client.timeout,
+		}
+	}
+
+	// wrap the transport (on a copy, as the http.Client could be shared)
+	httpClient := *client.httpClient
+	if _, ok := httpClient.Transport.(*RetryTransport); !ok {
+		base := httpClient.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		var transport http.RoundTripper = &userAgentTransport{base: base, userAgent: client.userAgent}
+		if client.debug {
+			transport = &debugTransport{base: transport, out: client.out}
+		}
+		httpClient.Transport = NewRetryTransport(transport, client.maxAttempts)
+	}
+	client.httpClient = &httpClient
+
 	resolverFn := client.resolver // copy for avoiding recursive call
 	client.resolver = func(ref registry.Reference) (remotes.Resolver, error) {
 		if resolverFn != nil {
@@ -75,11 +112,9 @@ func NewClient(options ...ClientOption) (*Client, error) {
 			}
 		}
 		headers := http.Header{}
-		headers.Set("User-Agent", version.GetUserAgent())
+		headers.Set("User-Agent", client.userAgent)
 		opts := []auth.ResolverOption{auth.WithResolverHeaders(headers)}
-		if client.httpClient != nil {
-			opts = append(opts, auth.WithResolverClient(client.httpClient))
-		}
+		opts = append(opts, auth.WithResolverClient(client.httpClient))
 		if client.plainHTTP {
 			opts = append(opts, auth.WithResolverPlainHTTP())
 		}
@@ -99,7 +134,7 @@ func NewClient(options ...ClientOption) (*Client, error) {
 		client.registryAuthorizer = &registryauth.Client{
 			Client: client.httpClient,
 			Header: http.Header{
-				"User-Agent": {version.GetUserAgent()},
+				"User-Agent": {client.userAgent},
 			},
 			Cache: cache,
 			Credential: func(ctx context.Context, reg string) (registryauth.Credential, error) {
@@ -130,16 +165,24 @@ func NewClient(options ...ClientOption) (*Client, error) {
 	return client, nil
 }
 
-func NewDefaultRegistryClient(plainHTTP bool) (*Client, error) {
+// NewDefaultRegistryClient returns a registry client with the default settings.
+func NewDefaultRegistryClient(plainHTTP bool, options ...ClientOption) (*Client, error) {
+	return NewClientWithParams(RegistryParams{PlainHTTP: plainHTTP}, "", false, options...)
+}
+
+// NewClientWithParams returns a registry client for some registry parameters (TLS, plain HTTP, retries...).
+// This is the preferred way for creating clients, as it uses the same transport settings
+// (proxies, timeouts, user agent...) for all the commands.
+func NewClientWithParams(p RegistryParams, registryConfig string, debug bool, options ...ClientOption) (*Client, error) {
 	opts := []ClientOption{
+		ClientOptDebug(debug),
 		ClientOptEnableCache(true),
 		ClientOptWriter(os.Stderr),
+		ClientOptCredentialsFile(registryConfig),
+		ClientOptRegistryParams(p),
 	}
-	if plainHTTP {
-		opts = append(opts, ClientOptPlainHTTP())
-	}
+	opts = append(opts, options...)
 
-	// Create a new registry client
 	registryClient, err := NewClient(opts...)
 	if err != nil {
 		return nil, err
@@ -147,34 +190,8 @@ func NewDefaultRegistryClient(plainHTTP bool) (*Client, error) {
 	return registryClient, nil
 }
 
-func NewClientWithParams(p RegistryParams, registryConfig string, debug bool) (*Client, error) {
-	if p.PlainHTTP {
-		registryClient, err := NewDefaultRegistryClient(p.PlainHTTP)
-		if err != nil {
-			return nil, err
-		}
-		return registryClient, nil
-	}
-
-	if p.CertFile != "" && p.KeyFile != "" || p.CAFile != "" || p.Insecure {
-		registryClient, err := NewClientWithTLSWithParams(p, registryConfig, debug)
-		if err != nil {
-			return nil, err
-		}
-
-		return registryClient, nil
-	}
-
-	return NewDefaultRegistryClient(p.PlainHTTP)
-}
-
 func NewClientWithTLSWithParams(p RegistryParams, registryConfig string, debug bool) (*Client, error) {
-	// Create a new registry client
-	registryClient, err := NewRegistryClientWithTLS(os.Stderr, p, registryConfig, debug)
-	if err != nil {
-		return nil, err
-	}
-	return registryClient, nil
+	return NewClientWithParams(p, registryConfig, debug)
 }
 
 // ClientOptDebug returns a function that sets the debug setting on client options set
@@ -212,6 +229,44 @@ func ClientOptHTTPClient(httpClient *http.Client) ClientOption {
 	}
 }
 
+// ClientOptMaxAttempts returns a function that sets the maximum number of attempts
+// for a request to the registry (0 uses the default, 1 disables retries)
+func ClientOptMaxAttempts(attempts int) ClientOption {
+	return func(client *Client) {
+		client.maxAttempts = attempts
+	}
+}
+
+// ClientOptRegistryParams returns a function that sets the TLS, plain HTTP and retry settings on a client options set
+func ClientOptRegistryParams(p RegistryParams) ClientOption {
+	return func(client *Client) {
+		client.params = p
+		client.plainHTTP = p.PlainHTTP
+		client.maxAttempts = p.MaxAttempts
+	}
+}
+
+// ClientOptServerName returns a function that sets the server name used for SNI on a client options set
+func ClientOptServerName(serverName string) ClientOption {
+	return func(client *Client) {
+		client.serverName = serverName
+	}
+}
+
+// ClientOptTimeout returns a function that sets the timeout for requests on a client options set
+func ClientOptTimeout(timeout time.Duration) ClientOption {
+	return func(client *Client) {
+		client.timeout = timeout
+	}
+}
+
+// ClientOptUserAgent returns a function that sets the user agent on a client options set
+func ClientOptUserAgent(userAgent string) ClientOption {
+	return func(client *Client) {
+		client.userAgent = userAgent
+	}
+}
+
 func ClientOptPlainHTTP() ClientOption {
 	return func(c *Client) {
 		c.plainHTTP = true
@@ -256,7 +311,7 @@ func (c *Client) Login(host string, options ...LoginOption) error {
 		auth.WithLoginHostname(host),
 		auth.WithLoginUsername(operation.username),
 		auth.WithLoginSecret(operation.password),
-		auth.WithLoginUserAgent(version.GetUserAgent()),
+		auth.WithLoginUserAgent(c.userAgent),
 		auth.WithLoginTLS(operation.certFile, operation.keyFile, operation.caFile),
 	}
 	if operation.insecure {
@@ -340,7 +395,9 @@ type (
 		Meta *common.Metadata `json:"meta"`
 	}
 
-	pullOperation struct{}
+	pullOperation struct {
+		progress ProgressFunc
+	}
 )
 
 // Pull downloads a WASM extension from a registry
@@ -354,6 +411,30 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 	for _, option := range options {
 		option(operation)
 	}
+
+	remotesResolver, err := c.resolver(parsedRef)
+	if err != nil {
+		return nil, err
+	}
+	registryStore := content.Registry{Resolver: remotesResolver}
+
+	result, err := pull(ctx(c.out, c.debug), withProgress(registryStore, operation.progress), parsedRef.String())
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(c.out, "Pulled: %s\n", result.Ref)
+	fmt.Fprintf(c.out, "Digest: %s\n", result.Manifest.Digest)
+
+	if strings.Contains(result.Ref, "_") {
+		fmt.Fprintf(c.out, "%s contains an underscore.\n", result.Ref)
+	}
+
+	return result, nil
+}
+
+// pull obtains a WASM extension from a target (a registry, an OCI layout...).
+func pull(ctx context.Context, from target.Target, ref string) (*PullResult, error) {
 	memoryStore := content.NewMemory()
 	allowedMediaTypes := []string{
 		WASMMetadataMediaType,
@@ -363,13 +444,7 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 	allowedMediaTypes = append(allowedMediaTypes, WASMLayerMediaType)
 
 	var descriptors, layers []ocispec.Descriptor
-	remotesResolver, err := c.resolver(parsedRef)
-	if err != nil {
-		return nil, err
-	}
-	registryStore := content.Registry{Resolver: remotesResolver}
-
-	manifest, err := oras.Copy(ctx(c.out, c.debug), registryStore, parsedRef.String(), memoryStore, "",
+	manifest, err := oras.Copy(ctx, from, ref, memoryStore, "",
 		oras.WithPullEmptyNameAllowed(),
 		oras.WithAllowedMediaTypes(allowedMediaTypes),
 		oras.WithLayerDescriptors(func(l []ocispec.Descriptor) {
@@ -416,7 +491,7 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 			Size:   configDescriptor.Size,
 		},
 		WASMExt: &DescriptorPullSummaryWithMeta{},
-		Ref:     parsedRef.String(),
+		Ref:     ref,
 	}
 
 	var getManifestErr error
@@ -455,13 +530,6 @@ func (c *Client) Pull(ref string, options ...PullOption) (*PullResult, error) {
 		return nil, getWASMExtDescriptorErr
 	}
 
-	fmt.Fprintf(c.out, "Pulled: %s\n", result.Ref)
-	fmt.Fprintf(c.out, "Digest: %s\n", result.Manifest.Digest)
-
-	if strings.Contains(result.Ref, "_") {
-		fmt.Fprintf(c.out, "%s contains an underscore.\n", result.Ref)
-	}
-
 	return result, nil
 }
 
@@ -492,8 +560,9 @@ type (
 	}
 
 	pushOperation struct {
-		strictMode bool
-		test       bool
+		annotations map[string]string
+		strictMode  bool
+		test        bool
 	}
 )
 
@@ -511,6 +580,28 @@ func (c *Client) Push(data []byte, meta common.Metadata, ref string, options ...
 		option(operation)
 	}
 
+	remotesResolver, err := c.resolver(parsedRef)
+	if err != nil {
+		return nil, err
+	}
+	registryStore := content.Registry{Resolver: remotesResolver}
+
+	result, err := push(ctx(c.out, c.debug), data, meta, registryStore, parsedRef.String(), operation)
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Fprintf(c.out, "Pushed: %s\n", result.Ref)
+	fmt.Fprintf(c.out, "Digest: %s\n", result.Manifest.Digest)
+	if strings.Contains(parsedRef.Reference, "_") {
+		fmt.Fprintf(c.out, "%s contains an underscore.\n", result.Ref)
+	}
+
+	return result, err
+}
+
+// push uploads a WASM extension to a target (a registry, an OCI layout...).
+func push(ctx context.Context, data []byte, meta common.Metadata, to target.Target, ref string, operation *pushOperation) (*PushResult, error) {
 	memoryStore := content.NewMemory()
 	wasmExeDescriptor, err := memoryStore.Add("", WASMLayerMediaType, data)
 	if err != nil {
@@ -529,23 +620,18 @@ func (c *Client) Push(data []byte, meta common.Metadata, ref string, options ...
 
 	descriptors := []ocispec.Descriptor{wasmExeDescriptor}
 
-	ociAnnotations := generateOCIAnnotations(&meta, operation.test)
+	ociAnnotations := generateOCIAnnotations(&meta, data, operation.annotations, operation.test)
 
 	manifestData, manifest, err := content.GenerateManifest(&metaDescriptor, ociAnnotations, descriptors...)
 	if err != nil {
 		return nil, err
 	}
 
-	if err := memoryStore.StoreManifest(parsedRef.String(), manifest, manifestData); err != nil {
+	if err := memoryStore.StoreManifest(ref, manifest, manifestData); err != nil {
 		return nil, err
 	}
 
-	remotesResolver, err := c.resolver(parsedRef)
-	if err != nil {
-		return nil, err
-	}
-	registryStore := content.Registry{Resolver: remotesResolver}
-	_, err = oras.Copy(ctx(c.out, c.debug), memoryStore, parsedRef.String(), registryStore, "",
+	_, err = oras.Copy(ctx, memoryStore, ref, to, "",
 		oras.WithNameValidation(nil))
 	if err != nil {
 		return nil, err
@@ -566,15 +652,9 @@ func (c *Client) Push(data []byte, meta common.Metadata, ref string, options ...
 			Size:   metaDescriptor.Size,
 		},
 		WASMExt: wasmSummary,
-		Ref:     parsedRef.String(),
-	}
-	fmt.Fprintf(c.out, "Pushed: %s\n", result.Ref)
-	fmt.Fprintf(c.out, "Digest: %s\n", result.Manifest.Digest)
-	if strings.Contains(parsedRef.Reference, "_") {
-		fmt.Fprintf(c.out, "%s contains an underscore.\n", result.Ref)
+		Ref:     ref,
 	}
-
-	return result, err
+	return result, nil
 }
 
 // PushOptStrictMode returns a function that sets the strictMode setting on push
@@ -584,6 +664,14 @@ func PushOptStrictMode(strictMode bool) PushOption {
 	}
 }
 
+// PushOptAnnotations returns a function that sets some extra annotations (like the
+// source revision) for the manifest on push. They can be overridden in the metadata.
+func PushOptAnnotations(annotations map[string]string) PushOption {
+	return func(operation *pushOperation) {
+		operation.annotations = annotations
+	}
+}
+
 // PushOptTest returns a function that sets whether test setting on push
 func PushOptTest(test bool) PushOption {
 	return func(operation *pushOperation) {
@@ -615,6 +703,11 @@ func (c *Client) Tags(ref string) ([]string, error) {
 		return nil, err
 	}
 
+	return sortVersionTags(registryTags), nil
+}
+
+// sortVersionTags returns the semver compliant tags, sorted from the highest version
+func sortVersionTags(registryTags []string) []string {
 	var tagVersions []*semver.Version
 	for _, tag := range registryTags {
 		// Change underscore (_) back to plus (+) for Helm
@@ -634,5 +727,5 @@ func (c *Client) Tags(ref string) ([]string, error) {
 		tags[iTv] = tv.String()
 	}
 
-	return tags, nil
+	return tags
 }
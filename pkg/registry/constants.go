@@ -13,3 +13,21 @@ const (
 	// WASMLayerMediaType is the reserved media type for Proxy Wasm Publisher package content
 	WASMLayerMediaType = "application/vnd.wasm.content.layer.v1+wasm"
 )
+
+// Annotations derived from the Wasm binary when publishing an extension
+const (
+	// AnnotationPrefix is the prefix of the annotations added by pwo
+	AnnotationPrefix = "io.proxy-wasm-oci."
+
+	// AnnotationABIVersion is the Proxy-Wasm ABI version the extension has been built for
+	AnnotationABIVersion = AnnotationPrefix + "abi.version"
+
+	// AnnotationWASIImports are the WASI functions imported by the extension, comma-separated
+	AnnotationWASIImports = AnnotationPrefix + "wasi.imports"
+
+	// AnnotationModuleSize is the size of the Wasm module, in bytes
+	AnnotationModuleSize = AnnotationPrefix + "module.size"
+
+	// AnnotationToolchain is the toolchain that produced the Wasm module (e.g. "TinyGo 0.30.0")
+	AnnotationToolchain = AnnotationPrefix + "toolchain"
+)
@@ -0,0 +1,306 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/labels"
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/pkg/content"
+	"oras.land/oras-go/pkg/registry"
+)
+
+// signatureSuffixes are the suffixes of the tags used (by cosign and similar tools)
+// for attaching signatures, attestations and SBOMs to a manifest.
+var signatureSuffixes = []string{".sig", ".att", ".sbom"}
+
+type (
+	// CopyOption allows specifying various settings on copy
+	CopyOption func(*copyOperation)
+
+	// CopyResult is the result returned upon successful copy.
+	CopyResult struct {
+		Src    string `json:"src"`
+		Dst    string `json:"dst"`
+		Digest string `json:"digest"`
+		// Signatures are the signatures (and other artifacts) attached to the manifest that were also copied.
+		Signatures []string `json:"signatures,omitempty"`
+	}
+
+	copyOperation struct {
+		skipSignatures bool
+	}
+)
+
+// Copy copies a manifest, with its config, layers and the attached signatures,
+// from a reference to another one. Contents are copied byte for byte, so the
+// manifest digest is preserved. Blobs are mounted from the source repository
+// when the source and the destination are in the same registry.
+//
+// When the destination does not have a tag, the tag of the source is used.
+func (c *Client) Copy(src, dst string, options ...CopyOption) (*CopyResult, error) {
+	srcRef, err := parseReference(src)
+	if err != nil {
+		return nil, err
+	}
+	dstRef, err := parseReference(dst)
+	if err != nil {
+		return nil, err
+	}
+	if dstRef.Reference == "" {
+		dstRef.Reference = srcRef.Reference
+	}
+	if srcRef.Reference == "" {
+		return nil, fmt.Errorf("no tag or digest in source reference %q", src)
+	}
+
+	operation := &copyOperation{}
+	for _, option := range options {
+		option(operation)
+	}
+
+	resolver, err := c.resolver(srcRef)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := ctx(c.out, c.debug)
+
+	desc, err := c.copyReference(ctx, resolver, srcRef, dstRef)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CopyResult{
+		Src:    srcRef.String(),
+		Dst:    dstRef.String(),
+		Digest: desc.Digest.String(),
+	}
+
+	if !operation.skipSignatures {
+		for _, suffix := range signatureSuffixes {
+			tag := signatureTag(desc.Digest, suffix)
+			srcSig := registry.Reference{Registry: srcRef.Registry, Repository: srcRef.Repository, Reference: tag}
+			dstSig := registry.Reference{Registry: dstRef.Registry, Repository: dstRef.Repository, Reference: tag}
+
+			if _, err := c.copyReference(ctx, resolver, srcSig, dstSig); err != nil {
+				if errdefs.IsNotFound(err) {
+					continue
+				}
+				return nil, fmt.Errorf("when copying %s: %w", srcSig.String(), err)
+			}
+			result.Signatures = append(result.Signatures, dstSig.String())
+		}
+	}
+
+	fmt.Fprintf(c.out, "Copied: %s -> %s\n", result.Src, result.Dst)
+	fmt.Fprintf(c.out, "Digest: %s\n", result.Digest)
+	for _, sig := range result.Signatures {
+		fmt.Fprintf(c.out, "Copied: %s\n", sig)
+	}
+
+	return result, nil
+}
+
+// CopyOptSkipSignatures returns a function that disables copying the signatures attached to a manifest
+func CopyOptSkipSignatures(skip bool) CopyOption {
+	return func(operation *copyOperation) {
+		operation.skipSignatures = skip
+	}
+}
+
+// copyReference copies the manifest pointed by a reference, and everything it references.
+func (c *Client) copyReference(ctx context.Context, resolver remotes.Resolver, srcRef, dstRef registry.Reference) (ocispec.Descriptor, error) {
+	name, desc, err := resolver.Resolve(ctx, srcRef.String())
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	fetcher, err := resolver.Fetcher(ctx, name)
+	if err != nil {
+		return ocispec.Descriptor{}, err
+	}
+
+	cp := &copier{
+		fetcher:      fetcher,
+		pusher:       registryPusher(resolver, dstRef),
+		mountSources: mountSources(srcRef, dstRef),
+	}
+
+	if err := cp.copy(ctx, desc, true); err != nil {
+		return ocispec.Descriptor{}, err
+	}
+	return desc, nil
+}
+
+// mountSources returns the annotations for mounting blobs from the source repository,
+// or nil when the source and the destination are not in the same registry.
+func mountSources(srcRef, dstRef registry.Reference) map[string]string {
+	if srcRef.Registry != dstRef.Registry {
+		return nil
+	}
+	host := dstRef.Registry
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return map[string]string{
+		fmt.Sprintf("%s.%s", labels.LabelDistributionSource, host): srcRef.Repository,
+	}
+}
+
+// pusherFunc returns the pusher for a descriptor, that can be the root of the copy.
+type pusherFunc func(ctx context.Context, desc ocispec.Descriptor, root bool) (remotes.Pusher, error)
+
+// registryPusher returns the pushers for copying to a reference in a registry.
+func registryPusher(resolver remotes.Resolver, dstRef registry.Reference) pusherFunc {
+	return func(ctx context.Context, desc ocispec.Descriptor, root bool) (remotes.Pusher, error) {
+		if root {
+			return resolver.Pusher(ctx, dstRef.String())
+		}
+		// children are referenced by digest, so they must not be tagged in the destination
+		childRef := registry.Reference{Registry: dstRef.Registry, Repository: dstRef.Repository, Reference: desc.Digest.String()}
+		return resolver.Pusher(ctx, childRef.String())
+	}
+}
+
+// layoutPusher returns the pushers for copying to an OCI layout, tagging the root.
+func layoutPusher(store *content.OCI, tag string) pusherFunc {
+	return func(ctx context.Context, desc ocispec.Descriptor, root bool) (remotes.Pusher, error) {
+		if root {
+			// the store tags the manifest with the digest in the reference
+			return store.Pusher(ctx, fmt.Sprintf("%s@%s", tag, desc.Digest))
+		}
+		// the store needs a reference for staging uploads
+		return store.Pusher(ctx, desc.Digest.Encoded())
+	}
+}
+
+// copier copies manifests and blobs from a source to a destination.
+type copier struct {
+	fetcher      remotes.Fetcher
+	pusher       pusherFunc
+	mountSources map[string]string
+}
+
+// copy copies a descriptor to the destination. Manifests and indexes are copied
+// after all their children, so the destination never has dangling references.
+func (cp *copier) copy(ctx context.Context, desc ocispec.Descriptor, root bool) error {
+	if !isManifest(desc.MediaType) {
+		return cp.push(ctx, desc, root, nil)
+	}
+
+	rc, err := cp.fetcher.Fetch(ctx, desc)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	children, err := manifestChildren(desc.MediaType, data)
+	if err != nil {
+		return fmt.Errorf("when parsing manifest %s: %w", desc.Digest, err)
+	}
+	for _, child := range children {
+		if err := cp.copy(ctx, child, false); err != nil {
+			return err
+		}
+	}
+
+	return cp.push(ctx, desc, root, data)
+}
+
+// push uploads a descriptor to the destination, with the given data or streaming it from the source.
+func (cp *copier) push(ctx context.Context, desc ocispec.Descriptor, root bool, data []byte) error {
+	pusher, err := cp.pusher(ctx, desc, root)
+	if err != nil {
+		return err
+	}
+
+	// open the source before starting the upload, so we never leave an upload
+	// waiting for a source that is not available
+	var src io.Reader
+	if data != nil {
+		src = bytes.NewReader(data)
+	} else {
+		rc, err := cp.fetcher.Fetch(ctx, desc)
+		if err != nil {
+			return err
+		}
+		defer rc.Close()
+		src = rc
+	}
+
+	pushDesc := desc
+	if data == nil && cp.mountSources != nil {
+		pushDesc.Annotations = map[string]string{}
+		for k, v := range desc.Annotations {
+			pushDesc.Annotations[k] = v
+		}
+		for k, v := range cp.mountSources {
+			pushDesc.Annotations[k] = v
+		}
+	}
+
+	w, err := pusher.Push(ctx, pushDesc)
+	if err != nil {
+		if errdefs.IsAlreadyExists(err) {
+			return nil
+		}
+		return err
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+
+	if err := w.Commit(ctx, desc.Size, desc.Digest); err != nil && !errdefs.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// isManifest returns true for the media types of manifests and indexes.
+func isManifest(mediaType string) bool {
+	switch mediaType {
+	case ocispec.MediaTypeImageManifest, ocispec.MediaTypeImageIndex,
+		images.MediaTypeDockerSchema2Manifest, images.MediaTypeDockerSchema2ManifestList:
+		return true
+	}
+	return false
+}
+
+// manifestChildren returns the descriptors referenced by a manifest or an index.
+func manifestChildren(mediaType string, data []byte) ([]ocispec.Descriptor, error) {
+	switch mediaType {
+	case ocispec.MediaTypeImageIndex, images.MediaTypeDockerSchema2ManifestList:
+		var index ocispec.Index
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, err
+		}
+		return index.Manifests, nil
+	}
+
+	var manifest ocispec.Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	children := []ocispec.Descriptor{manifest.Config}
+	for _, layer := range manifest.Layers {
+		if !strings.HasPrefix(layer.MediaType, "application/vnd.docker.image.rootfs.foreign") {
+			children = append(children, layer)
+		}
+	}
+	return children, nil
+}
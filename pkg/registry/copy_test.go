@@ -0,0 +1,233 @@
+package registry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/errdefs"
+	"github.com/containerd/containerd/remotes"
+	"github.com/opencontainers/go-digest"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"oras.land/oras-go/pkg/registry"
+)
+
+const foreignLayerMediaType = "application/vnd.docker.image.rootfs.foreign.diff.tar.gzip"
+
+// memFetcher is a fetcher for some blobs in memory
+type memFetcher map[digest.Digest][]byte
+
+func (f memFetcher) Fetch(_ context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+	data, ok := f[desc.Digest]
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", desc.Digest, errdefs.ErrNotFound)
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// add adds a blob, returning its descriptor
+func (f memFetcher) add(mediaType string, data []byte) ocispec.Descriptor {
+	desc := ocispec.Descriptor{MediaType: mediaType, Digest: digest.FromBytes(data), Size: int64(len(data))}
+	f[desc.Digest] = data
+	return desc
+}
+
+// memPush is a push to a memPusher
+type memPush struct {
+	desc ocispec.Descriptor
+	root bool
+}
+
+// memPusher stores the pushed blobs in memory
+type memPusher struct {
+	pushes   []memPush
+	blobs    map[digest.Digest][]byte
+	existing map[digest.Digest]bool
+}
+
+func newMemPusher() *memPusher {
+	return &memPusher{blobs: map[digest.Digest][]byte{}, existing: map[digest.Digest]bool{}}
+}
+
+func (p *memPusher) pusher(_ context.Context, desc ocispec.Descriptor, root bool) (remotes.Pusher, error) {
+	return remotes.PusherFunc(func(_ context.Context, pushDesc ocispec.Descriptor) (content.Writer, error) {
+		p.pushes = append(p.pushes, memPush{desc: pushDesc, root: root})
+		if p.existing[desc.Digest] {
+			return nil, fmt.Errorf("%s: %w", desc.Digest, errdefs.ErrAlreadyExists)
+		}
+		return &memWriter{pusher: p}, nil
+	}), nil
+}
+
+// memWriter writes a blob to a memPusher
+type memWriter struct {
+	bytes.Buffer
+	pusher *memPusher
+}
+
+func (w *memWriter) Close() error { return nil }
+
+func (w *memWriter) Digest() digest.Digest { return digest.FromBytes(w.Bytes()) }
+
+func (w *memWriter) Commit(_ context.Context, size int64, expected digest.Digest, _ ...content.Opt) error {
+	if int64(w.Len()) != size || w.Digest() != expected {
+		return fmt.Errorf("unexpected content for %s", expected)
+	}
+	w.pusher.blobs[expected] = append([]byte{}, w.Bytes()...)
+	return nil
+}
+
+func (w *memWriter) Status() (content.Status, error) { return content.Status{}, nil }
+
+func (w *memWriter) Truncate(int64) error { return nil }
+
+// newTestManifest adds a manifest with a config, a layer and a foreign layer to a fetcher
+func newTestManifest(t *testing.T, f memFetcher) (manifest, config, layer, foreign ocispec.Descriptor) {
+	t.Helper()
+	config = f.add(WASMMetadataMediaType, []byte(`{"name": "myext"}`))
+	layer = f.add(WASMLayerMediaType, []byte("some wasm"))
+	// foreign layers are not in the registry
+	foreign = ocispec.Descriptor{MediaType: foreignLayerMediaType, Digest: digest.FromString("foreign"), Size: 7}
+
+	data, err := json.Marshal(ocispec.Manifest{
+		MediaType: ocispec.MediaTypeImageManifest,
+		Config:    config,
+		Layers:    []ocispec.Descriptor{layer, foreign},
+	})
+	require.NoError(t, err)
+	manifest = f.add(ocispec.MediaTypeImageManifest, data)
+	return manifest, config, layer, foreign
+}
+
+func TestManifestChildren(t *testing.T) {
+	f := memFetcher{}
+	manifest, config, layer, _ := newTestManifest(t, f)
+
+	children, err := manifestChildren(manifest.MediaType, f[manifest.Digest])
+	require.NoError(t, err)
+	// foreign layers are skipped
+	assert.Equal(t, []ocispec.Descriptor{config, layer}, children)
+
+	index, err := json.Marshal(ocispec.Index{Manifests: []ocispec.Descriptor{manifest}})
+	require.NoError(t, err)
+	children, err = manifestChildren(ocispec.MediaTypeImageIndex, index)
+	require.NoError(t, err)
+	assert.Equal(t, []ocispec.Descriptor{manifest}, children)
+
+	_, err = manifestChildren(ocispec.MediaTypeImageManifest, []byte("not json"))
+	assert.Error(t, err)
+}
+
+func TestCopierCopy(t *testing.T) {
+	f := memFetcher{}
+	manifest, config, layer, _ := newTestManifest(t, f)
+	indexData, err := json.Marshal(ocispec.Index{MediaType: ocispec.MediaTypeImageIndex, Manifests: []ocispec.Descriptor{manifest}})
+	require.NoError(t, err)
+	index := f.add(ocispec.MediaTypeImageIndex, indexData)
+	mount := map[string]string{"containerd.io/distribution.source.myregistry.com": "myrepo"}
+
+	for name, tCase := range map[string]struct {
+		root           ocispec.Descriptor
+		mountSources   map[string]string
+		existing       []digest.Digest
+		expectedPushes []digest.Digest
+		expectedBlobs  []digest.Digest
+	}{
+		"manifest": {
+			root:           manifest,
+			expectedPushes: []digest.Digest{config.Digest, layer.Digest, manifest.Digest},
+			expectedBlobs:  []digest.Digest{config.Digest, layer.Digest, manifest.Digest},
+		},
+		"index": {
+			root:           index,
+			expectedPushes: []digest.Digest{config.Digest, layer.Digest, manifest.Digest, index.Digest},
+			expectedBlobs:  []digest.Digest{config.Digest, layer.Digest, manifest.Digest, index.Digest},
+		},
+		"mounted blobs": {
+			root:           manifest,
+			mountSources:   mount,
+			expectedPushes: []digest.Digest{config.Digest, layer.Digest, manifest.Digest},
+			expectedBlobs:  []digest.Digest{config.Digest, layer.Digest, manifest.Digest},
+		},
+		"existing blobs": {
+			root:           manifest,
+			existing:       []digest.Digest{config.Digest, layer.Digest},
+			expectedPushes: []digest.Digest{config.Digest, layer.Digest, manifest.Digest},
+			expectedBlobs:  []digest.Digest{manifest.Digest},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			p := newMemPusher()
+			for _, d := range tCase.existing {
+				p.existing[d] = true
+			}
+			cp := &copier{fetcher: f, pusher: p.pusher, mountSources: tCase.mountSources}
+			require.NoError(t, cp.copy(context.Background(), tCase.root, true))
+
+			// children are pushed before the manifests referencing them
+			var pushed []digest.Digest
+			for i, push := range p.pushes {
+				pushed = append(pushed, push.desc.Digest)
+				assert.Equal(t, i == len(p.pushes)-1, push.root, "root of %s", push.desc.Digest)
+
+				// the mount annotations are only for blobs
+				if tCase.mountSources != nil && !isManifest(push.desc.MediaType) {
+					assert.Equal(t, tCase.mountSources, push.desc.Annotations)
+				} else {
+					assert.Empty(t, push.desc.Annotations)
+				}
+			}
+			assert.Equal(t, tCase.expectedPushes, pushed)
+
+			assert.Len(t, p.blobs, len(tCase.expectedBlobs))
+			for _, d := range tCase.expectedBlobs {
+				assert.Equal(t, f[d], p.blobs[d], "content of %s", d)
+			}
+		})
+	}
+}
+
+func TestCopierCopyMissingBlob(t *testing.T) {
+	f := memFetcher{}
+	manifest, _, layer, _ := newTestManifest(t, f)
+	delete(f, layer.Digest)
+
+	p := newMemPusher()
+	cp := &copier{fetcher: f, pusher: p.pusher}
+	err := cp.copy(context.Background(), manifest, true)
+	assert.ErrorIs(t, err, errdefs.ErrNotFound)
+	// the manifest is not pushed without all its children
+	assert.NotContains(t, p.blobs, manifest.Digest)
+}
+
+func TestMountSources(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		src, dst registry.Reference
+		expected map[string]string
+	}{
+		"same registry": {
+			src:      registry.Reference{Registry: "myregistry.com", Repository: "staging/myrepo"},
+			dst:      registry.Reference{Registry: "myregistry.com", Repository: "prod/myrepo"},
+			expected: map[string]string{"containerd.io/distribution.source.myregistry.com": "staging/myrepo"},
+		},
+		"same registry with a port": {
+			src:      registry.Reference{Registry: "localhost:5000", Repository: "staging/myrepo"},
+			dst:      registry.Reference{Registry: "localhost:5000", Repository: "prod/myrepo"},
+			expected: map[string]string{"containerd.io/distribution.source.localhost": "staging/myrepo"},
+		},
+		"different registries": {
+			src: registry.Reference{Registry: "staging.com", Repository: "myrepo"},
+			dst: registry.Reference{Registry: "prod.com", Repository: "myrepo"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, mountSources(tCase.src, tCase.dst))
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"syscall"
+
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+)
+
+// statusCodeRegexp matches the status code in the errors returned by the
+// registry clients that do not provide a typed error.
+var statusCodeRegexp = regexp.MustCompile(`unexpected status code (?:\S+: )?(\d{3})\b`)
+
+// IsUnavailable returns true when an error is caused by the registry being
+// unavailable: a connection error (connection refused or reset, unreachable
+// hosts, DNS errors when dialing...), a timeout, or a 5xx or 429 response.
+//
+// Other errors, like TLS certificate errors, authentication errors or invalid
+// references, are not considered, as they will not be fixed by waiting.
+func IsUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	if code, ok := StatusCode(err); ok {
+		return code >= http.StatusInternalServerError || code == http.StatusTooManyRequests
+	}
+
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var invalidCertErr x509.CertificateInvalidError
+	var hostnameErr x509.HostnameError
+	var recordHeaderErr tls.RecordHeaderError
+	switch {
+	case errors.As(err, &certErr), errors.As(err, &unknownAuthorityErr), errors.As(err, &invalidCertErr),
+		errors.As(err, &hostnameErr), errors.As(err, &recordHeaderErr):
+		return false
+	}
+
+	var netErr net.Error
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return true
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	case errors.As(err, &opErr) && opErr.Op == "dial", errors.As(err, &dnsErr):
+		return true
+	case errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET),
+		errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return true
+	}
+
+	return false
+}
+
+// StatusCode returns the HTTP status code returned by the registry that
+// caused an error, if any.
+func StatusCode(err error) (int, bool) {
+	var unexpected remoteerrors.ErrUnexpectedStatus
+	if errors.As(err, &unexpected) {
+		return unexpected.StatusCode, true
+	}
+
+	if m := statusCodeRegexp.FindStringSubmatch(err.Error()); m != nil {
+		if code, err := strconv.Atoi(m[1]); err == nil {
+			return code, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,48 @@
+package registry
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+
+	remoteerrors "github.com/containerd/containerd/remotes/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsUnavailable(t *testing.T) {
+	urlErr := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://myregistry.com/v2/", Err: err}
+	}
+
+	for name, tCase := range map[string]struct {
+		err      error
+		expected bool
+	}{
+		"nil":                 {err: nil, expected: false},
+		"connection refused":  {err: urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}), expected: true},
+		"connection reset":    {err: urlErr(&net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}), expected: true},
+		"dial error":          {err: urlErr(&net.OpError{Op: "dial", Net: "tcp", Err: errors.New("no route")}), expected: true},
+		"DNS error":           {err: urlErr(&net.DNSError{Err: "no such host", Name: "myregistry.com"}), expected: true},
+		"timeout":             {err: urlErr(os.ErrDeadlineExceeded), expected: true},
+		"context deadline":    {err: fmt.Errorf("when pulling: %w", context.DeadlineExceeded), expected: true},
+		"5xx status":          {err: remoteerrors.ErrUnexpectedStatus{StatusCode: 503}, expected: true},
+		"429 status":          {err: errors.New("GET https://myregistry.com/v2/: unexpected status code 429: too many requests"), expected: true},
+		"401 status":          {err: errors.New("GET https://myregistry.com/v2/: unexpected status code 401: Unauthorized"), expected: false},
+		"404 status":          {err: remoteerrors.ErrUnexpectedStatus{StatusCode: 404}, expected: false},
+		"unknown authority":   {err: urlErr(x509.UnknownAuthorityError{}), expected: false},
+		"invalid certificate": {err: urlErr(x509.CertificateInvalidError{Reason: x509.Expired}), expected: false},
+		"wrong hostname":      {err: urlErr(x509.HostnameError{Host: "myregistry.com", Certificate: &x509.Certificate{}}), expected: false},
+		"invalid URL":         {err: urlErr(errors.New("unsupported protocol scheme")), expected: false},
+		"other error":         {err: errors.New("invalid reference"), expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, IsUnavailable(tCase.err))
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package registry
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"oras.land/oras-go/pkg/content"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
+)
+
+// OCILayoutScheme is the URL scheme for OCI image layout directories,
+// like "oci-layout:///path/to/dir:1.0.0"
+const OCILayoutScheme = "oci-layout"
+
+// IsOCILayout determines whether or not a URL is to be treated as an OCI image layout directory
+func IsOCILayout(url string) bool {
+	return strings.HasPrefix(url, fmt.Sprintf("%s://", OCILayoutScheme))
+}
+
+// ParseLayoutReference splits a reference like "oci-layout:///path/to/dir:1.0.0"
+// in the directory and the tag (that can be empty).
+func ParseLayoutReference(ref string) (string, string, error) {
+	if !IsOCILayout(ref) {
+		return "", "", fmt.Errorf("invalid OCI layout reference: %s", ref)
+	}
+
+	dir := strings.TrimPrefix(ref, fmt.Sprintf("%s://", OCILayoutScheme))
+	tag := ""
+	if idx := strings.LastIndexByte(dir, ':'); idx > strings.LastIndexByte(dir, '/') {
+		dir, tag = dir[:idx], dir[idx+1:]
+	}
+	if dir == "" {
+		return "", "", fmt.Errorf("no directory in OCI layout reference: %s", ref)
+	}
+
+	return filepath.Clean(dir), tag, nil
+}
+
+// RefTag returns the tag in an OCI (or OCI layout) reference, or an empty string if there is no tag.
+func RefTag(ref string) (string, error) {
+	if IsOCILayout(ref) {
+		_, tag, err := ParseLayoutReference(ref)
+		return tag, err
+	}
+
+	parsedReference, err := parseReference(strings.TrimPrefix(ref, fmt.Sprintf("%s://", OCIScheme)))
+	if err != nil {
+		return "", err
+	}
+	return parsedReference.Reference, nil
+}
+
+// LayoutTags provides a sorted list all semver compliant tags in an OCI layout directory
+func LayoutTags(dir string) ([]string, error) {
+	store, err := openLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var tags []string
+	for name := range store.ListReferences() {
+		tags = append(tags, name)
+	}
+	return sortVersionTags(tags), nil
+}
+
+// PullLayout obtains a WASM extension from an OCI layout directory
+func PullLayout(dir, tag string, options ...PullOption) (*PullResult, error) {
+	operation := &pullOperation{}
+	for _, option := range options {
+		option(operation)
+	}
+
+	store, err := openLayout(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := pull(context.Background(), withProgress(store, operation.progress), tag)
+	if err != nil {
+		return nil, fmt.Errorf("when reading %s from %s: %w", tag, dir, err)
+	}
+	result.Ref = fmt.Sprintf("%s://%s:%s", OCILayoutScheme, dir, tag)
+
+	return result, nil
+}
+
+// PushLayout stores a WASM extension in an OCI layout directory, creating it when it does not exist
+func PushLayout(data []byte, meta common.Metadata, dir, tag string, options ...PushOption) (*PushResult, error) {
+	operation := &pushOperation{}
+	for _, option := range options {
+		option(operation)
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	store, err := content.NewOCI(dir)
+	if err != nil {
+		return nil, fmt.Errorf("when opening OCI layout %s: %w", dir, err)
+	}
+
+	result, err := push(context.Background(), data, meta, store, tag, operation)
+	if err != nil {
+		return nil, fmt.Errorf("when writing %s to %s: %w", tag, dir, err)
+	}
+
+	// the store leaves an (empty) directory for staging uploads
+	_ = os.Remove(filepath.Join(dir, "ingest"))
+	result.Ref = fmt.Sprintf("%s://%s:%s", OCILayoutScheme, dir, tag)
+
+	return result, nil
+}
+
+// openLayout opens an existing OCI layout directory
+func openLayout(dir string) (*content.OCI, error) {
+	if _, err := os.Stat(filepath.Join(dir, "oci-layout")); err != nil {
+		return nil, fmt.Errorf("%s is not an OCI layout directory: %w", dir, err)
+	}
+
+	store, err := content.NewOCI(dir)
+	if err != nil {
+		return nil, fmt.Errorf("when opening OCI layout %s: %w", dir, err)
+	}
+	return store, nil
+}
@@ -0,0 +1,95 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/common"
+)
+
+func TestParseLayoutReference(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		ref         string
+		expectedDir string
+		expectedTag string
+		expectedMsg string
+	}{
+		"absolute with a tag":          {ref: "oci-layout:///tmp/layout:1.0.0", expectedDir: "/tmp/layout", expectedTag: "1.0.0"},
+		"absolute without a tag":       {ref: "oci-layout:///tmp/layout", expectedDir: "/tmp/layout"},
+		"relative with a tag":          {ref: "oci-layout://layout:1.0.0", expectedDir: "layout", expectedTag: "1.0.0"},
+		"relative in a directory":      {ref: "oci-layout://./build/layout:latest", expectedDir: filepath.Join("build", "layout"), expectedTag: "latest"},
+		"relative without a tag":       {ref: "oci-layout://../layout", expectedDir: filepath.Join("..", "layout")},
+		"colon in a directory":         {ref: "oci-layout:///tmp/a:b/layout", expectedDir: filepath.Clean("/tmp/a:b/layout")},
+		"colon in a directory and tag": {ref: "oci-layout:///tmp/a:b/layout:1.0.0", expectedDir: filepath.Clean("/tmp/a:b/layout"), expectedTag: "1.0.0"},
+		"trailing slash":               {ref: "oci-layout:///tmp/layout/", expectedDir: filepath.Clean("/tmp/layout")},
+		"empty tag":                    {ref: "oci-layout:///tmp/layout:", expectedDir: "/tmp/layout"},
+		"no directory":                 {ref: "oci-layout://", expectedMsg: "no directory in OCI layout reference"},
+		"only a tag":                   {ref: "oci-layout://:1.0.0", expectedMsg: "no directory in OCI layout reference"},
+		"not a layout":                 {ref: "oci://myregistry.com/myrepo:1.0.0", expectedMsg: "invalid OCI layout reference"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir, tag, err := ParseLayoutReference(tCase.ref)
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expectedDir, dir)
+			assert.Equal(t, tCase.expectedTag, tag)
+		})
+	}
+}
+
+func TestLayoutRoundTrip(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "layout")
+	meta := common.Metadata{APIVersion: "v1", Name: "myext", Version: "1.0.0"}
+
+	for _, tCase := range []struct {
+		tag  string
+		data []byte
+	}{
+		{tag: "1.0.0", data: []byte("wasm 1.0.0")},
+		{tag: "1.1.0", data: []byte("wasm 1.1.0")},
+		// tags that are not versions are not listed
+		{tag: "latest", data: []byte("wasm 1.1.0")},
+	} {
+		res, err := PushLayout(tCase.data, meta, dir, tCase.tag)
+		require.NoError(t, err)
+		assert.Equal(t, "oci-layout://"+dir+":"+tCase.tag, res.Ref)
+	}
+
+	// the staging directory of the store is removed
+	assert.NoDirExists(t, filepath.Join(dir, "ingest"))
+
+	tags, err := LayoutTags(dir)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1.1.0", "1.0.0"}, tags)
+
+	pulled, err := PullLayout(dir, "1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wasm 1.0.0"), pulled.WASMExt.Data)
+	assert.Equal(t, "myext", pulled.WASMExt.Meta.Name)
+	assert.Equal(t, "oci-layout://"+dir+":1.0.0", pulled.Ref)
+
+	latest, err := PullLayout(dir, "latest")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("wasm 1.1.0"), latest.WASMExt.Data)
+
+	_, err = PullLayout(dir, "2.0.0")
+	assert.ErrorContains(t, err, "when reading 2.0.0 from")
+}
+
+func TestLayoutNotALayout(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "file"), nil, 0o644))
+
+	_, err := LayoutTags(dir)
+	assert.ErrorContains(t, err, "is not an OCI layout directory")
+
+	_, err = PullLayout(filepath.Join(dir, "missing"), "1.0.0")
+	assert.ErrorContains(t, err, "is not an OCI layout directory")
+}
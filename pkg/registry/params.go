@@ -6,6 +6,8 @@ type RegistryParams struct {
 	CAFile    string
 	Insecure  bool
 	PlainHTTP bool
+	// MaxAttempts is the maximum number of attempts for a request to the registry (0 uses the default).
+	MaxAttempts int
 }
 
 // PushOpt is a type of function that sets options for a push action.
@@ -54,3 +56,10 @@ func WithPlainHTTP(plainHTTP bool) RegistryParamsOpt {
 		p.PlainHTTP = plainHTTP
 	}
 }
+
+// WithMaxAttempts sets the maximum number of attempts for a request to the registry.
+func WithMaxAttempts(attempts int) RegistryParamsOpt {
+	return func(p *RegistryParams) {
+		p.MaxAttempts = attempts
+	}
+}
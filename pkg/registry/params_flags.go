@@ -8,4 +8,5 @@ func AddRegistryParamsFlags(f *pflag.FlagSet, r *RegistryParams) {
 	f.StringVar(&r.CAFile, "ca-file", "", "verify certificates of HTTPS-enabled servers using this CA bundle")
 	f.BoolVar(&r.Insecure, "insecure-skip-tls-verify", false, "skip tls certificate checks for the chart upload")
 	f.BoolVar(&r.PlainHTTP, "plain-http", false, "use insecure HTTP connections for the chart upload")
+	f.IntVar(&r.MaxAttempts, "max-attempts", DefaultMaxAttempts, "maximum number of attempts for a request to the registry (1 disables retries)")
 }
@@ -0,0 +1,69 @@
+package registry
+
+import (
+	"context"
+	"io"
+
+	"github.com/containerd/containerd/remotes"
+	ocispec "github.com/opencontainers/image-spec/specs-go/v1"
+	"oras.land/oras-go/pkg/target"
+)
+
+// ProgressFunc is called while downloading a WASM extension, with the bytes
+// downloaded so far and the total size (or -1 when unknown)
+type ProgressFunc func(done, total int64)
+
+// PullOptProgress returns a function that sets a callback for the progress of the WASM layer on pull
+func PullOptProgress(progress ProgressFunc) PullOption {
+	return func(operation *pullOperation) {
+		operation.progress = progress
+	}
+}
+
+// progressTarget is a target that reports the progress of the WASM layers fetched from it
+type progressTarget struct {
+	target.Target
+	progress ProgressFunc
+}
+
+func (t *progressTarget) Fetcher(ctx context.Context, ref string) (remotes.Fetcher, error) {
+	fetcher, err := t.Target.Fetcher(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	return remotes.FetcherFunc(func(ctx context.Context, desc ocispec.Descriptor) (io.ReadCloser, error) {
+		rc, err := fetcher.Fetch(ctx, desc)
+		if err != nil || desc.MediaType != WASMLayerMediaType {
+			return rc, err
+		}
+		t.progress(0, desc.Size)
+		return &ProgressReader{ReadCloser: rc, Total: desc.Size, Progress: t.progress}, nil
+	}), nil
+}
+
+// withProgress wraps a target for reporting the progress of the WASM layers (if there is a callback)
+func withProgress(from target.Target, progress ProgressFunc) target.Target {
+	if progress == nil {
+		return from
+	}
+	return &progressTarget{Target: from, progress: progress}
+}
+
+// ProgressReader is a reader that reports the bytes read
+type ProgressReader struct {
+	io.ReadCloser
+	Total    int64
+	Progress ProgressFunc
+
+	done int64
+}
+
+func (r *ProgressReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.done += int64(n)
+		r.Progress(r.done, r.Total)
+	}
+	return n, err
+}
@@ -0,0 +1,187 @@
+package registry
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the default maximum number of attempts for a request to a registry.
+	DefaultMaxAttempts = 4
+
+	// DefaultMinBackoff is the default backoff after the first failed attempt.
+	DefaultMinBackoff = 250 * time.Millisecond
+
+	// DefaultMaxBackoff is the default maximum backoff between attempts.
+	DefaultMaxBackoff = 10 * time.Second
+
+	// DefaultMaxRetryAfter is the maximum time we wait when a registry returns a Retry-After header.
+	DefaultMaxRetryAfter = 1 * time.Minute
+)
+
+// RetryTransport is a http.RoundTripper that retries requests to registries
+// on transport errors and on responses like 429 (Too Many Requests) or 503
+// (Service Unavailable), with a jittered exponential backoff.
+//
+// Requests that are not idempotent (like the POST, PATCH and PUT requests used
+// for uploading blobs) are only retried when they have not reached the registry
+// or were explicitly rejected with a 429, and requests with a body are only
+// retried when the body can be obtained again. Every attempt uses a copy of
+// the original request, which is never modified.
+type RetryTransport struct {
+	// Base is the transport used for performing the requests.
+	Base http.RoundTripper
+	// MaxAttempts is the maximum number of attempts for a request (1 disables retries).
+	MaxAttempts int
+	// MinBackoff is the backoff after the first failed attempt.
+	MinBackoff time.Duration
+	// MaxBackoff is the maximum backoff between attempts.
+	MaxBackoff time.Duration
+	// MaxRetryAfter is the maximum time honored in a Retry-After header.
+	MaxRetryAfter time.Duration
+}
+
+// NewRetryTransport wraps a transport with retries. A zero maxAttempts uses the default.
+func NewRetryTransport(base http.RoundTripper, maxAttempts int) *RetryTransport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	return &RetryTransport{
+		Base:          base,
+		MaxAttempts:   maxAttempts,
+		MinBackoff:    DefaultMinBackoff,
+		MaxBackoff:    DefaultMaxBackoff,
+		MaxRetryAfter: DefaultMaxRetryAfter,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 {
+			attemptReq = req.Clone(req.Context())
+			if req.Body != nil && req.Body != http.NoBody {
+				body, err := req.GetBody()
+				if err != nil {
+					return nil, err
+				}
+				attemptReq.Body = body
+			}
+		}
+
+		resp, err := t.Base.RoundTrip(attemptReq)
+
+		if attempt >= t.MaxAttempts || !t.shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := t.backoff(attempt)
+		if resp != nil {
+			if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+				wait = min(max(wait, retryAfter), t.MaxRetryAfter)
+			}
+			// drain the body so the connection can be reused
+			_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// shouldRetry returns true if a request can be retried after the given result.
+func (t *RetryTransport) shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+
+	// we cannot send the body again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+
+	idempotent := isIdempotent(req.Method)
+
+	if err != nil {
+		if idempotent {
+			return true
+		}
+		// non-idempotent requests can only be retried when they did not reach the registry
+		return isConnectError(err)
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusRequestTimeout, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent
+	}
+
+	return false
+}
+
+// backoff returns the (jittered) time to wait after a failed attempt.
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	d := t.MinBackoff << (attempt - 1)
+	if d <= 0 || d > t.MaxBackoff {
+		d = t.MaxBackoff
+	}
+	// "equal jitter": wait between d/2 and d
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// isIdempotent returns true for the HTTP methods that can be safely repeated.
+// PUT is not included: the PUT that completes a blob upload consumes the upload
+// session, so it cannot be repeated once it has reached the registry.
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// isConnectError returns true when an error happened before sending the request.
+func isConnectError(err error) bool {
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+// parseRetryAfter parses a Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if d := time.Until(date); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
@@ -0,0 +1,240 @@
+package registry
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// result is the result of an attempt: a response with the status code, or an error.
+type result struct {
+	status int
+	err    error
+}
+
+var (
+	errDial  = &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	errReset = &net.OpError{Op: "read", Net: "tcp", Err: os.NewSyscallError("read", syscall.ECONNRESET)}
+)
+
+func TestRetryTransport(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		method           string
+		results          []result
+		expectedAttempts int
+		expectedStatus   int
+	}{
+		"success":                  {method: http.MethodGet, results: []result{{status: 200}}, expectedAttempts: 1, expectedStatus: 200},
+		"GET after 503":            {method: http.MethodGet, results: []result{{status: 503}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"GET after 429":            {method: http.MethodGet, results: []result{{status: 429}, {status: 429}, {status: 200}}, expectedAttempts: 3, expectedStatus: 200},
+		"GET after reset":          {method: http.MethodGet, results: []result{{err: errReset}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"GET max attempts":         {method: http.MethodGet, results: []result{{status: 500}, {status: 500}, {status: 500}, {status: 500}}, expectedAttempts: 3, expectedStatus: 500},
+		"GET not found":            {method: http.MethodGet, results: []result{{status: 404}}, expectedAttempts: 1, expectedStatus: 404},
+		"GET unauthorized":         {method: http.MethodGet, results: []result{{status: 401}}, expectedAttempts: 1, expectedStatus: 401},
+		"HEAD after dial error":    {method: http.MethodHead, results: []result{{err: errDial}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"POST after dial error":    {method: http.MethodPost, results: []result{{err: errDial}, {status: 202}}, expectedAttempts: 2, expectedStatus: 202},
+		"POST after reset":         {method: http.MethodPost, results: []result{{err: errReset}}, expectedAttempts: 1},
+		"POST after 503":           {method: http.MethodPost, results: []result{{status: 503}}, expectedAttempts: 1, expectedStatus: 503},
+		"POST after 429":           {method: http.MethodPost, results: []result{{status: 429}, {status: 202}}, expectedAttempts: 2, expectedStatus: 202},
+		"PATCH after reset":        {method: http.MethodPatch, results: []result{{err: errReset}}, expectedAttempts: 1},
+		"PUT after dial error":     {method: http.MethodPut, results: []result{{err: errDial}, {status: 201}}, expectedAttempts: 2, expectedStatus: 201},
+		"PUT after reset":          {method: http.MethodPut, results: []result{{err: errReset}}, expectedAttempts: 1},
+		"PUT after 500":            {method: http.MethodPut, results: []result{{status: 500}}, expectedAttempts: 1, expectedStatus: 500},
+		"PUT after 429":            {method: http.MethodPut, results: []result{{status: 429}, {status: 201}}, expectedAttempts: 2, expectedStatus: 201},
+		"DELETE after 502":         {method: http.MethodDelete, results: []result{{status: 502}, {status: 202}}, expectedAttempts: 2, expectedStatus: 202},
+		"GET with DNS error":       {method: http.MethodGet, results: []result{{err: &net.DNSError{Err: "no such host"}}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"POST with DNS error":      {method: http.MethodPost, results: []result{{err: &net.DNSError{Err: "no such host"}}, {status: 202}}, expectedAttempts: 2, expectedStatus: 202},
+		"GET with 408":             {method: http.MethodGet, results: []result{{status: 408}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"GET with unexpected 501":  {method: http.MethodGet, results: []result{{status: 501}}, expectedAttempts: 1, expectedStatus: 501},
+		"GET with unexpected 3xx":  {method: http.MethodGet, results: []result{{status: 307}}, expectedAttempts: 1, expectedStatus: 307},
+		"POST with other error":    {method: http.MethodPost, results: []result{{err: errors.New("boom")}}, expectedAttempts: 1},
+		"GET with other error":     {method: http.MethodGet, results: []result{{err: errors.New("boom")}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"OPTIONS after 504":        {method: http.MethodOptions, results: []result{{status: 504}, {status: 200}}, expectedAttempts: 2, expectedStatus: 200},
+		"GET after several errors": {method: http.MethodGet, results: []result{{err: errReset}, {status: 503}, {status: 200}}, expectedAttempts: 3, expectedStatus: 200},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			transport := NewRetryTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				r := tCase.results[attempts]
+				attempts++
+				if r.err != nil {
+					return nil, r.err
+				}
+				return &http.Response{StatusCode: r.status, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+			}), 3)
+			transport.MinBackoff = time.Millisecond
+			transport.MaxBackoff = time.Millisecond
+
+			req, err := http.NewRequest(tCase.method, "https://myregistry.com/v2/", nil)
+			require.NoError(t, err)
+			resp, err := transport.RoundTrip(req)
+
+			assert.Equal(t, tCase.expectedAttempts, attempts)
+			if tCase.expectedStatus == 0 {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expectedStatus, resp.StatusCode)
+		})
+	}
+}
+
+func TestRetryTransportBody(t *testing.T) {
+	var bodies []string
+	var requests []*http.Request
+	transport := NewRetryTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		requests = append(requests, req)
+		data, err := io.ReadAll(req.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(data))
+		if len(bodies) == 1 {
+			return nil, errDial
+		}
+		return &http.Response{StatusCode: 201, Header: http.Header{}, Body: http.NoBody}, nil
+	}), 3)
+	transport.MinBackoff = time.Millisecond
+
+	// the body is sent again in every attempt
+	req, err := http.NewRequest(http.MethodPut, "https://myregistry.com/v2/myrepo/manifests/1.0.0", strings.NewReader("manifest"))
+	require.NoError(t, err)
+	body := req.Body
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, []string{"manifest", "manifest"}, bodies)
+
+	// without modifying the request of the caller
+	require.Len(t, requests, 2)
+	assert.NotSame(t, req, requests[1])
+	assert.Equal(t, body, req.Body)
+
+	// bodies that cannot be obtained again are not retried
+	bodies = nil
+	req, err = http.NewRequest(http.MethodPut, "https://myregistry.com/v2/myrepo/manifests/1.0.0", strings.NewReader("manifest"))
+	require.NoError(t, err)
+	req.GetBody = nil
+	_, err = transport.RoundTrip(req)
+	assert.Error(t, err)
+	assert.Len(t, bodies, 1)
+}
+
+func TestRetryTransportRetryAfter(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		retryAfter    string
+		maxRetryAfter time.Duration
+		expectedMin   time.Duration
+		expectedMax   time.Duration
+	}{
+		"seconds":          {retryAfter: "1", maxRetryAfter: time.Minute, expectedMin: time.Second, expectedMax: 2 * time.Second},
+		"capped":           {retryAfter: "3600", maxRetryAfter: 100 * time.Millisecond, expectedMin: 100 * time.Millisecond, expectedMax: time.Second},
+		"date in the past": {retryAfter: "Mon, 02 Jan 2006 15:04:05 GMT", maxRetryAfter: time.Minute, expectedMax: 500 * time.Millisecond},
+		"invalid":          {retryAfter: "soon", maxRetryAfter: time.Minute, expectedMax: 500 * time.Millisecond},
+	} {
+		t.Run(name, func(t *testing.T) {
+			attempts := 0
+			transport := NewRetryTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				attempts++
+				if attempts == 1 {
+					return &http.Response{StatusCode: 429, Header: http.Header{"Retry-After": {tCase.retryAfter}}, Body: http.NoBody}, nil
+				}
+				return &http.Response{StatusCode: 200, Header: http.Header{}, Body: http.NoBody}, nil
+			}), 2)
+			transport.MinBackoff = time.Millisecond
+			transport.MaxBackoff = time.Millisecond
+			transport.MaxRetryAfter = tCase.maxRetryAfter
+
+			req, err := http.NewRequest(http.MethodGet, "https://myregistry.com/v2/", nil)
+			require.NoError(t, err)
+			start := time.Now()
+			resp, err := transport.RoundTrip(req)
+			elapsed := time.Since(start)
+
+			require.NoError(t, err)
+			assert.Equal(t, 200, resp.StatusCode)
+			assert.GreaterOrEqual(t, elapsed, tCase.expectedMin)
+			assert.Less(t, elapsed, tCase.expectedMax)
+		})
+	}
+}
+
+func TestRetryTransportCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	transport := NewRetryTransport(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		cancel()
+		return &http.Response{StatusCode: 503, Header: http.Header{}, Body: http.NoBody}, nil
+	}), 3)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://myregistry.com/v2/", nil)
+	require.NoError(t, err)
+	resp, err := transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Equal(t, 503, resp.StatusCode)
+}
+
+func TestBackoff(t *testing.T) {
+	transport := NewRetryTransport(nil, 0)
+
+	for name, tCase := range map[string]struct {
+		attempt     int
+		expectedMax time.Duration
+	}{
+		"first attempt":  {attempt: 1, expectedMax: DefaultMinBackoff},
+		"second attempt": {attempt: 2, expectedMax: 2 * DefaultMinBackoff},
+		"third attempt":  {attempt: 3, expectedMax: 4 * DefaultMinBackoff},
+		"capped":         {attempt: 10, expectedMax: DefaultMaxBackoff},
+		"overflow":       {attempt: 100, expectedMax: DefaultMaxBackoff},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				d := transport.backoff(tCase.attempt)
+				assert.GreaterOrEqual(t, d, tCase.expectedMax/2)
+				assert.LessOrEqual(t, d, tCase.expectedMax)
+			}
+		})
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		value      string
+		expected   time.Duration
+		expectedOk bool
+	}{
+		"empty":       {value: ""},
+		"seconds":     {value: "120", expected: 2 * time.Minute, expectedOk: true},
+		"zero":        {value: "0", expectedOk: true},
+		"negative":    {value: "-1"},
+		"past date":   {value: "Mon, 02 Jan 2006 15:04:05 GMT", expectedOk: true},
+		"invalid":     {value: "tomorrow"},
+		"decimal":     {value: "1.5"},
+		"with spaces": {value: " 10"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			d, ok := parseRetryAfter(tCase.value)
+			assert.Equal(t, tCase.expectedOk, ok)
+			assert.Equal(t, tCase.expected, d)
+		})
+	}
+
+	// dates in the future
+	d, ok := parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	assert.True(t, ok)
+	assert.InDelta(t, time.Hour, d, float64(2*time.Second))
+}
@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	transportsMu sync.Mutex
+	// transports are shared between clients with the same parameters, so they can reuse connections
+	transports = map[string]*http.Transport{}
+)
+
+// NewTransport returns the HTTP transport used for talking to registries.
+//
+// Proxies are obtained from the environment (HTTP_PROXY, HTTPS_PROXY and NO_PROXY),
+// and the TLS configuration from the registry parameters. The serverName is
+// used for SNI when not empty. Transports are shared by clients with the same settings.
+func NewTransport(p RegistryParams, serverName string) (*http.Transport, error) {
+	key := fmt.Sprintf("%s|%s|%s|%t|%s", p.CertFile, p.KeyFile, p.CAFile, p.Insecure, serverName)
+
+	transportsMu.Lock()
+	defer transportsMu.Unlock()
+
+	if t, ok := transports[key]; ok {
+		return t, nil
+	}
+
+	tlsConf, err := NewClientTLS(p)
+	if err != nil {
+		return nil, fmt.Errorf("can't create TLS config for client: %w", err)
+	}
+	tlsConf.ServerName = serverName
+
+	t := &http.Transport{
+		// From https://github.com/google/go-containerregistry/blob/31786c6cbb82d6ec4fb8eb79cd9387905130534e/pkg/v1/remote/options.go#L87
+		Proxy:              http.ProxyFromEnvironment,
+		DisableCompression: true,
+		DialContext: (&net.Dialer{
+			// We wrap the transport in retries, so reduce the
+			// default dial timeout to 5s to avoid 5x 30s of connection
+			// timeouts when doing the "ping" on certain http registries.
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConf,
+	}
+
+	transports[key] = t
+	return t, nil
+}
+
+// userAgentTransport sets the User-Agent in requests that do not have one.
+type userAgentTransport struct {
+	base      http.RoundTripper
+	userAgent string
+}
+
+func (t *userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("User-Agent") == "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("User-Agent", t.userAgent)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// debugTransport traces all the requests (and their responses) to a writer.
+// Headers are not printed, as they could contain credentials.
+type debugTransport struct {
+	base http.RoundTripper
+	out  io.Writer
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	fmt.Fprintf(t.out, "--> %s %s\n", req.Method, req.URL.Redacted())
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	if err != nil {
+		fmt.Fprintf(t.out, "<-- %s %s: %v (%s)\n", req.Method, req.URL.Redacted(), err, elapsed)
+		return nil, err
+	}
+	fmt.Fprintf(t.out, "<-- %d %s %s (%s)\n", resp.StatusCode, req.Method, req.URL.Redacted(), elapsed)
+	return resp, nil
+}
@@ -6,8 +6,8 @@ import (
 	"crypto/x509"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -20,6 +20,7 @@ import (
 
 	"github.com/inercia/proxy-wasm-oci/pkg/common"
 	"github.com/inercia/proxy-wasm-oci/pkg/utils"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
 )
 
 var immutableOciAnnotations = []string{
@@ -79,6 +80,26 @@ func GetTagMatchingVersionOrConstraint(tags []string, versionString string) (str
 	return "", errors.Errorf("Could not locate a version matching provided version string %s", versionString)
 }
 
+// GetTagsMatchingConstraint returns all the tags that are versions matching a semver constraint.
+func GetTagsMatchingConstraint(tags []string, constraintString string) ([]string, error) {
+	constraint, err := semver.NewConstraint(constraintString)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []string
+	for _, v := range tags {
+		test, err := semver.NewVersion(v)
+		if err != nil {
+			continue
+		}
+		if constraint.Check(test) {
+			res = append(res, v)
+		}
+	}
+	return res, nil
+}
+
 // ctx retrieves a fresh context.
 // disable verbose logging coming from ORAS (unless debug is enabled)
 func ctx(out io.Writer, debug bool) context.Context {
@@ -117,34 +138,20 @@ func parseReference(raw string) (registry.Reference, error) {
 
 // NewRegistryClientWithTLS is a helper function to create a new registry client with TLS enabled.
 func NewRegistryClientWithTLS(out io.Writer, p RegistryParams, registryConfig string, debug bool) (*Client, error) {
-	tlsConf, err := NewClientTLS(p)
-	if err != nil {
-		return nil, fmt.Errorf("can't create TLS config for client: %s", err)
-	}
-	// Create a new registry client
-	registryClient, err := NewClient(
-		ClientOptDebug(debug),
-		ClientOptEnableCache(true),
-		ClientOptWriter(out),
-		ClientOptCredentialsFile(registryConfig),
-		ClientOptHTTPClient(&http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: tlsConf,
-			},
-		}),
-	)
-	if err != nil {
-		return nil, err
-	}
-	return registryClient, nil
+	return NewClientWithParams(p, registryConfig, debug, ClientOptWriter(out))
 }
 
 // generateOCIAnnotations will generate OCI annotations to include within the OCI manifest
-func generateOCIAnnotations(meta *common.Metadata, test bool) map[string]string {
+func generateOCIAnnotations(meta *common.Metadata, data []byte, extra map[string]string, test bool) map[string]string {
 
 	// Get annotations from Chart attributes
 	ociAnnotations := generateChartOCIAnnotations(meta, test)
 
+	// Extra annotations (like the source revision) can be overridden in the metadata
+	for k, v := range extra {
+		ociAnnotations = addToMap(ociAnnotations, k, v)
+	}
+
 	// Copy Chart annotations
 annotations:
 	for chartAnnotationKey, chartAnnotationValue := range meta.Annotations {
@@ -160,9 +167,35 @@ annotations:
 		ociAnnotations[chartAnnotationKey] = chartAnnotationValue
 	}
 
+	// Annotations derived from the binary always describe what is being pushed
+	for k, v := range generateWasmOCIAnnotations(data) {
+		ociAnnotations[k] = v
+	}
+
 	return ociAnnotations
 }
 
+// generateWasmOCIAnnotations generates OCI annotations from the Wasm binary: the
+// Proxy-Wasm ABI version, the WASI imports, the size and the toolchain
+func generateWasmOCIAnnotations(data []byte) map[string]string {
+	annotations := map[string]string{}
+	annotations = addToMap(annotations, AnnotationModuleSize, strconv.Itoa(len(data)))
+
+	m, err := wasm.Parse(data)
+	if err != nil {
+		// binaries can be pushed without validation
+		return annotations
+	}
+
+	if pw, err := m.ProxyWasm(); err == nil {
+		annotations = addToMap(annotations, AnnotationABIVersion, string(pw.ABIVersion))
+		annotations = addToMap(annotations, AnnotationWASIImports, strings.Join(pw.WASIFunctions, ","))
+	}
+	annotations = addToMap(annotations, AnnotationToolchain, m.Toolchain())
+
+	return annotations
+}
+
 // getChartOCIAnnotations will generate OCI annotations from the provided chart
 func generateChartOCIAnnotations(meta *common.Metadata, test bool) map[string]string {
 	chartOCIAnnotations := map[string]string{}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/keyauth"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+)
+
+// PrefetchRequest is the body of a request for prefetching extensions.
+type PrefetchRequest struct {
+	Refs []string `json:"refs"`
+}
+
+// PrefetchResult is the result of prefetching an extension.
+type PrefetchResult struct {
+	Ref   string       `json:"ref"`
+	Entry *cache.Entry `json:"entry,omitempty"`
+	Error string       `json:"error,omitempty"`
+}
+
+// RegisterAdmin registers the administrative endpoints (authenticated with the admin token).
+func RegisterAdmin(a fiber.Router, log *zap.Logger, server *Server) {
+	log = log.Named("admin")
+
+	if server.adminToken == "" {
+		log.Warn("No admin token provided: admin API disabled")
+		return
+	}
+
+	admin := a.Group(PathAdmin, keyauth.New(keyauth.Config{
+		Validator: func(c *fiber.Ctx, key string) (bool, error) {
+			if subtle.ConstantTimeCompare([]byte(key), []byte(server.adminToken)) != 1 {
+				return false, keyauth.ErrMissingOrMalformedAPIKey
+			}
+			return true, nil
+		},
+	}))
+
+	admin.Get(relPath(PathAdminCache), func(c *fiber.Ctx) error {
+		entries, err := server.cache.List()
+		if err != nil {
+			log.Error("could not list cache", zap.Error(err))
+			return fiber.ErrInternalServerError
+		}
+		return c.JSON(entries)
+	})
+
+	admin.Delete(relPath(PathAdminCache), func(c *fiber.Ctx) error {
+		ref := c.Query("ref")
+		digest := c.Query("digest")
+		if ref == "" && digest == "" && !c.QueryBool("all") {
+			log.Error("no 'ref', 'digest' or 'all' found in request")
+			return fiber.ErrBadRequest
+		}
+
+		log.Info("Purging cache", zap.String("ref", ref), zap.String("digest", digest))
+		removed, err := server.cache.Purge(ref, digest)
+		if err != nil {
+			log.Error("could not purge cache", zap.Error(err))
+			return fiber.ErrInternalServerError
+		}
+		return c.JSON(removed)
+	})
+
+	admin.Post(relPath(PathAdminRefresh), func(c *fiber.Ctx) error {
+		ref := c.Query("ref")
+		if ref == "" {
+			log.Error("no 'ref' found in request")
+			return fiber.ErrBadRequest
+		}
+		log := log.With(zap.String("ref", ref))
+
+		entry, err := RefreshWASMExtension(log, server, ref, server.registryParams)
+		if err != nil {
+			log.Error("error refreshing WASM extension", zap.Error(err))
+			return fiber.NewError(fiber.StatusBadGateway, err.Error())
+		}
+		return c.JSON(entry)
+	})
+
+	admin.Get(relPath(PathAdminChannels), func(c *fiber.Ctx) error {
+		return c.JSON(server.channels.List())
+	})
+
+	admin.Get(relPath(PathAdminChannels)+"/:channel", func(c *fiber.Ctx) error {
+		ch, ok := server.channels.Get(c.Params("channel"))
+		if !ok {
+			return fiber.ErrNotFound
+		}
+		return c.JSON(ch)
+	})
+
+	admin.Put(relPath(PathAdminChannels)+"/:channel", func(c *fiber.Ctx) error {
+		ch := &Channel{}
+		if err := c.BodyParser(ch); err != nil {
+			log.Error("could not parse channel", zap.Error(err))
+			return fiber.ErrBadRequest
+		}
+
+		name := c.Params("channel")
+		if err := server.channels.Set(name, ch); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		log.Info("Channel updated", zap.String("channel", name))
+		return c.JSON(ch)
+	})
+
+	admin.Put(relPath(PathAdminChannels)+"/:channel/:name", func(c *fiber.Ctx) error {
+		ext := &ChannelExtension{}
+		if err := c.BodyParser(ext); err != nil {
+			log.Error("could not parse channel extension", zap.Error(err))
+			return fiber.ErrBadRequest
+		}
+
+		name, extName := c.Params("channel"), c.Params("name")
+		if err := server.channels.SetExtension(name, extName, ext); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		log.Info("Channel rollout updated", zap.String("channel", name), zap.String("name", extName))
+		return c.JSON(ext)
+	})
+
+	admin.Delete(relPath(PathAdminChannels)+"/:channel", func(c *fiber.Ctx) error {
+		if !server.channels.Delete(c.Params("channel")) {
+			return fiber.ErrNotFound
+		}
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+
+	admin.Post(relPath(PathAdminPrefetch), func(c *fiber.Ctx) error {
+		req := PrefetchRequest{}
+		if err := c.BodyParser(&req); err != nil {
+			log.Error("could not parse prefetch request", zap.Error(err))
+			return fiber.ErrBadRequest
+		}
+
+		results := make([]PrefetchResult, 0, len(req.Refs))
+		for _, ref := range req.Refs {
+			log := log.With(zap.String("ref", ref))
+
+			res := PrefetchResult{Ref: ref}
+			entry, err := DownloadWASMExtension(log, server, ref, server.registryParams)
+			if err != nil {
+				log.Error("error prefetching WASM extension", zap.Error(err))
+				res.Error = err.Error()
+			}
+			res.Entry = entry
+			results = append(results, res)
+		}
+		return c.JSON(results)
+	})
+}
+
+// relPath returns a path relative to the admin prefix.
+func relPath(p string) string {
+	return p[len(PathAdmin):]
+}
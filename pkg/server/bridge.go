@@ -2,56 +2,118 @@ package server
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/Masterminds/semver/v3"
 	"go.uber.org/zap"
-	reg "oras.land/oras-go/pkg/registry"
 
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
 	"github.com/inercia/proxy-wasm-oci/pkg/downloader"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 )
 
-func DownloadWASMExtension(log *zap.Logger, server *Server, ref string, dir string, r registry.RegistryParams) (string, error) {
-	raw, err, _ := server.downloads.Do(ref, func() (interface{}, error) {
-		log.Info("Creating new registry client")
-		registryClient, err := registry.NewClientWithParams(r, server.settings.RegistryConfigFilename, server.settings.Debug)
+// DownloadWASMExtension obtains a WASM extension from the server cache, downloading
+// it from the registry when it is not there.
+func DownloadWASMExtension(log *zap.Logger, server *Server, ref string, r registry.RegistryParams) (*cache.Entry, error) {
+	return DownloadWASMExtensionVersion(log, server, ref, "", r)
+}
+
+// DownloadWASMExtensionVersion obtains a version of a WASM extension from the server cache,
+// downloading it from the registry when it is not there. The version can be a tag or
+// a semver constraint: when empty, it is obtained from the reference.
+func DownloadWASMExtensionVersion(log *zap.Logger, server *Server, ref, version string, r registry.RegistryParams) (*cache.Entry, error) {
+	raw, err, _ := server.downloads.Do(ref+"@"+version, func() (interface{}, error) {
+		puller, ref, err := newPuller(log, server, ref, version, r)
 		if err != nil {
-			return nil, fmt.Errorf("when creating registry client: %w", err)
+			return nil, err
 		}
 
-		if !registry.IsOCI(ref) {
-			return nil, fmt.Errorf("invalid OCI reference: %s", ref)
-		}
+		log.Sugar().Infof("Fetching %s", ref)
+		return puller.Fetch(ref)
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		version := ">0.0.0-0"
-		refNoScheme := strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme))
-		parsedReference, err := reg.ParseReference(refNoScheme)
+	return raw.(*cache.Entry), nil
+}
+
+// RefreshWASMExtension resolves again a (floating) reference, downloading the
+// WASM extension into the server cache when it is not there.
+func RefreshWASMExtension(log *zap.Logger, server *Server, ref string, r registry.RegistryParams) (*cache.Entry, error) {
+	raw, err, _ := server.downloads.Do("refresh:"+ref, func() (interface{}, error) {
+		puller, ref, err := newPuller(log, server, ref, "", r)
 		if err != nil {
-			return "", err
+			return nil, err
+		}
+
+		log.Sugar().Infof("Refreshing %s", ref)
+		return puller.Refresh(ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return raw.(*cache.Entry), nil
+}
+
+// newPuller creates a puller for a reference, returning the reference it must pull
+// (that can be different to the one provided, like for extensions in a bundle).
+func newPuller(log *zap.Logger, server *Server, ref, version string, r registry.RegistryParams) (*downloader.Pull, string, error) {
+	log.Info("Creating new registry client")
+	registryClient, err := registry.NewClientWithParams(r, server.settings.RegistryConfigFilename, server.settings.Debug)
+	if err != nil {
+		return nil, "", fmt.Errorf("when creating registry client: %w", err)
+	}
+
+	switch {
+	case registry.IsOCI(ref):
+		if layoutRef, ok := server.bundleRef(ref); ok {
+			log.Sugar().Infof("Using %s from bundle", ref)
+			ref = layoutRef
+		}
+	case registry.IsOCILayout(ref):
+		if err := server.checkLayoutRef(ref); err != nil {
+			return nil, "", err
 		}
-		if _, err = semver.NewVersion(parsedReference.Reference); parsedReference.Reference != "" && err == nil {
-			log.Sugar().Infof("Downloading version %s", parsedReference.Reference)
-			version = parsedReference.Reference
+	case downloader.All(server.settings).SupportsRef(ref):
+		// URLs (and the schemes in plugins)
+		if err := server.checkURLRef(ref); err != nil {
+			return nil, "", err
 		}
+	default:
+		return nil, "", fmt.Errorf("invalid OCI reference: %s", ref)
+	}
 
-		puller := downloader.NewPull(server.settings, server.registryConfig,
-			registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
-			registry.WithInsecure(r.Insecure),
-			registry.WithPlainHTTP(r.PlainHTTP),
-			downloader.WithDestDir(dir),
-			downloader.WithVersion(version),
-		)
-		puller.SetRegistryClient(registryClient)
-
-		log.Sugar().Infof("Downloading %s", ref)
-		savedFilename, err := puller.Run(ref)
+	if version == "" && downloader.IsVersionedRef(ref) {
+		version = ">0.0.0-0"
+		tag, err := registry.RefTag(ref)
 		if err != nil {
-			return "", err
+			return nil, "", err
 		}
+		if _, err = semver.NewVersion(tag); tag != "" && err == nil {
+			log.Sugar().Infof("Using version %s", tag)
+			version = tag
+		}
+	}
 
-		return savedFilename, nil
-	})
+	// every puller gets its own copy of the configuration, as they run concurrently
+	// (from the limiter, the admin API and the webhook) and set their own client
+	pullerCfg := *server.registryConfig
+	puller := downloader.NewPull(server.settings, &pullerCfg,
+		registry.WithTLSClientConfig(r.CertFile, r.KeyFile, r.CAFile),
+		registry.WithInsecure(r.Insecure),
+		registry.WithPlainHTTP(r.PlainHTTP),
+		registry.WithMaxAttempts(r.MaxAttempts),
+		downloader.WithVersion(version),
+		downloader.WithCache(server.cache),
+		downloader.WithResolveTTL(server.resolveTTL),
+		downloader.WithStaleIfError(server.staleIfError),
+		downloader.WithLimiter(server.limiter),
+		// the modules from registries or URLs must be Proxy-Wasm extensions
+		// before they are cached or served to any client
+		downloader.WithVerify(true),
+	)
+	puller.SetRegistryClient(registryClient)
 
-	return raw.(string), err
+	return puller, ref, nil
 }
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+)
+
+// Channels keeps the release channels, that can be changed at runtime.
+type Channels struct {
+	mu         sync.RWMutex
+	hashHeader string
+	channels   map[string]*Channel
+}
+
+// NewChannels creates the release channels from the server configuration.
+func NewChannels(cfg *Config) *Channels {
+	res := &Channels{channels: map[string]*Channel{}}
+	if cfg != nil {
+		res.hashHeader = cfg.HashHeader
+		for name, ch := range cfg.Channels {
+			res.channels[name] = ch
+		}
+	}
+	return res
+}
+
+// List returns all the channels.
+func (c *Channels) List() map[string]*Channel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	res := make(map[string]*Channel, len(c.channels))
+	for name, ch := range c.channels {
+		res[name] = ch
+	}
+	return res
+}
+
+// Get returns a channel by name.
+func (c *Channels) Get(name string) (*Channel, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	ch, ok := c.channels[name]
+	return ch, ok
+}
+
+// Set creates or replaces a channel.
+func (c *Channels) Set(name string, ch *Channel) error {
+	if err := ch.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.channels[name] = ch
+	return nil
+}
+
+// SetExtension creates or replaces an extension in a channel,
+// creating the channel when it does not exist.
+func (c *Channels) SetExtension(name, extName string, ext *ChannelExtension) error {
+	if err := ext.Validate(); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.channels[name]
+	if !ok {
+		ch = &Channel{}
+	}
+
+	// channels are replaced (not modified) so readers never see partial updates
+	updated := &Channel{
+		HashHeader: ch.HashHeader,
+		Extensions: map[string]*ChannelExtension{},
+	}
+	for n, e := range ch.Extensions {
+		updated.Extensions[n] = e
+	}
+	updated.Extensions[extName] = ext
+	c.channels[name] = updated
+
+	return nil
+}
+
+// Delete removes a channel, returning true if it existed.
+func (c *Channels) Delete(name string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_, ok := c.channels[name]
+	delete(c.channels, name)
+	return ok
+}
+
+// HashHeader returns the request header used for assigning clients to versions in a channel.
+func (c *Channels) HashHeader(name string) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if ch, ok := c.channels[name]; ok && ch.HashHeader != "" {
+		return ch.HashHeader
+	}
+	return c.hashHeader
+}
+
+// Resolve returns the reference and version of an extension in a channel for a client.
+//
+// The same client (identified by a key like Envoy's node ID or the client IP)
+// always obtains the same version as long as the rollout does not change.
+func (c *Channels) Resolve(name, extName, clientKey string) (string, string, error) {
+	ch, ok := c.Get(name)
+	if !ok {
+		return "", "", fmt.Errorf("unknown channel %q", name)
+	}
+
+	ext, ok := ch.Extensions[extName]
+	if !ok {
+		return "", "", fmt.Errorf("unknown extension %q in channel %q", extName, name)
+	}
+
+	total := 0
+	for _, v := range ext.Versions {
+		total += v.Weight
+	}
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s/%s/%s", name, extName, clientKey)
+	point := int(h.Sum64() % uint64(total))
+
+	for _, v := range ext.Versions {
+		if point < v.Weight {
+			return ext.Ref, v.Version, nil
+		}
+		point -= v.Weight
+	}
+
+	// not reached: the point is always below the total weight
+	last := ext.Versions[len(ext.Versions)-1]
+	return ext.Ref, last.Version, nil
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestChannels(versions ...*WeightedVersion) *Channels {
+	return NewChannels(&Config{
+		Channels: map[string]*Channel{
+			"canary": {
+				Extensions: map[string]*ChannelExtension{
+					"myext": {Ref: "oci://myregistry.com/myrepo/myext", Versions: versions},
+				},
+			},
+		},
+	})
+}
+
+func TestChannelsResolveWeights(t *testing.T) {
+	const clients = 10000
+
+	for name, tCase := range map[string]struct {
+		versions []*WeightedVersion
+		// expected are the expected percentages of clients for every version
+		expected map[string]float64
+	}{
+		"single version": {
+			versions: []*WeightedVersion{{Version: "1.0.0", Weight: 1}},
+			expected: map[string]float64{"1.0.0": 100},
+		},
+		"90/10": {
+			versions: []*WeightedVersion{{Version: "1.0.0", Weight: 90}, {Version: "~1.1", Weight: 10}},
+			expected: map[string]float64{"1.0.0": 90, "~1.1": 10},
+		},
+		"equal weights": {
+			versions: []*WeightedVersion{{Version: "1.0.0", Weight: 1}, {Version: "1.1.0", Weight: 1}, {Version: "1.2.0", Weight: 1}},
+			expected: map[string]float64{"1.0.0": 33.3, "1.1.0": 33.3, "1.2.0": 33.3},
+		},
+		"zero weight": {
+			versions: []*WeightedVersion{{Version: "1.0.0", Weight: 100}, {Version: "1.1.0", Weight: 0}},
+			expected: map[string]float64{"1.0.0": 100},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c := newTestChannels(tCase.versions...)
+
+			counts := map[string]int{}
+			for i := 0; i < clients; i++ {
+				ref, version, err := c.Resolve("canary", "myext", fmt.Sprintf("node-%d", i))
+				require.NoError(t, err)
+				assert.Equal(t, "oci://myregistry.com/myrepo/myext", ref)
+				counts[version]++
+			}
+
+			for version, count := range counts {
+				assert.Contains(t, tCase.expected, version)
+				assert.InDelta(t, tCase.expected[version], 100*float64(count)/clients, 2, "version %s", version)
+			}
+		})
+	}
+}
+
+func TestChannelsResolveStable(t *testing.T) {
+	c := newTestChannels(&WeightedVersion{Version: "1.0.0", Weight: 50}, &WeightedVersion{Version: "1.1.0", Weight: 50})
+
+	// the same client always obtains the same version
+	_, first, err := c.Resolve("canary", "myext", "node-1")
+	require.NoError(t, err)
+	for i := 0; i < 100; i++ {
+		_, version, err := c.Resolve("canary", "myext", "node-1")
+		require.NoError(t, err)
+		assert.Equal(t, first, version)
+	}
+
+	// even after the channel is replaced with the same rollout
+	require.NoError(t, c.SetExtension("canary", "myext", &ChannelExtension{
+		Ref:      "oci://myregistry.com/myrepo/myext",
+		Versions: []*WeightedVersion{{Version: "1.0.0", Weight: 50}, {Version: "1.1.0", Weight: 50}},
+	}))
+	_, version, err := c.Resolve("canary", "myext", "node-1")
+	require.NoError(t, err)
+	assert.Equal(t, first, version)
+
+	// the hash does not depend on the process (it must be the same in all the replicas)
+	assert.Equal(t, "1.0.0", first)
+}
+
+func TestChannelsResolveErrors(t *testing.T) {
+	c := newTestChannels(&WeightedVersion{Version: "1.0.0", Weight: 1})
+
+	_, _, err := c.Resolve("stable", "myext", "node-1")
+	assert.ErrorContains(t, err, `unknown channel "stable"`)
+
+	_, _, err = c.Resolve("canary", "other", "node-1")
+	assert.ErrorContains(t, err, `unknown extension "other"`)
+}
+
+func TestChannelExtensionValidate(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		versions    []*WeightedVersion
+		expectedMsg string
+	}{
+		"valid":           {versions: []*WeightedVersion{{Version: "1.0.0", Weight: 90}, {Version: "~1.1", Weight: 10}}},
+		"maximum weights": {versions: []*WeightedVersion{{Version: "1.0.0", Weight: MaxWeight}, {Version: "1.1.0", Weight: MaxWeight}}},
+		"no versions":     {expectedMsg: "no versions"},
+		"empty version":   {versions: []*WeightedVersion{{Weight: 1}}, expectedMsg: "empty version"},
+		"negative weight": {versions: []*WeightedVersion{{Version: "1.0.0", Weight: -1}}, expectedMsg: `negative weight for version "1.0.0"`},
+		"zero weights":    {versions: []*WeightedVersion{{Version: "1.0.0"}, {Version: "1.1.0"}}, expectedMsg: "must be positive"},
+		"too large weight": {
+			versions:    []*WeightedVersion{{Version: "1.0.0", Weight: MaxWeight + 1}},
+			expectedMsg: `weight for version "1.0.0" is larger than 1000000`,
+		},
+		"overflow": {
+			versions:    []*WeightedVersion{{Version: "1.0.0", Weight: math.MaxInt}, {Version: "1.1.0", Weight: 1}},
+			expectedMsg: `weight for version "1.0.0" is larger than 1000000`,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ext := &ChannelExtension{Ref: "oci://myregistry.com/myrepo/myext", Versions: tCase.versions}
+			err := ext.Validate()
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestChannelsSetExtensionOverflow(t *testing.T) {
+	c := newTestChannels(&WeightedVersion{Version: "1.0.0", Weight: 1})
+
+	// weights set through the admin API are validated too
+	err := c.SetExtension("canary", "myext", &ChannelExtension{
+		Ref:      "oci://myregistry.com/myrepo/myext",
+		Versions: []*WeightedVersion{{Version: "1.0.0", Weight: math.MaxInt}, {Version: "1.1.0", Weight: 1}},
+	})
+	assert.ErrorContains(t, err, "is larger than")
+
+	// and the rollout is not changed
+	_, version, err := c.Resolve("canary", "myext", "node-1")
+	require.NoError(t, err)
+	assert.Equal(t, "1.0.0", version)
+}
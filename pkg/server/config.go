@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+// Config is the configuration of the server, usually loaded from a file.
+type Config struct {
+	// HashHeader is the default request header used for consistently assigning
+	// clients to the versions in a channel (e.g. a header with Envoy's node ID).
+	// The client IP is used when the header is not present in the request.
+	HashHeader string `json:"hashHeader,omitempty"`
+	// Channels are the release channels (e.g. "stable", "canary"), by name.
+	Channels map[string]*Channel `json:"channels,omitempty"`
+	// Watch is a list of references that are prefetched as soon as a registry
+	// notifies a push to their repository.
+	Watch []string `json:"watch,omitempty"`
+}
+
+// Channel is a release channel, where several versions of an extension
+// can be rolled out to different percentages of the clients.
+type Channel struct {
+	// HashHeader overrides the default HashHeader for this channel.
+	HashHeader string `json:"hashHeader,omitempty"`
+	// Extensions are the extensions in the channel, by name.
+	Extensions map[string]*ChannelExtension `json:"extensions"`
+}
+
+// ChannelExtension is an extension in a channel.
+type ChannelExtension struct {
+	// Ref is the OCI reference of the extension, without a tag (e.g. "oci://myregistry.com/myrepo").
+	Ref string `json:"ref"`
+	// Versions are the versions of the extension being rolled out.
+	Versions []*WeightedVersion `json:"versions"`
+}
+
+// MaxWeight is the maximum weight of a version in a rollout, so the sum of
+// the weights of all the versions can never overflow.
+const MaxWeight = 1000000
+
+// WeightedVersion is a version of an extension with its weight in a rollout.
+type WeightedVersion struct {
+	// Version is a tag (e.g. "1.2.0") or a semver constraint (e.g. "~1.2").
+	Version string `json:"version"`
+	// Weight is the relative weight of the version in the rollout (up to MaxWeight).
+	Weight int `json:"weight"`
+}
+
+// LoadConfig loads the server configuration from a YAML (or JSON) file.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", filename, err)
+	}
+
+	return cfg, nil
+}
+
+// Validate checks the configuration for known issues.
+func (cfg *Config) Validate() error {
+	for _, ref := range cfg.Watch {
+		if !registry.IsOCI(ref) {
+			return fmt.Errorf("invalid OCI reference in watch list: %q", ref)
+		}
+	}
+	for name, ch := range cfg.Channels {
+		if err := ch.Validate(); err != nil {
+			return fmt.Errorf("channel %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the channel for known issues.
+func (ch *Channel) Validate() error {
+	if ch == nil {
+		return fmt.Errorf("empty channel")
+	}
+	for name, ext := range ch.Extensions {
+		if err := ext.Validate(); err != nil {
+			return fmt.Errorf("extension %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Validate checks the extension for known issues.
+func (ext *ChannelExtension) Validate() error {
+	if ext == nil {
+		return fmt.Errorf("empty extension")
+	}
+	if !registry.IsOCI(ext.Ref) && !registry.IsOCILayout(ext.Ref) {
+		return fmt.Errorf("invalid OCI reference: %q", ext.Ref)
+	}
+	if len(ext.Versions) == 0 {
+		return fmt.Errorf("no versions")
+	}
+
+	total := 0
+	for _, v := range ext.Versions {
+		if v == nil || v.Version == "" {
+			return fmt.Errorf("empty version")
+		}
+		if v.Weight < 0 {
+			return fmt.Errorf("negative weight for version %q", v.Version)
+		}
+		if v.Weight > MaxWeight {
+			return fmt.Errorf("weight for version %q is larger than %d", v.Version, MaxWeight)
+		}
+		total += v.Weight
+	}
+	if total == 0 {
+		return fmt.Errorf("the sum of all the weights must be positive")
+	}
+
+	return nil
+}
@@ -4,9 +4,70 @@ import "time"
 
 const (
 	DefMinGraceShutdownTimeout = 2 * time.Second
+
+	// DefResolveTTL is the default time a resolution of a floating reference is reused.
+	DefResolveTTL = 1 * time.Minute
+
+	// DefPullsPerRegistry is the default maximum number of concurrent requests to a registry.
+	DefPullsPerRegistry = 10
+
+	// DefPullQueueTimeout is the default maximum time a request to a registry waits in the queue.
+	DefPullQueueTimeout = 30 * time.Second
+
+	// DefClientRateWindow is the default time window for the per-client rate limit.
+	DefClientRateWindow = 1 * time.Minute
+
+	// DefRetryAfterSecs is the time clients are asked to wait when the server is overloaded.
+	DefRetryAfterSecs = 5
 )
 
 const (
 	// PathWASMDownload is the path where the Proxy-WASM binary can be downloaded.
 	PathWASMDownload = "/api/v1/wasm/download"
+
+	// PathWASMInfo is the path where the information about a Proxy-WASM extension can be obtained.
+	PathWASMInfo = "/api/v1/wasm/info"
+
+	// PathWebhookRegistry is the path where registries send their notifications.
+	PathWebhookRegistry = "/api/v1/webhooks/registry"
+
+	// PathAdmin is the prefix for all the (authenticated) administrative endpoints.
+	PathAdmin = "/api/v1/admin"
+
+	// PathAdminCache is the path for listing and purging the cached extensions.
+	PathAdminCache = PathAdmin + "/cache"
+
+	// PathAdminRefresh is the path for resolving again a floating reference.
+	PathAdminRefresh = PathAdmin + "/refresh"
+
+	// PathAdminPrefetch is the path for fetching a list of references into the cache.
+	PathAdminPrefetch = PathAdmin + "/prefetch"
+
+	// PathAdminChannels is the path for inspecting and changing the release channels.
+	PathAdminChannels = PathAdmin + "/channels"
+)
+
+const (
+	// HeaderSignature is the request header with the HMAC signature of a registry notification.
+	HeaderSignature = "X-Pwo-Signature-256"
+
+	// HeaderHubSignature is an alternative header with the HMAC signature of a registry notification.
+	HeaderHubSignature = "X-Hub-Signature-256"
+
+	// HeaderRef is the response header with the reference of the extension served.
+	HeaderRef = "X-Pwo-Ref"
+
+	// HeaderStale is the response header set when serving a stale extension.
+	HeaderStale = "X-Pwo-Stale"
+
+	// HeaderWarning is the standard warning header, used for marking stale responses.
+	HeaderWarning = "Warning"
+
+	// WarningStale is the value of the warning header for stale responses.
+	WarningStale = `110 - "Response is Stale"`
+)
+
+const (
+	// ContentTypeWASM is the content type used for serving Wasm modules.
+	ContentTypeWASM = "application/wasm"
 )
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"os"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/wasm"
+)
+
+// ExtensionInfo is the information about an extension returned by the info endpoint.
+type ExtensionInfo struct {
+	*cache.Entry
+
+	// ProxyWasm is the Proxy-Wasm information of the extension (nil if it is not a valid extension).
+	ProxyWasm *wasm.ProxyWasm `json:"proxyWasm,omitempty"`
+	// Error is the reason why the extension is not a valid Proxy-Wasm extension.
+	Error string `json:"error,omitempty"`
+	// MinHostVersions are the oldest releases of the known proxies compatible with the
+	// extension, by proxy (like {"envoy": "1.22", "istio": "1.14"}).
+	MinHostVersions map[string]string `json:"minHostVersions,omitempty"`
+}
+
+// newExtensionInfo inspects an extension in the cache.
+func newExtensionInfo(filename string, entry *cache.Entry) (*ExtensionInfo, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &ExtensionInfo{Entry: entry}
+	_, pw, err := wasm.ValidateProxyWasm(data)
+	if err != nil {
+		info.Error = err.Error()
+		return info, nil
+	}
+	info.ProxyWasm = pw
+	info.MinHostVersions = wasm.KnownHosts.Minimum(pw)
+	return info, nil
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+// ErrLayoutDirNotAllowed is returned for references to OCI layout directories
+// (or files) that are not in the directories the server can read from.
+var ErrLayoutDirNotAllowed = errors.New("local directory not allowed")
+
+// ErrURLNotAllowed is returned for URLs (like http:// or the schemes provided
+// by plugins) that do not start with any of the prefixes the server can fetch from.
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+// checkLayoutRef checks that a reference to an OCI layout directory
+// is in one of the directories the server can read from.
+func (s *Server) checkLayoutRef(ref string) error {
+	dir, _, err := registry.ParseLayoutReference(ref)
+	if err != nil {
+		return err
+	}
+	return s.checkLocalDir(dir)
+}
+
+// checkLocalDir checks that a directory is in one of the directories the server can read from.
+func (s *Server) checkLocalDir(dir string) error {
+	dir, err := realPath(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, allowed := range s.layoutDirs {
+		allowed, err := realPath(allowed)
+		if err != nil {
+			continue
+		}
+		if dir == allowed || strings.HasPrefix(dir, allowed+string(filepath.Separator)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrLayoutDirNotAllowed, dir)
+}
+
+// checkURLRef checks that a file:// reference is in one of the directories the
+// server can read from, and that any other URL starts with one of the allowed prefixes.
+func (s *Server) checkURLRef(ref string) error {
+	u, err := url.Parse(ref)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "file" {
+		return s.checkLocalDir(filepath.Dir(filepath.FromSlash(u.Path)))
+	}
+
+	// dot segments could escape the prefix (like in "https://example.com/releases/../other")
+	clean := *u
+	if clean.Path != "" {
+		clean.Path = path.Clean(u.Path)
+		if strings.HasSuffix(u.Path, "/") && clean.Path != "/" {
+			clean.Path += "/"
+		}
+		clean.RawPath = ""
+	}
+
+	for _, prefix := range s.allowedURLs {
+		if hasURLPrefix(clean.String(), prefix) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %s", ErrURLNotAllowed, u.Redacted())
+}
+
+// hasURLPrefix returns true when a URL starts with a prefix, at a boundary of the
+// URL, so "https://example.com" does not match "https://example.com.evil.com/".
+func hasURLPrefix(ref, prefix string) bool {
+	if prefix == "" || !strings.HasPrefix(ref, prefix) {
+		return false
+	}
+	if len(ref) == len(prefix) || strings.HasSuffix(prefix, "/") {
+		return true
+	}
+	return strings.ContainsRune("/?#", rune(ref[len(prefix)]))
+}
+
+// bundleRef returns the reference to the OCI layout in the bundle (if any)
+// for an OCI reference, or false when the repository is not in the bundle.
+func (s *Server) bundleRef(ref string) (string, bool) {
+	if s.bundle == nil {
+		return "", false
+	}
+	return s.bundle.LayoutRef(s.bundleDir, ref)
+}
+
+// realPath returns the absolute path of a file, with all the symlinks resolved
+// (when the file exists) so they cannot be used for escaping a directory.
+func realPath(path string) (string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		return resolved, nil
+	}
+	return path, nil
+}
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckURLRef(t *testing.T) {
+	dir := t.TempDir()
+	allowedDir := filepath.Join(dir, "allowed")
+	require.NoError(t, os.Mkdir(allowedDir, 0o755))
+
+	server := &Server{
+		layoutDirs:  []string{allowedDir},
+		allowedURLs: []string{"https://example.com/releases/", "https://downloads.example.com", "myscheme://"},
+	}
+
+	for name, tCase := range map[string]struct {
+		ref         string
+		expectedErr error
+	}{
+		"allowed prefix":                 {ref: "https://example.com/releases/v1.0.0/myext.wasm"},
+		"allowed host":                   {ref: "https://downloads.example.com/myext.wasm"},
+		"allowed host with query":        {ref: "https://downloads.example.com?file=myext.wasm"},
+		"allowed plugin scheme":          {ref: "myscheme://bucket/myext.wasm"},
+		"parent directory":               {ref: "https://example.com/releases/../other/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"encoded parent directory":       {ref: "https://example.com/releases/%2e%2e/other/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"current directory":              {ref: "https://example.com/releases/./v1.0.0/myext.wasm"},
+		"other path":                     {ref: "https://example.com/other/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"other host":                     {ref: "http://169.254.169.254/latest/meta-data", expectedErr: ErrURLNotAllowed},
+		"host with the same prefix":      {ref: "https://downloads.example.com.evil.com/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"user info with the same prefix": {ref: "https://downloads.example.com@evil.com/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"other scheme":                   {ref: "http://example.com/releases/v1.0.0/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"other plugin scheme":            {ref: "otherscheme://bucket/myext.wasm", expectedErr: ErrURLNotAllowed},
+		"file in allowed directory":      {ref: "file://" + filepath.ToSlash(filepath.Join(allowedDir, "myext.wasm"))},
+		"file in other directory":        {ref: "file://" + filepath.ToSlash(filepath.Join(dir, "myext.wasm")), expectedErr: ErrLayoutDirNotAllowed},
+	} {
+		t.Run(name, func(t *testing.T) {
+			err := server.checkURLRef(tCase.ref)
+			if tCase.expectedErr != nil {
+				assert.ErrorIs(t, err, tCase.expectedErr)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+
+	// without any prefix, no URL is allowed
+	server.allowedURLs = nil
+	assert.ErrorIs(t, server.checkURLRef("https://example.com/releases/v1.0.0/myext.wasm"), ErrURLNotAllowed)
+}
@@ -0,0 +1,99 @@
+package server
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrQueueTimeout is returned when a request to a registry has been waiting
+// in the queue for longer than the queue timeout.
+var ErrQueueTimeout = errors.New("timeout waiting for a free slot for pulling from the registry")
+
+// PullLimiter limits the number of concurrent requests to registries, globally
+// and per registry. Requests exceeding those limits wait in a queue, up to a timeout.
+type PullLimiter struct {
+	global      chan struct{}
+	perRegistry int
+	timeout     time.Duration
+
+	mu         sync.Mutex
+	registries map[string]chan struct{}
+}
+
+// NewPullLimiter creates a new limiter. Zero (or negative) limits mean no limit.
+func NewPullLimiter(global, perRegistry int, timeout time.Duration) *PullLimiter {
+	l := &PullLimiter{
+		perRegistry: perRegistry,
+		timeout:     timeout,
+		registries:  map[string]chan struct{}{},
+	}
+	if global > 0 {
+		l.global = make(chan struct{}, global)
+	}
+	return l
+}
+
+// Acquire waits until a request to a registry host can proceed, returning
+// a function that must be called when the request is done.
+func (l *PullLimiter) Acquire(host string) (func(), error) {
+	var timeout <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	metricPullQueueLength.Add(1)
+	defer metricPullQueueLength.Add(-1)
+
+	releaseGlobal, err := acquireSlot(l.global, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	releaseRegistry, err := acquireSlot(l.registry(host), timeout)
+	if err != nil {
+		releaseGlobal()
+		return nil, err
+	}
+
+	metricPullsInFlight.Add(1)
+	return func() {
+		metricPullsInFlight.Add(-1)
+		releaseRegistry()
+		releaseGlobal()
+	}, nil
+}
+
+// registry returns the semaphore for a registry host.
+func (l *PullLimiter) registry(host string) chan struct{} {
+	if l.perRegistry <= 0 {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	sem, ok := l.registries[host]
+	if !ok {
+		sem = make(chan struct{}, l.perRegistry)
+		l.registries[host] = sem
+	}
+	return sem
+}
+
+// acquireSlot takes a slot in a semaphore (a nil semaphore is unlimited).
+func acquireSlot(sem chan struct{}, timeout <-chan time.Time) (func(), error) {
+	if sem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case sem <- struct{}{}:
+		return func() { <-sem }, nil
+	case <-timeout:
+		metricPullQueueTimeouts.Add(1)
+		return nil, ErrQueueTimeout
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullLimiter(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+
+	for name, tCase := range map[string]struct {
+		global      int
+		perRegistry int
+		// acquired are the hosts of the slots taken before the last request
+		acquired []string
+		host     string
+		expected error
+	}{
+		"no limits":                         {acquired: []string{"a", "a", "a"}, host: "a"},
+		"below the global limit":            {global: 2, acquired: []string{"a"}, host: "a"},
+		"global limit":                      {global: 2, acquired: []string{"a", "b"}, host: "c", expected: ErrQueueTimeout},
+		"below the registry limit":          {perRegistry: 2, acquired: []string{"a"}, host: "a"},
+		"registry limit":                    {perRegistry: 2, acquired: []string{"a", "a"}, host: "a", expected: ErrQueueTimeout},
+		"registry limit of other registry":  {perRegistry: 2, acquired: []string{"a", "a"}, host: "b"},
+		"global limit before registry one":  {global: 3, perRegistry: 2, acquired: []string{"a", "b", "c"}, host: "d", expected: ErrQueueTimeout},
+		"registry limit below global limit": {global: 3, perRegistry: 1, acquired: []string{"a"}, host: "a", expected: ErrQueueTimeout},
+	} {
+		t.Run(name, func(t *testing.T) {
+			l := NewPullLimiter(tCase.global, tCase.perRegistry, timeout)
+			for _, host := range tCase.acquired {
+				release, err := l.Acquire(host)
+				require.NoError(t, err)
+				defer release()
+			}
+
+			start := time.Now()
+			release, err := l.Acquire(tCase.host)
+			if tCase.expected != nil {
+				assert.ErrorIs(t, err, tCase.expected)
+				assert.GreaterOrEqual(t, time.Since(start), timeout)
+				return
+			}
+			require.NoError(t, err)
+			release()
+		})
+	}
+}
+
+func TestPullLimiterRelease(t *testing.T) {
+	l := NewPullLimiter(1, 1, time.Second)
+
+	release, err := l.Acquire("a")
+	require.NoError(t, err)
+
+	// a request waiting in the queue proceeds when the slot is released
+	acquired := make(chan error, 1)
+	go func() {
+		release, err := l.Acquire("a")
+		if err == nil {
+			release()
+		}
+		acquired <- err
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("acquired a slot before it was released")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	release()
+	select {
+	case err := <-acquired:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("slot not acquired after it was released")
+	}
+
+	// and a failed request does not keep the global slot
+	l = NewPullLimiter(2, 1, 20*time.Millisecond)
+	releaseA, err := l.Acquire("a")
+	require.NoError(t, err)
+	defer releaseA()
+	_, err = l.Acquire("a")
+	require.ErrorIs(t, err, ErrQueueTimeout)
+	releaseB, err := l.Acquire("b")
+	require.NoError(t, err)
+	releaseB()
+}
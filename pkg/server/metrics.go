@@ -0,0 +1,21 @@
+package server
+
+import "expvar"
+
+// Metrics exported by the server (available at /debug/vars).
+var (
+	// metricStaleServed counts the extensions served from the cache while the registry was unavailable.
+	metricStaleServed = expvar.NewInt("pwo_stale_served_total")
+
+	// metricPullQueueLength is the number of requests to registries waiting for a free slot.
+	metricPullQueueLength = expvar.NewInt("pwo_pull_queue_length")
+
+	// metricPullsInFlight is the number of requests to registries in progress.
+	metricPullsInFlight = expvar.NewInt("pwo_pulls_in_flight")
+
+	// metricPullQueueTimeouts counts the requests to registries that timed out waiting in the queue.
+	metricPullQueueTimeouts = expvar.NewInt("pwo_pull_queue_timeouts_total")
+
+	// metricClientRateLimited counts the download requests rejected by the per-client rate limit.
+	metricClientRateLimited = expvar.NewInt("pwo_client_rate_limited_total")
+)
@@ -1,47 +1,122 @@
 package server
 
 import (
-	"os"
+	"errors"
+	"strconv"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+	"github.com/gofiber/fiber/v2/middleware/limiter"
 	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
 )
 
 // RegisterWASMBridge func for common paths (unauthenticated).
 func RegisterWASMBridge(a fiber.Router, log *zap.Logger, server *Server) {
+	handlers := []fiber.Handler{}
+	if server.clientRateLimit > 0 {
+		handlers = append(handlers, limiter.New(limiter.Config{
+			Max:        server.clientRateLimit,
+			Expiration: server.clientRateWindow,
+			LimitReached: func(c *fiber.Ctx) error {
+				log.Warn("client rate limit reached", zap.String("client", c.IP()))
+				metricClientRateLimited.Add(1)
+				return fiber.ErrTooManyRequests
+			},
+		}))
+	}
 
-	a.Get(PathWASMDownload, func(c *fiber.Ctx) error {
+	a.Get(PathWASMDownload, append(handlers, func(c *fiber.Ctx) error {
 		log.Info("Received request to download WASM extension")
 
-		m := c.Queries()
-		ref, ok := m["ref"]
-		if !ok {
-			log.Error("no 'ref' found in request")
-			return fiber.ErrBadRequest
+		entry, log, err := fetchWASMExtension(c, log, server)
+		if err != nil {
+			return err
 		}
-		log := log.With(zap.String("ref", ref))
 
-		log.Info("Valid request")
+		if err := c.SendFile(server.cache.Path(entry), false); err != nil {
+			return err
+		}
+		c.Set(fiber.HeaderContentType, ContentTypeWASM)
+		c.Set(HeaderRef, entry.Ref)
 
-		regCfg := registry.RegistryParams{
-			// TODO: maybe we should set some things here from query parameters...
+		if entry.Stale {
+			log.Warn("registry unavailable: serving stale extension", zap.String("resolved", entry.Ref))
+			metricStaleServed.Add(1)
+			c.Set(HeaderStale, "true")
+			c.Set(HeaderWarning, WarningStale)
 		}
 
-		// create a temp directory to download the extension
-		tempDir, err := os.MkdirTemp("", "pwo-download-*")
+		return nil
+	})...)
+
+	a.Get(PathWASMInfo, append(handlers, func(c *fiber.Ctx) error {
+		log.Info("Received request for information about WASM extension")
+
+		entry, log, err := fetchWASMExtension(c, log, server)
 		if err != nil {
-			log.Error("could not create temporary directory", zap.Error(err))
-			return fiber.ErrInternalServerError
+			return err
 		}
-		// TODO: we should remove temporary directories
 
-		fileName, err := DownloadWASMExtension(log, server, ref, tempDir, regCfg)
+		info, err := newExtensionInfo(server.cache.Path(entry), entry)
 		if err != nil {
-			log.Error("error downloading WASM extension", zap.Error(err))
+			log.Error("error inspecting WASM extension", zap.Error(err))
 			return fiber.ErrInternalServerError
 		}
+		c.Set(HeaderRef, entry.Ref)
+		return c.JSON(info)
+	})...)
+}
+
+// fetchWASMExtension obtains the extension requested (with a 'ref', or a 'channel' and
+// a 'name'), from the cache or from the registry. It returns the errors as HTTP errors.
+func fetchWASMExtension(c *fiber.Ctx, log *zap.Logger, server *Server) (*cache.Entry, *zap.Logger, error) {
+	m := c.Queries()
+	ref, ok := m["ref"]
+	version := ""
+	if !ok {
+		channel, name := m["channel"], m["name"]
+		if channel == "" || name == "" {
+			log.Error("no 'ref' (or 'channel' and 'name') found in request")
+			return nil, log, fiber.ErrBadRequest
+		}
+
+		var err error
+		ref, version, err = server.channels.Resolve(channel, name, clientKey(c, server.channels.HashHeader(channel)))
+		if err != nil {
+			log.Error("could not resolve channel", zap.String("channel", channel), zap.Error(err))
+			return nil, log, fiber.ErrNotFound
+		}
+		log = log.With(zap.String("channel", channel), zap.String("version", version))
+	}
+	log = log.With(zap.String("ref", ref))
 
-		return c.SendFile(fileName, false)
-	})
+	log.Info("Valid request")
+
+	entry, err := DownloadWASMExtensionVersion(log, server, ref, version, server.registryParams)
+	if errors.Is(err, ErrQueueTimeout) {
+		log.Error("too many concurrent downloads", zap.Error(err))
+		c.Set(fiber.HeaderRetryAfter, strconv.Itoa(DefRetryAfterSecs))
+		return nil, log, fiber.ErrServiceUnavailable
+	}
+	if errors.Is(err, ErrLayoutDirNotAllowed) || errors.Is(err, ErrURLNotAllowed) {
+		log.Error("forbidden reference", zap.Error(err))
+		return nil, log, fiber.ErrForbidden
+	}
+	if err != nil {
+		log.Error("error downloading WASM extension", zap.Error(err))
+		return nil, log, fiber.ErrInternalServerError
+	}
+	return entry, log, nil
+}
+
+// clientKey returns the key used for identifying a client in a channel rollout:
+// the value of the given header, or the client IP when not present.
+func clientKey(c *fiber.Ctx, header string) string {
+	if header != "" {
+		if v := c.Get(header); v != "" {
+			return v
+		}
+	}
+	return c.IP()
 }
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"strconv"
+	"time"
 
 	"github.com/gofiber/contrib/fiberzap/v2"
 	"github.com/gofiber/fiber/v2"
@@ -15,6 +16,7 @@ import (
 	"go.uber.org/zap"
 	"golang.org/x/sync/singleflight"
 
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
 	"github.com/inercia/proxy-wasm-oci/pkg/config"
 	"github.com/inercia/proxy-wasm-oci/pkg/registry"
 )
@@ -26,11 +28,135 @@ type Server struct {
 	settings       *config.GlobalSettings
 	registryConfig *registry.Configuration
 	downloads      singleflight.Group
+
+	cache           *cache.Cache
+	resolveTTL      time.Duration
+	adminToken      string
+	registryParams  registry.RegistryParams
+	staleIfError    bool
+	channels        *Channels
+	watch           []string
+	webhookSecret   string
+	webhookInsecure bool
+	layoutDirs      []string
+	allowedURLs     []string
+	bundle          *registry.BundleIndex
+	bundleDir       string
+
+	limiter          *PullLimiter
+	clientRateLimit  int
+	clientRateWindow time.Duration
+}
+
+// ServerOpt is a type of function that sets options for the server.
+type ServerOpt func(*Server)
+
+// WithCache sets the cache where the extensions are stored.
+func WithCache(c *cache.Cache) ServerOpt {
+	return func(s *Server) {
+		s.cache = c
+	}
+}
+
+// WithResolveTTL sets the time the resolution of a floating reference is reused.
+func WithResolveTTL(ttl time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.resolveTTL = ttl
+	}
+}
+
+// WithRegistryParams sets the parameters used for connecting to the registries.
+func WithRegistryParams(p registry.RegistryParams) ServerOpt {
+	return func(s *Server) {
+		s.registryParams = p
+	}
+}
+
+// WithConfig sets the server configuration.
+func WithConfig(cfg *Config) ServerOpt {
+	return func(s *Server) {
+		s.channels = NewChannels(cfg)
+		s.watch = cfg.Watch
+	}
+}
+
+// WithWebhookSecret sets the secret used for checking the HMAC signature
+// of the notifications sent by registries.
+func WithWebhookSecret(secret string) ServerOpt {
+	return func(s *Server) {
+		s.webhookSecret = secret
+	}
+}
+
+// WithInsecureWebhook enables the registry webhook without a secret, accepting
+// notifications that are not authenticated.
+func WithInsecureWebhook(enabled bool) ServerOpt {
+	return func(s *Server) {
+		s.webhookInsecure = enabled
+	}
+}
+
+// WithLayoutDirs sets the directories where OCI layout directories can be read from.
+// References to OCI layout directories are rejected when empty.
+func WithLayoutDirs(dirs []string) ServerOpt {
+	return func(s *Server) {
+		s.layoutDirs = dirs
+	}
+}
+
+// WithAllowedURLPrefixes sets the prefixes of the URLs (like
+// "https://github.com/myorg/") the server can fetch extensions from.
+// References to any URL are rejected when empty.
+func WithAllowedURLPrefixes(prefixes []string) ServerOpt {
+	return func(s *Server) {
+		s.allowedURLs = prefixes
+	}
+}
+
+// WithBundle sets a bundle (extracted in a directory) where the extensions
+// are obtained from, instead of from their registries.
+func WithBundle(index *registry.BundleIndex, dir string) ServerOpt {
+	return func(s *Server) {
+		s.bundle = index
+		s.bundleDir = dir
+	}
+}
+
+// WithPullLimits sets the maximum number of concurrent requests to registries, globally and
+// per registry, and the maximum time a request can wait in the queue for a free slot.
+func WithPullLimits(global, perRegistry int, queueTimeout time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.limiter = NewPullLimiter(global, perRegistry, queueTimeout)
+	}
+}
+
+// WithClientRateLimit sets the maximum number of download requests a client
+// can perform in a time window. Zero disables the limit.
+func WithClientRateLimit(max int, window time.Duration) ServerOpt {
+	return func(s *Server) {
+		s.clientRateLimit = max
+		s.clientRateWindow = window
+	}
+}
+
+// WithStaleIfError enables serving stale extensions from the cache when the registry is unavailable.
+func WithStaleIfError(stale bool) ServerOpt {
+	return func(s *Server) {
+		s.staleIfError = stale
+	}
+}
+
+// WithAdminToken sets the token required for using the admin API.
+// The admin API is disabled when no token is provided.
+func WithAdminToken(token string) ServerOpt {
+	return func(s *Server) {
+		s.adminToken = token
+	}
 }
 
 // StartServer starts a Fiber server.
 // The server will be gracefully shutdown when the context is canceled.
-func NewServer(settings *config.GlobalSettings, l *zap.Logger, regCfg *registry.Configuration) (*Server, error) {
+func NewServer(settings *config.GlobalSettings, l *zap.Logger, regCfg *registry.Configuration, opts ...ServerOpt) (*Server, error) {
 	log := l
 	log.Info("Creating API server.")
 
@@ -50,7 +176,22 @@ func NewServer(settings *config.GlobalSettings, l *zap.Logger, regCfg *registry.
 		settings:       settings,
 		registryConfig: regCfg,
 		downloads:      singleflight.Group{},
+
+		resolveTTL: DefResolveTTL,
 	}
+	for _, opt := range opts {
+		opt(res)
+	}
+	if res.limiter == nil {
+		res.limiter = NewPullLimiter(settings.BurstLimit, 0, 0)
+	}
+	if res.channels == nil {
+		res.channels = NewChannels(nil)
+	}
+	if res.cache == nil {
+		res.cache = cache.New(config.CachePath("server"), cache.WithLogger(log.Named("cache")))
+	}
+	log.Sugar().Infof("API server: using cache at %s", res.cache.Dir())
 
 	appRoot.Use(fiberzap.New(fiberzap.Config{
 		Logger: log,
@@ -70,6 +211,8 @@ func NewServer(settings *config.GlobalSettings, l *zap.Logger, regCfg *registry.
 
 	appRootMain := appRoot.Group("/")
 	RegisterWASMBridge(appRootMain, log, res)
+	RegisterAdmin(appRootMain, log, res)
+	RegisterWebhooks(appRootMain, log, res)
 
 	return res, nil
 }
@@ -90,6 +233,9 @@ func (server *Server) Start(ctx context.Context, port int) error {
 		} else {
 			log.Info("API server: graceful shutdown of API completed. No API available from now on...")
 		}
+		if err := server.cache.Flush(); err != nil {
+			log.Error("API server: could not save the cache index", zap.Error(err))
+		}
 	}()
 
 	log.Sugar().Infof("API server: listening on :%d", port)
@@ -0,0 +1,193 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+	"github.com/inercia/proxy-wasm-oci/pkg/registry"
+)
+
+const (
+	// EventActionPush is the action for a manifest or blob pushed to the registry.
+	EventActionPush = "push"
+
+	// EventActionDelete is the action for a manifest or tag deleted from the registry.
+	EventActionDelete = "delete"
+)
+
+// RegistryEnvelope is a notification sent by a registry, as described in
+// https://distribution.github.io/distribution/about/notifications/
+type RegistryEnvelope struct {
+	Events []RegistryEvent `json:"events"`
+}
+
+// RegistryEvent is an event in a registry notification.
+type RegistryEvent struct {
+	ID     string `json:"id"`
+	Action string `json:"action"`
+	Target struct {
+		MediaType  string `json:"mediaType"`
+		Digest     string `json:"digest"`
+		Repository string `json:"repository"`
+		Tag        string `json:"tag"`
+	} `json:"target"`
+	Request struct {
+		Host string `json:"host"`
+	} `json:"request"`
+}
+
+// RegisterWebhooks registers the endpoint receiving notifications from registries.
+// The endpoint is not registered without a secret, unless insecure webhooks are enabled.
+func RegisterWebhooks(a fiber.Router, log *zap.Logger, server *Server) {
+	log = log.Named("webhook")
+
+	if server.webhookSecret == "" {
+		if !server.webhookInsecure {
+			log.Info("No webhook secret provided: registry webhook disabled")
+			return
+		}
+		log.Warn("No webhook secret provided: registry notifications will not be authenticated")
+	}
+
+	a.Post(PathWebhookRegistry, func(c *fiber.Ctx) error {
+		body := c.Body()
+
+		if server.webhookSecret != "" {
+			signature := c.Get(HeaderSignature)
+			if signature == "" {
+				signature = c.Get(HeaderHubSignature)
+			}
+			if !validSignature(body, signature, server.webhookSecret) {
+				log.Error("invalid signature in registry notification")
+				return fiber.ErrUnauthorized
+			}
+		}
+
+		envelope := RegistryEnvelope{}
+		if err := json.Unmarshal(body, &envelope); err != nil {
+			log.Error("could not parse registry notification", zap.Error(err))
+			return fiber.ErrBadRequest
+		}
+
+		// the host can be forced when the registry is known by a different name
+		host := c.Query("registry")
+
+		for _, event := range envelope.Events {
+			if event.Action != EventActionPush && event.Action != EventActionDelete {
+				continue
+			}
+			if event.Target.Repository == "" {
+				continue
+			}
+			if event.Action == EventActionPush && event.Target.Tag == "" {
+				// blobs (and untagged manifests) do not change any tag
+				continue
+			}
+
+			eventHost := host
+			if eventHost == "" {
+				eventHost = event.Request.Host
+			}
+
+			handleRegistryEvent(log, server, eventHost, event)
+		}
+
+		return c.SendStatus(fiber.StatusAccepted)
+	})
+}
+
+// handleRegistryEvent invalidates the cache for the repository in the event,
+// prefetching the watched references in that repository.
+func handleRegistryEvent(log *zap.Logger, server *Server, host string, event RegistryEvent) {
+	log = log.With(
+		zap.String("action", event.Action),
+		zap.String("host", host),
+		zap.String("repository", event.Target.Repository),
+		zap.String("tag", event.Target.Tag))
+	log.Info("Registry notification received")
+
+	resolutions, err := server.cache.Resolutions()
+	if err != nil {
+		log.Error("could not list resolutions", zap.Error(err))
+		return
+	}
+	for _, r := range resolutions {
+		if !inRepository(r.Ref, host, event.Target.Repository) {
+			continue
+		}
+		log.Info("Invalidating resolution", zap.String("ref", r.Ref))
+		if _, err := server.cache.ForgetResolutions(r.Ref); err != nil {
+			log.Error("could not invalidate resolution", zap.String("ref", r.Ref), zap.Error(err))
+		}
+	}
+
+	// the tag could point to a different manifest now
+	if event.Target.Tag != "" {
+		entries, err := server.cache.List()
+		if err != nil {
+			log.Error("could not list cache", zap.Error(err))
+			return
+		}
+		for _, e := range entries {
+			if _, tag := cache.SplitTag(e.Ref); tag != event.Target.Tag || !inRepository(e.Ref, host, event.Target.Repository) {
+				continue
+			}
+			log.Info("Purging cached extension", zap.String("ref", e.Ref))
+			if _, err := server.cache.Purge(e.Ref, ""); err != nil {
+				log.Error("could not purge cached extension", zap.String("ref", e.Ref), zap.Error(err))
+			}
+		}
+	}
+
+	if event.Action != EventActionPush {
+		return
+	}
+
+	for _, ref := range server.watch {
+		if !inRepository(ref, host, event.Target.Repository) {
+			continue
+		}
+		go func(ref string) {
+			log := log.With(zap.String("ref", ref))
+			log.Info("Prefetching watched reference")
+			if _, err := RefreshWASMExtension(log, server, ref, server.registryParams); err != nil {
+				log.Error("error prefetching watched reference", zap.Error(err))
+			}
+		}(ref)
+	}
+}
+
+// inRepository returns true if a reference (like "oci://myregistry.com/myrepo:1.0.0")
+// belongs to a repository in a registry. Any registry matches when the host is empty.
+func inRepository(ref, host, repository string) bool {
+	repo, _ := cache.SplitTag(strings.TrimPrefix(ref, fmt.Sprintf("%s://", registry.OCIScheme)))
+	refHost, refRepository, ok := strings.Cut(repo, "/")
+	if !ok || refRepository != repository {
+		return false
+	}
+	return host == "" || refHost == host
+}
+
+// validSignature checks a signature like "sha256=<hex HMAC>" of a payload.
+func validSignature(payload []byte, signature, secret string) bool {
+	hexSignature, ok := strings.CutPrefix(signature, "sha256=")
+	if !ok {
+		return false
+	}
+	received, err := hex.DecodeString(hexSignature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hmac.Equal(received, mac.Sum(nil))
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/cache"
+)
+
+const testNotification = `{"events": [{"action": "push", "target": {"repository": "myrepo", "tag": "1.0.0"}, "request": {"host": "myregistry.com"}}]}`
+
+func sign(payload, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestValidSignature(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		signature string
+		expected  bool
+	}{
+		"valid":              {signature: sign(testNotification, "secret"), expected: true},
+		"other secret":       {signature: sign(testNotification, "other"), expected: false},
+		"other payload":      {signature: sign(testNotification+" ", "secret"), expected: false},
+		"no prefix":          {signature: strings.TrimPrefix(sign(testNotification, "secret"), "sha256="), expected: false},
+		"other algorithm":    {signature: "sha1=" + strings.TrimPrefix(sign(testNotification, "secret"), "sha256="), expected: false},
+		"not hex":            {signature: "sha256=not-hex", expected: false},
+		"truncated":          {signature: sign(testNotification, "secret")[:20], expected: false},
+		"empty":              {signature: "", expected: false},
+		"only the prefix":    {signature: "sha256=", expected: false},
+		"uppercase hex":      {signature: "sha256=" + strings.ToUpper(strings.TrimPrefix(sign(testNotification, "secret"), "sha256=")), expected: true},
+		"signature of empty": {signature: sign("", "secret"), expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, validSignature([]byte(testNotification), tCase.signature, "secret"))
+		})
+	}
+}
+
+func TestWebhookAuthentication(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		secret   string
+		insecure bool
+		headers  map[string]string
+		expected int
+	}{
+		"valid signature":          {secret: "secret", headers: map[string]string{HeaderSignature: sign(testNotification, "secret")}, expected: fiber.StatusAccepted},
+		"valid hub signature":      {secret: "secret", headers: map[string]string{HeaderHubSignature: sign(testNotification, "secret")}, expected: fiber.StatusAccepted},
+		"invalid signature":        {secret: "secret", headers: map[string]string{HeaderSignature: sign(testNotification, "other")}, expected: fiber.StatusUnauthorized},
+		"no signature":             {secret: "secret", expected: fiber.StatusUnauthorized},
+		"no secret":                {expected: fiber.StatusNotFound},
+		"no secret, insecure":      {insecure: true, expected: fiber.StatusAccepted},
+		"secret and insecure":      {secret: "secret", insecure: true, expected: fiber.StatusUnauthorized},
+		"signature without secret": {headers: map[string]string{HeaderSignature: sign(testNotification, "secret")}, expected: fiber.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			server := &Server{
+				cache:           cache.New(t.TempDir()),
+				webhookSecret:   tCase.secret,
+				webhookInsecure: tCase.insecure,
+			}
+			app := fiber.New()
+			RegisterWebhooks(app, zap.NewNop(), server)
+
+			req := httptest.NewRequest(fiber.MethodPost, PathWebhookRegistry, strings.NewReader(testNotification))
+			for k, v := range tCase.headers {
+				req.Header.Set(k, v)
+			}
+			resp, err := app.Test(req)
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package suite
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// Expect is the expected outcome of a test. Only the fields set are checked.
+type Expect struct {
+	// ConfigRejected expects the plugin configuration to be rejected (with proxy_on_configure returning false)
+	ConfigRejected bool `json:"configRejected,omitempty"`
+
+	// LocalResponse expects the extension to send (or not) a local response
+	LocalResponse *bool `json:"localResponse,omitempty"`
+	// Status is the status received by the client: the status of the local response,
+	// or the status of the response from the upstream (after the changes made by the extension)
+	Status int `json:"status,omitempty"`
+	// Body is the body received by the client
+	Body *string `json:"body,omitempty"`
+	// ResponseHeaders are some headers received by the client
+	ResponseHeaders map[string]string `json:"responseHeaders,omitempty"`
+	// RemovedResponseHeaders are some headers not received by the client
+	RemovedResponseHeaders []string `json:"removedResponseHeaders,omitempty"`
+
+	// RequestHeaders are some headers received by the upstream
+	RequestHeaders map[string]string `json:"requestHeaders,omitempty"`
+	// RemovedRequestHeaders are some headers not received by the upstream
+	RemovedRequestHeaders []string `json:"removedRequestHeaders,omitempty"`
+	// RequestBody is the body received by the upstream
+	RequestBody *string `json:"requestBody,omitempty"`
+
+	// Logs are some texts found in the messages logged by the extension
+	Logs []string `json:"logs,omitempty"`
+}
+
+// checkStart checks the expectations when starting the plugin
+func (e *Expect) checkStart(started bool, logs []host.LogEntry) []string {
+	var failures []string
+	switch {
+	case e.ConfigRejected && started:
+		failures = append(failures, "expected the plugin configuration to be rejected")
+	case !e.ConfigRejected && !started:
+		failures = append(failures, "the plugin configuration was rejected")
+	}
+	return append(failures, e.checkLogs(logs)...)
+}
+
+// check checks the expectations for the result of a request
+func (e *Expect) check(res *host.HTTPResult, logs []host.LogEntry) []string {
+	var failures []string
+	failf := func(format string, args ...any) {
+		failures = append(failures, fmt.Sprintf(format, args...))
+	}
+
+	// what the client receives: the local response, or the response from the upstream
+	status, body, headers := 0, "", host.Headers(nil)
+	if lr := res.LocalResponse; lr != nil {
+		status, body, headers = int(lr.StatusCode), string(lr.Body), lr.Headers
+	} else if res.Response != nil {
+		body, headers = string(res.Response.Body), res.Response.Headers
+		if s, ok := res.Response.Headers.Get(":status"); ok {
+			status, _ = strconv.Atoi(s)
+		}
+	}
+
+	if e.LocalResponse != nil && *e.LocalResponse != (res.LocalResponse != nil) {
+		if *e.LocalResponse {
+			failf("expected a local response, but the request was sent to the upstream")
+		} else {
+			failf("expected the request to be sent to the upstream, but got a local response with status %d", status)
+		}
+	}
+	if e.Status != 0 && e.Status != status {
+		failf("expected status %d, got %d", e.Status, status)
+	}
+	if e.Body != nil && *e.Body != body {
+		failf("expected body %q, got %q", *e.Body, body)
+	}
+	failures = append(failures, checkHeaders("response", headers, e.ResponseHeaders, e.RemovedResponseHeaders)...)
+
+	if e.RequestHeaders != nil || e.RemovedRequestHeaders != nil || e.RequestBody != nil {
+		if res.LocalResponse != nil {
+			if e.LocalResponse == nil {
+				failf("expected the request to be sent to the upstream, but got a local response with status %d", status)
+			}
+		} else {
+			failures = append(failures, checkHeaders("request", res.Request.Headers, e.RequestHeaders, e.RemovedRequestHeaders)...)
+			if e.RequestBody != nil && *e.RequestBody != string(res.Request.Body) {
+				failf("expected request body %q, got %q", *e.RequestBody, string(res.Request.Body))
+			}
+		}
+	}
+
+	return append(failures, e.checkLogs(logs)...)
+}
+
+// checkLogs checks that all the expected texts have been logged
+func (e *Expect) checkLogs(logs []host.LogEntry) []string {
+	var failures []string
+	for _, expected := range e.Logs {
+		found := false
+		for _, l := range logs {
+			if strings.Contains(l.Message, expected) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			failures = append(failures, fmt.Sprintf("expected a log message with %q", expected))
+		}
+	}
+	return failures
+}
+
+// checkHeaders checks the values of some headers, and that some other headers are not present
+func checkHeaders(kind string, headers host.Headers, expected map[string]string, removed []string) []string {
+	var failures []string
+
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		value, ok := headers.Get(name)
+		switch {
+		case !ok:
+			failures = append(failures, fmt.Sprintf("expected %s header %q", kind, name))
+		case value != expected[name]:
+			failures = append(failures, fmt.Sprintf("expected %s header %q to be %q, got %q", kind, name, expected[name], value))
+		}
+	}
+	for _, name := range removed {
+		if value, ok := headers.Get(name); ok {
+			failures = append(failures, fmt.Sprintf("expected no %s header %q, got %q", kind, name, value))
+		}
+	}
+	return failures
+}
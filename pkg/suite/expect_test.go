@@ -0,0 +1,165 @@
+package suite
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+func boolPtr(b bool) *bool { return &b }
+
+func stringPtr(s string) *string { return &s }
+
+func TestExpectCheck(t *testing.T) {
+	// the request was sent to the upstream, and the response has been modified by the extension
+	upstream := &host.HTTPResult{
+		Request: &host.HTTPRequest{
+			Headers: host.Headers{{Name: ":path", Value: "/a"}, {Name: "x-user", Value: "alice"}},
+			Body:    host.Body("request"),
+		},
+		Response: &host.HTTPResponse{
+			Headers: host.Headers{{Name: ":status", Value: "201"}, {Name: "content-type", Value: "text/plain"}},
+			Body:    host.Body("created"),
+		},
+	}
+	// the extension sent a local response
+	local := &host.HTTPResult{
+		Request: &host.HTTPRequest{Headers: host.Headers{{Name: ":path", Value: "/a"}}},
+		LocalResponse: &host.LocalResponse{
+			StatusCode: 403,
+			Body:       host.Body("denied"),
+			Headers:    host.Headers{{Name: "x-reason", Value: "no token"}},
+		},
+	}
+	logs := []host.LogEntry{{Level: host.LogLevelInfo, Message: "user alice authenticated"}}
+
+	for name, tCase := range map[string]struct {
+		expect           Expect
+		result           *host.HTTPResult
+		expectedFailures []string
+	}{
+		"nothing expected": {
+			result: upstream,
+		},
+		"upstream response": {
+			expect: Expect{
+				LocalResponse:   boolPtr(false),
+				Status:          201,
+				Body:            stringPtr("created"),
+				ResponseHeaders: map[string]string{"Content-Type": "text/plain"},
+				RequestHeaders:  map[string]string{"x-user": "alice"},
+				RequestBody:     stringPtr("request"),
+				Logs:            []string{"alice authenticated"},
+			},
+			result: upstream,
+		},
+		"local response": {
+			expect: Expect{
+				LocalResponse:   boolPtr(true),
+				Status:          403,
+				Body:            stringPtr("denied"),
+				ResponseHeaders: map[string]string{"x-reason": "no token"},
+			},
+			result: local,
+		},
+		"status of the local response, not of the upstream": {
+			expect:           Expect{Status: 201},
+			result:           local,
+			expectedFailures: []string{"expected status 201, got 403"},
+		},
+		"status of the upstream": {
+			expect:           Expect{Status: 403, Body: stringPtr("denied")},
+			result:           upstream,
+			expectedFailures: []string{"expected status 403, got 201", `expected body "denied", got "created"`},
+		},
+		"unexpected local response": {
+			expect:           Expect{LocalResponse: boolPtr(false)},
+			result:           local,
+			expectedFailures: []string{"expected the request to be sent to the upstream, but got a local response with status 403"},
+		},
+		"missing local response": {
+			expect:           Expect{LocalResponse: boolPtr(true)},
+			result:           upstream,
+			expectedFailures: []string{"expected a local response, but the request was sent to the upstream"},
+		},
+		"request headers with a local response": {
+			expect:           Expect{RequestHeaders: map[string]string{"x-user": "alice"}},
+			result:           local,
+			expectedFailures: []string{"expected the request to be sent to the upstream, but got a local response with status 403"},
+		},
+		"request headers with a local response expected": {
+			// the failure is reported only once
+			expect:           Expect{LocalResponse: boolPtr(false), RequestBody: stringPtr("request")},
+			result:           local,
+			expectedFailures: []string{"expected the request to be sent to the upstream, but got a local response with status 403"},
+		},
+		"headers": {
+			expect: Expect{
+				ResponseHeaders: map[string]string{"content-type": "application/json", "x-missing": "1"},
+				RequestHeaders:  map[string]string{"x-user": "bob"},
+			},
+			result: upstream,
+			expectedFailures: []string{
+				`expected response header "content-type" to be "application/json", got "text/plain"`,
+				`expected response header "x-missing"`,
+				`expected request header "x-user" to be "bob", got "alice"`,
+			},
+		},
+		"removed headers": {
+			expect: Expect{
+				RemovedResponseHeaders: []string{"Content-Type", "x-other"},
+				RemovedRequestHeaders:  []string{"x-user"},
+			},
+			result: upstream,
+			expectedFailures: []string{
+				`expected no response header "Content-Type", got "text/plain"`,
+				`expected no request header "x-user", got "alice"`,
+			},
+		},
+		"removed headers of the local response": {
+			expect:           Expect{RemovedResponseHeaders: []string{"content-type", "x-reason"}},
+			result:           local,
+			expectedFailures: []string{`expected no response header "x-reason", got "no token"`},
+		},
+		"request body": {
+			expect:           Expect{RequestBody: stringPtr("other")},
+			result:           upstream,
+			expectedFailures: []string{`expected request body "other", got "request"`},
+		},
+		"logs": {
+			expect:           Expect{Logs: []string{"authenticated", "bob"}},
+			result:           upstream,
+			expectedFailures: []string{`expected a log message with "bob"`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expectedFailures, tCase.expect.check(tCase.result, logs))
+		})
+	}
+}
+
+func TestExpectCheckStart(t *testing.T) {
+	logs := []host.LogEntry{{Level: host.LogLevelError, Message: "invalid configuration: missing key"}}
+
+	for name, tCase := range map[string]struct {
+		expect           Expect
+		started          bool
+		expectedFailures []string
+	}{
+		"started":               {started: true},
+		"rejected as expected":  {expect: Expect{ConfigRejected: true, Logs: []string{"missing key"}}, started: false},
+		"unexpected rejection":  {started: false, expectedFailures: []string{"the plugin configuration was rejected"}},
+		"unexpected acceptance": {expect: Expect{ConfigRejected: true}, started: true, expectedFailures: []string{"expected the plugin configuration to be rejected"}},
+		"missing logs": {
+			expect:           Expect{ConfigRejected: true, Logs: []string{"invalid json"}},
+			started:          false,
+			expectedFailures: []string{`expected a log message with "invalid json"`},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expectedFailures, tCase.expect.checkStart(tCase.started, logs))
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package suite
+
+import (
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JUnit XML elements, as understood by most CI systems
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Time       string           `xml:"time,attr"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	TestCases  []junitTestCase  `xml:"testcase"`
+}
+
+type junitProperties struct {
+	Properties []junitProperty `xml:"property"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results of some suites as a JUnit XML report
+func WriteJUnit(w io.Writer, results []*Result) error {
+	report := junitTestSuites{}
+	var total time.Duration
+
+	for _, r := range results {
+		suite := junitTestSuite{
+			Name: r.Name,
+			Time: seconds(r.Duration),
+		}
+		if r.Module != "" {
+			suite.Properties = &junitProperties{Properties: []junitProperty{{Name: "module", Value: r.Module}}}
+		}
+
+		for _, t := range r.Tests {
+			tc := junitTestCase{
+				Name:      t.Name,
+				ClassName: r.Name,
+				Time:      seconds(t.Duration),
+			}
+			switch {
+			case t.Err != nil:
+				tc.Error = &junitMessage{Message: t.Err.Error(), Text: t.Err.Error()}
+				suite.Errors++
+			case len(t.Failures) > 0:
+				tc.Failure = &junitMessage{Message: t.Failures[0], Text: strings.Join(t.Failures, "\n")}
+				suite.Failures++
+			}
+			if len(t.Logs) > 0 {
+				lines := make([]string, 0, len(t.Logs))
+				for _, l := range t.Logs {
+					lines = append(lines, l.String())
+				}
+				tc.SystemOut = strings.Join(lines, "\n")
+			}
+			suite.TestCases = append(suite.TestCases, tc)
+		}
+		suite.Tests = len(suite.TestCases)
+
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Errors += suite.Errors
+		report.Suites = append(report.Suites, suite)
+		total += r.Duration
+	}
+	report.Time = seconds(total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// seconds formats a duration in seconds, as in JUnit reports
+func seconds(d time.Duration) string {
+	return strconv.FormatFloat(d.Seconds(), 'f', 3, 64)
+}
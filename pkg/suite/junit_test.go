@@ -0,0 +1,57 @@
+package suite
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+func TestWriteJUnit(t *testing.T) {
+	results := []*Result{
+		{
+			Name:     "auth",
+			Module:   "/tmp/auth.wasm",
+			Duration: 1500 * time.Millisecond,
+			Tests: []TestResult{
+				{Name: "accepts valid tokens", Duration: 2 * time.Millisecond},
+				{
+					Name:     "rejects invalid tokens",
+					Duration: 3 * time.Millisecond,
+					Failures: []string{"expected status 403, got 200", `expected body "denied", got ""`},
+					Logs:     []host.LogEntry{{Level: host.LogLevelWarn, Message: "token <expired> & invalid"}},
+				},
+				{
+					Name:     "survives long headers",
+					Duration: time.Second,
+					Err:      errors.New("proxy_on_request_headers trapped: timeout"),
+				},
+			},
+		},
+		{
+			Name:     "empty",
+			Duration: 0,
+		},
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteJUnit(&buf, results))
+
+	golden := filepath.Join("testdata", "junit.xml")
+	if *update {
+		require.NoError(t, os.WriteFile(golden, buf.Bytes(), 0o644))
+	}
+	expected, err := os.ReadFile(golden)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), buf.String())
+}
@@ -0,0 +1,241 @@
+// Package suite runs declarative test suites for Proxy-Wasm extensions: YAML files
+// with some plugin configuration, the requests sent to the extension and the
+// expected outcomes (local responses, modified headers and bodies, logs...).
+//
+// Tests run against the compiled Wasm module in the embedded host (see pkg/host),
+// so they do not depend on the language or the SDK used for writing the extension.
+package suite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"sigs.k8s.io/yaml"
+
+	"github.com/inercia/proxy-wasm-oci/pkg/host"
+)
+
+// Suite is a list of tests for an extension
+type Suite struct {
+	// Name is the name of the suite (the name of the file by default)
+	Name string `json:"name,omitempty"`
+	// Module is the extension tested: a Wasm file (relative to the suite) or a
+	// reference (like "oci://myregistry.com/myrepo:1.0.0")
+	Module string `json:"module,omitempty"`
+	// Config is the plugin configuration used by all the tests
+	Config string `json:"config,omitempty"`
+	// VMConfig is the VM configuration used by all the tests
+	VMConfig string `json:"vmConfig,omitempty"`
+	// Properties are the properties used by all the tests
+	Properties map[string]string `json:"properties,omitempty"`
+	// RootID is the root ID of the plugin
+	RootID string `json:"rootID,omitempty"`
+	// LogLevel is the log level reported to the extension (like "debug")
+	LogLevel string `json:"logLevel,omitempty"`
+	// Timeout is the maximum time for every callback (like "1s")
+	Timeout string `json:"timeout,omitempty"`
+	// Tests are the tests, run in order
+	Tests []Test `json:"tests"`
+
+	// Filename is the file where the suite was loaded from
+	Filename string `json:"-"`
+}
+
+// Test is a request (and a response from the upstream) sent to the extension, with the expected outcome
+type Test struct {
+	Name string `json:"name"`
+	// Config overrides the plugin configuration of the suite
+	Config *string `json:"config,omitempty"`
+	// Properties are added to the properties of the suite
+	Properties map[string]string `json:"properties,omitempty"`
+
+	host.ExchangeSpec
+
+	Expect Expect `json:"expect"`
+}
+
+// Load loads a suite from a YAML file
+func Load(filename string) (*Suite, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Suite{}
+	if err := yaml.UnmarshalStrict(data, s); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+	s.Filename = filename
+	if s.Name == "" {
+		s.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	if s.Module != "" && !strings.Contains(s.Module, "://") && !filepath.IsAbs(s.Module) {
+		s.Module = filepath.Join(filepath.Dir(filename), s.Module)
+	}
+
+	if _, err := s.hostOptions(); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(s.Tests) == 0 {
+		return nil, fmt.Errorf("%s: no tests", filename)
+	}
+	seen := map[string]bool{}
+	for i, t := range s.Tests {
+		if t.Name == "" {
+			return nil, fmt.Errorf("%s: test #%d has no name", filename, i+1)
+		}
+		if seen[t.Name] {
+			return nil, fmt.Errorf("%s: duplicate test %q", filename, t.Name)
+		}
+		seen[t.Name] = true
+	}
+
+	return s, nil
+}
+
+// hostOptions returns the options for the host set in the suite
+func (s *Suite) hostOptions() ([]host.Option, error) {
+	var opts []host.Option
+	if s.RootID != "" {
+		opts = append(opts, host.WithRootID(s.RootID))
+	}
+	if s.LogLevel != "" {
+		level, err := host.ParseLogLevel(s.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, host.WithLogLevel(level))
+	}
+	if s.Timeout != "" {
+		timeout, err := time.ParseDuration(s.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeout: %w", err)
+		}
+		opts = append(opts, host.WithTimeout(timeout))
+	}
+	return opts, nil
+}
+
+// Result is the result of running a suite
+type Result struct {
+	Name     string
+	Module   string
+	Duration time.Duration
+	Tests    []TestResult
+}
+
+// Failed returns the number of tests that did not pass
+func (r *Result) Failed() int {
+	n := 0
+	for _, t := range r.Tests {
+		if !t.Passed() {
+			n++
+		}
+	}
+	return n
+}
+
+// TestResult is the result of running a test
+type TestResult struct {
+	Name     string
+	Duration time.Duration
+	// Failures are the expectations that were not met
+	Failures []string
+	// Err is set when the test could not be run (e.g. the extension trapped)
+	Err error
+	// Logs are the messages logged by the extension
+	Logs []host.LogEntry
+}
+
+// Passed returns true when the test passed
+func (r *TestResult) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+// Run runs all the tests of the suite against an extension. Every test runs in a new host,
+// started with the configuration of the test, so tests are independent of each other.
+// The options given override the options of the host set in the suite (like the timeout).
+func (s *Suite) Run(ctx context.Context, data []byte, opts ...host.Option) *Result {
+	start := time.Now()
+	res := &Result{Name: s.Name, Module: s.Module}
+
+	suiteOpts, err := s.hostOptions()
+	if err != nil {
+		for _, t := range s.Tests {
+			res.Tests = append(res.Tests, TestResult{Name: t.Name, Err: err})
+		}
+		res.Duration = time.Since(start)
+		return res
+	}
+
+	// the module is compiled only once for all the tests
+	cache := wazero.NewCompilationCache()
+	defer cache.Close(ctx)
+	opts = append(append([]host.Option{host.WithCompilationCache(cache)}, suiteOpts...), opts...)
+
+	for _, t := range s.Tests {
+		t := t
+		res.Tests = append(res.Tests, s.runTest(ctx, data, &t, opts...))
+	}
+	res.Duration = time.Since(start)
+	return res
+}
+
+// runTest runs a test in a new host
+func (s *Suite) runTest(ctx context.Context, data []byte, t *Test, opts ...host.Option) (res TestResult) {
+	start := time.Now()
+	res.Name = t.Name
+	defer func() { res.Duration = time.Since(start) }()
+
+	config := s.Config
+	if t.Config != nil {
+		config = *t.Config
+	}
+	properties := map[string]string{}
+	for k, v := range s.Properties {
+		properties[k] = v
+	}
+	for k, v := range t.Properties {
+		properties[k] = v
+	}
+
+	h, err := host.New(ctx, data, append([]host.Option{
+		host.WithPluginConfiguration([]byte(config)),
+		host.WithVMConfiguration([]byte(s.VMConfig)),
+		host.WithProperties(properties),
+	}, opts...)...)
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	defer h.Close(ctx)
+
+	err = h.Start(ctx)
+	res.Logs = h.Logs()
+	if errors.Is(err, host.ErrFailed) {
+		res.Failures = t.Expect.checkStart(false, res.Logs)
+		return res
+	} else if err != nil {
+		res.Err = err
+		return res
+	}
+	if t.Expect.ConfigRejected {
+		res.Failures = t.Expect.checkStart(true, res.Logs)
+		return res
+	}
+
+	result, err := h.HTTP(ctx, t.Request.HTTPRequest(), t.Response.HTTPResponse())
+	res.Logs = h.Logs()
+	if err != nil {
+		res.Err = err
+		return res
+	}
+	res.Failures = t.Expect.check(result, res.Logs)
+	return res
+}
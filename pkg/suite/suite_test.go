@@ -0,0 +1,63 @@
+package suite
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		content        string
+		expectedName   string
+		expectedModule string
+		// relativeModule is true when the module is expected relative to the suite
+		relativeModule bool
+		expectedMsg    string
+	}{
+		"relative module": {
+			content:        "module: main.wasm\ntests:\n- name: a\n",
+			expectedName:   "suite",
+			expectedModule: "main.wasm",
+			relativeModule: true,
+		},
+		"remote module": {
+			content:        "name: auth\nmodule: oci://myregistry.com/auth:1.0.0\ntests:\n- name: a\n",
+			expectedName:   "auth",
+			expectedModule: "oci://myregistry.com/auth:1.0.0",
+		},
+		"host options": {
+			content:      "timeout: 2s\nlogLevel: debug\nrootID: auth\ntests:\n- name: a\n",
+			expectedName: "suite",
+		},
+		"no tests":          {content: "module: main.wasm\n", expectedMsg: "no tests"},
+		"no test name":      {content: "tests:\n- request: {path: /}\n", expectedMsg: "test #1 has no name"},
+		"duplicate test":    {content: "tests:\n- name: a\n- name: a\n", expectedMsg: `duplicate test "a"`},
+		"unknown field":     {content: "tests:\n- name: a\n  expect: {stauts: 200}\n", expectedMsg: "unknown field"},
+		"invalid timeout":   {content: "timeout: soon\ntests:\n- name: a\n", expectedMsg: "invalid timeout"},
+		"invalid log level": {content: "logLevel: loud\ntests:\n- name: a\n", expectedMsg: `unknown log level "loud"`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "suite.yaml")
+			require.NoError(t, os.WriteFile(filename, []byte(tCase.content), 0o644))
+
+			s, err := Load(filename)
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expectedName, s.Name)
+			assert.Equal(t, filename, s.Filename)
+			expectedModule := tCase.expectedModule
+			if tCase.relativeModule {
+				expectedModule = filepath.Join(dir, expectedModule)
+			}
+			assert.Equal(t, expectedModule, s.Module)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUntar(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		headers []*tar.Header
+		// expected are the files expected in the directory
+		expected      []string
+		expectedError bool
+	}{
+		"files and directories": {
+			headers:  []*tar.Header{{Name: "dir/", Typeflag: tar.TypeDir}, {Name: "dir/file"}, {Name: "other/file"}, {Name: "file"}},
+			expected: []string{"dir/file", "file", "other/file"},
+		},
+		"current directory": {
+			headers:  []*tar.Header{{Name: "./file"}, {Name: "dir/./other"}},
+			expected: []string{"dir/other", "file"},
+		},
+		"parent directory inside the directory": {
+			headers:       []*tar.Header{{Name: "dir/../file"}},
+			expectedError: true,
+		},
+		"parent directory": {
+			headers:       []*tar.Header{{Name: "../file"}},
+			expectedError: true,
+		},
+		"parent directory in the middle": {
+			headers:       []*tar.Header{{Name: "dir/../../file"}},
+			expectedError: true,
+		},
+		"only the parent directory": {
+			headers:       []*tar.Header{{Name: "..", Typeflag: tar.TypeDir}},
+			expectedError: true,
+		},
+		"absolute path": {
+			headers:       []*tar.Header{{Name: "/tmp/file"}},
+			expectedError: true,
+		},
+		"symbolic link": {
+			headers:  []*tar.Header{{Name: "link", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"}, {Name: "file"}},
+			expected: []string{"file"},
+		},
+		"hard link": {
+			headers:  []*tar.Header{{Name: "link", Typeflag: tar.TypeLink, Linkname: "../file"}},
+			expected: nil,
+		},
+		"file after an invalid one": {
+			headers:       []*tar.Header{{Name: "file"}, {Name: "../other"}},
+			expectedError: true,
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tw := tar.NewWriter(&buf)
+			for _, hdr := range tCase.headers {
+				data := []byte(hdr.Name)
+				if hdr.Typeflag == 0 {
+					hdr.Typeflag = tar.TypeReg
+				}
+				if hdr.Typeflag == tar.TypeReg {
+					hdr.Size = int64(len(data))
+				}
+				hdr.Mode = 0o644
+				require.NoError(t, tw.WriteHeader(hdr))
+				if hdr.Typeflag == tar.TypeReg {
+					_, err := tw.Write(data)
+					require.NoError(t, err)
+				}
+			}
+			require.NoError(t, tw.Close())
+
+			// extract in a subdirectory, so we can detect files written outside it
+			parent := t.TempDir()
+			dir := filepath.Join(parent, "dir")
+			require.NoError(t, os.Mkdir(dir, 0o755))
+
+			err := Untar(&buf, dir)
+			if tCase.expectedError {
+				assert.ErrorContains(t, err, "invalid path in archive")
+			} else {
+				require.NoError(t, err)
+			}
+
+			var files []string
+			require.NoError(t, filepath.Walk(parent, func(path string, info os.FileInfo, err error) error {
+				if err != nil || info.IsDir() {
+					return err
+				}
+				rel, err := filepath.Rel(dir, path)
+				if err != nil {
+					return err
+				}
+				files = append(files, filepath.ToSlash(rel))
+				return nil
+			}))
+			if tCase.expectedError {
+				for _, f := range files {
+					assert.NotContains(t, f, "..", "file extracted outside the directory")
+				}
+				return
+			}
+			assert.Equal(t, tCase.expected, files)
+		})
+	}
+}
+
+func TestTarDir(t *testing.T) {
+	src := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(src, "dir", "empty"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "file"), []byte("file"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(src, "dir", "file"), []byte("dir/file"), 0o644))
+	require.NoError(t, os.Symlink("file", filepath.Join(src, "link")))
+
+	var buf bytes.Buffer
+	require.NoError(t, TarDir(&buf, src))
+
+	dst := t.TempDir()
+	require.NoError(t, Untar(&buf, dst))
+
+	data, err := os.ReadFile(filepath.Join(dst, "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "file", string(data))
+	data, err = os.ReadFile(filepath.Join(dst, "dir", "file"))
+	require.NoError(t, err)
+	assert.Equal(t, "dir/file", string(data))
+	assert.DirExists(t, filepath.Join(dst, "dir", "empty"))
+	assert.NoFileExists(t, filepath.Join(dst, "link"))
+}
@@ -0,0 +1,151 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ABIVersion is a version of the Proxy-Wasm ABI
+type ABIVersion string
+
+const (
+	ABIVersion010 ABIVersion = "0.1.0"
+	ABIVersion020 ABIVersion = "0.2.0"
+	ABIVersion021 ABIVersion = "0.2.1"
+)
+
+// abiMarkerPrefix is the prefix of the function exported by Proxy-Wasm modules for
+// declaring the ABI version they use (e.g. "proxy_abi_version_0_2_1")
+const abiMarkerPrefix = "proxy_abi_version_"
+
+// ABIVersions are the known Proxy-Wasm ABI versions, from the oldest
+var ABIVersions = []ABIVersion{ABIVersion010, ABIVersion020, ABIVersion021}
+
+// Marker returns the name of the function exported for declaring an ABI version
+func (v ABIVersion) Marker() string {
+	return abiMarkerPrefix + strings.ReplaceAll(string(v), ".", "_")
+}
+
+const (
+	// HostModule is the module Proxy-Wasm host functions are imported from
+	HostModule = "env"
+
+	// hostFunctionPrefix is the prefix of all the Proxy-Wasm host functions
+	hostFunctionPrefix = "proxy_"
+
+	// foreignFunctionImport is the host function for calling the foreign functions of the host
+	foreignFunctionImport = "proxy_call_foreign_function"
+)
+
+// WASIModules are the modules WASI functions are imported from
+var WASIModules = []string{"wasi_snapshot_preview1", "wasi_unstable"}
+
+// ErrNotProxyWasm is returned for Wasm modules that are not Proxy-Wasm extensions
+var ErrNotProxyWasm = errors.New("not a Proxy-Wasm module (no proxy_abi_version_* export)")
+
+// ProxyWasm is the Proxy-Wasm information of a module
+type ProxyWasm struct {
+	// ABIVersion is the Proxy-Wasm ABI the module has been built for
+	ABIVersion ABIVersion `json:"abiVersion"`
+	// HostFunctions are the Proxy-Wasm host functions the module needs, sorted
+	HostFunctions []string `json:"hostFunctions"`
+	// WASIFunctions are the WASI functions the module needs, sorted
+	WASIFunctions []string `json:"wasiFunctions,omitempty"`
+	// ForeignFunctions are the known foreign functions the module may call, sorted (see Module.ForeignFunctions)
+	ForeignFunctions []string `json:"foreignFunctions,omitempty"`
+	// OtherImports are the imports that are neither Proxy-Wasm nor WASI functions (as "module.name")
+	OtherImports []string `json:"otherImports,omitempty"`
+	// Callbacks are the Proxy-Wasm callbacks exported by the module, sorted
+	Callbacks []string `json:"callbacks"`
+}
+
+// ABIVersions returns the Proxy-Wasm ABI versions declared by the module, with the
+// unknown ones last
+func (m *Module) ABIVersions() []ABIVersion {
+	var versions []ABIVersion
+	for _, e := range m.Exports {
+		if e.Kind != KindFunction || !strings.HasPrefix(e.Name, abiMarkerPrefix) {
+			continue
+		}
+		versions = append(versions, ABIVersion(strings.ReplaceAll(strings.TrimPrefix(e.Name, abiMarkerPrefix), "_", ".")))
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return abiIndex(versions[i]) < abiIndex(versions[j])
+	})
+	return versions
+}
+
+// ProxyWasm returns the Proxy-Wasm information of the module, or ErrNotProxyWasm
+// when the module does not declare a Proxy-Wasm ABI version. The foreign functions
+// are guessed from the ones of the KnownHosts (see Hosts.ProxyWasm for other hosts).
+func (m *Module) ProxyWasm() (*ProxyWasm, error) {
+	return m.proxyWasm(KnownHosts.KnownForeignFunctions())
+}
+
+// proxyWasm returns the Proxy-Wasm information of the module, guessing the
+// foreign functions it may call from a list of known ones.
+func (m *Module) proxyWasm(foreignFunctions []string) (*ProxyWasm, error) {
+	versions := m.ABIVersions()
+	switch {
+	case len(versions) == 0:
+		return nil, ErrNotProxyWasm
+	case len(versions) > 1:
+		return nil, fmt.Errorf("module declares several Proxy-Wasm ABI versions: %v", versions)
+	case abiIndex(versions[0]) == len(ABIVersions):
+		return nil, fmt.Errorf("unknown Proxy-Wasm ABI version %s", versions[0])
+	}
+
+	pw := &ProxyWasm{ABIVersion: versions[0]}
+	for _, imp := range m.Imports {
+		if imp.Kind != KindFunction {
+			continue
+		}
+		switch {
+		case imp.Module == HostModule && strings.HasPrefix(imp.Name, hostFunctionPrefix):
+			pw.HostFunctions = append(pw.HostFunctions, imp.Name)
+		case isWASIModule(imp.Module):
+			pw.WASIFunctions = append(pw.WASIFunctions, imp.Name)
+		default:
+			pw.OtherImports = append(pw.OtherImports, imp.Module+"."+imp.Name)
+		}
+	}
+	for _, e := range m.Exports {
+		if e.Kind == KindFunction && strings.HasPrefix(e.Name, hostFunctionPrefix) && !strings.HasPrefix(e.Name, abiMarkerPrefix) {
+			pw.Callbacks = append(pw.Callbacks, e.Name)
+		}
+	}
+
+	sort.Strings(pw.HostFunctions)
+	for _, name := range pw.HostFunctions {
+		if name == foreignFunctionImport {
+			pw.ForeignFunctions = m.ForeignFunctions(foreignFunctions)
+		}
+	}
+	sort.Strings(pw.WASIFunctions)
+	sort.Strings(pw.Callbacks)
+
+	return pw, nil
+}
+
+// abiIndex returns the position of an ABI version in the known versions
+// (or the number of known versions when it is unknown)
+func abiIndex(v ABIVersion) int {
+	for i, known := range ABIVersions {
+		if v == known {
+			return i
+		}
+	}
+	return len(ABIVersions)
+}
+
+func isWASIModule(module string) bool {
+	for _, m := range WASIModules {
+		if module == m {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,113 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+)
+
+func TestABIVersions(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		exports  [][]byte
+		expected []ABIVersion
+	}{
+		"no marker":     {exports: [][]byte{wasmtest.Export("_start", wasmtest.KindFunction, 0)}},
+		"0.1.0":         {exports: [][]byte{wasmtest.Export("proxy_abi_version_0_1_0", wasmtest.KindFunction, 0)}, expected: []ABIVersion{ABIVersion010}},
+		"0.2.1":         {exports: [][]byte{wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0)}, expected: []ABIVersion{ABIVersion021}},
+		"not function":  {exports: [][]byte{wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindGlobal, 0)}},
+		"sorted":        {exports: [][]byte{wasmtest.Export("proxy_abi_version_9_9_9", wasmtest.KindFunction, 0), wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0), wasmtest.Export("proxy_abi_version_0_1_0", wasmtest.KindFunction, 0)}, expected: []ABIVersion{ABIVersion010, ABIVersion021, "9.9.9"}},
+		"unknown":       {exports: [][]byte{wasmtest.Export("proxy_abi_version_1_0_0", wasmtest.KindFunction, 0)}, expected: []ABIVersion{"1.0.0"}},
+		"other exports": {exports: [][]byte{wasmtest.Export("memory", wasmtest.KindMemory, 0), wasmtest.Export("proxy_abi_version_0_2_0", wasmtest.KindFunction, 0), wasmtest.Export("proxy_on_tick", wasmtest.KindFunction, 0)}, expected: []ABIVersion{ABIVersion020}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := Parse(wasmtest.Module(wasmtest.Exports(tCase.exports...)))
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, m.ABIVersions())
+		})
+	}
+}
+
+func TestProxyWasm(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		imports       [][]byte
+		exports       [][]byte
+		expected      *ProxyWasm
+		expectedError error
+		expectedMsg   string
+	}{
+		"Proxy-Wasm module": {
+			imports: [][]byte{
+				wasmtest.ImportFunc("env", "proxy_log", 0),
+				wasmtest.ImportFunc("env", "proxy_get_header_map_value", 0),
+				wasmtest.ImportFunc("wasi_snapshot_preview1", "fd_write", 0),
+				wasmtest.ImportFunc("wasi_snapshot_preview1", "clock_time_get", 0),
+				wasmtest.ImportFunc("env", "abort", 0),
+			},
+			exports: [][]byte{
+				wasmtest.Export("memory", wasmtest.KindMemory, 0),
+				wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0),
+				wasmtest.Export("proxy_on_request_headers", wasmtest.KindFunction, 0),
+				wasmtest.Export("proxy_on_context_create", wasmtest.KindFunction, 0),
+				wasmtest.Export("malloc", wasmtest.KindFunction, 0),
+			},
+			expected: &ProxyWasm{
+				ABIVersion:    ABIVersion021,
+				HostFunctions: []string{"proxy_get_header_map_value", "proxy_log"},
+				WASIFunctions: []string{"clock_time_get", "fd_write"},
+				OtherImports:  []string{"env.abort"},
+				Callbacks:     []string{"proxy_on_context_create", "proxy_on_request_headers"},
+			},
+		},
+		"old WASI module": {
+			imports:  [][]byte{wasmtest.ImportFunc("wasi_unstable", "fd_write", 0)},
+			exports:  [][]byte{wasmtest.Export("proxy_abi_version_0_1_0", wasmtest.KindFunction, 0)},
+			expected: &ProxyWasm{ABIVersion: ABIVersion010, WASIFunctions: []string{"fd_write"}},
+		},
+		"host functions from other module": {
+			imports:  [][]byte{wasmtest.ImportFunc("other", "proxy_log", 0)},
+			exports:  [][]byte{wasmtest.Export("proxy_abi_version_0_2_0", wasmtest.KindFunction, 0)},
+			expected: &ProxyWasm{ABIVersion: ABIVersion020, OtherImports: []string{"other.proxy_log"}},
+		},
+		"not Proxy-Wasm": {
+			exports:       [][]byte{wasmtest.Export("_start", wasmtest.KindFunction, 0)},
+			expectedError: ErrNotProxyWasm,
+		},
+		"several ABI versions": {
+			exports:     [][]byte{wasmtest.Export("proxy_abi_version_0_1_0", wasmtest.KindFunction, 0), wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0)},
+			expectedMsg: "module declares several Proxy-Wasm ABI versions",
+		},
+		"unknown ABI version": {
+			exports:     [][]byte{wasmtest.Export("proxy_abi_version_1_0_0", wasmtest.KindFunction, 0)},
+			expectedMsg: "unknown Proxy-Wasm ABI version 1.0.0",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var sections [][]byte
+			if tCase.imports != nil {
+				sections = append(sections, wasmtest.Imports(tCase.imports...))
+			}
+			sections = append(sections, wasmtest.Exports(tCase.exports...))
+			m, err := Parse(wasmtest.Module(sections...))
+			require.NoError(t, err)
+
+			pw, err := m.ProxyWasm()
+			switch {
+			case tCase.expectedError != nil:
+				assert.ErrorIs(t, err, tCase.expectedError)
+			case tCase.expectedMsg != "":
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tCase.expected, pw)
+			}
+		})
+	}
+}
+
+func TestABIVersionMarker(t *testing.T) {
+	assert.Equal(t, "proxy_abi_version_0_2_1", ABIVersion021.Marker())
+	assert.Equal(t, "proxy_abi_version_0_1_0", ABIVersion010.Marker())
+}
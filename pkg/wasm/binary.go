@@ -0,0 +1,398 @@
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"unicode/utf8"
+)
+
+// Magic is the header every Wasm binary starts with ("\0asm")
+var Magic = []byte{0x00, 0x61, 0x73, 0x6d}
+
+// BinaryVersion is the version of the Wasm binary format supported
+const BinaryVersion = 1
+
+var (
+	// ErrNotWasm is returned when the data is not a Wasm binary
+	ErrNotWasm = errors.New("not a Wasm binary (bad magic header)")
+
+	// ErrUnsupportedVersion is returned for Wasm binaries with an unsupported version
+	// of the binary format (like Wasm components)
+	ErrUnsupportedVersion = errors.New("unsupported Wasm binary version")
+)
+
+// section IDs, as in https://webassembly.github.io/spec/core/binary/modules.html#sections
+const (
+	sectionCustom byte = 0
+	sectionImport byte = 2
+	sectionMemory byte = 5
+	sectionExport byte = 7
+	sectionData   byte = 11
+)
+
+// ExternalKind is the kind of an import or export
+type ExternalKind byte
+
+const (
+	KindFunction ExternalKind = 0
+	KindTable    ExternalKind = 1
+	KindMemory   ExternalKind = 2
+	KindGlobal   ExternalKind = 3
+	KindTag      ExternalKind = 4
+)
+
+func (k ExternalKind) String() string {
+	switch k {
+	case KindFunction:
+		return "func"
+	case KindTable:
+		return "table"
+	case KindMemory:
+		return "memory"
+	case KindGlobal:
+		return "global"
+	case KindTag:
+		return "tag"
+	}
+	return fmt.Sprintf("unknown(%d)", byte(k))
+}
+
+// MarshalText implements encoding.TextMarshaler
+func (k ExternalKind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// Import is something (usually a function) a module imports from the host
+type Import struct {
+	Module string       `json:"module"`
+	Name   string       `json:"name"`
+	Kind   ExternalKind `json:"kind"`
+}
+
+// Export is something a module exports to the host
+type Export struct {
+	Name string       `json:"name"`
+	Kind ExternalKind `json:"kind"`
+}
+
+// Memory is a linear memory, with sizes in pages (of 64KiB)
+type Memory struct {
+	Min      uint64 `json:"min"`
+	Max      uint64 `json:"max,omitempty"`
+	HasMax   bool   `json:"hasMax"`
+	Shared   bool   `json:"shared,omitempty"`
+	Imported bool   `json:"imported,omitempty"`
+}
+
+// CustomSection is a custom section, like "name" or "producers"
+type CustomSection struct {
+	Name string `json:"name"`
+	Data []byte `json:"-"`
+}
+
+// Module is the information obtained from a Wasm binary
+type Module struct {
+	// Version is the version of the binary format
+	Version uint32 `json:"version"`
+	// Size is the size of the binary, in bytes
+	Size int `json:"size"`
+	// Imports are the imports, in order
+	Imports []Import `json:"imports"`
+	// Exports are the exports, in order
+	Exports []Export `json:"exports"`
+	// Memories are the memories (both imported and defined in the module)
+	Memories []Memory `json:"memories"`
+	// CustomSections are the custom sections, in order
+	CustomSections []CustomSection `json:"customSections"`
+
+	// data is the contents of the data section (the data segments), not parsed
+	data []byte
+}
+
+// IsWasm returns true when some data starts with the header of a Wasm binary
+func IsWasm(data []byte) bool {
+	return len(data) >= 8 && bytes.Equal(data[:4], Magic) &&
+		binary.LittleEndian.Uint32(data[4:8]) == BinaryVersion
+}
+
+// ParseFile parses a Wasm binary in a file
+func ParseFile(filename string) (*Module, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse parses a Wasm binary, obtaining its imports, exports, memories and custom sections
+// (and keeping the data section, see ForeignFunctions). Other sections (like the code) are
+// skipped without being validated.
+func Parse(data []byte) (*Module, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], Magic) {
+		return nil, ErrNotWasm
+	}
+
+	m := &Module{
+		Version: binary.LittleEndian.Uint32(data[4:8]),
+		Size:    len(data),
+	}
+	if m.Version != BinaryVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, m.Version)
+	}
+
+	r := &reader{data: data, pos: 8}
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, fmt.Errorf("when reading size of section %d: %w", id, err)
+		}
+		start := r.pos
+		content, err := r.bytes(int(size))
+		if err != nil {
+			return nil, fmt.Errorf("when reading section %d: %w", id, err)
+		}
+
+		sr := &reader{data: content}
+		switch id {
+		case sectionCustom:
+			err = m.parseCustomSection(sr)
+		case sectionImport:
+			err = m.parseImportSection(sr)
+		case sectionMemory:
+			err = m.parseMemorySection(sr)
+		case sectionExport:
+			err = m.parseExportSection(sr)
+		case sectionData:
+			m.data = content
+		}
+		if err != nil {
+			return nil, fmt.Errorf("when parsing section %d at offset %d: %w", id, start, err)
+		}
+	}
+
+	return m, nil
+}
+
+func (m *Module) parseCustomSection(r *reader) error {
+	name, err := r.name()
+	if err != nil {
+		return err
+	}
+	m.CustomSections = append(m.CustomSections, CustomSection{Name: name, Data: r.data[r.pos:]})
+	return nil
+}
+
+func (m *Module) parseImportSection(r *reader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		module, err := r.name()
+		if err != nil {
+			return err
+		}
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+
+		switch ExternalKind(kind) {
+		case KindFunction:
+			_, err = r.u32() // type index
+		case KindTable:
+			if _, err = r.byte(); err == nil { // reference type
+				_, err = r.limits()
+			}
+		case KindMemory:
+			var mem Memory
+			if mem, err = r.limits(); err == nil {
+				mem.Imported = true
+				m.Memories = append(m.Memories, mem)
+			}
+		case KindGlobal:
+			_, err = r.bytes(2) // value type and mutability
+		case KindTag:
+			if _, err = r.byte(); err == nil { // attribute
+				_, err = r.u32() // type index
+			}
+		default:
+			return fmt.Errorf("unknown import kind %d for %s.%s", kind, module, name)
+		}
+		if err != nil {
+			return err
+		}
+
+		m.Imports = append(m.Imports, Import{Module: module, Name: name, Kind: ExternalKind(kind)})
+	}
+
+	return nil
+}
+
+func (m *Module) parseMemorySection(r *reader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		mem, err := r.limits()
+		if err != nil {
+			return err
+		}
+		m.Memories = append(m.Memories, mem)
+	}
+
+	return nil
+}
+
+func (m *Module) parseExportSection(r *reader) error {
+	count, err := r.u32()
+	if err != nil {
+		return err
+	}
+
+	for i := uint32(0); i < count; i++ {
+		name, err := r.name()
+		if err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		if _, err := r.u32(); err != nil { // index
+			return err
+		}
+		m.Exports = append(m.Exports, Export{Name: name, Kind: ExternalKind(kind)})
+	}
+
+	return nil
+}
+
+// HasExport returns true when the module exports something with a name (and kind)
+func (m *Module) HasExport(name string, kind ExternalKind) bool {
+	for _, e := range m.Exports {
+		if e.Name == name && e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// CustomSection returns the (first) custom section with a name
+func (m *Module) CustomSection(name string) (*CustomSection, bool) {
+	for i := range m.CustomSections {
+		if m.CustomSections[i].Name == name {
+			return &m.CustomSections[i], true
+		}
+	}
+	return nil, false
+}
+
+// reader reads the values in a Wasm binary
+type reader struct {
+	data []byte
+	pos  int
+}
+
+func (r *reader) eof() bool {
+	return r.pos >= len(r.data)
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.eof() {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n int) ([]byte, error) {
+	if n < 0 || n > len(r.data)-r.pos {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := r.data[r.pos : r.pos+n]
+	r.pos += n
+	return b, nil
+}
+
+// u64 reads an unsigned LEB128 integer
+func (r *reader) u64() (uint64, error) {
+	var result uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+	return 0, errors.New("integer representation too long")
+}
+
+// u32 reads an unsigned LEB128 integer that must fit in 32 bits
+func (r *reader) u32() (uint32, error) {
+	v, err := r.u64()
+	if err != nil {
+		return 0, err
+	}
+	if v > 0xffffffff {
+		return 0, errors.New("integer too large")
+	}
+	return uint32(v), nil
+}
+
+// name reads a name, a UTF-8 string prefixed by its length
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(int(n))
+	if err != nil {
+		return "", err
+	}
+	if !utf8.Valid(b) {
+		return "", errors.New("invalid UTF-8 in name")
+	}
+	return string(b), nil
+}
+
+// limits reads the limits of a memory or table
+func (r *reader) limits() (Memory, error) {
+	flags, err := r.byte()
+	if err != nil {
+		return Memory{}, err
+	}
+
+	var mem Memory
+	if mem.Min, err = r.u64(); err != nil {
+		return Memory{}, err
+	}
+	if flags&0x01 != 0 {
+		if mem.Max, err = r.u64(); err != nil {
+			return Memory{}, err
+		}
+		mem.HasMax = true
+	}
+	mem.Shared = flags&0x02 != 0
+
+	return mem, nil
+}
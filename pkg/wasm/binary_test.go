@@ -0,0 +1,148 @@
+package wasm
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+)
+
+func TestParse(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		data             []byte
+		expectedImports  []Import
+		expectedExports  []Export
+		expectedMemories []Memory
+		expectedCustom   []string
+	}{
+		"empty module": {
+			data: wasmtest.Module(),
+		},
+		"imports and exports": {
+			data: wasmtest.Module(
+				wasmtest.Imports(wasmtest.ImportFunc("env", "proxy_log", 0), wasmtest.ImportFunc("wasi_snapshot_preview1", "fd_write", 0)),
+				wasmtest.Exports(wasmtest.Export("memory", wasmtest.KindMemory, 0), wasmtest.Export("proxy_on_memory_allocate", wasmtest.KindFunction, 0)),
+			),
+			expectedImports: []Import{
+				{Module: "env", Name: "proxy_log", Kind: KindFunction},
+				{Module: "wasi_snapshot_preview1", Name: "fd_write", Kind: KindFunction},
+			},
+			expectedExports: []Export{
+				{Name: "memory", Kind: KindMemory},
+				{Name: "proxy_on_memory_allocate", Kind: KindFunction},
+			},
+		},
+		"other kinds of imports": {
+			data: wasmtest.Module(wasmtest.Imports(
+				append(append(wasmtest.Name("env"), wasmtest.Name("table")...), byte(KindTable), 0x70, 0x00, 0x01),
+				append(append(wasmtest.Name("env"), wasmtest.Name("memory")...), byte(KindMemory), 0x01, 0x02, 0x10),
+				append(append(wasmtest.Name("env"), wasmtest.Name("global")...), byte(KindGlobal), 0x7f, 0x00),
+				append(append(wasmtest.Name("env"), wasmtest.Name("tag")...), byte(KindTag), 0x00, 0x00),
+			)),
+			expectedImports: []Import{
+				{Module: "env", Name: "table", Kind: KindTable},
+				{Module: "env", Name: "memory", Kind: KindMemory},
+				{Module: "env", Name: "global", Kind: KindGlobal},
+				{Module: "env", Name: "tag", Kind: KindTag},
+			},
+			expectedMemories: []Memory{{Min: 2, Max: 16, HasMax: true, Imported: true}},
+		},
+		"memories": {
+			data: wasmtest.Module(wasmtest.Section(sectionMemory, wasmtest.Vector([]byte{0x00, 0x11}, []byte{0x03, 0x01, 0x80, 0x80, 0x04}))),
+			expectedMemories: []Memory{
+				{Min: 17},
+				{Min: 1, Max: 65536, HasMax: true, Shared: true},
+			},
+		},
+		"custom sections": {
+			data: wasmtest.Module(
+				wasmtest.Custom("name", []byte{0x01, 0x02}),
+				wasmtest.Exports(wasmtest.Export("_start", wasmtest.KindFunction, 0)),
+				wasmtest.Custom("producers", nil),
+			),
+			expectedExports: []Export{{Name: "_start", Kind: KindFunction}},
+			expectedCustom:  []string{"name", "producers"},
+		},
+		"skipped sections": {
+			// a type section and a code section, not parsed
+			data:            wasmtest.Module(wasmtest.Section(1, []byte{0xff, 0xff}), wasmtest.Exports(wasmtest.Export("f", wasmtest.KindFunction, 0)), wasmtest.Section(10, []byte{0xff})),
+			expectedExports: []Export{{Name: "f", Kind: KindFunction}},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, err := Parse(tCase.data)
+			require.NoError(t, err)
+			assert.EqualValues(t, BinaryVersion, m.Version)
+			assert.Equal(t, len(tCase.data), m.Size)
+			assert.Equal(t, tCase.expectedImports, m.Imports)
+			assert.Equal(t, tCase.expectedExports, m.Exports)
+			assert.Equal(t, tCase.expectedMemories, m.Memories)
+
+			var custom []string
+			for _, s := range m.CustomSections {
+				custom = append(custom, s.Name)
+			}
+			assert.Equal(t, tCase.expectedCustom, custom)
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	valid := wasmtest.Module(wasmtest.Imports(wasmtest.ImportFunc("env", "proxy_log", 0)))
+
+	for name, tCase := range map[string]struct {
+		data          []byte
+		expectedError error
+		expectedMsg   string
+	}{
+		"empty":               {data: nil, expectedError: ErrNotWasm},
+		"only the magic":      {data: []byte{0x00, 0x61, 0x73, 0x6d}, expectedError: ErrNotWasm},
+		"bad magic":           {data: []byte("\x00ASM\x01\x00\x00\x00"), expectedError: ErrNotWasm},
+		"text format":         {data: []byte("(module)"), expectedError: ErrNotWasm},
+		"component":           {data: []byte{0x00, 0x61, 0x73, 0x6d, 0x0d, 0x00, 0x01, 0x00}, expectedError: ErrUnsupportedVersion},
+		"version 2":           {data: []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00}, expectedError: ErrUnsupportedVersion},
+		"truncated section":   {data: valid[:len(valid)-1], expectedError: io.ErrUnexpectedEOF},
+		"truncated size":      {data: wasmtest.Module([]byte{sectionImport, 0x80}), expectedError: io.ErrUnexpectedEOF},
+		"size too large":      {data: wasmtest.Module(append(append([]byte{sectionExport}, wasmtest.LEB(100)...), 0x00)), expectedError: io.ErrUnexpectedEOF},
+		"truncated import":    {data: wasmtest.Module(wasmtest.Section(sectionImport, wasmtest.Vector(wasmtest.Name("env")))), expectedError: io.ErrUnexpectedEOF},
+		"missing imports":     {data: wasmtest.Module(wasmtest.Section(sectionImport, []byte{0x02})), expectedError: io.ErrUnexpectedEOF},
+		"truncated export":    {data: wasmtest.Module(wasmtest.Section(sectionExport, wasmtest.Vector(wasmtest.Name("memory")))), expectedError: io.ErrUnexpectedEOF},
+		"truncated memory":    {data: wasmtest.Module(wasmtest.Section(sectionMemory, wasmtest.Vector([]byte{0x01, 0x01}))), expectedError: io.ErrUnexpectedEOF},
+		"unknown import kind": {data: wasmtest.Module(wasmtest.Section(sectionImport, wasmtest.Vector(append(append(wasmtest.Name("env"), wasmtest.Name("f")...), 0x09)))), expectedMsg: "unknown import kind 9 for env.f"},
+		"invalid UTF-8 name":  {data: wasmtest.Module(wasmtest.Exports(append(append(wasmtest.LEB(2), 0xff, 0xfe), byte(KindFunction), 0x00))), expectedMsg: "invalid UTF-8 in name"},
+		"integer too long":    {data: wasmtest.Module([]byte{sectionCustom, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80}), expectedMsg: "integer representation too long"},
+		"integer too large":   {data: wasmtest.Module([]byte{sectionCustom, 0x80, 0x80, 0x80, 0x80, 0x10}), expectedMsg: "integer too large"},
+		"truncated name":      {data: wasmtest.Module(wasmtest.Section(sectionCustom, []byte{0x05, 'n'})), expectedError: io.ErrUnexpectedEOF},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(tCase.data)
+			require.Error(t, err)
+			if tCase.expectedError != nil {
+				assert.ErrorIs(t, err, tCase.expectedError)
+			}
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+			}
+		})
+	}
+}
+
+func TestIsWasm(t *testing.T) {
+	assert.True(t, IsWasm(wasmtest.Module()))
+	assert.False(t, IsWasm([]byte{0x00, 0x61, 0x73, 0x6d, 0x0d, 0x00, 0x01, 0x00}))
+	assert.False(t, IsWasm([]byte("\x00asm")))
+	assert.False(t, IsWasm(nil))
+}
+
+func TestHasExport(t *testing.T) {
+	m, err := Parse(wasmtest.Module(wasmtest.Exports(wasmtest.Export("memory", wasmtest.KindMemory, 0), wasmtest.Export("_start", wasmtest.KindFunction, 0))))
+	require.NoError(t, err)
+
+	assert.True(t, m.HasExport("memory", KindMemory))
+	assert.True(t, m.HasExport("_start", KindFunction))
+	assert.False(t, m.HasExport("memory", KindFunction))
+	assert.False(t, m.HasExport("_initialize", KindFunction))
+}
@@ -0,0 +1,415 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/Masterminds/semver/v3"
+	"sigs.k8s.io/yaml"
+)
+
+// Host is a release of a Proxy-Wasm host (like Envoy 1.26), with the ABI versions,
+// host functions and foreign functions it supports
+type Host struct {
+	// Name is the name of the host, like "envoy" or "istio"
+	Name string `json:"name"`
+	// Version is the (minor) version of the release, like "1.26"
+	Version string `json:"version"`
+	// BasedOn is another release with the same support (like "envoy@1.26" for Istio 1.18).
+	// When set, the ABI versions, host functions and foreign functions are taken from it.
+	BasedOn string `json:"basedOn,omitempty"`
+
+	ABIVersions      []ABIVersion `json:"abiVersions,omitempty"`
+	HostFunctions    []string     `json:"hostFunctions,omitempty"`
+	ForeignFunctions []string     `json:"foreignFunctions,omitempty"`
+}
+
+// String returns the host as "name@version"
+func (h *Host) String() string {
+	return h.Name + "@" + h.Version
+}
+
+// Hosts is a table of host releases
+type Hosts []Host
+
+// hostFunctions are the host functions all the known hosts (all the hosts based on
+// proxy-wasm-cpp-host) support, whatever the ABI version of the extension
+var hostFunctions = []string{
+	"proxy_add_header_map_value",
+	"proxy_call_foreign_function",
+	"proxy_clear_route_cache",
+	"proxy_close_stream",
+	"proxy_continue_request",
+	"proxy_continue_response",
+	"proxy_continue_stream",
+	"proxy_define_metric",
+	"proxy_dequeue_shared_queue",
+	"proxy_done",
+	"proxy_enqueue_shared_queue",
+	"proxy_get_buffer_bytes",
+	"proxy_get_buffer_status",
+	"proxy_get_configuration",
+	"proxy_get_current_time_nanoseconds",
+	"proxy_get_header_map_pairs",
+	"proxy_get_header_map_size",
+	"proxy_get_header_map_value",
+	"proxy_get_metric",
+	"proxy_get_property",
+	"proxy_get_shared_data",
+	"proxy_get_status",
+	"proxy_grpc_call",
+	"proxy_grpc_cancel",
+	"proxy_grpc_close",
+	"proxy_grpc_send",
+	"proxy_grpc_stream",
+	"proxy_http_call",
+	"proxy_increment_metric",
+	"proxy_log",
+	"proxy_record_metric",
+	"proxy_register_shared_queue",
+	"proxy_remove_header_map_value",
+	"proxy_replace_header_map_value",
+	"proxy_resolve_shared_queue",
+	"proxy_send_local_response",
+	"proxy_set_buffer_bytes",
+	"proxy_set_effective_context",
+	"proxy_set_header_map_pairs",
+	"proxy_set_property",
+	"proxy_set_shared_data",
+	"proxy_set_tick_period_milliseconds",
+}
+
+// envoyReleases are the changes in the Proxy-Wasm support of the Envoy releases,
+// from the first one with a stable Wasm runtime. Every release supports everything
+// in the previous ones, plus the ABI versions and functions listed.
+var envoyReleases = []Host{
+	{
+		Version:          "1.17",
+		ABIVersions:      []ABIVersion{ABIVersion010, ABIVersion020},
+		HostFunctions:    hostFunctions,
+		ForeignFunctions: []string{"compress", "declare_property", "expr_create", "expr_delete", "expr_evaluate", "uncompress"},
+	},
+	{Version: "1.19", ForeignFunctions: []string{"set_envoy_filter_state"}},
+	{Version: "1.20", ABIVersions: []ABIVersion{ABIVersion021}},
+	{Version: "1.22", HostFunctions: []string{"proxy_get_log_level"}},
+	{Version: "1.24", ForeignFunctions: []string{"clear_route_cache"}},
+	{Version: "1.26"},
+	{Version: "1.28", ForeignFunctions: []string{"verify_signature"}},
+	{Version: "1.30"},
+}
+
+// istioReleases are the Envoy releases used by the Istio releases
+var istioReleases = map[string]string{
+	"1.9":  "1.17",
+	"1.11": "1.19",
+	"1.12": "1.20",
+	"1.14": "1.22",
+	"1.16": "1.24",
+	"1.18": "1.26",
+	"1.20": "1.28",
+	"1.22": "1.30",
+}
+
+// KnownHosts are the host releases known by default. Releases that are not in the
+// table have the support of the previous known release of the same host.
+var KnownHosts = knownHosts()
+
+func knownHosts() Hosts {
+	var res Hosts
+
+	var prev Host
+	for _, r := range envoyReleases {
+		h := Host{
+			Name:             "envoy",
+			Version:          r.Version,
+			ABIVersions:      append(append([]ABIVersion{}, prev.ABIVersions...), r.ABIVersions...),
+			HostFunctions:    sortedUnion(prev.HostFunctions, r.HostFunctions),
+			ForeignFunctions: sortedUnion(prev.ForeignFunctions, r.ForeignFunctions),
+		}
+		res = append(res, h)
+		prev = h
+	}
+
+	for istio, envoy := range istioReleases {
+		res = append(res, Host{Name: "istio", Version: istio, BasedOn: "envoy@" + envoy})
+	}
+
+	res, err := res.resolve()
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// LoadHosts loads a table of host releases from a YAML file (a list of hosts), adding
+// them to the known ones (and replacing the known ones with the same name and version)
+func LoadHosts(filename string) (Hosts, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var loaded Hosts
+	if err := yaml.UnmarshalStrict(data, &loaded); err != nil {
+		return nil, fmt.Errorf("when parsing %s: %w", filename, err)
+	}
+
+	res := Hosts{}
+	for _, h := range KnownHosts {
+		replaced := false
+		for _, l := range loaded {
+			if l.Name == h.Name && l.Version == h.Version {
+				replaced = true
+			}
+		}
+		if !replaced {
+			res = append(res, h)
+		}
+	}
+	res = append(res, loaded...)
+
+	if res, err = res.resolve(); err != nil {
+		return nil, fmt.Errorf("in %s: %w", filename, err)
+	}
+	return res, nil
+}
+
+// resolve checks the releases, copying the support of the releases they are based on,
+// and sorts them by name and version
+func (hosts Hosts) resolve() (Hosts, error) {
+	res := append(Hosts{}, hosts...)
+	for i := range res {
+		if res[i].Name == "" {
+			return nil, fmt.Errorf("no name for host release #%d", i+1)
+		}
+		if _, err := semver.NewVersion(res[i].Version); err != nil {
+			return nil, fmt.Errorf("invalid version %q for %s: %w", res[i].Version, res[i].Name, err)
+		}
+	}
+
+	for i := range res {
+		if res[i].BasedOn == "" {
+			continue
+		}
+		base, err := hosts.exact(res[i].BasedOn)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", res[i].String(), err)
+		}
+		if base.BasedOn != "" {
+			return nil, fmt.Errorf("%s: %s is based on another host too", res[i].String(), base.String())
+		}
+		res[i].ABIVersions = base.ABIVersions
+		res[i].HostFunctions = base.HostFunctions
+		res[i].ForeignFunctions = base.ForeignFunctions
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].Name != res[j].Name {
+			return res[i].Name < res[j].Name
+		}
+		return semver.MustParse(res[i].Version).LessThan(semver.MustParse(res[j].Version))
+	})
+	return res, nil
+}
+
+// exact returns the release with exactly some name and version ("name@version")
+func (hosts Hosts) exact(spec string) (*Host, error) {
+	name, version, _ := strings.Cut(spec, "@")
+	for i := range hosts {
+		if hosts[i].Name == name && hosts[i].Version == version {
+			return &hosts[i], nil
+		}
+	}
+	return nil, fmt.Errorf("unknown host release %s", spec)
+}
+
+// Find returns a host release, as "name@version" (like "envoy@1.26" or "envoy@1.27.1"). When
+// the version is not in the table, it returns the previous known release of the host, with
+// the version requested.
+func (hosts Hosts) Find(spec string) (*Host, error) {
+	name, version, ok := strings.Cut(spec, "@")
+	if !ok || name == "" || version == "" {
+		return nil, fmt.Errorf("invalid host %q (must be like envoy@1.26)", spec)
+	}
+	v, err := semver.NewVersion(version)
+	if err != nil {
+		return nil, fmt.Errorf("invalid version in host %q: %w", spec, err)
+	}
+
+	var res *Host
+	known := false
+	for i := range hosts {
+		if hosts[i].Name != name {
+			continue
+		}
+		known = true
+		if !semver.MustParse(hosts[i].Version).GreaterThan(v) {
+			res = &hosts[i]
+		}
+	}
+	switch {
+	case !known:
+		return nil, fmt.Errorf("unknown host %q (known hosts: %s)", name, strings.Join(hosts.Names(), ", "))
+	case res == nil:
+		return nil, fmt.Errorf("%s is older than all the known releases of %s (no Proxy-Wasm support)", spec, name)
+	}
+
+	h := *res
+	h.Version = version
+	return &h, nil
+}
+
+// Names returns the names of the hosts, sorted
+func (hosts Hosts) Names() []string {
+	var names []string
+	for _, h := range hosts {
+		if len(names) == 0 || names[len(names)-1] != h.Name {
+			names = append(names, h.Name)
+		}
+	}
+	return names
+}
+
+// Minimum returns the oldest release of every host compatible with an extension (by host
+// name, like {"envoy": "1.22"}). Hosts without any compatible release are not included.
+func (hosts Hosts) Minimum(pw *ProxyWasm) map[string]string {
+	res := map[string]string{}
+	for i := range hosts {
+		if _, ok := res[hosts[i].Name]; ok {
+			continue
+		}
+		if hosts[i].Check(pw).Compatible() {
+			res[hosts[i].Name] = hosts[i].Version
+		}
+	}
+	return res
+}
+
+// Compatibility is the result of checking an extension against a host release
+type Compatibility struct {
+	Host string `json:"host"`
+	// UnsupportedABIVersion is the ABI version of the extension, when the host does not support it
+	UnsupportedABIVersion ABIVersion `json:"unsupportedABIVersion,omitempty"`
+	// MissingHostFunctions are the host functions imported by the extension that the host does not have
+	MissingHostFunctions []string `json:"missingHostFunctions,omitempty"`
+	// MissingForeignFunctions are the foreign functions the extension may call that the host does not have
+	MissingForeignFunctions []string `json:"missingForeignFunctions,omitempty"`
+}
+
+// Compatible returns true when the host supports the ABI version and all the host functions
+// of the extension. The foreign functions are not considered, as they are only guessed
+// (see ProxyWasm.ForeignFunctions).
+func (c *Compatibility) Compatible() bool {
+	return c.UnsupportedABIVersion == "" && len(c.MissingHostFunctions) == 0
+}
+
+// Check checks an extension against a host release
+func (h *Host) Check(pw *ProxyWasm) *Compatibility {
+	res := &Compatibility{Host: h.String()}
+
+	found := false
+	for _, v := range h.ABIVersions {
+		if v == pw.ABIVersion {
+			found = true
+		}
+	}
+	if !found {
+		res.UnsupportedABIVersion = pw.ABIVersion
+	}
+
+	res.MissingHostFunctions = missing(pw.HostFunctions, h.HostFunctions)
+	res.MissingForeignFunctions = missing(pw.ForeignFunctions, h.ForeignFunctions)
+	return res
+}
+
+// KnownForeignFunctions returns the foreign functions of all the host releases, sorted
+func (hosts Hosts) KnownForeignFunctions() []string {
+	var res []string
+	for _, h := range hosts {
+		res = sortedUnion(res, h.ForeignFunctions)
+	}
+	return res
+}
+
+// ProxyWasm returns the Proxy-Wasm information of a module, guessing the foreign
+// functions it may call from the foreign functions of these hosts.
+func (hosts Hosts) ProxyWasm(m *Module) (*ProxyWasm, error) {
+	return m.proxyWasm(hosts.KnownForeignFunctions())
+}
+
+// ForeignFunctions returns the known foreign functions (like the ones of Hosts.KnownForeignFunctions)
+// a module may call with proxy_call_foreign_function, in the order of the known ones. As the names
+// of the functions are arguments, they are guessed by looking for them in the data of the module
+// (excluding the occurrences that are part of the name of another known foreign function, like
+// "compress" in "uncompress").
+func (m *Module) ForeignFunctions(known []string) []string {
+	var res []string
+	for _, name := range known {
+		for i := 0; i < len(m.data); {
+			j := bytes.Index(m.data[i:], []byte(name))
+			if j < 0 {
+				break
+			}
+			if !partOfAnother(m.data, i+j, name, known) {
+				res = append(res, name)
+				break
+			}
+			i += j + 1
+		}
+	}
+	return res
+}
+
+// partOfAnother returns true when an occurrence of a name (at some position) is part of another name
+func partOfAnother(data []byte, pos int, name string, names []string) bool {
+	for _, other := range names {
+		if other == name {
+			continue
+		}
+		for k := 0; k+len(name) <= len(other); k++ {
+			if other[k:k+len(name)] != name {
+				continue
+			}
+			start := pos - k
+			if start >= 0 && start+len(other) <= len(data) && string(data[start:start+len(other)]) == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// missing returns the elements of a list that are not in another list
+func missing(list, in []string) []string {
+	var res []string
+	for _, s := range list {
+		found := false
+		for _, t := range in {
+			if s == t {
+				found = true
+				break
+			}
+		}
+		if !found {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+// sortedUnion returns the sorted union of two lists of strings
+func sortedUnion(a, b []string) []string {
+	set := map[string]bool{}
+	for _, s := range append(append([]string{}, a...), b...) {
+		set[s] = true
+	}
+	res := make([]string, 0, len(set))
+	for s := range set {
+		res = append(res, s)
+	}
+	sort.Strings(res)
+	return res
+}
@@ -0,0 +1,181 @@
+package wasm
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+)
+
+func TestHostsFind(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		spec            string
+		expectedVersion string
+		// expectedABI is the last ABI version supported by the release
+		expectedABI ABIVersion
+		expectedMsg string
+	}{
+		"known release":            {spec: "envoy@1.26", expectedVersion: "1.26", expectedABI: ABIVersion021},
+		"patch release":            {spec: "envoy@1.27.1", expectedVersion: "1.27.1", expectedABI: ABIVersion021},
+		"first release":            {spec: "envoy@1.17.0", expectedVersion: "1.17.0", expectedABI: ABIVersion020},
+		"release before 0.2.1":     {spec: "envoy@1.19", expectedVersion: "1.19", expectedABI: ABIVersion020},
+		"newer than all":           {spec: "envoy@2.0", expectedVersion: "2.0", expectedABI: ABIVersion021},
+		"istio":                    {spec: "istio@1.18", expectedVersion: "1.18", expectedABI: ABIVersion021},
+		"istio between releases":   {spec: "istio@1.10", expectedVersion: "1.10", expectedABI: ABIVersion020},
+		"older than all":           {spec: "envoy@1.16", expectedMsg: "envoy@1.16 is older than all the known releases of envoy"},
+		"unknown host":             {spec: "nginx@1.0", expectedMsg: `unknown host "nginx" (known hosts: envoy, istio)`},
+		"no version":               {spec: "envoy", expectedMsg: "invalid host"},
+		"empty version":            {spec: "envoy@", expectedMsg: "invalid host"},
+		"invalid version":          {spec: "envoy@latest", expectedMsg: "invalid version in host"},
+		"no name":                  {spec: "@1.26", expectedMsg: "invalid host"},
+		"prerelease of known host": {spec: "envoy@1.28.0-dev", expectedVersion: "1.28.0-dev", expectedABI: ABIVersion021},
+	} {
+		t.Run(name, func(t *testing.T) {
+			h, err := KnownHosts.Find(tCase.spec)
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expectedVersion, h.Version)
+			assert.Equal(t, tCase.expectedABI, h.ABIVersions[len(h.ABIVersions)-1])
+		})
+	}
+}
+
+func TestHostsMinimum(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		pw       *ProxyWasm
+		expected map[string]string
+	}{
+		"ABI 0.1.0": {
+			pw:       &ProxyWasm{ABIVersion: ABIVersion010, HostFunctions: []string{"proxy_log"}},
+			expected: map[string]string{"envoy": "1.17", "istio": "1.9"},
+		},
+		"ABI 0.2.1": {
+			pw:       &ProxyWasm{ABIVersion: ABIVersion021, HostFunctions: []string{"proxy_log"}},
+			expected: map[string]string{"envoy": "1.20", "istio": "1.12"},
+		},
+		"new host function": {
+			pw:       &ProxyWasm{ABIVersion: ABIVersion020, HostFunctions: []string{"proxy_get_log_level", "proxy_log"}},
+			expected: map[string]string{"envoy": "1.22", "istio": "1.14"},
+		},
+		"foreign functions are not considered": {
+			pw:       &ProxyWasm{ABIVersion: ABIVersion020, HostFunctions: []string{"proxy_call_foreign_function"}, ForeignFunctions: []string{"verify_signature"}},
+			expected: map[string]string{"envoy": "1.17", "istio": "1.9"},
+		},
+		"unknown host function": {
+			pw:       &ProxyWasm{ABIVersion: ABIVersion021, HostFunctions: []string{"proxy_unknown"}},
+			expected: map[string]string{},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, KnownHosts.Minimum(tCase.pw))
+		})
+	}
+}
+
+func TestHostCheck(t *testing.T) {
+	h, err := KnownHosts.Find("envoy@1.20")
+	require.NoError(t, err)
+
+	c := h.Check(&ProxyWasm{
+		ABIVersion:       ABIVersion021,
+		HostFunctions:    []string{"proxy_call_foreign_function", "proxy_get_log_level", "proxy_log"},
+		ForeignFunctions: []string{"compress", "verify_signature"},
+	})
+	assert.Equal(t, &Compatibility{
+		Host:                    "envoy@1.20",
+		MissingHostFunctions:    []string{"proxy_get_log_level"},
+		MissingForeignFunctions: []string{"verify_signature"},
+	}, c)
+	assert.False(t, c.Compatible())
+
+	h, err = KnownHosts.Find("envoy@1.17")
+	require.NoError(t, err)
+	c = h.Check(&ProxyWasm{ABIVersion: ABIVersion021, HostFunctions: []string{"proxy_log"}})
+	assert.Equal(t, ABIVersion021, c.UnsupportedABIVersion)
+	assert.False(t, c.Compatible())
+
+	// missing foreign functions do not make the extension incompatible
+	c = h.Check(&ProxyWasm{ABIVersion: ABIVersion020, ForeignFunctions: []string{"verify_signature"}})
+	assert.True(t, c.Compatible())
+}
+
+func TestLoadHosts(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "hosts.yaml")
+	require.NoError(t, os.WriteFile(filename, []byte(`
+- name: myproxy
+  version: "2.0"
+  basedOn: envoy@1.26
+- name: envoy
+  version: "1.26"
+  abiVersions: ["0.2.1"]
+  hostFunctions: [proxy_log]
+  foreignFunctions: [my_function]
+`), 0o644))
+
+	hosts, err := LoadHosts(filename)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"envoy", "istio", "myproxy"}, hosts.Names())
+
+	// the known releases are replaced, and used by the ones based on them
+	for _, spec := range []string{"envoy@1.26", "myproxy@2.1"} {
+		h, err := hosts.Find(spec)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"proxy_log"}, h.HostFunctions, spec)
+		assert.Equal(t, []string{"my_function"}, h.ForeignFunctions, spec)
+	}
+	assert.Contains(t, hosts.KnownForeignFunctions(), "my_function")
+	assert.NotContains(t, KnownHosts.KnownForeignFunctions(), "my_function")
+
+	for name, content := range map[string]string{
+		"unknown base":     "- {name: myproxy, version: '1.0', basedOn: envoy@0.1}",
+		"base with a base": "- {name: myproxy, version: '1.0', basedOn: istio@1.18}",
+		"invalid version":  "- {name: myproxy, version: latest}",
+		"no name":          "- {version: '1.0'}",
+		"unknown field":    "- {name: myproxy, version: '1.0', functions: [proxy_log]}",
+	} {
+		t.Run(name, func(t *testing.T) {
+			require.NoError(t, os.WriteFile(filename, []byte(content), 0o644))
+			_, err := LoadHosts(filename)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestForeignFunctions(t *testing.T) {
+	data := wasmtest.Section(sectionData, []byte("\x00uncompress\x00set_envoy_filter_state\x00my_function\x00"))
+	m, err := Parse(wasmtest.Module(
+		wasmtest.Imports(wasmtest.ImportFunc("env", "proxy_call_foreign_function", 0)),
+		wasmtest.Exports(wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0)),
+		data,
+	))
+	require.NoError(t, err)
+
+	// "compress" is only part of "uncompress"
+	assert.Equal(t, []string{"set_envoy_filter_state", "uncompress"}, m.ForeignFunctions(KnownHosts.KnownForeignFunctions()))
+	assert.Equal(t, []string{"my_function"}, m.ForeignFunctions([]string{"my_function", "other_function"}))
+	assert.Empty(t, m.ForeignFunctions(nil))
+
+	pw, err := m.ProxyWasm()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"set_envoy_filter_state", "uncompress"}, pw.ForeignFunctions)
+
+	// with the foreign functions of other hosts
+	hosts := append(Hosts{{Name: "myproxy", Version: "1.0", ForeignFunctions: []string{"my_function"}}}, KnownHosts...)
+	pw, err = hosts.ProxyWasm(m)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"my_function", "set_envoy_filter_state", "uncompress"}, pw.ForeignFunctions)
+
+	// the data is only scanned when the module can call foreign functions
+	m, err = Parse(wasmtest.Module(wasmtest.Exports(wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0)), data))
+	require.NoError(t, err)
+	pw, err = hosts.ProxyWasm(m)
+	require.NoError(t, err)
+	assert.Empty(t, pw.ForeignFunctions)
+}
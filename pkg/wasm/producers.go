@@ -0,0 +1,104 @@
+package wasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ProducersSection is the name of the custom section with the tools that produced a module,
+// as in https://github.com/WebAssembly/tool-conventions/blob/main/ProducersSection.md
+const ProducersSection = "producers"
+
+// Producers fields
+const (
+	ProducersLanguage    = "language"
+	ProducersProcessedBy = "processed-by"
+	ProducersSDK         = "sdk"
+)
+
+// Producer is a tool (or language) that produced a module
+type Producer struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+func (p Producer) String() string {
+	if p.Version == "" {
+		return p.Name
+	}
+	return p.Name + " " + p.Version
+}
+
+// Producers returns the contents of the producers section, by field (like "language"
+// or "processed-by"). It returns an empty map when there is no producers section.
+func (m *Module) Producers() (map[string][]Producer, error) {
+	producers := map[string][]Producer{}
+
+	section, ok := m.CustomSection(ProducersSection)
+	if !ok {
+		return producers, nil
+	}
+
+	r := &reader{data: section.Data}
+	count, err := r.u32()
+	if err != nil {
+		return nil, fmt.Errorf("when parsing producers section: %w", err)
+	}
+	for i := uint32(0); i < count; i++ {
+		field, err := r.name()
+		if err != nil {
+			return nil, fmt.Errorf("when parsing producers section: %w", err)
+		}
+		n, err := r.u32()
+		if err != nil {
+			return nil, fmt.Errorf("when parsing producers section: %w", err)
+		}
+		for j := uint32(0); j < n; j++ {
+			var p Producer
+			if p.Name, err = r.name(); err == nil {
+				p.Version, err = r.name()
+			}
+			if err != nil {
+				return nil, fmt.Errorf("when parsing producers section: %w", err)
+			}
+			producers[field] = append(producers[field], p)
+		}
+	}
+
+	return producers, nil
+}
+
+// Toolchain returns the toolchain that produced the module (like "TinyGo 0.30.0"),
+// from the producers section, or an empty string when it is unknown.
+func (m *Module) Toolchain() string {
+	producers, err := m.Producers()
+	if err != nil {
+		return ""
+	}
+
+	if processedBy := producers[ProducersProcessedBy]; len(processedBy) > 0 {
+		// the known compilers are preferred over post-processors (like wasm-opt), and
+		// the language toolchains over the backends they use (like clang in TinyGo)
+		for _, c := range compilers {
+			for _, p := range processedBy {
+				if isCompiler(p.Name, c) {
+					return p.String()
+				}
+			}
+		}
+		return processedBy[0].String()
+	}
+	if languages := producers[ProducersLanguage]; len(languages) > 0 {
+		return languages[0].String()
+	}
+	return ""
+}
+
+// compilers are (prefixes of) the names of known compilers in the producers section,
+// by priority: clang is last, as it is also listed by the toolchains based on LLVM
+var compilers = []string{"TinyGo", "rustc", "Emscripten", "Go", "AssemblyScript", "Zig", "clang"}
+
+// isCompiler returns true when the name of a producer is the name of a compiler.
+func isCompiler(name, compiler string) bool {
+	return strings.HasPrefix(strings.ToLower(name), strings.ToLower(compiler))
+}
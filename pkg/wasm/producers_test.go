@@ -0,0 +1,107 @@
+package wasm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+)
+
+// producersSection builds a producers section, with the producers (as name and version) of every field
+func producersSection(fields map[string][][2]string, order ...string) []byte {
+	var entries [][]byte
+	for _, field := range order {
+		var values [][]byte
+		for _, p := range fields[field] {
+			values = append(values, append(wasmtest.Name(p[0]), wasmtest.Name(p[1])...))
+		}
+		entries = append(entries, append(wasmtest.Name(field), wasmtest.Vector(values...)...))
+	}
+	return wasmtest.Custom(ProducersSection, wasmtest.Vector(entries...))
+}
+
+func TestProducers(t *testing.T) {
+	m, err := Parse(wasmtest.Module(producersSection(map[string][][2]string{
+		ProducersLanguage:    {{"Go", "1.21"}},
+		ProducersProcessedBy: {{"TinyGo", "0.30.0"}, {"wasm-opt", ""}},
+	}, ProducersLanguage, ProducersProcessedBy)))
+	require.NoError(t, err)
+
+	producers, err := m.Producers()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Producer{
+		ProducersLanguage:    {{Name: "Go", Version: "1.21"}},
+		ProducersProcessedBy: {{Name: "TinyGo", Version: "0.30.0"}, {Name: "wasm-opt"}},
+	}, producers)
+
+	// without a producers section
+	m, err = Parse(wasmtest.Module())
+	require.NoError(t, err)
+	producers, err = m.Producers()
+	require.NoError(t, err)
+	assert.Empty(t, producers)
+
+	// with an invalid one
+	m, err = Parse(wasmtest.Module(wasmtest.Custom(ProducersSection, []byte{0x01, 0x05})))
+	require.NoError(t, err)
+	_, err = m.Producers()
+	assert.ErrorContains(t, err, "when parsing producers section")
+}
+
+func TestToolchain(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		languages   [][2]string
+		processedBy [][2]string
+		expected    string
+	}{
+		"no producers": {
+			expected: "",
+		},
+		"TinyGo": {
+			languages:   [][2]string{{"Go", "1.21.0"}},
+			processedBy: [][2]string{{"clang", "15.0.0 (https://github.com/tinygo-org/llvm-project 33c8d2f5e5e0)"}, {"TinyGo", "0.28.1"}},
+			expected:    "TinyGo 0.28.1",
+		},
+		"rustc": {
+			languages:   [][2]string{{"Rust", ""}},
+			processedBy: [][2]string{{"rustc", "1.75.0 (82e1608df 2023-12-21)"}, {"clang", "17.0.6"}},
+			expected:    "rustc 1.75.0 (82e1608df 2023-12-21)",
+		},
+		"Emscripten": {
+			processedBy: [][2]string{{"clang", "18.0.0"}, {"Emscripten", "3.1.50"}},
+			expected:    "Emscripten 3.1.50",
+		},
+		"clang": {
+			languages:   [][2]string{{"C11", ""}},
+			processedBy: [][2]string{{"clang", "17.0.6"}},
+			expected:    "clang 17.0.6",
+		},
+		"post-processor": {
+			processedBy: [][2]string{{"wasm-opt", "116"}, {"TinyGo", "0.30.0"}},
+			expected:    "TinyGo 0.30.0",
+		},
+		"unknown tools": {
+			processedBy: [][2]string{{"mycompiler", "1.0"}, {"wasm-opt", "116"}},
+			expected:    "mycompiler 1.0",
+		},
+		"only the language": {
+			languages: [][2]string{{"AssemblyScript", "0.27"}},
+			expected:  "AssemblyScript 0.27",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var sections [][]byte
+			if tCase.languages != nil || tCase.processedBy != nil {
+				sections = append(sections, producersSection(map[string][][2]string{
+					ProducersLanguage:    tCase.languages,
+					ProducersProcessedBy: tCase.processedBy,
+				}, ProducersLanguage, ProducersProcessedBy))
+			}
+			m, err := Parse(wasmtest.Module(sections...))
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, m.Toolchain())
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package wasm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// DefaultStripSections are the custom sections removed by default: debugging
+// information, names and the tools that produced the module, as they are not
+// needed for running the module
+var DefaultStripSections = []string{
+	"name",
+	"producers",
+	"sourceMappingURL",
+	"external_debug_info",
+	".debug_*",
+}
+
+// SectionMatcher returns a function that matches the names of custom sections
+// against some patterns (names, or prefixes ending in "*"), unless they are in some
+// names to keep
+func SectionMatcher(patterns, keep []string) func(string) bool {
+	return func(name string) bool {
+		for _, k := range keep {
+			if name == k {
+				return false
+			}
+		}
+		for _, p := range patterns {
+			if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(name, prefix) {
+				return true
+			}
+			if name == p {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// StripResult is the result of stripping custom sections from a module
+type StripResult struct {
+	// Data is the module without the custom sections
+	Data []byte
+	// Removed are the names of the custom sections removed, in order
+	Removed []string
+	// OriginalSize is the size of the module before stripping it
+	OriginalSize int
+}
+
+// Saved returns the number of bytes saved
+func (r *StripResult) Saved() int {
+	return r.OriginalSize - len(r.Data)
+}
+
+func (r *StripResult) String() string {
+	if len(r.Removed) == 0 {
+		return fmt.Sprintf("no custom sections removed (%d bytes)", r.OriginalSize)
+	}
+	return fmt.Sprintf("removed custom sections %s: %d -> %d bytes (saved %d bytes, %.1f%%)",
+		strings.Join(r.Removed, ", "), r.OriginalSize, len(r.Data), r.Saved(),
+		100*float64(r.Saved())/float64(r.OriginalSize))
+}
+
+// Strip rewrites a module without the custom sections matched by a function.
+// All the other sections are copied unchanged.
+func Strip(data []byte, strip func(name string) bool) (*StripResult, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], Magic) {
+		return nil, ErrNotWasm
+	}
+
+	result := &StripResult{OriginalSize: len(data)}
+	out := bytes.NewBuffer(make([]byte, 0, len(data)))
+	out.Write(data[:8])
+
+	r := &reader{data: data, pos: 8}
+	for !r.eof() {
+		start := r.pos
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, fmt.Errorf("when reading size of section %d: %w", id, err)
+		}
+		content, err := r.bytes(int(size))
+		if err != nil {
+			return nil, fmt.Errorf("when reading section %d: %w", id, err)
+		}
+
+		if id == sectionCustom {
+			name, err := (&reader{data: content}).name()
+			if err != nil {
+				return nil, fmt.Errorf("when reading custom section name at offset %d: %w", start, err)
+			}
+			if strip(name) {
+				result.Removed = append(result.Removed, name)
+				continue
+			}
+		}
+
+		out.Write(data[start:r.pos])
+	}
+
+	result.Data = out.Bytes()
+	return result, nil
+}
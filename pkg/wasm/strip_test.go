@@ -0,0 +1,124 @@
+package wasm
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+)
+
+func TestSectionMatcher(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		patterns []string
+		keep     []string
+		section  string
+		expected bool
+	}{
+		"name":                  {patterns: DefaultStripSections, section: "name", expected: true},
+		"producers":             {patterns: DefaultStripSections, section: "producers", expected: true},
+		"debug section":         {patterns: DefaultStripSections, section: ".debug_info", expected: true},
+		"other section":         {patterns: DefaultStripSections, section: "target_features", expected: false},
+		"prefix of a name":      {patterns: DefaultStripSections, section: "names", expected: false},
+		"kept":                  {patterns: DefaultStripSections, keep: []string{"producers"}, section: "producers", expected: false},
+		"kept with prefix":      {patterns: DefaultStripSections, keep: []string{".debug_line"}, section: ".debug_line", expected: false},
+		"other kept":            {patterns: DefaultStripSections, keep: []string{".debug_line"}, section: ".debug_info", expected: true},
+		"everything":            {patterns: []string{"*"}, section: "target_features", expected: true},
+		"no patterns":           {section: "name", expected: false},
+		"wildcard not a suffix": {patterns: []string{"*_info"}, section: "external_debug_info", expected: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tCase.expected, SectionMatcher(tCase.patterns, tCase.keep)(tCase.section))
+		})
+	}
+}
+
+func TestStrip(t *testing.T) {
+	imports := wasmtest.Imports(wasmtest.ImportFunc("env", "proxy_log", 0))
+	exports := wasmtest.Exports(wasmtest.Export("proxy_abi_version_0_2_1", wasmtest.KindFunction, 0))
+	names := wasmtest.Custom("name", []byte{0x01, 0x02, 0x03})
+	producers := producersSection(map[string][][2]string{ProducersProcessedBy: {{"TinyGo", "0.30.0"}}}, ProducersProcessedBy)
+	debug := wasmtest.Custom(".debug_info", make([]byte, 100))
+	features := wasmtest.Custom("target_features", []byte{0x00})
+
+	for name, tCase := range map[string]struct {
+		data            []byte
+		keep            []string
+		expected        []byte
+		expectedRemoved []string
+	}{
+		"default sections": {
+			data:            wasmtest.Module(names, imports, producers, exports, debug, features),
+			expected:        wasmtest.Module(imports, exports, features),
+			expectedRemoved: []string{"name", "producers", ".debug_info"},
+		},
+		"kept sections": {
+			data:            wasmtest.Module(imports, exports, producers, debug),
+			keep:            []string{"producers"},
+			expected:        wasmtest.Module(imports, exports, producers),
+			expectedRemoved: []string{".debug_info"},
+		},
+		"nothing to remove": {
+			data:     wasmtest.Module(imports, exports, features),
+			expected: wasmtest.Module(imports, exports, features),
+		},
+		"empty module": {
+			data:     wasmtest.Module(),
+			expected: wasmtest.Module(),
+		},
+		"several sections with the same name": {
+			data:            wasmtest.Module(names, exports, names),
+			expected:        wasmtest.Module(exports),
+			expectedRemoved: []string{"name", "name"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			result, err := Strip(tCase.data, SectionMatcher(DefaultStripSections, tCase.keep))
+			require.NoError(t, err)
+			assert.Equal(t, tCase.expected, result.Data)
+			assert.Equal(t, tCase.expectedRemoved, result.Removed)
+			assert.Equal(t, len(tCase.data), result.OriginalSize)
+			assert.Equal(t, len(tCase.data)-len(tCase.expected), result.Saved())
+
+			// the result is still a valid module, with the same imports and exports
+			original, err := Parse(tCase.data)
+			require.NoError(t, err)
+			stripped, err := Parse(result.Data)
+			require.NoError(t, err)
+			assert.Equal(t, original.Imports, stripped.Imports)
+			assert.Equal(t, original.Exports, stripped.Exports)
+		})
+	}
+}
+
+func TestStripErrors(t *testing.T) {
+	for name, tCase := range map[string]struct {
+		data          []byte
+		expectedError error
+		expectedMsg   string
+	}{
+		"not Wasm":          {data: []byte("(module)"), expectedError: ErrNotWasm},
+		"truncated section": {data: wasmtest.Module(append([]byte{sectionCustom}, wasmtest.LEB(10)...)), expectedError: io.ErrUnexpectedEOF},
+		"truncated size":    {data: wasmtest.Module([]byte{sectionExport, 0x80}), expectedError: io.ErrUnexpectedEOF},
+		"invalid name":      {data: wasmtest.Module(wasmtest.Section(sectionCustom, []byte{0x05, 'n'})), expectedMsg: "when reading custom section name at offset 8"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Strip(tCase.data, SectionMatcher(DefaultStripSections, nil))
+			require.Error(t, err)
+			if tCase.expectedError != nil {
+				assert.ErrorIs(t, err, tCase.expectedError)
+			}
+			if tCase.expectedMsg != "" {
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+			}
+		})
+	}
+}
+
+func TestStripResultString(t *testing.T) {
+	assert.Equal(t, "no custom sections removed (100 bytes)", (&StripResult{Data: make([]byte, 100), OriginalSize: 100}).String())
+	assert.Equal(t, "removed custom sections name, producers: 200 -> 150 bytes (saved 50 bytes, 25.0%)",
+		(&StripResult{Data: make([]byte, 150), Removed: []string{"name", "producers"}, OriginalSize: 200}).String())
+}
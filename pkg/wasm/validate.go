@@ -0,0 +1,57 @@
+package wasm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidProxyWasm is returned when a binary is not a valid Proxy-Wasm extension
+var ErrInvalidProxyWasm = errors.New("invalid Proxy-Wasm extension")
+
+// MemoryExport is the name of the memory every Proxy-Wasm module must export
+const MemoryExport = "memory"
+
+// requiredCallbacks are the callbacks every Proxy-Wasm module must export, as alternatives
+// (in the 0.1.0 ABI, the memory could be allocated with "malloc")
+var requiredCallbacks = [][]string{
+	{"proxy_on_memory_allocate", "malloc"},
+	{"proxy_on_context_create"},
+}
+
+// ValidateProxyWasm checks that some data is a Proxy-Wasm extension: a Wasm binary
+// that declares a known Proxy-Wasm ABI version and exports the memory and the
+// callbacks all the hosts need. It returns the parsed module on success.
+func ValidateProxyWasm(data []byte) (*Module, *ProxyWasm, error) {
+	m, err := Parse(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidProxyWasm, err)
+	}
+
+	pw, err := m.ProxyWasm()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrInvalidProxyWasm, err)
+	}
+
+	var missing []string
+	if !m.HasExport(MemoryExport, KindMemory) {
+		missing = append(missing, MemoryExport)
+	}
+	for _, alternatives := range requiredCallbacks {
+		found := false
+		for _, name := range alternatives {
+			if m.HasExport(name, KindFunction) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, alternatives[0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, fmt.Errorf("%w: missing required exports: %s", ErrInvalidProxyWasm, strings.Join(missing, ", "))
+	}
+
+	return m, pw, nil
+}
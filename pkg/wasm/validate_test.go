@@ -0,0 +1,88 @@
+package wasm
+
+import (
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/inercia/proxy-wasm-oci/internal/wasmtest"
+)
+
+func TestValidateProxyWasm(t *testing.T) {
+	var gzipped bytes.Buffer
+	gw := gzip.NewWriter(&gzipped)
+	_, err := gw.Write(wasmtest.ProxyWasm())
+	require.NoError(t, err)
+	require.NoError(t, gw.Close())
+
+	exports := func(names ...string) []byte {
+		var entries [][]byte
+		for _, name := range names {
+			kind := wasmtest.KindFunction
+			if name == MemoryExport {
+				kind = wasmtest.KindMemory
+			}
+			entries = append(entries, wasmtest.Export(name, kind, 0))
+		}
+		return wasmtest.Module(wasmtest.Exports(entries...))
+	}
+
+	for name, tCase := range map[string]struct {
+		data        []byte
+		expectedMsg string
+	}{
+		"minimal module": {
+			data: wasmtest.ProxyWasm(),
+		},
+		"malloc in the 0.1.0 ABI": {
+			data: exports("proxy_abi_version_0_1_0", "memory", "malloc", "proxy_on_context_create"),
+		},
+		"gzip": {
+			data:        gzipped.Bytes(),
+			expectedMsg: "invalid Proxy-Wasm extension: not a Wasm binary",
+		},
+		"bad magic": {
+			data:        []byte{0x00, 0x61, 0x73, 0x00, 0x01, 0x00, 0x00, 0x00},
+			expectedMsg: "invalid Proxy-Wasm extension",
+		},
+		"bad version": {
+			data:        []byte{0x00, 0x61, 0x73, 0x6d, 0x02, 0x00, 0x00, 0x00},
+			expectedMsg: "invalid Proxy-Wasm extension",
+		},
+		"no ABI version": {
+			data:        exports("memory", "proxy_on_memory_allocate", "proxy_on_context_create"),
+			expectedMsg: "invalid Proxy-Wasm extension",
+		},
+		"no memory": {
+			data:        exports("proxy_abi_version_0_2_1", "proxy_on_memory_allocate", "proxy_on_context_create"),
+			expectedMsg: "missing required exports: memory",
+		},
+		"no allocation": {
+			data:        exports("proxy_abi_version_0_2_1", "memory", "proxy_on_context_create"),
+			expectedMsg: "missing required exports: proxy_on_memory_allocate",
+		},
+		"no context creation": {
+			data:        exports("proxy_abi_version_0_2_1", "memory", "proxy_on_memory_allocate"),
+			expectedMsg: "missing required exports: proxy_on_context_create",
+		},
+		"nothing": {
+			data:        exports("proxy_abi_version_0_2_1"),
+			expectedMsg: "missing required exports: memory, proxy_on_memory_allocate, proxy_on_context_create",
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			m, pw, err := ValidateProxyWasm(tCase.data)
+			if tCase.expectedMsg != "" {
+				assert.ErrorIs(t, err, ErrInvalidProxyWasm)
+				assert.ErrorContains(t, err, tCase.expectedMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.NotNil(t, m)
+			assert.NotNil(t, pw)
+		})
+	}
+}